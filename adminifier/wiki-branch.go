@@ -1,89 +1,131 @@
 package adminifier
 
-// working with multiple branches is disabled until go-git supports it officially
-// see: https://github.com/cooper/quiki/issues/156
-
-// func handleSwitchBranch(wr *wikiRequest) {
-// 	branchName := strings.TrimPrefix(wr.r.URL.Path, wr.wikiRoot+"func/switch-branch/")
-// 	if branchName == "" {
-// 		wr.err = errors.New("no branch selected")
-// 		return
-// 	}
-
-// 	// bad branch name
-// 	if !wiki.ValidBranchName(branchName) {
-// 		wr.err = errors.New("invalid branch name: " + branchName)
-// 		return
-// 	}
-
-// 	// fetch the branch
-// 	_, wr.err = wr.wi.Branch(branchName)
-// 	if wr.err != nil {
-// 		return
-// 	}
-
-// 	// set branch
-// 	sessMgr.Put(wr.r.Context(), "branch", branchName)
-
-// 	// TODO: when this request is submitted by JS, the UI can just reload
-// 	// the current frame so the user stays on the same page, just in new branch
-
-// 	// redirect back to dashboard
-// 	http.Redirect(wr.w, wr.r, wr.wikiRoot+"dashboard", http.StatusTemporaryRedirect)
-// }
-
-// func handleCreateBranch(wr *wikiRequest) {
-
-// 	// TODO: need a different version of parsePost that returns JSON errors
-// 	if !parsePost(wr.w, wr.r, "branch") {
-// 		return
-// 	}
-
-// 	// bad branch name
-// 	branchName := wr.r.Form.Get("branch")
-// 	if !wiki.ValidBranchName(branchName) {
-// 		wr.err = errors.New("invalid branch name: " + branchName)
-// 		return
-// 	}
-
-// 	// create or switch branches
-// 	_, err := wr.wi.NewBranch(branchName)
-// 	if err != nil {
-// 		wr.err = err
-// 		return
-// 	}
-// 	sessMgr.Put(wr.r.Context(), "branch", branchName)
-
-// 	// redirect back to dashboard
-// 	http.Redirect(wr.w, wr.r, wr.wikiRoot+"dashboard", http.StatusTemporaryRedirect)
-// }
-
-// // possibly switch wiki branches
-// func switchUserWiki(wr *wikiRequest, wi *webserver.WikiInfo) {
-// 	userWiki := wi
-// 	branchName := sessMgr.GetString(wr.r.Context(), "branch")
-// 	if branchName != "" {
-// 		branchWiki, err := wi.Branch(branchName)
-// 		if err != nil {
-// 			wr.err = err
-// 			return
-// 		}
-// 		userWiki = wi.Copy(branchWiki)
-// 	}
-// 	wr.wi = userWiki
-// }
-
-// func handleSwitchBranchFrame(wr *wikiRequest) {
-// 	branches, err := wr.wi.BranchNames()
-// 	if err != nil {
-// 		wr.err = err
-// 		return
-// 	}
-// 	wr.dot = struct {
-// 		Branches []string
-// 		wikiTemplate
-// 	}{
-// 		Branches:     branches,
-// 		wikiTemplate: getGenericTemplate(wr),
-// 	}
-// }
+import (
+	"net/http"
+	"strings"
+
+	"github.com/cooper/quiki/webserver"
+	"github.com/cooper/quiki/wiki"
+	"github.com/go-git/go-git/v5"
+	"github.com/pkg/errors"
+)
+
+func handleSwitchBranch(wr *wikiRequest) {
+	branchName := strings.TrimPrefix(wr.r.URL.Path, wr.wikiRoot+"func/switch-branch/")
+	if branchName == "" {
+		wr.err = errors.New("no branch selected")
+		return
+	}
+
+	// bad branch name
+	if branchName != "master" && !wiki.ValidBranchName(branchName) {
+		wr.err = errors.New("invalid branch name: " + branchName)
+		return
+	}
+
+	// fetch the branch
+	_, wr.err = wr.wi.Branch(branchName)
+	if wr.err != nil {
+		return
+	}
+
+	// set branch
+	sessMgr.Put(wr.r.Context(), "branch", branchName)
+
+	// TODO: when this request is submitted by JS, the UI can just reload
+	// the current frame so the user stays on the same page, just in new branch
+
+	// redirect back to dashboard
+	http.Redirect(wr.w, wr.r, wr.wikiRoot+"dashboard", http.StatusTemporaryRedirect)
+}
+
+func handleCreateBranch(wr *wikiRequest) {
+
+	// TODO: need a different version of parsePost that returns JSON errors
+	if !parsePost(wr.w, wr.r, "branch") {
+		return
+	}
+
+	// bad branch name
+	branchName := wr.r.Form.Get("branch")
+	if !wiki.ValidBranchName(branchName) {
+		wr.err = errors.New("invalid branch name: " + branchName)
+		return
+	}
+
+	// create or switch branches
+	_, err := wr.wi.NewBranch(branchName)
+	if err != nil {
+		wr.err = err
+		return
+	}
+	sessMgr.Put(wr.r.Context(), "branch", branchName)
+
+	// redirect back to dashboard
+	http.Redirect(wr.w, wr.r, wr.wikiRoot+"dashboard", http.StatusSeeOther)
+}
+
+func handleMergeBranch(wr *wikiRequest) {
+	if !parsePost(wr.w, wr.r, "branch") {
+		return
+	}
+
+	// merge into master.
+	// wr.wi may be the branch wiki, so use the master one
+	branchName := wr.r.Form.Get("branch")
+	master, ok := webserver.Wikis[wr.shortcode]
+	if !ok {
+		wr.err = errors.New("wiki not found: " + wr.shortcode)
+		return
+	}
+	wr.err = master.MergeBranch(branchName, getCommitOpts(wr, wr.r.Form.Get("message")))
+	if wr.err != nil {
+		return
+	}
+
+	// switch back to master
+	sessMgr.Put(wr.r.Context(), "branch", "master")
+
+	// redirect back to dashboard
+	http.Redirect(wr.w, wr.r, wr.wikiRoot+"dashboard", http.StatusSeeOther)
+}
+
+// possibly switch wiki branches
+func switchUserWiki(wr *wikiRequest, wi *webserver.WikiInfo) {
+	userWiki := wi
+	branchName := sessMgr.GetString(wr.r.Context(), "branch")
+	if branchName != "" && branchName != "master" {
+		branchWiki, err := wi.Branch(branchName)
+
+		// the branch was deleted; fall back to master
+		if errors.Is(err, git.ErrBranchNotFound) {
+			sessMgr.Put(wr.r.Context(), "branch", "master")
+			wr.wi = wi
+			return
+		}
+
+		if err != nil {
+			wr.err = err
+			return
+		}
+		userWiki = wi.Copy(branchWiki)
+	}
+	wr.wi = userWiki
+}
+
+func handleSwitchBranchFrame(wr *wikiRequest) {
+	branches, err := wr.wi.BranchNames()
+	if err != nil {
+		wr.err = err
+		return
+	}
+	wr.dot = struct {
+		Branches []string
+		MainPage string
+		wikiTemplate
+	}{
+		Branches:     branches,
+		MainPage:     wr.wi.Opt.MainPage,
+		wikiTemplate: getGenericTemplate(wr),
+	}
+}
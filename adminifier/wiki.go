@@ -21,7 +21,7 @@ import (
 )
 
 var wikiFrameHandlers = map[string]func(*wikiRequest){
-	// "switch-branch": handleSwitchBranchFrame,
+	"switch-branch": handleSwitchBranchFrame,
 	"dashboard":     handleDashboardFrame,
 	"pages":         handlePagesFrame,
 	"pages/":        handlePagesFrame,
@@ -39,14 +39,22 @@ var wikiFrameHandlers = map[string]func(*wikiRequest){
 }
 
 var wikiFuncHandlers = map[string]func(*wikiRequest){
-	// "switch-branch/":      handleSwitchBranch,
-	// "create-branch":       handleCreateBranch,
+	"switch-branch/":      handleSwitchBranch,
+	"create-branch":       handleCreateBranch,
+	"merge-branch":        handleMergeBranch,
 	"write-page":          handleWritePage,
 	"write-model":         handleWriteModel,
 	"write-config":        handleWriteWikiConfig,
 	"image/":              handleImage,
 	"page-revisions":      handlePageRevisions,
 	"page-diff":           handlePageDiff,
+	"page-source":         handlePageSource,
+	"format-source":       handleFormatSource,
+	"revert-page":         handleRevertPage,
+	"page-backlinks":      handlePageBacklinks,
+	"move-page":           handleMovePage,
+	"move-model":          handleMoveModel,
+	"move-image":          handleMoveImage,
 	"create-page":         handleCreatePage,
 	"create-model":        handleCreateModel,
 	"create-page-folder":  handleCreatePageFolder,
@@ -179,13 +187,12 @@ func setupWikiHandlers(shortcode string, wi *webserver.WikiInfo) error {
 			}
 			dot = wr
 
-			wr.wi = wi
-			// DISABLED: possibly switch wikis
-			// switchUserWiki(wr, wi)
-			// if wr.err != nil {
-			// 	// FIXME: don't panic
-			// 	panic(wr.err)
-			// }
+			// possibly switch wikis
+			switchUserWiki(wr, wi)
+			if wr.err != nil {
+				http.Error(w, wr.err.Error(), http.StatusInternalServerError)
+				return
+			}
 
 			// call handler
 			handler(wr)
@@ -251,13 +258,12 @@ func setupWikiHandlers(shortcode string, wi *webserver.WikiInfo) error {
 				r:         r,
 			}
 
-			wr.wi = wi
-			// DISABLED: possibly switch wikis
-			// switchUserWiki(wr, wi)
-			// if wr.err != nil {
-			// 	// FIXME: don't panic
-			// 	panic(wr.err)
-			// }
+			// possibly switch wikis
+			switchUserWiki(wr, wi)
+			if wr.err != nil {
+				http.Error(w, wr.err.Error(), http.StatusInternalServerError)
+				return
+			}
 
 			// call handler
 			handler(wr)
@@ -324,17 +330,36 @@ func handleDashboardFrame(wr *wikiRequest) {
 		}
 	}
 
+	// recent changes, optionally by one author
+	author := wr.r.URL.Query().Get("author")
+	changes, _ := wr.wi.RecentChangesBy(author, dashboardChangesLimit, time.Time{})
+	for _, group := range changes {
+		for i, change := range group.Changes {
+			group.Changes[i].Message = strings.TrimSpace(change.Message)
+		}
+	}
+	authors, _ := wr.wi.ChangeAuthors()
+
 	wr.dot = struct {
 		Logs     string
 		Errors   []wikifier.PageInfo
 		Warnings []wikifier.PageInfo
+		Changes  []wiki.ChangeGroup
+		Authors  []string
+		Author   string
 	}{
 		Logs:     string(logs),
 		Errors:   errors,
 		Warnings: warnings,
+		Changes:  changes,
+		Authors:  authors,
+		Author:   author,
 	}
 }
 
+// number of changes shown on the dashboard
+const dashboardChangesLimit = 50
+
 var sorters map[string]wiki.SortFunc = map[string]wiki.SortFunc{
 	"t": wiki.SortTitle,
 	"a": wiki.SortAuthor,
@@ -535,6 +560,13 @@ func handleWikiHelpFrame(wr *wikiRequest) {
 func handleEditor(wr *wikiRequest, path, file, title string, o editorOpts) {
 	wr.tmplName = "frame-editor.tpl"
 
+	// the revision the content is based on. this must be fetched before the
+	// content, so that edits made in between can be merged when saving
+	revision, err := wr.wi.GetLatestCommitHash()
+	if err != nil {
+		log.Printf("error getting latest commit hash: %v", err)
+	}
+
 	// call DisplayFile to get the content
 	var fileRes wiki.DisplayFile
 	switch r := wr.wi.DisplayFile(path).(type) {
@@ -550,11 +582,12 @@ func handleEditor(wr *wikiRequest, path, file, title string, o editorOpts) {
 
 	// json stuff
 	jsonData, err := json.Marshal(struct {
-		Page     bool `json:"page"`
-		Model    bool `json:"model"`
-		Config   bool `json:"config"`
-		Category bool `json:"category"`
-		Info     any  `json:"info,omitempty"` // PageInfo or ModelInfo
+		Page     bool   `json:"page"`
+		Model    bool   `json:"model"`
+		Config   bool   `json:"config"`
+		Category bool   `json:"category"`
+		Info     any    `json:"info,omitempty"`     // PageInfo or ModelInfo
+		Revision string `json:"revision,omitempty"` // latest commit hash
 		wiki.DisplayFile
 	}{
 		Page:        o.page,
@@ -562,6 +595,7 @@ func handleEditor(wr *wikiRequest, path, file, title string, o editorOpts) {
 		Config:      o.config,
 		Category:    o.cat,
 		Info:        o.info,
+		Revision:    revision,
 		DisplayFile: fileRes,
 	})
 	if err != nil {
@@ -604,10 +638,15 @@ func handleWritePage(wr *wikiRequest) {
 
 	pageName, content, message := wr.r.Form.Get("name"), wr.r.Form.Get("content"), wr.r.Form.Get("message")
 
-	// write the page
+	// write the page, merging changes made since the base revision
+	commit := getCommitOpts(wr, message)
+	commit.Base = wr.r.Form.Get("base")
 	res := handleWriteFile(wr, func() error {
-		return wr.wi.WritePage(pageName, []byte(content), true, getCommitOpts(wr, message))
+		return wr.wi.WritePage(pageName, []byte(content), true, commit)
 	})
+	if commit.Base != "" {
+		addMergedContent(res, wr.wi.PathForPage(pageName), content)
+	}
 
 	// display the page
 	var warnings []wikifier.Warning
@@ -635,9 +674,14 @@ func handleWriteModel(wr *wikiRequest) {
 		return
 	}
 	modelName, content, message := wr.r.Form.Get("name"), wr.r.Form.Get("content"), wr.r.Form.Get("message")
+	commit := getCommitOpts(wr, message)
+	commit.Base = wr.r.Form.Get("base")
 	res := handleWriteFile(wr, func() error {
-		return wr.wi.WriteModel(modelName, []byte(content), true, getCommitOpts(wr, message))
+		return wr.wi.WriteModel(modelName, []byte(content), true, commit)
 	})
+	if commit.Base != "" {
+		addMergedContent(res, wr.wi.PathForModel(modelName), content)
+	}
 	json.NewEncoder(wr.w).Encode(res)
 }
 
@@ -666,10 +710,18 @@ func handleWriteWikiConfig(wr *wikiRequest) {
 
 func handleWriteFile(wr *wikiRequest, writeFunc func() error) map[string]any {
 	if err := writeFunc(); err != nil {
-		return map[string]any{
+		res := map[string]any{
 			"success": false,
 			"error":   err.Error(),
 		}
+
+		// changes conflict with others made since the editor loaded
+		var conflict *wiki.MergeConflictError
+		if errors.As(err, &conflict) {
+			res["conflict"] = conflict
+		}
+
+		return res
 	}
 
 	// fetch latest commit hash
@@ -684,6 +736,58 @@ func handleWriteFile(wr *wikiRequest, writeFunc func() error) map[string]any {
 	}
 }
 
+// addMergedContent adds the file content to a successful write response if
+// it differs from what was submitted, which happens when changes were merged
+func addMergedContent(res map[string]any, path, submitted string) {
+	if res["success"] != true {
+		return
+	}
+	if content, err := os.ReadFile(path); err == nil && string(content) != submitted {
+		res["content"] = string(content)
+		res["merged"] = true
+	}
+}
+
+func handleMovePage(wr *wikiRequest) {
+	handleMove(wr, func(name, newName, message string) (string, error) {
+		move := wr.wi.MovePage
+		if wr.r.Form.Get("redirect") != "" {
+			move = wr.wi.MovePageRedirect
+		}
+		if err := move(name, newName, getCommitOpts(wr, message)); err != nil {
+			return "", err
+		}
+		return wr.wi.FindPage(newName).Name(), nil
+	})
+}
+
+func handleMoveModel(wr *wikiRequest) {
+	handleMove(wr, func(name, newName, message string) (string, error) {
+		return wikifier.ModelName(newName), wr.wi.MoveModel(name, newName, getCommitOpts(wr, message))
+	})
+}
+
+func handleMoveImage(wr *wikiRequest) {
+	handleMove(wr, func(name, newName, message string) (string, error) {
+		return newName, wr.wi.MoveImage(name, newName, getCommitOpts(wr, message))
+	})
+}
+
+func handleMove(wr *wikiRequest, moveFunc func(name, newName, message string) (string, error)) {
+	if !parsePost(wr.w, wr.r, "name", "newName") {
+		return
+	}
+
+	name, newName, message := wr.r.Form.Get("name"), wr.r.Form.Get("newName"), wr.r.Form.Get("message")
+	var movedName string
+	res := handleWriteFile(wr, func() (err error) {
+		movedName, err = moveFunc(name, newName, message)
+		return
+	})
+	res["name"] = movedName
+	json.NewEncoder(wr.w).Encode(res)
+}
+
 func handleImage(wr *wikiRequest) {
 	imageName := strings.TrimPrefix(wr.r.URL.Path, wr.wikiRoot+"func/image/")
 	si := wiki.SizedImageFromName(imageName)
@@ -762,6 +866,101 @@ func handlePageDiff(wr *wikiRequest) {
 	})
 }
 
+func handlePageSource(wr *wikiRequest) {
+	if !parsePost(wr.w, wr.r, "page", "commit") {
+		return
+	}
+
+	pageName, commit := wr.r.Form.Get("page"), wr.r.Form.Get("commit")
+
+	var content []byte
+	var err error
+
+	if wr.r.URL.Query().Has("model") {
+		content, err = wr.wi.ModelAtRevision(pageName, commit)
+	} else {
+		content, err = wr.wi.PageAtRevision(pageName, commit)
+	}
+
+	if err != nil {
+		wr.err = err
+		return
+	}
+
+	jsonWriter := json.NewEncoder(wr.w)
+	wr.err = jsonWriter.Encode(map[string]any{
+		"success": true,
+		"content": string(content),
+	})
+}
+
+func handleFormatSource(wr *wikiRequest) {
+	if !parsePost(wr.w, wr.r, "content") {
+		return
+	}
+
+	// a parse error is reported to the editor rather than failing
+	content, err := wikifier.FormatSource([]byte(wr.r.Form.Get("content")))
+	if err != nil {
+		json.NewEncoder(wr.w).Encode(map[string]any{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	jsonWriter := json.NewEncoder(wr.w)
+	wr.err = jsonWriter.Encode(map[string]any{
+		"success": true,
+		"content": string(content),
+	})
+}
+
+func handleRevertPage(wr *wikiRequest) {
+	if !parsePost(wr.w, wr.r, "page", "commit") {
+		return
+	}
+
+	pageName, commit, message := wr.r.Form.Get("page"), wr.r.Form.Get("commit"), wr.r.Form.Get("message")
+	isModel := wr.r.URL.Query().Has("model")
+
+	// write the old content as a new revision
+	res := handleWriteFile(wr, func() error {
+		if isModel {
+			return wr.wi.RevertModel(pageName, commit, getCommitOpts(wr, message))
+		}
+		return wr.wi.RevertPage(pageName, commit, getCommitOpts(wr, message))
+	})
+
+	// send back the restored content for the editor
+	if res["success"] == true {
+		var content []byte
+		var err error
+		if isModel {
+			content, err = wr.wi.ModelAtRevision(pageName, commit)
+		} else {
+			content, err = wr.wi.PageAtRevision(pageName, commit)
+		}
+		if err == nil {
+			res["content"] = string(content)
+		}
+	}
+
+	json.NewEncoder(wr.w).Encode(res)
+}
+
+func handlePageBacklinks(wr *wikiRequest) {
+	if !parsePost(wr.w, wr.r, "page") {
+		return
+	}
+
+	jsonWriter := json.NewEncoder(wr.w)
+	wr.err = jsonWriter.Encode(map[string]any{
+		"success":   true,
+		"backlinks": wr.wi.Backlinks(wr.r.Form.Get("page")),
+	})
+}
+
 func handleCreatePage(wr *wikiRequest) {
 	handleCreate("page", wr, func(dir, title string) (string, error) {
 		return wr.wi.CreatePage(dir, title, nil, getCommitOpts(wr, "Create page: "+title))
@@ -19,6 +19,7 @@ type Config struct {
 	WikiPath    string
 	ForceGen    bool
 	JSONOutput  bool
+	ToMarkdown  bool
 	Reload      bool
 	QuikiDir    string
 	// server options - only used in full mode
@@ -26,6 +27,7 @@ type Config struct {
 	Port   string
 	Host   string
 	Config string
+	Export string // static site output directory
 }
 
 // Parser interface for different cli modes
@@ -56,22 +58,26 @@ func InitQuikiDir(c *Config) {
 }
 
 // RunInteractiveMode reads from stdin and processes a page
-func RunInteractiveMode(jsonOutput bool) {
+func RunInteractiveMode(c *Config) {
 	input, err := io.ReadAll(os.Stdin)
 	if err != nil {
 		log.Fatal(err)
 	}
 	page := wikifier.NewPageSource(string(input))
-	RunPageAndExit(page, jsonOutput)
+	RunPageAndExit(page, c)
 }
 
 // RunPageAndExit processes a wikifier page and exits
-func RunPageAndExit(page *wikifier.Page, jsonOutput bool) {
+func RunPageAndExit(page *wikifier.Page, c *Config) {
 	err := page.Parse()
 	if err != nil {
 		log.Fatal(err)
 	}
-	if jsonOutput {
+	if c.ToMarkdown {
+		fmt.Print(page.ToMarkdown())
+		os.Exit(0)
+	}
+	if c.JSONOutput {
 		json.NewEncoder(os.Stdout).Encode(page)
 		os.Exit(0)
 	}
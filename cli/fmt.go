@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/cooper/quiki/wikifier"
+	"github.com/pkg/errors"
+)
+
+// source file extensions formatted when walking a directory
+var formatExtensions = map[string]bool{
+	".page":  true,
+	".model": true,
+	".conf":  true,
+}
+
+// RunFormatAndExit handles the fmt command, which formats quiki source files
+// much like gofmt. with no files, it reads from stdin. with -l, it exits with
+// status 1 if any file is not formatted
+func RunFormatAndExit(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write result to source file instead of stdout")
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: fmt [-w] [-l] [path ...]\n\n")
+		fmt.Fprintf(os.Stderr, "formats quiki source files. directories are searched for\n")
+		fmt.Fprintf(os.Stderr, ".page, .model, and .conf files. with -l, exits with status 1\n")
+		fmt.Fprintf(os.Stderr, "if any file is not formatted\n\n")
+		fmt.Fprintf(os.Stderr, "options:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// no files; read from stdin
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "error: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		input, err := io.ReadAll(os.Stdin)
+		changed := false
+		if err == nil {
+			changed, err = formatSource("<standard input>", input, false, *list)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if *list && changed {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		err := filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			// only look at source files within directories
+			if d.IsDir() || (name != path && !formatExtensions[filepath.Ext(name)]) {
+				return nil
+			}
+
+			// skip this file but continue with others
+			changed, err := formatFile(name, *write, *list)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+			} else if *list && changed {
+				status = 1
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+// formatFile formats a single source file
+func formatFile(name string, write, list bool) (bool, error) {
+	input, err := os.ReadFile(name)
+	if err != nil {
+		return false, err
+	}
+	return formatSource(name, input, write, list)
+}
+
+// formatSource formats source, then writes it back to the file, lists the
+// file name, or outputs the result. it returns whether the formatting differs
+func formatSource(name string, input []byte, write, list bool) (bool, error) {
+	output, err := wikifier.FormatSource(input)
+	if err != nil {
+		return false, errors.Wrap(err, name)
+	}
+	changed := !bytes.Equal(input, output)
+
+	if list && changed {
+		fmt.Println(name)
+	}
+	if write {
+		if !changed {
+			return false, nil
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return true, err
+		}
+		return true, os.WriteFile(name, output, info.Mode().Perm())
+	}
+	if !list {
+		_, err = os.Stdout.Write(output)
+	}
+	return changed, err
+}
@@ -15,6 +15,7 @@ type Parser struct {
 	AuthHandler   func(*cli.Config)
 	ReloadHandler func(*cli.Config)
 	ServerHandler func(*cli.Config)
+	ExportHandler func(*cli.Config)
 }
 
 func (p *Parser) SetupFlags(c *cli.Config) {
@@ -28,6 +29,7 @@ func (p *Parser) SetupFlags(c *cli.Config) {
 	flag.StringVar(&c.Port, "port", "", "port to listen on")
 	flag.StringVar(&c.Host, "host", "", "default HTTP host")
 	flag.BoolVar(&c.Reload, "reload", false, "send reload signal to running server")
+	flag.StringVar(&c.Export, "export", "", "export the wiki given by -wiki as a static site to this directory")
 }
 
 func (p *Parser) HandleCommand(c *cli.Config, args []string) error {
@@ -39,6 +41,14 @@ func (p *Parser) HandleCommand(c *cli.Config, args []string) error {
 		return nil
 	}
 
+	// export static site
+	if c.Export != "" {
+		if p.ExportHandler != nil {
+			p.ExportHandler(c)
+		}
+		return nil
+	}
+
 	// check for auth command
 	if len(args) > 0 && args[0] == "auth" {
 		if p.AuthHandler != nil {
@@ -69,7 +79,12 @@ func Usage() {
 	fmt.Fprintf(os.Stderr, "  quiki                       run webserver\n")
 	fmt.Fprintf(os.Stderr, "  quiki -dir=/var/lib/quiki   run webserver w/ different config/data dir\n")
 	fmt.Fprintf(os.Stderr, "  quiki somepage.page         render standalone page to stdout\n")
-	fmt.Fprintf(os.Stderr, "  quiki -wiki=/path my_page   render page within wiki context\n\n")
+	fmt.Fprintf(os.Stderr, "  quiki -wiki=/path my_page   render page within wiki context\n")
+	fmt.Fprintf(os.Stderr, "  quiki -to-markdown my_page  convert page to Markdown\n")
+	fmt.Fprintf(os.Stderr, "  quiki fmt -w /path/pages    format page sources in place\n")
+	fmt.Fprintf(os.Stderr, "  quiki lsp                   run a language server on stdio\n")
+	fmt.Fprintf(os.Stderr, "  quiki -wiki=/path -export=/out\n")
+	fmt.Fprintf(os.Stderr, "                              export wiki as a static site\n\n")
 	fmt.Fprintf(os.Stderr, "options:\n")
 	flag.PrintDefaults()
 }
@@ -14,12 +14,19 @@ type Parser struct{}
 func (p *Parser) SetupFlags(c *cli.Config) {
 	flag.BoolVar(&c.Interactive, "i", false, "interactive mode, read from stdin")
 	flag.BoolVar(&c.JSONOutput, "json", false, "output JSON instead of HTML")
+	flag.BoolVar(&c.ToMarkdown, "to-markdown", false, "output Markdown instead of HTML")
 }
 
 func (p *Parser) HandleCommand(c *cli.Config, args []string) error {
+	// format source files
+	if len(args) > 0 && args[0] == "fmt" {
+		cli.RunFormatAndExit(args[1:])
+		return nil
+	}
+
 	// handle interactive mode
 	if c.Interactive {
-		cli.RunInteractiveMode(c.JSONOutput)
+		cli.RunInteractiveMode(c)
 		return nil
 	}
 
@@ -31,7 +38,7 @@ func (p *Parser) HandleCommand(c *cli.Config, args []string) error {
 
 	// process standalone page
 	page := wikifier.NewPage(args[0])
-	cli.RunPageAndExit(page, c.JSONOutput)
+	cli.RunPageAndExit(page, c)
 	return nil
 }
 
@@ -40,7 +47,11 @@ func Usage() {
 	fmt.Fprintf(os.Stderr, "minimal wikifier engine for processing standalone page files\n\n")
 	fmt.Fprintf(os.Stderr, "common usages:\n")
 	fmt.Fprintf(os.Stderr, "  wikifier somepage.page    render page to HTML and output to stdout\n")
-	fmt.Fprintf(os.Stderr, "  wikifier -i               read page content from stdin\n\n")
+	fmt.Fprintf(os.Stderr, "  wikifier -i               read page content from stdin\n")
+	fmt.Fprintf(os.Stderr, "  wikifier -to-markdown somepage.page\n")
+	fmt.Fprintf(os.Stderr, "                            convert page to Markdown\n")
+	fmt.Fprintf(os.Stderr, "  wikifier fmt -w somepage.page\n")
+	fmt.Fprintf(os.Stderr, "                            format page source in place\n\n")
 	fmt.Fprintf(os.Stderr, "options:\n")
 	flag.PrintDefaults()
 }
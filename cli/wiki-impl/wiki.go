@@ -7,6 +7,7 @@ import (
 
 	"github.com/cooper/quiki/cli"
 	tiny "github.com/cooper/quiki/cli/tiny-impl"
+	"github.com/cooper/quiki/lsp"
 	"github.com/cooper/quiki/pregenerate"
 	"github.com/cooper/quiki/wiki"
 	"github.com/cooper/quiki/wikifier"
@@ -26,9 +27,20 @@ func (p *Parser) SetupFlags(c *cli.Config) {
 }
 
 func (p *Parser) HandleCommand(c *cli.Config, args []string) error {
+	// format source files
+	if len(args) > 0 && args[0] == "fmt" {
+		cli.RunFormatAndExit(args[1:])
+		return nil
+	}
+
+	// run a language server on stdio
+	if len(args) > 0 && args[0] == "lsp" {
+		return lsp.Serve(os.Stdin, os.Stdout, c.WikiPath)
+	}
+
 	// handle interactive mode (inherited from tiny)
 	if c.Interactive {
-		cli.RunInteractiveMode(c.JSONOutput)
+		cli.RunInteractiveMode(c)
 		return nil
 	}
 
@@ -68,13 +80,13 @@ func (p *Parser) handlePageFile(c *cli.Config, pageFile string) error {
 		if page == nil {
 			return errors.Errorf("page not found: %s", pageFile)
 		}
-		cli.RunPageAndExit(page, c.JSONOutput)
+		cli.RunPageAndExit(page, c)
 		return nil
 	}
 
 	// standalone page
 	page := wikifier.NewPage(pageFile)
-	cli.RunPageAndExit(page, c.JSONOutput)
+	cli.RunPageAndExit(page, c)
 	return nil
 }
 
@@ -84,8 +96,11 @@ func Usage() {
 	fmt.Fprintf(os.Stderr, "common usages:\n")
 	fmt.Fprintf(os.Stderr, "  quiki-wiki somepage.page         render standalone page to HTML\n")
 	fmt.Fprintf(os.Stderr, "  quiki-wiki -wiki=/path my_page   render page within wiki context\n")
+	fmt.Fprintf(os.Stderr, "  quiki-wiki -to-markdown my_page  convert page to Markdown\n")
 	fmt.Fprintf(os.Stderr, "  quiki-wiki -wiki=/path           pregenerate all pages in wiki\n")
-	fmt.Fprintf(os.Stderr, "  quiki-wiki -i                    read page content from stdin\n\n")
+	fmt.Fprintf(os.Stderr, "  quiki-wiki -i                    read page content from stdin\n")
+	fmt.Fprintf(os.Stderr, "  quiki-wiki fmt -w /path/pages    format page sources in place\n")
+	fmt.Fprintf(os.Stderr, "  quiki-wiki lsp                   run a language server on stdio\n\n")
 	fmt.Fprintf(os.Stderr, "options:\n")
 	flag.PrintDefaults()
 }
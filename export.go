@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log"
+
+	"github.com/cooper/quiki/cli"
+	"github.com/cooper/quiki/webserver"
+	"github.com/cooper/quiki/wiki"
+)
+
+// runExport renders the wiki given by -wiki as a static site in the
+// directory given by -export
+func runExport(c *cli.Config) {
+	if c.WikiPath == "" {
+		log.Fatal("-export requires -wiki=/path/to/wiki")
+	}
+
+	w, err := wiki.NewWiki(c.WikiPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if c.ForceGen {
+		w.Opt.Page.ForceGen = true
+	}
+
+	if err := webserver.Export(w, c.Export); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -5,6 +5,7 @@ go 1.23.1
 toolchain go1.23.4
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/Songmu/go-httpdate v1.0.0
 	github.com/alecthomas/chroma/v2 v2.20.0
 	github.com/alexedwards/scs/v2 v2.9.0
@@ -15,11 +16,13 @@ require (
 	github.com/go-git/go-git/v5 v5.16.2
 	github.com/grokify/html-strip-tags-go v0.1.0
 	github.com/pkg/errors v0.9.1
-	github.com/russross/blackfriday/v2 v2.1.0
 	github.com/shirou/gopsutil/v3 v3.24.5
 	github.com/whyrusleeping/hellabot v0.0.0-20191113145436-fd8fa1922281
+	github.com/yuin/goldmark v1.8.6
 	golang.org/x/crypto v0.41.0
+	golang.org/x/net v0.43.0
 	golang.org/x/term v0.34.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -52,7 +55,6 @@ require (
 	github.com/xanzy/ssh-agent v0.3.3 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
 	golang.org/x/image v0.30.0 // indirect
-	golang.org/x/net v0.43.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
 	gopkg.in/inconshreveable/log15.v2 v2.0.0-20200109203555-b30bc20e4fd1 // indirect
 	gopkg.in/sorcix/irc.v1 v1.1.4 // indirect
@@ -0,0 +1,109 @@
+package lsp
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// special variables which can be set at the top of a page
+var pageVariables = []struct{ name, desc string }{
+	{"page.title", "Human-readable page title"},
+	{"page.author", "Name of the page author"},
+	{"page.created", "UNIX timestamp or HTTP date of the page creation time"},
+	{"page.desc", "Page description, up to 160 characters"},
+	{"page.keywords", "Comma-separated list of keywords"},
+	{"page.image", "Image representing the page, for link previews"},
+	{"page.draft", "Marks the page as a draft which is not served publicly"},
+	{"page.redirect", "Page redirect target"},
+	{"page.enable.title", "Whether to display the page title as the first section header"},
+	{"page.code.lang", "Language for syntax highlighting in code{} blocks"},
+	{"page.code.style", "Style for syntax highlighting in code{} blocks"},
+}
+
+// special variables which can be set at the top of a model
+var modelVariables = []struct{ name, desc string }{
+	{"model.title", "Human-readable model title"},
+	{"model.author", "Name of the model author"},
+	{"model.desc", "Model description"},
+	{"model.params", "Options accepted by the model"},
+}
+
+var (
+	linkCompletionRegex     = regexp.MustCompile(`\[\[(?:[^\[\]|]*\|)?\s*([^\[\]|]*)$`)
+	modelCompletionRegex    = regexp.MustCompile(`(?:\$|\bmodel\s*\[\s*)([^\s\[\]{}#~$]*)$`)
+	variableCompletionRegex = regexp.MustCompile(`(?:^|[^\w\\])-?[@%]([\w.]*)$`)
+	blockCompletionRegex    = regexp.MustCompile(`(?:^|[{}])\s*~?([a-z]*)$`)
+)
+
+func (s *server) completion(doc *document, pos position) completionList {
+	pos = doc.clamp(pos)
+	before, _ := doc.lineAt(pos)
+	list := completionList{Items: []completionItem{}}
+
+	// the typed text is replaced with the completion
+	add := func(prefix, label string, kind completionItemKind, detail, docs string) {
+		if !strings.HasPrefix(strings.ToLower(label), strings.ToLower(prefix)) {
+			return
+		}
+		start := pos
+		start.Character -= utf16Len(prefix)
+		list.Items = append(list.Items, completionItem{
+			Label:         label,
+			Kind:          kind,
+			Detail:        detail,
+			Documentation: docs,
+			TextEdit:      &textEdit{textRange{start, pos}, label},
+		})
+	}
+
+	switch {
+
+	// [[ page link
+	case linkCompletionRegex.MatchString(before):
+		prefix := linkCompletionRegex.FindStringSubmatch(before)[1]
+		w := s.wikiFor(doc)
+		if w == nil || strings.Contains(prefix, ":") {
+			break
+		}
+		for _, info := range w.Pages() {
+			add(prefix, info.FileNE, kindFile, info.Title, info.Description)
+		}
+
+	// $model or model [name]
+	case modelCompletionRegex.MatchString(before):
+		prefix := modelCompletionRegex.FindStringSubmatch(before)[1]
+		w := s.wikiFor(doc)
+		if w == nil {
+			break
+		}
+		for _, info := range w.Models() {
+			add(prefix, wikifier.PageNameNE(info.File), kindModule, info.Title, info.Description)
+		}
+
+	// @page.something
+	case variableCompletionRegex.MatchString(before):
+		prefix := variableCompletionRegex.FindStringSubmatch(before)[1]
+		vars := pageVariables
+		if doc.ext() == ".model" {
+			vars = modelVariables
+		}
+		for _, v := range vars {
+			add(prefix, v.name, kindProperty, v.desc, "")
+		}
+
+	// block type at the start of a line or after a brace
+	case blockCompletionRegex.MatchString(before):
+		prefix := blockCompletionRegex.FindStringSubmatch(before)[1]
+		for _, typ := range wikifier.BlockTypes() {
+			detail := typ + "{}"
+			if alias := wikifier.BlockAlias(typ); alias != typ {
+				detail = "same as " + alias + "{}"
+			}
+			add(prefix, typ, kindClass, detail, "")
+		}
+	}
+
+	return list
+}
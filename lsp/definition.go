@@ -0,0 +1,101 @@
+package lsp
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	linkRegex  = regexp.MustCompile(`\[\[(.*?)\]\]`)
+	modelRegex = regexp.MustCompile(`\$([^\s\[\]{}#~$;]+)|\bmodel\s*\[\s*([^\]]*?)\s*\]`)
+)
+
+// reference is a page link or model usage in a document
+type reference struct {
+	model bool   // true for a model, false for a page
+	name  string // page or model name
+	rng   textRange
+}
+
+// referenceAt finds the page link or model under the cursor
+func (doc *document) referenceAt(pos position) *reference {
+	line := doc.line(pos.Line)
+	i := byteOffset(line, pos.Character)
+	rangeOf := func(start, end int) textRange {
+		return textRange{
+			Start: position{pos.Line, utf16Len(line[:start])},
+			End:   position{pos.Line, utf16Len(line[:end])},
+		}
+	}
+
+	// [[ page ]] or [[ display | page ]]
+	for _, m := range linkRegex.FindAllStringSubmatchIndex(line, -1) {
+		if i < m[0] || i > m[1] {
+			continue
+		}
+		target := line[m[2]:m[3]]
+		if pipe := strings.IndexByte(target, '|'); pipe != -1 {
+			target = target[pipe+1:]
+		}
+
+		// remove the section
+		if hash := strings.IndexByte(target, '#'); hash != -1 {
+			target = target[:hash]
+		}
+		target = strings.TrimSpace(target)
+
+		// categories, external wikis, sites, and email do not have a page
+		if target == "" || strings.HasPrefix(target, "~") || strings.ContainsAny(target, ":@") {
+			return nil
+		}
+		return &reference{name: target, rng: rangeOf(m[0], m[1])}
+	}
+
+	// $model or model [name]
+	for _, m := range modelRegex.FindAllStringSubmatchIndex(line, -1) {
+		if i < m[0] || i > m[1] {
+			continue
+		}
+		name := ""
+		if m[2] != -1 {
+			name = line[m[2]:m[3]]
+		} else {
+			name = line[m[4]:m[5]]
+		}
+		if name == "" {
+			return nil
+		}
+		return &reference{model: true, name: name, rng: rangeOf(m[0], m[1])}
+	}
+
+	return nil
+}
+
+// definition finds the file for the page or model under the cursor
+func (s *server) definition(doc *document, pos position) *location {
+	ref := doc.referenceAt(pos)
+	if ref == nil {
+		return nil
+	}
+	w := s.wikiFor(doc)
+	if w == nil {
+		return nil
+	}
+
+	var path string
+	if ref.model {
+		path = w.PathForModel(ref.name)
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	} else {
+		page := w.FindPage(ref.name)
+		if !page.Exists() {
+			return nil
+		}
+		path = page.FilePath
+	}
+
+	return &location{URI: pathToURI(path)}
+}
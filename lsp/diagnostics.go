@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// publishDiagnostics parses a document and sends its warnings and error
+func (s *server) publishDiagnostics(doc *document) {
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         doc.uri,
+		Version:     doc.version,
+		Diagnostics: s.diagnostics(doc),
+	})
+}
+
+func (s *server) diagnostics(doc *document) []diagnostic {
+	diagnostics := []diagnostic{}
+
+	// markdown is converted before parsing, so positions would not match
+	if doc.ext() == ".md" || doc.text == "" {
+		return diagnostics
+	}
+
+	// configuration only has variables. some warnings are only found when
+	// generating the page, but models are only generated with options, so
+	// they are just parsed
+	page := s.newPage(doc)
+	page.VarsOnly = doc.ext() == ".conf"
+	err := check(page, !page.VarsOnly && doc.ext() != ".model")
+
+	// the parser stops at the first error
+	if err != nil {
+		var perr *wikifier.ParserError
+		msg, pos := err.Error(), wikifier.Position{}
+		if errors.As(err, &perr) {
+			msg, pos = perr.Err.Error(), perr.Pos
+		}
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    doc.rangeFor(pos),
+			Severity: severityError,
+			Source:   "quiki",
+			Message:  msg,
+		})
+	}
+
+	for _, warn := range page.Warnings {
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    doc.rangeFor(warn.Pos),
+			Severity: severityWarning,
+			Source:   "quiki",
+			Message:  warn.Message,
+		})
+	}
+	return diagnostics
+}
+
+// check parses a page, then generates it if generate is true. a bug in the
+// wikifier should not take the server down with it, so a panic is returned
+// as an error
+func check(page *wikifier.Page, generate bool) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal error: %v", r)
+		}
+	}()
+	if err = page.Parse(); err != nil || !generate {
+		return err
+	}
+	page.HTML()
+	return nil
+}
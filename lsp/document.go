@@ -0,0 +1,130 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// document is a file open in the editor
+type document struct {
+	uri     string
+	path    string // empty if not a file
+	text    string
+	lines   []string
+	version int
+}
+
+func newDocument(uri, text string) *document {
+	doc := &document{uri: uri, path: uriToPath(uri)}
+	doc.setText(text)
+	return doc
+}
+
+func (doc *document) setText(text string) {
+	doc.text = text
+	doc.lines = strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+}
+
+// line returns a line of the document, or an empty string if it does not exist
+func (doc *document) line(n int) string {
+	if n < 0 || n >= len(doc.lines) {
+		return ""
+	}
+	return doc.lines[n]
+}
+
+// clamp moves a position to the nearest one within the document
+func (doc *document) clamp(pos position) position {
+	pos.Line = min(max(pos.Line, 0), len(doc.lines)-1)
+	pos.Character = min(max(pos.Character, 0), utf16Len(doc.lines[pos.Line]))
+	return pos
+}
+
+// ext returns the file extension, like .page
+func (doc *document) ext() string {
+	return strings.ToLower(filepath.Ext(doc.path))
+}
+
+// lineAt returns the text of the line before and after a position
+func (doc *document) lineAt(pos position) (before, after string) {
+	line := doc.line(pos.Line)
+	i := byteOffset(line, pos.Character)
+	return line[:i], line[i:]
+}
+
+// rangeFor converts a wikifier position to a range extending to the end of
+// the line, since that is all the parser knows about where something is
+func (doc *document) rangeFor(pos wikifier.Position) textRange {
+	line := max(pos.Line-1, 0)
+	text := doc.line(line)
+
+	// column is in runes, starting at 1
+	start := 0
+	if pos.Column > 1 {
+		start = len(text)
+		for i := range text {
+			if pos.Column--; pos.Column == 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	// whole line if it is at the end
+	end := len(strings.TrimRight(text, " \t"))
+	if start >= end {
+		start = len(text) - len(strings.TrimLeft(text, " \t"))
+		end = len(text)
+	}
+	return textRange{
+		Start: position{line, utf16Len(text[:start])},
+		End:   position{line, utf16Len(text[:end])},
+	}
+}
+
+// byteOffset converts a UTF-16 offset within a line to a byte offset
+func byteOffset(line string, character int) int {
+	for i, r := range line {
+		if character <= 0 {
+			return i
+		}
+		character -= utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// uriToPath returns the file path for a file: URI, or an empty string
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	path := u.Path
+
+	// windows paths look like /C:/dir
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.Clean(filepath.FromSlash(path))
+}
+
+// pathToURI returns a file: URI for a path
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
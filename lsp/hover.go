@@ -0,0 +1,52 @@
+package lsp
+
+import "strings"
+
+// hover describes the model under the cursor
+func (s *server) hover(doc *document, pos position) *hover {
+	ref := doc.referenceAt(pos)
+	if ref == nil || !ref.model {
+		return nil
+	}
+	w := s.wikiFor(doc)
+	if w == nil {
+		return nil
+	}
+	info := w.ModelInfo(ref.name)
+	if info.File == "" {
+		return nil
+	}
+
+	// title and description
+	var b strings.Builder
+	b.WriteString("**" + info.Title + "** (`" + info.File + "`)\n")
+	if info.Author != "" {
+		b.WriteString("\nby " + info.Author + "\n")
+	}
+	if info.Description != "" {
+		b.WriteString("\n" + info.Description + "\n")
+	}
+
+	// options from @model.params
+	if len(info.Params) != 0 {
+		b.WriteString("\nOptions:\n")
+		for _, param := range info.Params {
+			b.WriteString("* `" + param.Name + "` " + param.Type)
+			if param.Required {
+				b.WriteString(", required")
+			}
+			if param.Default != "" {
+				b.WriteString(", default `" + param.Default + "`")
+			}
+			if param.Description != "" {
+				b.WriteString(" - " + param.Description)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: b.String()},
+		Range:    &ref.rng,
+	}
+}
@@ -0,0 +1,266 @@
+// Package lsp implements a language server for quiki source files.
+//
+// The server speaks the Language Server Protocol over stdio. It provides
+// diagnostics from the wikifier parser, completion of block types, page
+// variables, models, and page links, hover for models, and go-to-definition
+// for page links and models.
+//
+// Features which need to know about other pages and models work when the
+// file is within a wiki, either given explicitly or found by looking for
+// wiki.conf in the directories above the file.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cooper/quiki/wiki"
+	"github.com/pkg/errors"
+)
+
+// largest message accepted from the client
+const maxMessageSize = 64 << 20
+
+type server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	wikiPath string // wiki given on the command line, if any
+
+	docs     map[string]*document  // open documents by URI
+	wikis    map[string]*wiki.Wiki // wikis by directory; nil if it failed to load
+	started  bool                  // initialize was received
+	shutdown bool                  // shutdown was received
+}
+
+// Serve runs a language server which reads from in and writes to out until
+// the client asks it to exit.
+//
+// If wikiPath is not empty, all files are treated as part of that wiki.
+// Otherwise, the wiki for each file is found by searching for wiki.conf.
+func Serve(in io.Reader, out io.Writer, wikiPath string) error {
+	if wikiPath != "" {
+		wikiPath, _ = filepath.Abs(wikiPath)
+	}
+	s := &server{
+		in:       bufio.NewReader(in),
+		out:      out,
+		wikiPath: wikiPath,
+		docs:     make(map[string]*document),
+		wikis:    make(map[string]*wiki.Wiki),
+	}
+	for {
+		req, err := s.readRequest()
+		if err == io.EOF {
+			return errors.New("connection closed without exit")
+		}
+		if err != nil {
+			return err
+		}
+		if req == nil {
+			continue
+		}
+
+		// the client is done with us
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+
+		s.handle(req)
+	}
+}
+
+// readRequest reads one message from the client. it returns nil if the
+// message was not valid JSON, after reporting the error
+func (s *server) readRequest() (*request, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, errors.Wrap(err, "bad Content-Length")
+	}
+	if length < 0 || length > maxMessageSize {
+		return nil, errors.Errorf("bad Content-Length: %d", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.replyError(nil, codeParseError, err.Error())
+		return nil, nil
+	}
+	return &req, nil
+}
+
+func (s *server) write(msg any) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("lsp: encode message: %v", err)
+		return
+	}
+	io.WriteString(s.out, "Content-Length: "+strconv.Itoa(len(body))+"\r\n\r\n")
+	s.out.Write(body)
+}
+
+func (s *server) reply(id *json.RawMessage, result any) {
+	s.write(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *server) replyError(id *json.RawMessage, code int, message string) {
+	s.write(errorResponse{JSONRPC: "2.0", ID: id, Error: responseError{code, message}})
+}
+
+func (s *server) notify(method string, params any) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle dispatches a request or notification
+func (s *server) handle(req *request) {
+	isRequest := req.ID != nil
+
+	// nothing but initialize is allowed until then
+	if !s.started && req.Method != "initialize" {
+		if isRequest {
+			s.replyError(req.ID, codeServerNotReady, "server not initialized")
+		}
+		return
+	}
+
+	var result any
+	var err error
+	switch req.Method {
+
+	case "initialize":
+		s.started = true
+		result = capabilities
+
+	case "initialized":
+
+	case "shutdown":
+		s.shutdown = true
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			doc := newDocument(params.TextDocument.URI, params.TextDocument.Text)
+			doc.version = params.TextDocument.Version
+			s.docs[doc.uri] = doc
+			s.publishDiagnostics(doc)
+		}
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			doc := s.docs[params.TextDocument.URI]
+			changes := params.ContentChanges
+
+			// full sync, so only the last change matters
+			if doc != nil && len(changes) != 0 {
+				doc.setText(changes[len(changes)-1].Text)
+				doc.version = params.TextDocument.Version
+				s.publishDiagnostics(doc)
+			}
+		}
+
+	case "textDocument/didSave":
+		var params didSaveParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			if doc := s.docs[params.TextDocument.URI]; doc != nil {
+				if params.Text != nil {
+					doc.setText(*params.Text)
+				}
+				s.publishDiagnostics(doc)
+			}
+		}
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			delete(s.docs, params.TextDocument.URI)
+
+			// clear diagnostics for the closed file
+			s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+				URI:         params.TextDocument.URI,
+				Diagnostics: []diagnostic{},
+			})
+		}
+
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			if doc := s.docs[params.TextDocument.URI]; doc != nil {
+				result = s.completion(doc, params.Position)
+			}
+		}
+
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			if doc := s.docs[params.TextDocument.URI]; doc != nil {
+				if h := s.hover(doc, params.Position); h != nil {
+					result = h
+				}
+			}
+		}
+
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			if doc := s.docs[params.TextDocument.URI]; doc != nil {
+				if loc := s.definition(doc, params.Position); loc != nil {
+					result = loc
+				}
+			}
+		}
+
+	default:
+		// notifications we don't know about are ignored, including $/ ones
+		if isRequest {
+			s.replyError(req.ID, codeMethodNotFound, "method not found: "+req.Method)
+		}
+		return
+	}
+
+	if !isRequest {
+		if err != nil {
+			log.Printf("lsp: %s: %v", req.Method, err)
+		}
+		return
+	}
+	if err != nil {
+		s.replyError(req.ID, codeInvalidParams, err.Error())
+		return
+	}
+	s.reply(req.ID, result)
+}
+
+// what the server supports, sent in reply to initialize
+var capabilities = map[string]any{
+	"capabilities": map[string]any{
+		"textDocumentSync": map[string]any{
+			"openClose": true,
+			"change":    1, // full
+			"save":      map[string]any{"includeText": false},
+		},
+		"completionProvider": map[string]any{
+			"triggerCharacters": []string{"@", ".", "$", "[", "|"},
+		},
+		"hoverProvider":      true,
+		"definitionProvider": true,
+	},
+	"serverInfo": map[string]any{
+		"name": "quiki",
+	},
+}
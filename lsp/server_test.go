@@ -0,0 +1,170 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// message builds a client message with its header
+func message(t *testing.T, id int, method string, params any) string {
+	t.Helper()
+	msg := map[string]any{"jsonrpc": "2.0", "method": method}
+	if id != 0 {
+		msg["id"] = id
+	}
+	if params != nil {
+		msg["params"] = params
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + string(body)
+}
+
+// serverMessage is a response or notification from the server
+type serverMessage struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// readMessages reads everything the server wrote
+func readMessages(t *testing.T, out []byte) []serverMessage {
+	t.Helper()
+	var msgs []serverMessage
+	r := bufio.NewReader(bytes.NewReader(out))
+	for {
+		header, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err == io.EOF {
+			return msgs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			t.Fatal(err)
+		}
+		var msg serverMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+// resultOf finds the response to a request
+func resultOf(t *testing.T, msgs []serverMessage, id int) serverMessage {
+	t.Helper()
+	for _, msg := range msgs {
+		if msg.ID == id && msg.Method == "" {
+			if msg.Error != nil {
+				t.Fatalf("request %d: %s", id, msg.Error.Message)
+			}
+			return msg
+		}
+	}
+	t.Fatalf("no response to request %d", id)
+	return serverMessage{}
+}
+
+func TestServe(t *testing.T) {
+	uri := pathToURI(filepath.Join(t.TempDir(), "test.page"))
+	text := "sec {\n    im\n}\n@x: [@truncate(\"abc\", 99999999999999999999)];\n"
+	at := func(line, character int) map[string]any {
+		return map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+			"position":     map[string]any{"line": line, "character": character},
+		}
+	}
+
+	in := message(t, 1, "initialize", map[string]any{}) +
+		message(t, 0, "initialized", map[string]any{}) +
+		message(t, 0, "textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": "quiki", "version": 1, "text": text},
+		}) +
+		message(t, 2, "textDocument/completion", at(1, 6)) +
+		message(t, 3, "textDocument/completion", at(100, 0)) +
+		message(t, 4, "shutdown", nil) +
+		message(t, 0, "exit", nil)
+
+	var out bytes.Buffer
+	if err := Serve(strings.NewReader(in), &out, ""); err != nil {
+		t.Fatal(err)
+	}
+	msgs := readMessages(t, out.Bytes())
+
+	// initialize
+	var init struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	json.Unmarshal(resultOf(t, msgs, 1).Result, &init)
+	if init.Capabilities["completionProvider"] == nil {
+		t.Errorf("initialize: no completion capability in %v", init.Capabilities)
+	}
+
+	// didOpen publishes diagnostics
+	var diags publishDiagnosticsParams
+	for _, msg := range msgs {
+		if msg.Method == "textDocument/publishDiagnostics" {
+			json.Unmarshal(msg.Params, &diags)
+		}
+	}
+	if diags.URI != uri {
+		t.Fatalf("didOpen: no diagnostics published for %s", uri)
+	}
+
+	// block type completion replaces what was typed
+	var list completionList
+	json.Unmarshal(resultOf(t, msgs, 2).Result, &list)
+	found := false
+	for _, item := range list.Items {
+		if item.Label != "image" {
+			continue
+		}
+		found = true
+		want := textRange{position{1, 4}, position{1, 6}}
+		if item.TextEdit == nil || item.TextEdit.Range != want {
+			t.Errorf("completion: image edit %+v, want range %+v", item.TextEdit, want)
+		}
+	}
+	if !found {
+		t.Errorf("completion: image not in %+v", list.Items)
+	}
+
+	// positions past the end are within the document
+	list = completionList{}
+	json.Unmarshal(resultOf(t, msgs, 3).Result, &list)
+	last := len(strings.Split(text, "\n")) - 1
+	if len(list.Items) == 0 {
+		t.Error("completion: nothing at the end of the document")
+	}
+	for _, item := range list.Items {
+		if item.TextEdit.Range.End.Line > last {
+			t.Fatalf("completion: %s edit %+v is past the end", item.Label, item.TextEdit.Range)
+		}
+	}
+}
+
+func TestServeBadLength(t *testing.T) {
+	for _, length := range []string{"-1", "99999999999", "abc"} {
+		in := "Content-Length: " + length + "\r\n\r\n{}"
+		if err := Serve(strings.NewReader(in), io.Discard, ""); err == nil {
+			t.Errorf("Content-Length %s: no error", length)
+		}
+	}
+}
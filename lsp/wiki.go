@@ -0,0 +1,73 @@
+package lsp
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cooper/quiki/wiki"
+	"github.com/cooper/quiki/wikifier"
+)
+
+// isWikiDir returns true if the directory contains a wiki
+func isWikiDir(dir string) bool {
+	fi, err := os.Stat(filepath.Join(dir, "wiki.conf"))
+	return err == nil && !fi.IsDir()
+}
+
+// wikiFor returns the wiki containing a document, or nil if it is not in one
+func (s *server) wikiFor(doc *document) *wiki.Wiki {
+	dir := s.wikiPath
+	if dir == "" {
+		if doc.path == "" {
+			return nil
+		}
+
+		// find the closest directory with wiki.conf
+		for dir = filepath.Dir(doc.path); !isWikiDir(dir); {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return nil
+			}
+			dir = parent
+		}
+	}
+
+	// already loaded, or failed to
+	if w, ok := s.wikis[dir]; ok {
+		return w
+	}
+
+	w, err := wiki.NewWiki(dir)
+	if err != nil {
+		log.Printf("lsp: load wiki %s: %v", dir, err)
+		w = nil
+	}
+	s.wikis[dir] = w
+	return w
+}
+
+// newPage creates a page for the current text of a document. within a
+// wiki, it has the wiki's options so that models and links work
+func (s *server) newPage(doc *document) *wikifier.Page {
+	w := s.wikiFor(doc)
+	if w == nil {
+		page := wikifier.NewPage(doc.path)
+		page.Source = doc.text
+		return page
+	}
+
+	// models are named relative to the models directory
+	var page *wikifier.Page
+	if modelDir, _ := filepath.Abs(w.Opt.Dir.Model); doc.ext() == ".model" && strings.HasPrefix(doc.path, modelDir+string(filepath.Separator)) {
+		name, _ := filepath.Rel(modelDir, doc.path)
+		page = wikifier.NewModel(doc.path, strings.TrimSuffix(filepath.ToSlash(name), ".model"))
+	} else {
+		page = wikifier.NewPage(doc.path)
+	}
+	page.Source = doc.text
+	page.Opt = &w.Opt
+	page.Wiki = w
+	return page
+}
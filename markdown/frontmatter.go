@@ -0,0 +1,289 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cooper/quiki/adminifier/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var varNameRegex = regexp.MustCompile(`[^\w\-]`)
+
+// front matter keys which have a @page equivalent
+var frontMatterPageKeys = map[string]string{
+	"title":       "title",
+	"author":      "author",
+	"authors":     "author",
+	"date":        "created",
+	"created":     "created",
+	"description": "desc",
+	"summary":     "desc",
+	"desc":        "desc",
+	"keywords":    "keywords",
+	"image":       "image",
+	"draft":       "draft",
+	"redirect":    "redirect",
+	"redirect_to": "redirect",
+}
+
+// front matter keys which list @category names
+var frontMatterCategoryKeys = map[string]bool{
+	"categories": true,
+	"category":   true,
+	"tags":       true,
+}
+
+// splitFrontMatter separates front matter from the start of Markdown input.
+// YAML front matter is delimited by --- and TOML front matter by +++.
+// If there is no front matter, format is empty and body is the input.
+func splitFrontMatter(input []byte) (format string, matter, body []byte) {
+	var delim string
+	switch {
+	case bytes.HasPrefix(input, []byte("---")):
+		format, delim = "yaml", "---"
+	case bytes.HasPrefix(input, []byte("+++")):
+		format, delim = "toml", "+++"
+	default:
+		return "", nil, input
+	}
+
+	// the opening delimiter must be alone on the first line
+	rest := input[len(delim):]
+	nl := bytes.IndexByte(rest, '\n')
+	if nl == -1 || len(bytes.TrimSpace(rest[:nl])) != 0 {
+		return "", nil, input
+	}
+	rest = rest[nl+1:]
+
+	// find the closing delimiter. YAML may also end with ...
+	for pos := 0; pos < len(rest); {
+		end := bytes.IndexByte(rest[pos:], '\n')
+		if end == -1 {
+			end = len(rest)
+		} else {
+			end += pos + 1
+		}
+		line := string(bytes.TrimSpace(rest[pos:end]))
+		if line == delim || (format == "yaml" && line == "...") {
+			return format, rest[:pos], rest[end:]
+		}
+		pos = end
+	}
+
+	// no closing delimiter; this is probably a thematic break
+	return "", nil, input
+}
+
+// parseFrontMatter parses YAML or TOML front matter. If YAML front matter is
+// not a mapping, it is probably Markdown instead, so the values are nil.
+func parseFrontMatter(format string, matter []byte) (map[string]any, error) {
+	if format == "toml" {
+		values := make(map[string]any)
+		if err := toml.Unmarshal(matter, &values); err != nil {
+			return nil, errors.Wrap(err, "toml front matter")
+		}
+		return values, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(matter, &doc); err != nil {
+		return nil, errors.Wrap(err, "yaml front matter")
+	}
+	values := make(map[string]any)
+	if len(doc.Content) == 0 {
+		// empty front matter
+		return values, nil
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	if err := doc.Decode(&values); err != nil {
+		return nil, errors.Wrap(err, "yaml front matter")
+	}
+	return values, nil
+}
+
+// renderFrontMatter writes @page and @category assignments for the
+// front matter values.
+func (r *QuikiRenderer) renderFrontMatter(w io.Writer) {
+	keys := make([]string, 0, len(r.FrontMatter))
+	for key := range r.FrontMatter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := r.FrontMatter[key]
+		lower := strings.ToLower(key)
+
+		// categories
+		if frontMatterCategoryKeys[lower] {
+			for _, name := range frontMatterStrings(value) {
+				if name = frontMatterVarName(name); name != "" {
+					io.WriteString(w, "@category."+name+";\n")
+				}
+			}
+			continue
+		}
+
+		// the title is written with the page footer
+		name, ok := frontMatterPageKeys[lower]
+		switch {
+		case name == "title":
+			continue
+		case name == "author":
+			// multiple authors are listed together
+			if list, isList := value.([]any); isList {
+				value = strings.Join(frontMatterStrings(list), ", ")
+			}
+		case name == "keywords":
+			// keywords are a list, but may be separated by commas
+			if str, isStr := value.(string); isStr {
+				var list []any
+				for _, keyword := range strings.Split(str, ",") {
+					list = append(list, strings.TrimSpace(keyword))
+				}
+				value = list
+			}
+		case name == "created":
+			// creation time is stored as a UNIX timestamp
+			if t, isTime := value.(time.Time); isTime {
+				value = strconv.FormatInt(t.Unix(), 10)
+			}
+		case !ok:
+			name = frontMatterVarName(key)
+		}
+
+		if name != "" {
+			frontMatterAssign(w, "@page."+name, value)
+		}
+	}
+	io.WriteString(w, "\n")
+}
+
+// frontMatterAssign writes an assignment for a front matter value.
+// Maps are assigned attribute by attribute.
+func frontMatterAssign(w io.Writer, varName string, value any) {
+	switch value := value.(type) {
+	case nil:
+	case bool:
+		if value {
+			io.WriteString(w, varName+";\n")
+		} else {
+			io.WriteString(w, "-"+varName+";\n")
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if name := frontMatterVarName(key); name != "" {
+				frontMatterAssign(w, varName+"."+name, value[key])
+			}
+		}
+	default:
+		io.WriteString(w, varName+": "+frontMatterValue(value, false)+";\n")
+	}
+}
+
+// frontMatterValue returns quiki source for a front matter value.
+// Lists become list{} and maps become map{}.
+func frontMatterValue(value any, inValue bool) string {
+	switch value := value.(type) {
+	case []any:
+		var b strings.Builder
+		b.WriteString("list {")
+		for _, item := range value {
+			b.WriteString(" " + frontMatterValue(item, true) + ";")
+		}
+		b.WriteString(" }")
+		return b.String()
+	case []map[string]any:
+		// toml arrays of tables
+		list := make([]any, len(value))
+		for i, item := range value {
+			list[i] = item
+		}
+		return frontMatterValue(list, inValue)
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString("map {")
+		for _, key := range keys {
+			b.WriteString(" " + utils.EscMapKey(key) + ": " + frontMatterValue(value[key], true) + ";")
+		}
+		b.WriteString(" }")
+		return b.String()
+	}
+	return frontMatterEsc(frontMatterString(value), inValue)
+}
+
+// frontMatterString returns the text of a scalar front matter value.
+func frontMatterString(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		// values are on one line
+		return strings.Join(strings.Fields(value), " ")
+	case time.Time:
+		return value.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// frontMatterStrings returns the text of a front matter value which may be
+// a single value or a list of them.
+func frontMatterStrings(value any) []string {
+	list, ok := value.([]any)
+	if !ok {
+		list = []any{value}
+	}
+	var strs []string
+	for _, item := range list {
+		if s := frontMatterString(item); s != "" {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+// frontMatterEsc escapes text for use as a variable, list, or map value.
+func frontMatterEsc(s string, inValue bool) string {
+	if !inValue {
+		return escVar(s)
+	}
+	// the list parser consumes one level of backslash escapes
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = utils.EscListMapValue(s)
+	return strings.Replace(s, "*", "\\*", -1)
+}
+
+// escVar escapes plain text for use as a variable value.
+func escVar(s string) string {
+	s = utils.EscFmt(s)
+	s = strings.Replace(s, "*", "\\*", -1)
+
+	// the variable parser does not unescape semicolons
+	return strings.Replace(s, ";", "[&#59]", -1)
+}
+
+// frontMatterVarName returns a front matter key or category name suitable
+// for use in a variable name.
+func frontMatterVarName(name string) string {
+	return varNameRegex.ReplaceAllString(strings.TrimSpace(name), "_")
+}
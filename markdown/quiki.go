@@ -9,15 +9,66 @@ import (
 	"strings"
 
 	"github.com/cooper/quiki/adminifier/utils"
-	"github.com/russross/blackfriday/v2"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
 )
 
 var punctuationRegex = regexp.MustCompile(`[^\w\- ]`)
 
 // Run parses Markdown and renders quiki soure code.
+//
+// Markdown is parsed according to CommonMark with the GitHub Flavored
+// Markdown extensions (tables, strikethrough, autolinks, and task lists)
+// as well as footnotes.
+//
+// Run is like Convert, except that invalid front matter is ignored.
 func Run(input []byte) []byte {
-	r := NewQuikiRenderer(QuikiRendererParameters{Flags: TableOfContents})
-	return blackfriday.Run(input, blackfriday.WithRenderer(r), blackfriday.WithExtensions(blackfriday.NoEmptyLineBeforeBlock|blackfriday.CommonExtensions))
+	source, err := Convert(input)
+	if err != nil {
+		_, _, body := splitFrontMatter(input)
+		source, _ = Convert(body)
+	}
+	return source
+}
+
+// Convert parses Markdown and renders quiki source code.
+//
+// YAML or TOML front matter at the start of the input is translated to
+// @page and @category variables. An error is returned only if the front
+// matter is invalid.
+func Convert(input []byte) ([]byte, error) {
+	params := QuikiRendererParameters{Flags: TableOfContents}
+
+	// extract front matter
+	format, matter, body := splitFrontMatter(input)
+	if format != "" {
+		values, err := parseFrontMatter(format, matter)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			body = input
+		}
+		params.FrontMatter = values
+		for key, value := range values {
+			if strings.ToLower(key) == "title" {
+				params.Title = frontMatterString(value)
+			}
+		}
+	}
+
+	r := NewQuikiRenderer(params)
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM, extension.Footnote),
+		goldmark.WithRenderer(r),
+	)
+	var buf bytes.Buffer
+	md.Convert(body, &buf) // only fails when writing fails
+	return buf.Bytes(), nil
 }
 
 // QuikiFlags is renderer configuration options.
@@ -41,12 +92,13 @@ type QuikiRendererParameters struct {
 	AbsolutePrefix string
 
 	// add this text to each footnote anchor, to ensure uniqueness.
+	// (footnotes are now quiki footnotes, which have their own anchors)
 	FootnoteAnchorPrefix string
 
 	// Show this text inside the <a> tag for a footnote return link, if the
 	// HTML_FOOTNOTE_RETURN_LINKS flag is enabled. If blank, the string
 	// <sup>[return]</sup> is used.
-	//
+	// (footnotes are now quiki footnotes, which always have return links)
 	FootnoteReturnLinkContents string
 
 	// If set, add this text to the front of each Heading ID, to ensure
@@ -63,11 +115,15 @@ type QuikiRendererParameters struct {
 	// page title. defaults to the first heading in the document
 	Title string
 
+	// front matter values to translate to @page and @category variables
+	FrontMatter map[string]any
+
 	// flags to customize the renderer's behavior
 	Flags QuikiFlags
 }
 
-// QuikiRenderer is a type that implements the Renderer interface for quiki source code output.
+// QuikiRenderer is a type that implements the goldmark Renderer interface
+// for quiki source code output.
 //
 // Do not create this directly, instead use the NewQuikiRenderer function.
 type QuikiRenderer struct {
@@ -80,12 +136,19 @@ type QuikiRenderer struct {
 	headerLevel int    // section depth
 	indent      int    // indent level
 	linkDest    string // link destination stored until end of link text
+	inHeading   int    // depth of headings, to collect heading text
+	inLink      int    // depth of links, which need | escaped
+	inValue     int    // depth of list values, which need ; escaped
+	inFootnote  int    // depth of footnotes, which are inline
+
+	source    []byte                 // markdown source
+	footnotes map[int]*east.Footnote // footnote definitions by index
 
 	lastOutputLen int
 }
 
 // NewQuikiRenderer creates and configures a QuikiRenderer object, which
-// satisfies the Renderer interface.
+// satisfies the goldmark Renderer interface.
 func NewQuikiRenderer(params QuikiRendererParameters) *QuikiRenderer {
 
 	if params.FootnoteReturnLinkContents == "" {
@@ -147,7 +210,7 @@ func (r *QuikiRenderer) ensureUniqueHeadingID(id string) string {
 }
 
 func (r *QuikiRenderer) addAbsPrefix(link []byte) []byte {
-	if r.AbsolutePrefix != "" && isRelativeLink(link) && link[0] != '.' {
+	if r.AbsolutePrefix != "" && len(link) != 0 && isRelativeLink(link) && link[0] != '.' {
 		newDest := r.AbsolutePrefix
 		if link[0] != '/' {
 			newDest += "/"
@@ -158,58 +221,22 @@ func (r *QuikiRenderer) addAbsPrefix(link []byte) []byte {
 	return link
 }
 
-func codeLanguage(info []byte) string {
-	endOfLang := bytes.IndexAny(info, "\t ")
-	if endOfLang < 0 {
-		endOfLang = len(info)
-	}
-	return string(info[:endOfLang])
-}
-
-func (r *QuikiRenderer) tag(w io.Writer, name []byte, attrs []string) {
-	w.Write(name)
-	if len(attrs) > 0 {
-		w.Write(spaceBytes)
-		w.Write([]byte(strings.Join(attrs, " ")))
-	}
-	w.Write(gtBytes)
-	r.lastOutputLen = 1
-}
-
-func footnoteRef(prefix string, node *blackfriday.Node) []byte {
-	urlFrag := prefix + string(slugify(node.Destination))
-	anchor := fmt.Sprintf(`<a href="#fn:%s">%d</a>`, urlFrag, node.NoteID)
-	return []byte(fmt.Sprintf(`<sup class="footnote-ref" id="fnref:%s">%s</sup>`, urlFrag, anchor))
-}
-
-func footnoteItem(prefix string, slug []byte) []byte {
-	return []byte(fmt.Sprintf(`<li id="fn:%s%s">`, prefix, slug))
+// skipParagraphTags is true for paragraphs which are displayed inline, such
+// as within footnotes
+func (r *QuikiRenderer) skipParagraphTags(node ast.Node) bool {
+	return r.inFootnote != 0
 }
 
-func footnoteReturnLink(prefix, returnLink string, slug []byte) []byte {
-	const format = ` <a class="footnote-return" href="#fnref:%s%s">%s</a>`
-	return []byte(fmt.Sprintf(format, prefix, slug, returnLink))
-}
-
-func skipParagraphTags(node *blackfriday.Node) bool {
-	grandparent := node.Parent.Parent
-	if grandparent == nil || grandparent.Type != blackfriday.List {
-		return false
-	}
-	tightOrTerm := grandparent.Tight || node.Parent.ListFlags&blackfriday.ListTypeTerm != 0
-	return grandparent.Type == blackfriday.List && tightOrTerm
-}
-
-func cellAlignment(align blackfriday.CellAlignFlags) string {
+func cellAlignment(align east.Alignment) string {
 	switch align {
-	case blackfriday.TableAlignmentLeft:
+	case east.AlignLeft:
 		return "left"
-	case blackfriday.TableAlignmentRight:
+	case east.AlignRight:
 		return "right"
-	case blackfriday.TableAlignmentCenter:
+	case east.AlignCenter:
 		return "center"
 	default:
-		return ""
+		return "-"
 	}
 }
 
@@ -219,14 +246,6 @@ func (r *QuikiRenderer) out(w io.Writer, text []byte) {
 }
 
 func (r *QuikiRenderer) addText(w io.Writer, text string) {
-	// if r.indent > 0 {
-	// 	indentStr := "    "
-	// 	newText := ""
-	// 	for _, line := range strings.Split(text, "\n") {
-	// 		newText += line + "\n" + indentStr
-	// 	}
-	// 	text = newText
-	// }
 	r.out(w, []byte(text))
 }
 
@@ -236,88 +255,189 @@ func (r *QuikiRenderer) cr(w io.Writer) {
 	}
 }
 
+// esc escapes text for where it appears: in a link, a list value, or
+// other formatted text.
+func (r *QuikiRenderer) esc(s string) string {
+	if r.inValue != 0 {
+		// the list parser consumes one level of backslash escapes
+		s = strings.Replace(s, "\\", "\\\\", -1)
+		s = utils.EscListMapValue(s)
+	} else {
+		s = utils.EscFmt(s)
+	}
+
+	// asterisks are emphasis in quiki too, and link text is formatted twice
+	star := "\\*"
+	if r.inLink != 0 {
+		star = "\\\\\\*"
+	}
+	s = strings.Replace(s, "*", star, -1)
+
+	if r.inLink != 0 {
+		s = strings.Replace(s, "|", "\\|", -1)
+	}
+	return s
+}
+
+// lines returns the text of a block with lines, such as code.
+func (r *QuikiRenderer) lines(node ast.Node) string {
+	var buf bytes.Buffer
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		buf.Write(line.Value(r.source))
+	}
+	return buf.String()
+}
+
+// text returns the plain text within an inline node.
+func (r *QuikiRenderer) text(node ast.Node) string {
+	var buf bytes.Buffer
+	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			buf.Write(textValue(n, r.source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(n.Value)
+		case *ast.AutoLink:
+			buf.Write(n.Label(r.source))
+		}
+		return ast.WalkContinue, nil
+	})
+	return buf.String()
+}
+
+// textValue returns the value of a text node with escapes and character
+// references resolved.
+func textValue(n *ast.Text, source []byte) []byte {
+	value := n.Value(source)
+	if n.IsRaw() {
+		return value
+	}
+	return util.ResolveEntityNames(util.ResolveNumericReferences(util.UnescapePunctuations(value)))
+}
+
+// footnote renders the content of a footnote inline.
+func (r *QuikiRenderer) footnote(w io.Writer, index int) {
+	note := r.footnotes[index]
+	if note == nil {
+		return
+	}
+	r.inFootnote++
+	for child := note.FirstChild(); child != nil; child = child.NextSibling() {
+		if child != note.FirstChild() {
+			r.addText(w, " ")
+		}
+		ast.Walk(child, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			return r.RenderNode(w, n, entering), nil
+		})
+	}
+	r.inFootnote--
+}
+
 var (
-	nlBytes    = []byte{'\n'}
-	gtBytes    = []byte{'>'}
-	spaceBytes = []byte{' '}
+	nlBytes = []byte{'\n'}
 )
 
 var (
-	hrTag         = []byte("<hr />")
-	tableTag      = []byte(`<table class="q-table">`)
-	tableCloseTag = []byte("</table>")
-	tdTag         = []byte("<td")
-	tdCloseTag    = []byte("</td>")
-	thTag         = []byte("<th")
-	thCloseTag    = []byte("</th>")
-	theadTag      = []byte("<thead>")
-	theadCloseTag = []byte("</thead>")
-	tbodyTag      = []byte("<tbody>")
-	tbodyCloseTag = []byte("</tbody>")
-	trTag         = []byte("<tr>")
-	trCloseTag    = []byte("</tr>")
-
-	footnotesDivBytes      = []byte("\n<div class=\"footnotes\">\n\n")
-	footnotesCloseDivBytes = []byte("\n</div>\n")
+	hrTag = []byte("<hr />")
 )
 
+// Render renders the Markdown syntax tree as quiki source code.
+// It implements the goldmark Renderer interface.
+func (r *QuikiRenderer) Render(w io.Writer, source []byte, n ast.Node) error {
+	r.source = source
+
+	// footnote definitions are used where they are referenced
+	r.footnotes = make(map[int]*east.Footnote)
+	ast.Walk(n, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if note, ok := node.(*east.Footnote); ok && entering {
+			r.footnotes[note.Index] = note
+		}
+		return ast.WalkContinue, nil
+	})
+
+	r.RenderHeader(w, n)
+	ast.Walk(n, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		return r.RenderNode(w, node, entering), nil
+	})
+	r.RenderFooter(w, n)
+	return nil
+}
+
+// AddOptions does nothing. It exists to implement the goldmark Renderer
+// interface.
+func (r *QuikiRenderer) AddOptions(...renderer.Option) {}
+
 // RenderNode is a default renderer of a single node of a syntax tree. For
 // block nodes it will be called twice: first time with entering=true, second
 // time with entering=false, so that it could know when it's working on an open
 // tag and when on close. It writes the result to w.
 //
 // The return value is a way to tell the calling walker to adjust its walk
-// pattern: e.g. it can terminate the traversal by returning Terminate. Or it
-// can ask the walker to skip a subtree of this node by returning SkipChildren.
-// The typical behavior is to return GoToNext, which asks for the usual
+// pattern: e.g. it can terminate the traversal by returning WalkStop. Or it
+// can ask the walker to skip a subtree of this node by returning WalkSkipChildren.
+// The typical behavior is to return WalkContinue, which asks for the usual
 // traversal to the next node.
-func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
-	attrs := []string{}
-	switch node.Type {
-
-	case blackfriday.Text:
-		s := string(node.Literal)
-		if node.Parent.Type == blackfriday.Link {
-			r.addText(w, utils.EscLink(s))
-		} else if node.Parent.Type == blackfriday.Paragraph && node.Parent.Parent.Type == blackfriday.Item {
-			r.addText(w, utils.EscListMapValue(s))
-		} else if node.Parent.Type == blackfriday.Item {
-			r.addText(w, utils.EscListMapValue(s))
-		} else if node.Parent.Type == blackfriday.Heading {
+func (r *QuikiRenderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	switch node := node.(type) {
+
+	case *ast.Text:
+		if !entering {
+			break
+		}
+		s := string(textValue(node, r.source))
+		if r.inHeading != 0 {
 			r.heading += s
-			r.addText(w, utils.EscFmt(s))
-		} else {
-			r.addText(w, utils.EscFmt(s))
 		}
+		r.addText(w, r.esc(s))
 
-	// newline
-	case blackfriday.Softbreak:
-		r.cr(w)
-		// TODO: make it configurable via out(renderer.softbreak)
-
-	// actual newline
-	case blackfriday.Hardbreak:
-		r.addText(w, "[br]")
-		r.cr(w)
+		// a block name is on one line
+		if r.inHeading != 0 && (node.HardLineBreak() || node.SoftLineBreak()) {
+			if node.HardLineBreak() {
+				r.addText(w, "[nl]")
+			} else {
+				r.heading += " "
+				r.addText(w, " ")
+			}
+		} else if node.HardLineBreak() {
+			// actual newline
+			r.addText(w, "[nl]")
+			r.out(w, nlBytes)
+		} else if node.SoftLineBreak() {
+			// newline
+			r.out(w, nlBytes)
+		}
 
-	// italicd
-	case blackfriday.Emph:
-		if entering {
-			r.addText(w, "[i]")
-		} else {
-			r.addText(w, "[/i]")
+	case *ast.String:
+		if !entering {
+			break
+		}
+		if r.inHeading != 0 {
+			r.heading += string(node.Value)
 		}
+		r.addText(w, r.esc(string(node.Value)))
 
-	// bold
-	case blackfriday.Strong:
+	// italic or bold
+	case *ast.Emphasis:
+		tag := "i"
+		if node.Level == 2 {
+			tag = "b"
+		}
 		if entering {
-			r.addText(w, "[b]")
+			r.addText(w, "["+tag+"]")
 		} else {
-			r.addText(w, "[/b]")
+			r.addText(w, "[/"+tag+"]")
 		}
 
 	// strikethrough
-	case blackfriday.Del:
+	case *east.Strikethrough:
 		if entering {
 			r.addText(w, "[s]")
 		} else {
@@ -325,75 +445,149 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 		}
 
 	// inline html
-	case blackfriday.HTMLSpan:
-		if r.Flags&SkipHTML != 0 {
+	case *ast.RawHTML:
+		if !entering || r.Flags&SkipHTML != 0 {
 			break
 		}
-		html := utils.EscFmt(string(node.Literal))
-		r.addText(w, "[html:"+html+"]")
+		var raw bytes.Buffer
+		for i := 0; i < node.Segments.Len(); i++ {
+			segment := node.Segments.At(i)
+			raw.Write(segment.Value(r.source))
+		}
+		r.addText(w, "[html:"+r.esc(raw.String())+"]")
 
 	// link
-	case blackfriday.Link:
+	case *ast.Link:
 		// mark it but don't link it if it is not a safe link
-		dest := node.LinkData.Destination
 		if r.Flags&SkipLinks != 0 {
 			if entering {
 				r.addText(w, "[c]")
 			} else {
 				r.addText(w, "[/c]")
 			}
-		} else {
-			if entering {
-				link := string(r.addAbsPrefix(dest))
-				link = utils.EscLink(link)
-				if hashIdx := strings.IndexByte(link, '#'); hashIdx != -1 {
-					r.linkDest = strings.TrimSuffix(link[:hashIdx], ".md") + link[hashIdx:]
-				} else {
-					r.linkDest = strings.TrimSuffix(link, ".md")
-				}
-				r.addText(w, "[[ ")
+			break
+		}
 
-				// TODO: anything we can do with node.LinkData.Title?
+		// [text]() has nowhere to go, so it is just the text
+		if len(node.Destination) == 0 {
+			break
+		}
+		if entering {
+			link := string(r.addAbsPrefix(node.Destination))
+			link = r.escLink(link)
+			if hashIdx := strings.IndexByte(link, '#'); hashIdx != -1 {
+				r.linkDest = strings.TrimSuffix(link[:hashIdx], ".md") + link[hashIdx:]
 			} else {
-				// if node.NoteID != 0 {
-				// 	break
-				// }
-				r.addText(w, " | "+r.linkDest+" ]]")
-				r.linkDest = ""
+				r.linkDest = strings.TrimSuffix(link, ".md")
 			}
+			r.addText(w, "[[ ")
+			r.inLink++
+
+			// TODO: anything we can do with node.Title?
+		} else {
+			r.inLink--
+			r.addText(w, " | "+r.linkDest+" ]]")
+			r.linkDest = ""
 		}
 
+	// <https://example.com> or a bare URL
+	case *ast.AutoLink:
+		if !entering {
+			break
+		}
+		label := string(node.Label(r.source))
+		if r.Flags&SkipLinks != 0 {
+			r.addText(w, "[c]"+r.esc(label)+"[/c]")
+			break
+		}
+		link := string(node.URL(r.source))
+		if node.AutoLinkType == ast.AutoLinkEmail {
+			if !strings.HasPrefix(strings.ToLower(link), "mailto:") {
+				link = "mailto:" + link
+			}
+		} else if !strings.Contains(link, "://") {
+			// www.example.com
+			link = "http://" + link
+		}
+		r.inLink++
+		r.addText(w, "[[ "+r.esc(label)+" | "+r.escLink(link)+" ]]")
+		r.inLink--
+
 	// image
-	case blackfriday.Image:
+	case *ast.Image:
 
 		// configured to skip images
-		if r.Flags&SkipImages != 0 {
-			return blackfriday.SkipChildren
+		if r.Flags&SkipImages != 0 || !entering {
+			return ast.WalkSkipChildren
 		}
 
-		if entering {
-			dest := node.LinkData.Destination
-			dest = r.addAbsPrefix(dest)
-			// FIXME: if dest is not relative, we can't display this image
-			r.addText(w, "~image {\n    file: "+utils.Esc(string(dest))+";\n    alt: ")
-		} else {
-			// FIXME: can we do anything with node.LinkData.Title?
-			r.out(w, []byte(";\n}"))
+		// ![alt]() has no image, so it is just the alt text
+		if len(node.Destination) == 0 {
+			r.addText(w, r.esc(r.text(node)))
+			return ast.WalkSkipChildren
 		}
 
+		dest := r.addAbsPrefix(node.Destination)
+		// FIXME: if dest is not relative, we can't display this image
+		r.inValue++
+		alt := r.esc(r.text(node))
+		r.inValue--
+		r.addText(w, "~image {\n    file: "+utils.EscListMapValue(string(dest))+";\n    alt: "+alt+";\n}")
+		// FIXME: can we do anything with node.Title?
+		return ast.WalkSkipChildren
+
 	// inline code
-	case blackfriday.Code:
-		var code string
-		if node.Parent != nil && node.Parent.Parent != nil &&
-			node.Parent.Type == blackfriday.Paragraph && node.Parent.Parent.Type == blackfriday.Item {
-			code = utils.EscListMapValue(string(node.Literal))
-		} else {
-			code = utils.EscFmt(string(node.Literal))
+	case *ast.CodeSpan:
+		if !entering {
+			break
+		}
+		var code bytes.Buffer
+		for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+			switch child := child.(type) {
+			case *ast.Text:
+				value := child.Value(r.source)
+				if bytes.HasSuffix(value, nlBytes) {
+					value = append(value[:len(value)-1:len(value)-1], ' ')
+				}
+				code.Write(value)
+			case *ast.String:
+				code.Write(child.Value)
+			}
+		}
+		r.addText(w, "[c]"+r.esc(code.String())+"[/c]")
+		return ast.WalkSkipChildren
+
+	// task list checkbox
+	case *east.TaskCheckBox:
+		if !entering {
+			break
+		}
+		checked := ""
+		if node.IsChecked {
+			checked = ` checked="checked"`
+		}
+		r.addText(w, `[html:<input type="checkbox" disabled="disabled"`+checked+` />] `)
+
+	// footnote reference
+	case *east.FootnoteLink:
+		if !entering {
+			break
 		}
-		r.addText(w, "[c]"+code+"[/c]")
+		r.addText(w, "[^ ")
+		r.footnote(w, node.Index)
+		r.addText(w, "]")
+
+	// footnotes are displayed where they are referenced
+	case *east.FootnoteList:
+		return ast.WalkSkipChildren
+
+	// link reference definitions are resolved by the parser
+	case *ast.LinkReferenceDefinition:
+		return ast.WalkSkipChildren
+	case *east.FootnoteBacklink, *east.Footnote:
 
 	// document
-	case blackfriday.Document:
+	case *ast.Document:
 		// close any open sections
 		if !entering {
 			for ; r.headerLevel > 0; r.headerLevel-- {
@@ -401,22 +595,35 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 			}
 		}
 
+	// paragraph in a tight list
+	case *ast.TextBlock:
+		_, inItem := node.Parent().(*ast.ListItem)
+		if !inItem {
+			break
+		}
+		if entering {
+			r.inValue++
+		} else {
+			r.inValue--
+		}
+
 	// paragraph
-	case blackfriday.Paragraph:
-		if skipParagraphTags(node) {
+	case *ast.Paragraph:
+		if r.skipParagraphTags(node) {
 			break
 		}
 		if entering {
 			// TODO: untangle this clusterfuck about when the newlines need
 			// to be added and when not.
-			if node.Prev != nil {
-				switch node.Prev.Type {
-				case blackfriday.HTMLBlock, blackfriday.List, blackfriday.Paragraph, blackfriday.Heading,
-					blackfriday.CodeBlock, blackfriday.BlockQuote, blackfriday.HorizontalRule:
+			if prev := node.PreviousSibling(); prev != nil {
+				switch prev.(type) {
+				case *ast.HTMLBlock, *ast.List, *ast.Paragraph, *ast.Heading,
+					*ast.CodeBlock, *ast.FencedCodeBlock, *ast.Blockquote,
+					*ast.ThematicBreak, *east.Table:
 					r.cr(w)
 				}
 			}
-			if node.Parent.Type == blackfriday.BlockQuote && node.Prev == nil {
+			if _, ok := node.Parent().(*ast.Blockquote); ok && node.PreviousSibling() == nil {
 				r.cr(w)
 			}
 			r.addText(w, "~p {\n")
@@ -424,13 +631,13 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 		} else {
 			r.indent--
 			r.addText(w, "\n}")
-			if !(node.Parent.Type == blackfriday.Item && node.Next == nil) {
+			if _, ok := node.Parent().(*ast.ListItem); !ok || node.NextSibling() != nil {
 				r.cr(w)
 			}
 		}
 
 	// blockquote
-	case blackfriday.BlockQuote:
+	case *ast.Blockquote:
 		if entering {
 			r.cr(w)
 			r.addText(w, "~quote {\n")
@@ -442,21 +649,30 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 		}
 
 	// HTML block
-	case blackfriday.HTMLBlock:
-		if r.Flags&SkipHTML != 0 {
+	case *ast.HTMLBlock:
+		if !entering || r.Flags&SkipHTML != 0 {
 			break
 		}
+		html := r.lines(node)
+		if node.HasClosure() {
+			html += string(node.ClosureLine.Value(r.source))
+		}
 		r.cr(w)
 		r.addText(w, "~html {\n")
 		r.indent++
-		r.addText(w, utils.Esc(string(node.Literal)))
+		r.addText(w, utils.Esc(strings.TrimRight(html, "\n")))
 		r.indent--
 		r.addText(w, "\n}")
 		r.cr(w)
 
 	// heading
-	case blackfriday.Heading:
+	case *ast.Heading:
 		level := r.QuikiRendererParameters.HeadingLevelOffset + node.Level
+		if level < 1 {
+			level = 1
+		} else if level > 6 {
+			level = 6
+		}
 		if entering {
 
 			// if we already have a header of this level open, this
@@ -479,20 +695,11 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 			// set level, start the section with name opening tag.
 			r.headerLevel = level
 			r.addText(w, "~sec [")
-
-			// if node.IsTitleblock {
-			// 	attrs = append(attrs, `class="title"`)
-			// }
+			r.inHeading++
 
 		} else {
-			// r.out(w, closeTag)
-			// if !(node.Parent.Type == blackfriday.Item && node.Next == nil) {
-			// 	r.cr(w)
-			// }
-
-			// $indent++;
+			r.inHeading--
 			r.indent++
-			//     $add_text->("$current_text] {\n");
 			r.addText(w, "]")
 
 			// assume page title as first heading
@@ -501,15 +708,12 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 			}
 
 			// figure the anchor for github compatibility
-			id := node.HeadingID
-			if node.HeadingID == "" {
-				// https://github.com/jch/html-pipeline/blob/master/lib/html/pipeline/toc_filter.rb
-				// $section_id =~ tr/A-Z/a-z/;                 # ASCII downcase
-				id = strings.ToLower(r.heading)                // downcase
-				id = punctuationRegex.ReplaceAllString(id, "") // remove punctuation
-				id = strings.Replace(id, " ", "-", -1)         // replace spaces with dashes
-				r.heading = ""
-			}
+			// https://github.com/jch/html-pipeline/blob/master/lib/html/pipeline/toc_filter.rb
+			// $section_id =~ tr/A-Z/a-z/;                     # ASCII downcase
+			id := strings.ToLower(r.heading)               // downcase
+			id = punctuationRegex.ReplaceAllString(id, "") // remove punctuation
+			id = strings.Replace(id, " ", "-", -1)         // replace spaces with dashes
+			r.heading = ""
 
 			// heading ID
 			id = r.ensureUniqueHeadingID(id)
@@ -523,29 +727,27 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 		}
 
 	// horizontal rule
-	// TODO
-	case blackfriday.HorizontalRule:
+	case *ast.ThematicBreak:
+		if !entering {
+			break
+		}
 		r.cr(w)
+		r.addText(w, "~html {\n")
 		r.out(w, hrTag)
+		r.addText(w, "\n}")
 		r.cr(w)
 
-	case blackfriday.List:
-
+	case *ast.List:
 		if entering {
-			if node.IsFootnotesList {
-				r.out(w, footnotesDivBytes)
-				r.out(w, hrTag)
-				r.cr(w)
-			}
 			r.cr(w)
-			if node.Parent.Type == blackfriday.Item && node.Parent.Parent.Tight {
-				r.cr(w)
+			if item, ok := node.Parent().(*ast.ListItem); ok {
+				if list, ok := item.Parent().(*ast.List); ok && list.IsTight {
+					r.cr(w)
+				}
 			}
 
-			if node.ListFlags&blackfriday.ListTypeOrdered != 0 {
+			if node.IsOrdered() {
 				r.addText(w, "numlist {")
-			} else if node.ListFlags&blackfriday.ListTypeDefinition != 0 {
-				r.addText(w, "definitions {")
 			} else {
 				r.addText(w, "list {")
 			}
@@ -554,142 +756,124 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 		} else {
 			r.indent--
 			r.addText(w, "\n}")
-			// if node.Parent.Type == blackfriday.Item && node.Next != nil {
-			// 	r.cr(w)
-			// }
-			// if node.Parent.Type == blackfriday.Document || node.Parent.Type == blackfriday.BlockQuote {
-			// 	r.cr(w)
-			// }
-			if node.IsFootnotesList {
-				r.out(w, footnotesCloseDivBytes)
-			}
 		}
-	case blackfriday.Item:
+
+	case *ast.ListItem:
 		if entering {
 			r.cr(w)
 		} else {
-			// if node.ListData.RefLink != nil {
-			// 	slug := slugify(node.ListData.RefLink)
-			// 	if r.Flags&FootnoteReturnLinks != 0 {
-			// 		r.out(w, footnoteReturnLink(r.FootnoteAnchorPrefix, r.FootnoteReturnLinkContents, slug))
-			// 	}
-			// }
 			r.addText(w, ";")
 		}
 
 	// code block
-	case blackfriday.CodeBlock:
+	case *ast.CodeBlock, *ast.FencedCodeBlock:
+		if !entering {
+			break
+		}
 		r.cr(w)
 
 		// TODO: count opening and closing brackets.
 		// if they match, use brace-escape rather than utils.Esc()
 		r.addText(w, "~code ")
-		if lang := codeLanguage(node.Info); lang != "" {
-			r.addText(w, "["+lang+"] ")
+		if fenced, ok := node.(*ast.FencedCodeBlock); ok {
+			if lang := fenced.Language(r.source); len(lang) != 0 {
+				r.addText(w, "["+utils.EscFmt(string(lang))+"] ")
+			}
 		}
 		r.addText(w, "{\n")
 		r.indent++
-		r.addText(w, utils.Esc(string(node.Literal)))
+		r.addText(w, utils.Esc(r.lines(node)))
 		r.indent--
 		r.addText(w, "}")
 
-		if node.Parent.Type != blackfriday.Item {
+		if _, ok := node.Parent().(*ast.ListItem); !ok {
 			r.cr(w)
 		}
+		return ast.WalkSkipChildren
 
 	// table
-	// just wrap in html
-	case blackfriday.Table:
+	case *east.Table:
 		if entering {
 			r.cr(w)
-			r.addText(w, "~html {")
-			r.out(w, tableTag)
-		} else {
-			r.out(w, tableCloseTag)
-			r.addText(w, "}")
-			r.cr(w)
-		}
-
-	// table cell
-	case blackfriday.TableCell:
-		openTag := tdTag
-		closeTag := tdCloseTag
-		if node.IsHeader {
-			openTag = thTag
-			closeTag = thCloseTag
-		}
-		if entering {
-			align := cellAlignment(node.Align)
-			if align != "" {
-				attrs = append(attrs, fmt.Sprintf(`align="%s"`, align))
+			r.addText(w, "~table {\n")
+
+			// column alignment, if any
+			align := make([]string, len(node.Alignments))
+			aligned := false
+			for i, a := range node.Alignments {
+				align[i] = cellAlignment(a)
+				aligned = aligned || a != east.AlignNone
 			}
-			if node.Prev == nil {
-				r.cr(w)
+			if aligned {
+				r.addText(w, "align: "+strings.Join(align, ", ")+";\n")
 			}
-			r.tag(w, openTag, attrs)
 		} else {
-			r.out(w, closeTag)
+			r.addText(w, "}")
 			r.cr(w)
 		}
 
-	// table head
-	case blackfriday.TableHead:
+	// table header row
+	case *east.TableHeader:
 		if entering {
-			r.cr(w)
-			r.out(w, theadTag)
+			r.addText(w, "~head {")
 		} else {
-			r.out(w, theadCloseTag)
-			r.cr(w)
+			r.addText(w, " };\n")
 		}
 
-	// table body
-	case blackfriday.TableBody:
+	// table row
+	case *east.TableRow:
 		if entering {
-			r.cr(w)
-			r.out(w, tbodyTag)
-			// XXX: this is to adhere to a rather silly test. Should fix test.
-			if node.FirstChild == nil {
-				r.cr(w)
-			}
+			r.addText(w, "~row {")
 		} else {
-			r.out(w, tbodyCloseTag)
-			r.cr(w)
+			r.addText(w, " };\n")
 		}
 
-	// table row
-	case blackfriday.TableRow:
+	// table cell
+	case *east.TableCell:
 		if entering {
-			r.cr(w)
-			r.out(w, trTag)
+			r.addText(w, " ")
+			r.inValue++
 		} else {
-			r.out(w, trCloseTag)
-			r.cr(w)
+			r.inValue--
+			r.addText(w, ";")
 		}
 
 	// unknown
 	default:
-		panic("Unknown node type " + node.Type.String())
+		panic("Unknown node type " + node.Kind().String())
 	}
 
-	return blackfriday.GoToNext
+	return ast.WalkContinue
+}
+
+// escLink escapes a link target.
+func (r *QuikiRenderer) escLink(link string) string {
+	link = utils.EscLink(link)
+	if r.inValue != 0 {
+		link = strings.Replace(link, ";", "\\;", -1)
+	}
+	return link
 }
 
 // RenderFooter renders the page footer.
-func (r *QuikiRenderer) RenderFooter(w io.Writer, ast *blackfriday.Node) {
+func (r *QuikiRenderer) RenderFooter(w io.Writer, doc ast.Node) {
 	// title must be done after the heading is extracted
 	if r.Title != "" {
-		io.WriteString(w, "\n@page.title: "+utils.EscFmt(r.Title)+";\n")
+		io.WriteString(w, "\n@page.title: "+escVar(r.Title)+";\n")
 	}
 }
 
 // RenderHeader renders the page header, which includes @page variable definitions.
-func (r *QuikiRenderer) RenderHeader(w io.Writer, ast *blackfriday.Node) {
+func (r *QuikiRenderer) RenderHeader(w io.Writer, doc ast.Node) {
 	if r.Flags&PartialPage != 0 {
 		return
 	}
 	io.WriteString(w, "@page.author:    Markdown;\n")
 	io.WriteString(w, "@page.generator: quiki/markdown;\n")
 	io.WriteString(w, "@page.generated;\n\n")
+	if len(r.FrontMatter) != 0 {
+		r.renderFrontMatter(w)
+	}
 	if r.Flags&TableOfContents != 0 {
 		io.WriteString(w, "toc{}\n\n")
 	}
@@ -0,0 +1,279 @@
+package markdown_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cooper/quiki/wikifier"
+	"golang.org/x/net/html"
+)
+
+// a CommonMark spec example, from testdata/spec.json. this is the spec's own
+// test data for version 0.31.2 (https://spec.commonmark.org/0.31.2/), which is
+// licensed under CC BY-SA 4.0
+type specExample struct {
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+	Example  int    `json:"example"`
+	Section  string `json:"section"`
+}
+
+// TestCommonMarkSpec renders each example in the CommonMark spec as a quiki
+// Markdown page and compares the result to the expected HTML. quiki's HTML
+// differs in ways which do not change the meaning, so both are normalized
+// first by normalizeSpecHTML.
+//
+// examples known to differ are listed in testdata/spec-failures.txt. the test
+// fails if any other example differs, or if a listed example no longer does,
+// so that the list stays accurate.
+func TestCommonMarkSpec(t *testing.T) {
+	var examples []specExample
+	data, err := os.ReadFile("testdata/spec.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &examples); err != nil {
+		t.Fatal(err)
+	}
+	known := readSpecFailures(t)
+
+	passed := 0
+	for _, ex := range examples {
+		got, want := renderSpecExample(t, ex.Markdown), normalizeSpecHTML(ex.HTML, false)
+		switch {
+		case got == want && known[ex.Example]:
+			t.Errorf("example %d (%s) now passes; remove it from spec-failures.txt", ex.Example, ex.Section)
+		case got != want && !known[ex.Example]:
+			t.Errorf("example %d (%s):\n%s\ngot:  %s\nwant: %s", ex.Example, ex.Section, ex.Markdown, got, want)
+		}
+		if got == want {
+			passed++
+		}
+	}
+	t.Logf("%d of %d spec examples pass", passed, len(examples))
+}
+
+// readSpecFailures reads the example numbers listed in spec-failures.txt.
+// lines starting with # are comments
+func readSpecFailures(t *testing.T) map[int]bool {
+	t.Helper()
+	file, err := os.Open("testdata/spec-failures.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	known := make(map[int]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, field := range strings.Fields(line) {
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				t.Fatalf("spec-failures.txt: %q is not an example number", field)
+			}
+			known[n] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return known
+}
+
+// renderSpecExample renders Markdown as a quiki page and normalizes the HTML
+func renderSpecExample(t *testing.T, md string) string {
+	t.Helper()
+	page := wikifier.NewPageSource(md)
+	page.Markdown = true
+
+	// the first heading would otherwise be displayed as the page title too
+	opt := *page.Opt
+	opt.Page.EnableTitle = false
+	page.Opt = &opt
+	if err := page.Parse(); err != nil {
+		return "error: " + err.Error()
+	}
+	return normalizeSpecHTML(string(page.HTML()), true)
+}
+
+var (
+	specSpaceRegex = regexp.MustCompile(`\s+`)
+	specTagRegex   = regexp.MustCompile(`\s*(<[^>]*>)\s*`)
+)
+
+// quiki's inline styles and the equivalent elements
+var specStyleTags = map[string]string{
+	"font-style: italic;":            "em",
+	"font-weight: bold;":             "strong",
+	"text-decoration: line-through;": "del",
+}
+
+// normalizeSpecHTML returns HTML in a form in which the spec's HTML and
+// quiki's can be compared:
+//
+//   - quiki's <div> and <span> elements are replaced with the plain elements
+//     they stand for or removed, and images are not wrapped in links to them
+//   - the table of contents which quiki adds to Markdown pages is removed
+//   - elements left open, as raw HTML may do, are closed
+//   - only the href, src, alt, and start attributes are kept
+//   - whitespace is collapsed, and whitespace around tags is removed,
+//     except within <pre>
+//
+// the HTML is tokenized rather than parsed, so that quiki's blocks within
+// paragraphs are kept where they are.
+func normalizeSpecHTML(s string, quiki bool) string {
+	var b strings.Builder
+	var stack []specElement
+	skip := 0 // depth within a removed element and its content
+	inPre := false
+
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag, drop := tok.Data, false
+			if quiki {
+				tag, drop = quikiSpecTag(tok)
+			}
+
+			// code blocks are highlighted with spans, so only the text is kept
+			if inPre && tag != "code" {
+				tag = ""
+			}
+			void := tt == html.SelfClosingTagToken || isVoidSpecTag(tok.Data)
+			if !void {
+				stack = append(stack, specElement{tok.Data, tag})
+				if drop || skip != 0 {
+					skip++
+				}
+			}
+			if skip != 0 || drop || tag == "" {
+				continue
+			}
+
+			b.WriteString("<" + tag)
+			for _, attr := range tok.Attr {
+				switch attr.Key {
+				case "href", "src", "alt", "start":
+					b.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+				}
+			}
+			b.WriteString(">")
+
+			// quiki's code blocks have no <code>
+			if tag == "pre" {
+				inPre = true
+				if quiki {
+					b.WriteString("<code>")
+				}
+			}
+
+		case html.EndTagToken:
+			// raw HTML in Markdown may leave elements open, so this also
+			// closes any opened since the one it ends
+			i := len(stack) - 1
+			for i >= 0 && stack[i].name != tok.Data {
+				i--
+			}
+			for i >= 0 && len(stack) > i {
+				closeSpecElement(&b, &stack, &skip, &inPre, quiki)
+			}
+		}
+	}
+	for len(stack) != 0 {
+		closeSpecElement(&b, &stack, &skip, &inPre, quiki)
+	}
+
+	// pre is kept as-is, so only collapse outside of it
+	parts := strings.Split(b.String(), "<pre>")
+	for i, part := range parts {
+		pre, rest := "", part
+		if i != 0 {
+			if end := strings.Index(part, "</pre>"); end != -1 {
+				pre, rest = "<pre>"+part[:end+len("</pre>")], part[end+len("</pre>"):]
+			}
+		}
+		rest = specSpaceRegex.ReplaceAllString(rest, " ")
+		parts[i] = pre + specTagRegex.ReplaceAllString(rest, "$1")
+	}
+	return strings.TrimSpace(strings.Join(parts, ""))
+}
+
+// an open element: its tag, and the normalized tag or "" if removed
+type specElement struct {
+	name, tag string
+}
+
+// closeSpecElement closes the innermost open element
+func closeSpecElement(b *strings.Builder, stack *[]specElement, skip *int, inPre *bool, quiki bool) {
+	el := (*stack)[len(*stack)-1]
+	*stack = (*stack)[:len(*stack)-1]
+	if *skip != 0 {
+		*skip--
+		return
+	}
+	if el.tag == "pre" {
+		*inPre = false
+		if quiki {
+			b.WriteString("</code>")
+		}
+	}
+	if el.tag != "" {
+		b.WriteString("</" + el.tag + ">")
+	}
+}
+
+// quikiSpecTag returns the plain element a quiki element stands for, or an
+// empty string if only its content should be kept. drop is true if neither
+// the element nor its content should be kept
+func quikiSpecTag(tok html.Token) (tag string, drop bool) {
+	var class, style string
+	for _, attr := range tok.Attr {
+		switch attr.Key {
+		case "class":
+			class = attr.Val
+		case "style":
+			style = attr.Val
+		}
+	}
+	classes := strings.Fields(class)
+	switch {
+	case slices.Contains(classes, "q-toc"):
+		return "", true
+	case tok.Data == "span":
+		return specStyleTags[style], false
+	case slices.Contains(classes, "q-image-a"):
+		return "", false
+	case tok.Data == "div" && strings.HasPrefix(class, "q-"):
+		return "", false
+	}
+	return tok.Data, false
+}
+
+func isVoidSpecTag(tag string) bool {
+	switch tag {
+	case "br", "hr", "img", "input":
+		return true
+	}
+	return false
+}
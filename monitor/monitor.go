@@ -318,17 +318,30 @@ func (ww *wikiWatcher) handlePageEvent(relPath string, event fsnotify.Event) {
 			ww.handlePotentialSymlinkChange(relPath)
 		}
 
+		// convert relPath to page name by removing .page extension if present
+		pageName := relPath
+		if filepath.Ext(pageName) == ".page" {
+			pageName = pageName[:len(pageName)-5]
+		}
+
 		// regenerate the page itself
+		queryState := ww.wiki.PageQueryState(pageName)
 		ww.regeneratePage(relPath)
 
-		// for newly created pages, regenerate pages that reference it
+		// for newly created pages, regenerate pages that reference it.
+		// otherwise, only pages which include it are outdated
 		if event.Op == fsnotify.Create {
-			// convert relPath to page name by removing .page extension if present
-			pageName := relPath
-			if filepath.Ext(pageName) == ".page" {
-				pageName = pageName[:len(pageName)-5]
-			}
 			ww.regenerateReferencingPages(pageName)
+		} else {
+			ww.regenerateIncludingPages(pageName)
+		}
+
+		// pages{} results may have changed too if the page is new or its
+		// info did. otherwise, only pages listing it show outdated info
+		if event.Op == fsnotify.Create || ww.wiki.PageQueryState(pageName) != queryState {
+			ww.regenerateQueryingPages(pageName)
+		} else {
+			ww.regenerateListingPages(pageName)
 		}
 
 	case fsnotify.Rename, fsnotify.Remove:
@@ -342,6 +355,7 @@ func (ww *wikiWatcher) handlePageEvent(relPath string, event fsnotify.Event) {
 
 		// regenerate pages that reference the deleted page to update broken links
 		ww.regenerateReferencingPages(pageName)
+		ww.regenerateQueryingPages(pageName)
 
 		// if this was a symlink, clean up target tracking
 		abs := filepath.Join(ww.wiki.Opt.Dir.Page, relPath)
@@ -418,3 +432,58 @@ func (ww *wikiWatcher) regenerateReferencingPages(pageName string) {
 		ww.pregenerateManager.GeneratePageSync(referencingPage, true)
 	}
 }
+
+// regenerateIncludingPages regenerates all pages that include the given page
+func (ww *wikiWatcher) regenerateIncludingPages(pageName string) {
+	includingPages := ww.wiki.GetIncludingPages(pageName)
+	if len(includingPages) == 0 {
+		return
+	}
+
+	log.Printf("Regenerating %d pages that include '%s': %v", len(includingPages), pageName, includingPages)
+	for _, includingPage := range includingPages {
+		ww.pregenerateManager.GeneratePageSync(includingPage, true)
+	}
+}
+
+// regenerateListingPages regenerates all pages that list the given page with pages{}
+func (ww *wikiWatcher) regenerateListingPages(pageName string) {
+	listingPages := ww.wiki.GetListingPages(pageName)
+	if len(listingPages) == 0 {
+		return
+	}
+
+	log.Printf("Regenerating %d pages that list '%s': %v", len(listingPages), pageName, listingPages)
+	for _, listingPage := range listingPages {
+		if err := ww.wiki.RegeneratePage(listingPage); err != nil {
+			log.Printf("Failed to clear cache for %s: %v", listingPage, err)
+			continue
+		}
+		ww.pregenerateManager.GeneratePageSync(listingPage, true)
+	}
+}
+
+// regenerateQueryingPages regenerates all pages with pages{} other than the
+// given page. their caches are cleared, since the pages they list may have
+// been created, removed, or changed without the cache being outdated
+func (ww *wikiWatcher) regenerateQueryingPages(pageName string) {
+	var queryingPages []string
+	for _, queryingPage := range ww.wiki.GetQueryingPages() {
+		queryingPageNE := queryingPage[:len(queryingPage)-len(filepath.Ext(queryingPage))]
+		if queryingPageNE != pageName {
+			queryingPages = append(queryingPages, queryingPage)
+		}
+	}
+	if len(queryingPages) == 0 {
+		return
+	}
+
+	log.Printf("Regenerating %d pages that list pages: %v", len(queryingPages), queryingPages)
+	for _, queryingPage := range queryingPages {
+		if err := ww.wiki.RegeneratePage(queryingPage); err != nil {
+			log.Printf("Failed to clear cache for %s: %v", queryingPage, err)
+			continue
+		}
+		ww.pregenerateManager.GeneratePageSync(queryingPage, true)
+	}
+}
@@ -719,6 +719,7 @@ func (m *Manager) checkInitialPregenerationComplete() {
 		m.debug("processing deferred validations after initial pregeneration...")
 		m.wiki.ProcessChecks()
 		m.debug("deferred validation processing complete - system now in normal validation mode")
+		m.regenerateQueryingPages()
 		m.mu.Lock() // re-lock for defer unlock
 	}
 } // pregenerateAllImages handles both synchronous and asynchronous image pregeneration
@@ -782,6 +783,7 @@ func (m *Manager) PregenerateSync() Stats {
 	}()
 
 	stats := m.pregenerateAllPages(true)
+	m.regenerateQueryingPages()
 	m.pregenerateAllImages(true)
 	return stats
 }
@@ -1026,6 +1028,18 @@ func (m *Manager) pregeneratePage(pageName string, isHighPriority bool) any {
 	return result
 }
 
+// regenerateQueryingPages regenerates pages with pages{} after all pages have
+// been generated, since pages listed may not have been generated before them
+func (m *Manager) regenerateQueryingPages() {
+	for _, pageName := range m.wiki.GetQueryingPages() {
+		if err := m.wiki.RegeneratePage(pageName); err != nil {
+			m.debug("pregenerate: failed to clear cache for %s: %v", pageName, err)
+			continue
+		}
+		m.pregeneratePage(pageName, true)
+	}
+}
+
 // priorityImageWorker handles high-priority image pregeneration
 func (m *Manager) priorityImageWorker() {
 	defer m.wg.Done()
@@ -12,6 +12,7 @@ func main() {
 		AuthHandler:   func(c *cli.Config) { handleAuthCommand(c) },
 		ReloadHandler: func(c *cli.Config) { handleReload(c) },
 		ServerHandler: func(c *cli.Config) { runServer(c) },
+		ExportHandler: func(c *cli.Config) { runExport(c) },
 	}
 
 	flag.Usage = impl.Usage
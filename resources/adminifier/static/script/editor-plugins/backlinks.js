@@ -0,0 +1,78 @@
+(function (a) {
+
+document.addEvent('editorLoaded', loadedHandler);
+document.addEvent('pageUnloaded', unloadedHandler);
+
+var ae;
+function loadedHandler () {
+    ae = a.editor;
+
+    // add toolbar functions
+    ae.addToolbarFunctions({
+        backlinks: displayBacklinks
+    });
+
+    // only pages can be linked to
+    if (!ae.isPage())
+        ae.liForAction('backlinks').addClass('disabled');
+}
+
+function unloadedHandler () {
+    document.removeEvent('editorLoaded', loadedHandler);
+    document.removeEvent('pageUnloaded', unloadedHandler);
+}
+
+// BACKLINKS VIEWER
+
+function displayBacklinks () {
+    if (!ae.isPage())
+        return;
+
+    // make the li stay open until finish()
+    var li = ae.liForAction('backlinks');
+    ae.setLiLoading(li, true);
+
+    // create the box
+    var box = ae.createPopupBox(li);
+    box.setStyles({ right: 0, bottom: 0 });
+    box.addClass('fixed');
+    box.innerHTML = tmpl('tmpl-backlinks-viewer', {});
+    var container = box.getElement('#editor-backlinks');
+
+    // populate and display it
+    var finish = function (data) {
+        ae.setLiLoading(li, false);
+        if (!box)
+            return;
+        if (!data.success) {
+            alert(data.error || 'No data');
+            return;
+        }
+        if (!data.backlinks || !data.backlinks.length) {
+            container.innerHTML = tmpl('tmpl-backlinks-none', {});
+        }
+        else data.backlinks.each(function (link) {
+            var row = new Element('a', {
+                class: 'editor-backlink-row',
+                href: a.wikiRoot + '/edit-page?page=' + encodeURIComponent(link.file)
+            });
+            row.innerHTML = tmpl('tmpl-backlink-row', link);
+            a.addFrameClickHandler(row);
+            container.appendChild(row);
+        });
+        ae.displayPopupBox(box, 'auto', li);
+    };
+
+    // request backlinks
+    new Request.JSON({
+        url: 'func/page-backlinks',
+        onSuccess: finish,
+        onFailure: function () {
+            finish({ error: 'Failed to fetch backlinks' });
+        },
+    }).post({
+        page: ae.getFilename()
+    });
+}
+
+})(adminifier);
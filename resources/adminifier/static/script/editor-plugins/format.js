@@ -0,0 +1,68 @@
+(function (a) {
+
+document.addEvent('editorLoaded', loadedHandler);
+document.addEvent('pageUnloaded', unloadedHandler);
+
+var ae;
+function loadedHandler () {
+    ae = a.editor;
+
+    // add toolbar functions
+    ae.addToolbarFunctions({
+        format: formatSource
+    });
+
+    // markdown is not quiki source
+    if (isMarkdown())
+        ae.liForAction('format').addClass('disabled');
+}
+
+function unloadedHandler () {
+    document.removeEvent('editorLoaded', loadedHandler);
+    document.removeEvent('pageUnloaded', unloadedHandler);
+}
+
+function isMarkdown () {
+    return /\.md$/.test(ae.getFilename() || '');
+}
+
+// FORMAT SOURCE
+
+function formatSource () {
+    if (isMarkdown())
+        return;
+
+    // make the li stay open until finish()
+    var li = ae.liForAction('format');
+    ae.setLiLoading(li, true);
+
+    var finish = function (data) {
+        ae.setLiLoading(li, false);
+        if (!data.success) {
+            alert(data.error || 'Format failed');
+            return;
+        }
+
+        // already formatted
+        if (data.content == editor.getValue())
+            return;
+
+        // replace the content, keeping the cursor on the same line
+        var pos = editor.getCursorPosition();
+        editor.setValue(data.content, -1);
+        editor.gotoLine(pos.row + 1, pos.column);
+    };
+
+    new Request.JSON({
+        url: 'func/format-source',
+        secure: true,
+        onSuccess: finish,
+        onFailure: function () {
+            finish({ error: 'Failed to format source' });
+        },
+    }).post({
+        content: editor.getValue()
+    });
+}
+
+})(adminifier);
@@ -33,6 +33,8 @@ function openPageInNewTab () {
         return;
     var root = a.wikiPageRoot;
     var pageName = ae.getFilename().replace(/\.page$/, '');
+    if (a.branch && a.branch != 'master')
+        pageName += '?branch=' + encodeURIComponent(a.branch);
     window.open(root + '/' + pageName);
 }
 
@@ -105,12 +107,18 @@ function handleDiffClick (box, row, e) {
         
         // view on wiki
         function () {
-            alert('Unimplemented');
+            if (ae.isModel()) {
+                alert('Models cannot be viewed on the wiki');
+                return;
+            }
+            var root = a.wikiPageRoot;
+            var pageName = ae.getFilename().replace(/\.page$/, '');
+            window.open(root + '/' + pageName + '?rev=' + row.get('data-commit'));
         },
         
         // view source
         function () {
-            alert('Unimplemented');
+            displaySourceViewer(box, row.get('data-commit'), msg);
         },
         
         // diff current
@@ -145,12 +153,25 @@ function handleDiffClick (box, row, e) {
         
         // revert
         function () {
-            alert('Unimplemented');
+            if (row.getPrevious()) {
+                alert('Only the most recent change can be reverted. ' +
+                    'Use Restore to return to an earlier version.');
+                return;
+            }
+            if (!prevRow) {
+                alert('This is the oldest revision');
+                return;
+            }
+            restoreRevision(box, prevRow.get('data-commit'), "Revert '" + msg + "'");
         },
         
         // restore
         function () {
-            alert('Unimplemented');
+            if (!row.getPrevious()) {
+                alert('This is the current version');
+                return;
+            }
+            restoreRevision(box, row.get('data-commit'), "Restore '" + msg + "'");
         },
         
         // back
@@ -164,6 +185,90 @@ function handleDiffClick (box, row, e) {
     });
 }
 
+// SOURCE VIEWER
+
+function displaySourceViewer (box, commit, message) {
+    box.addClass('sticky');
+    var finish = function (data) {
+        if (!data.success) {
+            alert(data.error);
+            box.removeClass('sticky');
+            return;
+        }
+
+        // create a modal window to show the source in
+        var pre = new Element('pre', {
+            class: 'editor-revision-source',
+            text:  data.content
+        });
+        var sourceWindow = new ModalWindow({
+            icon:           'code',
+            title:          "Source at '" + message + "'",
+            padded:         true,
+            html:           pre.outerHTML,
+            width:          '90%',
+            doneText:       'Done',
+            id:             'editor-source-window',
+            autoDestroy:    true,
+            onDone:         function () {
+                setTimeout(function () { box.removeClass('sticky'); }, 100);
+            },
+        });
+        sourceWindow.show();
+    };
+
+    // request source at revision
+    var req = new Request.JSON({
+        url: 'func/page-source' + (ae.isModel() ? '?model' : ''),
+        onSuccess: finish,
+        onFailure: function () {
+            finish({ error: 'Failed to fetch page source' });
+        },
+    }).post({
+        page: ae.getFilename(),
+        commit: commit
+    });
+}
+
+// REVERT/RESTORE
+
+function restoreRevision (box, commit, message) {
+    var question = message + '?';
+    if (ae.hasUnsavedChanges())
+        question += ' Unsaved changes will be lost.';
+    if (!confirm(question))
+        return;
+
+    var finish = function (data) {
+        if (!data.success) {
+            alert(data.error || data.revError || 'Revert failed');
+            return;
+        }
+
+        // load the restored content into the editor
+        if (typeof data.content != 'undefined') {
+            editor.setValue(data.content, -1);
+            ae.lastSavedData = data.content;
+            ae.revision = data.revLatestHash;
+        }
+        ae.closePopup(box);
+    };
+
+    // write the old content as a new revision
+    var req = new Request.JSON({
+        url: 'func/revert-page' + (ae.isModel() ? '?model' : ''),
+        secure: true,
+        onSuccess: finish,
+        onFailure: function () {
+            finish({ error: 'Failed to revert page' });
+        },
+    }).post({
+        page: ae.getFilename(),
+        commit: commit,
+        message: message
+    });
+}
+
 // DIFF VIEWER
 
 function displayDiffViewer (box, from, to, message, which) {
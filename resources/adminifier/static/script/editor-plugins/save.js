@@ -10,17 +10,23 @@ function loadedHandler () {
     // add toolbar functions
     ae.addToolbarFunctions({
         save:       displaySaveHelper,
-        delete:     displayDeleteConfirmation
+        delete:     displayDeleteConfirmation,
+        move:       displayMoveHelper
     });
 
-    if (ae.isConfig())
+    if (ae.isConfig()) {
         ae.liForAction('delete').addClass('disabled');
+        ae.liForAction('move').addClass('disabled');
+    }
 
     // add keyboard shortcut
     ae.addKeyboardShortcuts([
         [ 'Ctrl-S', 'Command-S', 'save']
     ]);
     
+    // the revision the editor content is based on
+    ae.revision = a.json.revision;
+
     // start the autosave timer
     resetAutosaveInterval();
 }
@@ -131,6 +137,115 @@ function displayDeleteConfirmation () {
     ae.displayPopupBox(box, 120, li);
 }
 
+// MOVE HELPER
+
+function displayMoveHelper () {
+    if (ae.isConfig())
+        return;
+    var li  = ae.liForAction('move');
+    var box = ae.createPopupBox(li);
+    ae.fakeAdopt(box);
+
+    box.innerHTML = tmpl('tmpl-move-helper', {
+        file:   ae.getFilename(),
+        page:   ae.isPage()
+    });
+
+    var movePage = function () {
+
+        // already moving
+        var input = $('editor-move-name');
+        if (!input)
+            return;
+        var newName = input.getProperty('value').trim();
+        if (!newName.length)
+            return;
+        var redirect = $('editor-move-redirect');
+        redirect = redirect && redirect.checked;
+
+        // prevent box from closing for now
+        box.addClass('sticky');
+
+        // "moving..."
+        $('editor-move-wrapper').innerHTML = tmpl('tmpl-save-spinner', {});
+        var btn = $('editor-move-button');
+        btn.innerHTML = 'Moving file';
+        btn.addClass('progress');
+
+        var fail = function (msg) {
+            alert('Move failed: ' + msg);
+
+            // switch to /!\
+            var i = btn.parentElement.getElement('i');
+            i.removeClass('fa-spinner');
+            i.removeClass('fa-spin');
+            i.addClass('fa-exclamation-triangle');
+
+            // update button
+            btn.addClass('failure');
+            btn.removeClass('progress');
+            btn.innerHTML = 'Move failed';
+
+            setTimeout(function () {
+                ae.closePopup(box);
+            }, 1500);
+        };
+
+        var success = function (data) {
+
+            // switch to checkmark
+            var i = btn.parentElement.getElement('i');
+            i.removeClass('fa-spinner');
+            i.removeClass('fa-spin');
+            i.addClass('fa-check-circle');
+
+            // update button
+            btn.addClass('success');
+            btn.removeClass('progress');
+            btn.innerHTML = 'File moved';
+
+            // open the editor at the new location
+            setTimeout(function () {
+                var type = ae.isModel() ? 'model' : 'page';
+                window.location = a.wikiRoot + '/edit-' + type +
+                    '?page=' + encodeURIComponent(data.name);
+            }, 1500);
+        };
+
+        // move request
+        new Request.JSON({
+            url: 'func/move-' + (ae.isModel() ? 'model' : 'page'),
+            secure: true,
+            onSuccess: function (data) {
+                if (data.success)
+                    success(data);
+                else
+                    fail(data.error || 'Unknown error');
+            },
+            onError: function () {
+                fail('Bad JSON reply');
+            },
+            onFailure: function () {
+                fail('Request error');
+            },
+        }).post({
+            name:       ae.getFilename(),
+            newName:    newName,
+            redirect:   redirect ? '1' : '',
+            message:    'Move ' + ae.getFilename() + ' to ' + newName
+        });
+    };
+
+    // display it
+    if (!ae.displayPopupBox(box, ae.isPage() ? 150 : 120, li))
+        return;
+
+    // on click or enter, move
+    $('editor-move-button').addEvent('click', movePage);
+    $('editor-move-name').onEnter(movePage);
+    $('editor-move-name').focus();
+}
+
 // SAVE COMMIT HELPER
 
 function displaySaveHelper () {
@@ -240,6 +355,10 @@ function _saveHelper () {
 function autosave () {
     if (ae.currentPopup) return; // FIXME
     
+    // don't bother the user about conflicts they are still resolving
+    if (hasConflictMarkers(editor.getValue()))
+        return;
+
     // make it apparent that autosave is occurring
     var li = ae.liForAction('save');
     ae.setLiLoading(li, true, true);
@@ -264,6 +383,12 @@ function autosave () {
 
 function saveRequest (saveData, message, success, fail) {
 
+    // conflicts loaded with "Resolve in editor" have not been resolved
+    if (hasConflictMarkers(saveData)) {
+        fail('Resolve the conflicts marked with <<<<<<< yours and >>>>>>> latest first');
+        return;
+    }
+
     // do the request
     new Request.JSON({
         url: 'func/write-' + (ae.isConfig() ? 'config' : ae.isModel() ? 'model' : 'page'),
@@ -274,9 +399,28 @@ function saveRequest (saveData, message, success, fail) {
             // saved file
             if (data.success) {
                 ae.lastSavedData = saveData;
+
+                // changes made by others were merged in. if there are new
+                // changes in the editor, keep the old base revision so that
+                // the next save merges them again
+                if (data.merged) {
+                    if (!ae.hasUnsavedChanges()) {
+                        loadMergedContent(data.content);
+                        ae.revision = data.revLatestHash;
+                    }
+                }
+                else if (data.revLatestHash)
+                    ae.revision = data.revLatestHash;
+
                 success(data);
             }
 
+            // changes conflict with others made since the editor loaded
+            else if (data.conflict) {
+                displayConflictWindow(data.conflict);
+                fail('Edit conflict');
+            }
+
             // nothing changed
             else if (data.revError && data.revError.match(/no changes|nothing to commit/)) {
                 data.unchanged = true;
@@ -304,13 +448,58 @@ function saveRequest (saveData, message, success, fail) {
     }).post({
         name:       ae.getFilename(),
         content:    saveData,
-        message:    message
+        message:    message,
+        base:       ae.revision || ''
     });
 
     // reset the autosave timer
     resetAutosaveInterval();
 }
 
+// EDIT CONFLICTS
+
+// true if the content has the markers around a conflict
+var conflictMarkerRegex = /^(<<<<<<< yours|>>>>>>> latest)$/m;
+function hasConflictMarkers (content) {
+    return conflictMarkerRegex.test(content);
+}
+
+// replace the editor content, keeping the cursor where it was
+function loadMergedContent (content) {
+    var pos = editor.getCursorPosition();
+    editor.setValue(content, -1);
+    editor.moveCursorToPosition(pos);
+    editor.clearSelection();
+    ae.lastSavedData = content;
+}
+
+function displayConflictWindow (conflict) {
+    clearAutosaveInterval();
+    var conflictWindow = new ModalWindow({
+        icon:           'exclamation-triangle',
+        title:          'Edit conflict',
+        padded:         true,
+        html:           tmpl('tmpl-edit-conflict', conflict),
+        width:          '500px',
+        doneText:       'Cancel',
+        id:             'editor-conflict-window',
+        autoDestroy:    true,
+        onDone:         resetAutosaveInterval
+    });
+
+    // load the merged text with conflict markers. the next save is based on
+    // the latest revision, so it will replace the conflicting changes
+    conflictWindow.addButton('Resolve in editor', function () {
+        editor.setValue(conflict.merged, -1);
+        ae.revision = conflict.latest;
+        var line = conflict.conflicts.length ? conflict.conflicts[0].line : 1;
+        editor.gotoLine(line, 0, true);
+        conflictWindow.hide();
+    });
+
+    conflictWindow.show();
+}
+
 var autosaveInterval;
 function resetAutosaveInterval () {
     clearAutosaveInterval();
@@ -9,7 +9,9 @@ var plugins = [
     'link',
     'page-options',
     'revision',
+    'backlinks',
     'emoji',
+    'format',
 ];
 
 // PAGE EVENTS
@@ -39,6 +39,35 @@
 </pre>
 {{end}}
 
+<h2>Recent Changes</h2>
+{{if .Authors}}
+<p class="change-authors">
+    <a href="dashboard"{{if not .Author}} class="active"{{end}}>All authors</a>
+    {{- range .Authors}} &middot; <a href="dashboard?author={{.}}"{{if eq . $.Author}} class="active"{{end}}>{{.}}</a>{{end}}
+</p>
+{{end}}
+{{range .Changes}}
+<h3>
+    {{- if eq .Type "page"}}Pages
+    {{- else if eq .Type "image"}}Images
+    {{- else if eq .Type "model"}}Models
+    {{- else}}Configuration{{end -}}
+</h3>
+<pre class="info">
+{{- range .Changes -}}
+{{.Date.Format "2006-01-02 15:04"}} {{if eq .Action "delete"}}{{.File}}
+{{- else if eq .Type "page"}}<a href="edit-page?page={{.File}}">{{.File}}</a>
+{{- else if eq .Type "model"}}<a href="edit-model?page={{.File}}">{{.File}}</a>
+{{- else if eq .Type "config"}}<a href="settings">{{.File}}</a>
+{{- else}}{{.File}}{{end}}
+{{- if eq .Action "create"}} created{{else if eq .Action "delete"}} deleted{{else}} modified{{end}}
+{{- with .Author}} by {{.}}{{end}}
+{{- with .Message}}: {{.}}{{end}}
+{{end -}}
+</pre>
+{{else}}
+<p>No recent changes.</p>
+{{end}}
 
 <h2>Logs</h2>
 <pre class="info">
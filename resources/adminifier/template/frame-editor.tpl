@@ -30,7 +30,9 @@
         {{end}}{{end}}
         <li data-action="save" class="right"><i class="fa right fa-save"></i> <span>Save</span></li>
         <li data-action="delete" class="right"><i class="fa right fa-trash"></i> Delete</li>
+        <li data-action="move" class="right"><i class="fa right fa-truck"></i> Move</li>
         <li data-action="revisions" class="right"><i class="fa right fa-history"></i> Revisions</li>
+        <li data-action="backlinks" class="right"><i class="fa right fa-link"></i> Links here</li>
         <li data-action="view" class="right"><i class="fa right fa-binoculars"></i> View</li>
         <li class="hidden right" data-action="options"><i class="fa right fa-wrench"></i> Options</li>
         <li id="toolbar-redo" data-action="redo" class="right disabled"><i class="fa right fa-redo"></i> Redo</li>
@@ -58,6 +60,7 @@
         <li class="hidden" data-action="infobox"><i class="fa fa-info-circle"></i> Infobox</li>
         <li class="hidden" data-action="code"><i class="fa fa-code"></i> Code</li>
         <li class="hidden" data-action="cite"><i class="fa fa-copyright"></i> Citation</li>
+        <li class="hidden" data-action="format"><i class="fa fa-align-left"></i> Format</li>
 
     </ul>
 </div>
@@ -90,6 +93,35 @@
     <div id="editor-save-commit" class="editor-tool-large-button">Commit changes</div>
 </template>
 
+<template id="tmpl-move-helper">
+    <div id="editor-move-wrapper">
+    New name<br />
+    <input id="editor-move-name" class="editor-full-width-input" type="text" value="{%= o.file %}" />
+    {% if (o.page) { %}
+    <label><input id="editor-move-redirect" type="checkbox" checked /> Leave a redirect behind</label>
+    {% } %}
+    </div>
+    <div id="editor-move-button" class="editor-tool-large-button">Move file</div>
+</template>
+
+<template id="tmpl-edit-conflict">
+    <p>
+        This file was changed by someone else while you were editing it,
+        and {%= o.conflicts.length == 1 ? 'one change conflicts' : o.conflicts.length + ' changes conflict' %}
+        with yours. Your changes have not been saved.
+    </p>
+    <ul>
+    {% for (var i = 0; i < o.conflicts.length; i++) { %}
+        <li>Line {%= o.conflicts[i].line %}</li>
+    {% } %}
+    </ul>
+    <p>
+        Choose <b>Resolve in editor</b> to load both versions, marked
+        with <code>&lt;&lt;&lt;&lt;&lt;&lt;&lt;</code> and
+        <code>&gt;&gt;&gt;&gt;&gt;&gt;&gt;</code>, then remove the markers and save again.
+    </p>
+</template>
+
 <template id="tmpl-save-spinner">
     <div style="text-align: center;"><i class="fa fa-spinner fa-3x fa-spin center"></i></div>
 </template>
@@ -265,6 +297,19 @@
     </div>
 </template>
 
+<template id="tmpl-backlinks-viewer">
+    <div id="editor-backlinks"></div>
+</template>
+
+<template id="tmpl-backlink-row">
+    <b>{%= o.title || o.file_ne %}</b><br />
+    {%= o.file %}{%= o.lines ? ', line ' + o.lines.join(', ') : '' %}
+</template>
+
+<template id="tmpl-backlinks-none">
+    <div class="editor-backlink-row">No pages link here.</div>
+</template>
+
 <template id="tmpl-color-name">
     <span style="padding-left: 10px;">{%= o.colorName %}</span>
 </template>
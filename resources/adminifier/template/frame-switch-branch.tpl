@@ -6,6 +6,13 @@
 
 <p>Branching is experimental.</p>
 
+<p>Editing on branch <b>{{or .Branch "master"}}</b>.
+{{if and .Branch (ne .Branch "master")}}
+    Changes made here are not visible on the wiki until the branch is merged.
+    <a href="{{.WikiRoots.Page}}/{{.MainPage}}?branch={{.Branch}}" target="_blank">Preview</a>
+{{end}}
+</p>
+
 <p><b>Choose Branch</b></p>
 <ul>
 {{$root := .Root}}
@@ -18,4 +25,13 @@
 <form action="{{.Root}}/func/create-branch" method="post">
     <input type="text" name="branch" />
     <input type="submit" name="submit" value="Create" />
-</form>
\ No newline at end of file
+</form>
+
+{{if and .Branch (ne .Branch "master")}}
+<p><b>Merge Branch</b></p>
+<form action="{{.Root}}/func/merge-branch" method="post">
+    <input type="hidden" name="branch" value="{{.Branch}}" />
+    <input type="text" name="message" placeholder="Merge branch {{.Branch}}" />
+    <input type="submit" name="submit" value="Merge into master" />
+</form>
+{{end}}
@@ -7,6 +7,7 @@ var adminifier = {
     wikiShortName:  '{{.Shortcode}}',
     wikiName:       '{{.Title}}',
     wikiPageRoot:   '{{.WikiRoots.Page}}',
+    branch:         '{{or .Branch "master"}}',
     themeName:      null,
     autosave:       3000000,
     homePage:       'dashboard'
@@ -18,7 +19,7 @@ var adminifier = {
 
 <div id="top-bar">
     <span class="top-title account-title right"><a href="#"><i class="fa fa-user"></i> {{.User.DisplayName}}</a></span>
-    {{/*<span class="top-title top-button"><a class="frame-click" href="{{.Root}}/switch-branch"><i class="fab fa-git-alt"></i> {{.Branch}}</a></span>*/}}
+    <span class="top-title top-button"><a class="frame-click" href="{{.Root}}/switch-branch"><i class="fab fa-git-alt"></i> {{or .Branch "master"}}</a></span>
     <input id="top-search" type="text" placeholder="Quick Search..." />
     <span class="top-title wiki-title">{{.Title}}</span>
     <span id="page-title" class="top-title page-title"><i class="fa fa-home"></i> <span></span></span>
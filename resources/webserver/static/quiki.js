@@ -13,6 +13,12 @@ document.addEvent('domready', function () {
             loadJS("/static/ext/nanogallery2/jquery.nanogallery2.min.js");
         });
     }
+
+    // sortable tables
+    $$("table.q-table-sortable").each(sortableTable);
+
+    // tabs
+    $$(".q-tabs").each(tabs);
 });
 
 window.addEvent('hashchange', hashLoad);
@@ -25,6 +31,7 @@ function hashLoad() {
     var anchor = 'qa-' + hash;
     var el = $(anchor);
     if (el) {
+        reveal(el);
         pos = el.getPosition();
         if ($('top-bar'))
             pos.y -= $('top-bar').offsetHeight;
@@ -32,6 +39,19 @@ function hashLoad() {
     }
 }
 
+// show the tab or open the details{} containing an element
+function reveal (el) {
+    for (var parent = el; parent; parent = parent.getParent()) {
+        if (parent.get('role') == 'tabpanel' && parent.hidden) {
+            var button = $(parent.get('aria-labelledby'));
+            if (button)
+                button.fireEvent('click');
+        }
+        if (parent.get('tag') == 'details')
+            parent.open = true;
+    }
+}
+
 function loadJS (src, onLoad) {
     var script = new Element('script', { src: src });
     if (onLoad)
@@ -48,6 +68,102 @@ function loadCSS (href) {
     document.head.appendChild(link);
 }
 
+// sort a table{} by the column of the header cell clicked
+function sortableTable (table) {
+    var body = table.tBodies[0];
+    if (!table.tHead || !body)
+        return;
+
+    // rows cannot be moved around if cells span several of them
+    if (body.getElements('td[rowspan], th[rowspan]').some(function (cell) {
+        return cell.rowSpan > 1;
+    }))
+        return;
+
+    var headRow = table.tHead.rows[table.tHead.rows.length - 1];
+    var col = 0;
+    Array.each(headRow.cells, function (th) {
+        var index = col;
+        col += th.colSpan;
+        th.addEvent('click', function () {
+            var desc = th.hasClass('q-table-sorted-asc');
+            headRow.getElements('th').removeClass('q-table-sorted-asc').removeClass('q-table-sorted-desc');
+            th.addClass(desc ? 'q-table-sorted-desc' : 'q-table-sorted-asc');
+
+            var rows = Array.from(body.rows);
+            rows.sort(function (a, b) {
+                var cmp = compareCells(cellAt(a, index), cellAt(b, index));
+                return desc ? -cmp : cmp;
+            });
+            rows.each(function (row) { body.appendChild(row); });
+        });
+    });
+}
+
+// switch between the panels of a tabs{} with its buttons. the arrow keys,
+// home, and end move between buttons
+function tabs (container) {
+
+    // only this container's buttons, not those of tabs{} nested in a panel
+    var list = container.getFirst('.q-tabs-list');
+    var buttons = list ? list.getChildren('.q-tabs-tab') : [];
+
+    function select (button, focus) {
+        buttons.each(function (b) {
+            var selected = b === button,
+                panel = $(b.get('aria-controls'));
+            b.set('aria-selected', selected ? 'true' : 'false');
+            if (selected)
+                b.removeAttribute('tabindex');
+            else
+                b.set('tabindex', '-1');
+            if (panel)
+                panel.hidden = !selected;
+        });
+        if (focus)
+            button.focus();
+    }
+
+    buttons.each(function (button, i) {
+        button.addEvent('click', function () {
+            select(button);
+        });
+        button.addEvent('keydown', function (e) {
+            var next;
+            switch (e.event.key) {
+                case 'ArrowRight': next = buttons[(i + 1) % buttons.length];                  break;
+                case 'ArrowLeft':  next = buttons[(i - 1 + buttons.length) % buttons.length]; break;
+                case 'Home':       next = buttons[0];                                         break;
+                case 'End':        next = buttons[buttons.length - 1];                        break;
+            }
+            if (!next)
+                return;
+            e.preventDefault();
+            select(next, true);
+        });
+    });
+}
+
+// find the cell of a row at a column, accounting for colspan
+function cellAt (row, index) {
+    var col = 0;
+    for (var i = 0; i < row.cells.length; i++) {
+        col += row.cells[i].colSpan;
+        if (col > index)
+            return row.cells[i].get('text').trim();
+    }
+    return '';
+}
+
+// compare cell text as numbers if both look like numbers, otherwise as text
+function compareCells (a, b) {
+    var numA = parseFloat(a.replace(/[^0-9.\-]/g, '')),
+        numB = parseFloat(b.replace(/[^0-9.\-]/g, ''));
+    if (!isNaN(numA) && !isNaN(numB) && /\d/.test(a) && /\d/.test(b))
+        return numA - numB;
+    return a.localeCompare(b);
+}
+
 // javascript image sizing
 exports.imageResize = function (img) {
     img.parentElement.parentElement.setStyle('width', img.offsetWidth + 'px');
@@ -11,9 +11,13 @@
 {{with .Author}}
     <meta name="author" content="{{.}}" />
 {{end}}
+{{.Meta}}
     <title>{{.VisibleTitle}}</title>
     <link rel="stylesheet" type="text/css" href="{{.StaticRoot}}/style.css" />
     <link rel="stylesheet" type="text/css" href="/static/quiki.css" />
+{{range .Feeds}}
+    <link rel="alternate" type="{{.Type}}" title="{{.Title}}" href="{{.Link}}" />
+{{end}}
 {{with .PageCSS}}
     <style>
 {{.}}
@@ -32,6 +36,13 @@
             {{range .Navigation}}
                 <li><a href="{{.Link}}">{{.Display}}</a></li>
             {{end}}
+            {{with .SearchRoot}}
+                <li class="search-nav">
+                    <form action="{{.}}" method="get">
+                        <input type="search" name="q" placeholder="Search" />
+                    </form>
+                </li>
+            {{end}}
         </ul>
         <a href="{{.Root.Wiki}}/">
             {{if .WikiLogo}}
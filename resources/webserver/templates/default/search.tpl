@@ -0,0 +1,22 @@
+{{template "header.tpl" .}}
+<div class="search">
+    <form class="search-form" action="{{.SearchRoot}}" method="get">
+        <input type="search" name="q" value="{{.Query}}" placeholder="Search {{.WikiTitle}}" autofocus />
+        <input type="submit" value="Search" />
+    </form>
+{{if .Query}}
+    {{with .SearchResults}}
+        <ol class="search-results">
+        {{range .}}
+            <li>
+                <a href="{{.Link}}">{{.Title}}</a>
+                <p>{{.Snippet}}</p>
+            </li>
+        {{end}}
+        </ol>
+    {{else}}
+        <p class="search-none">No pages matched your search.</p>
+    {{end}}
+{{end}}
+</div>
+{{template "footer.tpl" .}}
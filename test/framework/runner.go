@@ -11,6 +11,12 @@ import (
 	"time"
 )
 
+var mdMainRegex = regexp.MustCompile(`<div class="q-main-\S* q-main">`)
+
+// matches warnings about the input logged by quiki, e.g.
+// 2006/01/02 15:04:05 :3:5: model{}: message
+var warningRegex = regexp.MustCompile(`(?m)^\S+ \S+ :(\d+:\d+: .*)$`)
+
 // TestCase represents a single formatter test
 type TestCase struct {
 	Name        string   `json:"name"`
@@ -19,13 +25,21 @@ type TestCase struct {
 	Description string   `json:"description"`
 	Skip        bool     `json:"skip,omitempty"`
 	Tags        []string `json:"tags,omitempty"`
+
+	// expected warnings about the input, as line:column: message.
+	// if empty, warnings are not checked
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 // TestSuite groups related test cases
 type TestSuite struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
+	Markdown    bool       `json:"markdown,omitempty"`
 	Tests       []TestCase `json:"tests"`
+
+	// files to create in the directory quiki runs in, such as models
+	Files map[string]string `json:"files,omitempty"`
 }
 
 // TestResult holds the outcome of a test
@@ -85,6 +99,21 @@ func (r *Runner) RunSuite(suite *TestSuite) *SuiteResult {
 		Results: make([]TestResult, 0, len(suite.Tests)),
 	}
 
+	// create the files
+	dir := ""
+	if len(suite.Files) != 0 {
+		var err error
+		if dir, err = os.MkdirTemp("", "quiki-test"); err == nil {
+			defer os.RemoveAll(dir)
+			err = writeFiles(dir, suite.Files)
+		}
+		if err != nil {
+			result.Results = append(result.Results, TestResult{Name: suite.Name, Error: err.Error()})
+			result.Failed++
+			return result
+		}
+	}
+
 	for _, test := range suite.Tests {
 		if test.Skip {
 			result.Skipped++
@@ -96,7 +125,7 @@ func (r *Runner) RunSuite(suite *TestSuite) *SuiteResult {
 			continue
 		}
 
-		testResult := r.runTest(test)
+		testResult := r.runTest(test, suite.Markdown, dir)
 		result.Results = append(result.Results, testResult)
 
 		if testResult.Passed {
@@ -110,8 +139,23 @@ func (r *Runner) RunSuite(suite *TestSuite) *SuiteResult {
 	return result
 }
 
-// runTest executes a single test case
-func (r *Runner) runTest(test TestCase) TestResult {
+// writeFiles writes files relative to a directory
+func writeFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("creating %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// runTest executes a single test case, with markdown input if specified.
+// if dir is not empty, quiki runs in that directory
+func (r *Runner) runTest(test TestCase, markdown bool, dir string) TestResult {
 	start := time.Now()
 	result := TestResult{
 		Name:     test.Name,
@@ -123,16 +167,53 @@ func (r *Runner) runTest(test TestCase) TestResult {
 	cmd := exec.Command(r.QuikiPath, "-i")
 	cmd.Stdin = strings.NewReader(test.Input)
 
+	// markdown is detected by file extension, so write it to a file
+	if markdown {
+		dir, err := os.MkdirTemp("", "quiki-test")
+		if err != nil {
+			result.Error = fmt.Sprintf("creating temp dir: %v", err)
+			return result
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "test.md")
+		if err := os.WriteFile(path, []byte(test.Input), 0644); err != nil {
+			result.Error = fmt.Sprintf("writing %s: %v", path, err)
+			return result
+		}
+		cmd = exec.Command(r.QuikiPath, path)
+	}
+
+	var stderr strings.Builder
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
 	output, err := cmd.Output()
 	if err != nil {
 		result.Error = fmt.Sprintf("command failed: %v", err)
 		return result
 	}
 
+	// markdown pages have a class derived from the file path
+	if markdown {
+		output = mdMainRegex.ReplaceAll(output, []byte(`<div class="q-main">`))
+	}
+
 	// extract just the content from the html output
 	actual := r.extractContent(string(output))
 	result.Actual = actual
 	result.Passed = (actual == test.Expected)
+
+	// compare warnings too
+	if len(test.Warnings) != 0 {
+		var warnings []string
+		for _, match := range warningRegex.FindAllStringSubmatch(stderr.String(), -1) {
+			warnings = append(warnings, match[1])
+		}
+		if strings.Join(warnings, "\n") != strings.Join(test.Warnings, "\n") {
+			result.Passed = false
+			result.Error = fmt.Sprintf("warnings: %q, expected %q", warnings, test.Warnings)
+		}
+	}
+
 	result.Duration = time.Since(start)
 
 	return result
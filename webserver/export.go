@@ -0,0 +1,362 @@
+package webserver
+
+// export.go - render a wiki as a static site
+
+import (
+	"html"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cooper/quiki/resources"
+	"github.com/cooper/quiki/wiki"
+	"github.com/pkg/errors"
+)
+
+// Export renders a wiki as a static site in outDir, suitable for hosting
+// without quiki.
+//
+// Each published page and category is rendered through the wiki's template
+// into an HTML file. Images in the sizes used by those pages are generated
+// and copied along with the template's static files. Links between them are
+// rewritten as relative URLs, so the site can be served from any path or
+// browsed directly from the filesystem.
+//
+// Features which require the server, such as search, feeds, and login, are
+// omitted. Link preview tags need absolute URLs, so they include URLs only
+// if root.ext is a full URL, like https://example.com.
+func Export(w *wiki.Wiki, outDir string) error {
+
+	// find templates, unless the server has already done so
+	if len(templateFses) == 0 {
+		if err := addEmbeddedTemplates(); err != nil {
+			return err
+		}
+	}
+
+	wi := &WikiInfo{Wiki: w, Name: filepath.Base(w.Dir()), Title: w.Opt.Name}
+	if err := loadWikiTemplate(wi); err != nil {
+		return errors.Wrap(err, "load template")
+	}
+	generateWikiLogo(wi)
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+
+	e := &exporter{wi: wi, outDir: outDir, images: make(map[string]bool)}
+
+	// pages
+	for _, name := range w.AllPageFiles() {
+		e.exportPage(name)
+	}
+
+	// main page at the wiki root
+	if mainPage := w.Opt.MainPage; mainPage != "" {
+		mainURL := e.pageURL(strings.TrimSuffix(mainPage, filepath.Ext(mainPage)))
+		if w.Opt.MainRedirect {
+			e.writeRedirect(wi.Opt.Root.Wiki+"/", mainURL)
+		} else {
+			e.exportPageAt(wi.Opt.Root.Wiki+"/", mainPage)
+		}
+	}
+
+	// categories, one file per page of posts
+	for _, cat := range w.Categories() {
+		e.exportCategory(cat.Name)
+	}
+
+	// images used by the above
+	e.exportImages()
+
+	// static files
+	for dir, fsys := range e.staticFses() {
+		if err := copyFS(fsys, filepath.Join(outDir, filepath.FromSlash(e.filePath(dir)))); err != nil {
+			return errors.Wrap(err, "copy static files")
+		}
+	}
+
+	log.Printf("[%s] exported %d files to %s", wi.Name, e.nFiles, outDir)
+	return nil
+}
+
+type exporter struct {
+	wi     *WikiInfo
+	outDir string
+	images map[string]bool // image names referenced by exported pages
+	nFiles int             // number of files written
+}
+
+func (e *exporter) pageURL(name string) string {
+	return e.wi.Opt.Root.Page + "/" + name
+}
+
+// export a page at its usual location
+func (e *exporter) exportPage(name string) {
+	e.exportPageAt(e.pageURL(strings.TrimSuffix(name, filepath.Ext(name))), name)
+}
+
+// export a page at the given URL path
+func (e *exporter) exportPageAt(urlPath, name string) {
+	switch res := e.wi.DisplayPage(name).(type) {
+	case wiki.DisplayPage:
+		e.writeTemplate(urlPath, "page", wikiPageFromRes(e.wi, res))
+	case wiki.DisplayRedirect:
+		e.writeRedirect(urlPath, res.Redirect)
+	case wiki.DisplayError:
+		if !res.Draft {
+			log.Printf("[%s] export %s: %s", e.wi.Name, name, res.Error)
+		}
+	}
+}
+
+// export each page of a category's posts
+func (e *exporter) exportCategory(catName string) {
+	catURL := e.wi.Opt.Root.Category + "/" + catName
+	for pageN := 0; ; pageN++ {
+		res, ok := e.wi.DisplayCategoryPosts(catName, pageN).(wiki.DisplayCategoryPosts)
+		if !ok {
+			return
+		}
+		page := wikiPageFromPostsRes(e.wi, res)
+
+		// the first page is at both the category root and /1
+		if pageN == 0 {
+			e.writeTemplate(catURL, "posts", page)
+		}
+		e.writeTemplate(catURL+"/"+strconv.Itoa(pageN+1), "posts", page)
+
+		if pageN >= res.NumPages-1 {
+			return
+		}
+	}
+}
+
+// render a template and write it at the given URL path
+func (e *exporter) writeTemplate(urlPath, templateName string, page wikiPage) {
+
+	// these require the server
+	page.SearchRoot = ""
+	page.Feeds = nil
+
+	// link previews need absolute URLs, which are known only from root.ext.
+	// without them, the URLs are left out
+	page.ExtRoot = externalRoot(e.wi, nil)
+
+	// and the page image, which is not linked elsewhere
+	if img := page.Image; img != nil && strings.HasPrefix(img.Path, e.wi.Opt.Root.Image+"/") {
+		e.images[strings.TrimPrefix(img.Path, e.wi.Opt.Root.Image+"/")] = true
+	}
+
+	content, err := executeTemplate(e.wi, templateName, page)
+	if err != nil {
+		log.Printf("[%s] export %s: %s", e.wi.Name, urlPath, err)
+		return
+	}
+	e.writeHTML(urlPath, content)
+}
+
+// write a page which redirects to another URL
+func (e *exporter) writeRedirect(urlPath, target string) {
+	target = html.EscapeString(e.relativeURL(path.Dir(e.filePath(urlPath)), target))
+	e.writeHTML(urlPath, []byte(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8" />
+    <meta http-equiv="refresh" content="0; url=`+target+`" />
+    <link rel="canonical" href="`+target+`" />
+</head>
+<body>
+    <a href="`+target+`">Redirect</a>
+</body>
+</html>
+`))
+}
+
+// write HTML at the given URL path with links rewritten
+func (e *exporter) writeHTML(urlPath string, content []byte) {
+	file := e.filePath(urlPath)
+	content = e.rewriteLinks(file, content)
+	outPath := filepath.Join(e.outDir, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		log.Printf("[%s] export %s: %s", e.wi.Name, urlPath, err)
+		return
+	}
+	if err := os.WriteFile(outPath, content, 0644); err != nil {
+		log.Printf("[%s] export %s: %s", e.wi.Name, urlPath, err)
+		return
+	}
+	e.nFiles++
+}
+
+// generate and copy each image referenced by exported pages
+func (e *exporter) exportImages() {
+	for name := range e.images {
+		res := e.wi.DisplaySizedImageGenerate(wiki.SizedImageFromName(name), true)
+
+		// the image is served in other dimensions; copy that file instead
+		if redir, ok := res.(wiki.DisplayRedirect); ok {
+			trueName := path.Join(path.Dir(name), redir.Redirect)
+			res = e.wi.DisplaySizedImageGenerate(wiki.SizedImageFromName(trueName), true)
+		}
+
+		img, ok := res.(wiki.DisplayImage)
+		if !ok {
+			log.Printf("[%s] export image %s: %+v", e.wi.Name, name, res)
+			continue
+		}
+		outPath := filepath.Join(e.outDir, filepath.FromSlash(e.filePath(e.wi.Opt.Root.Image+"/"+name)))
+		if err := copyFile(img.Path, outPath); err != nil {
+			log.Printf("[%s] export image %s: %s", e.wi.Name, name, err)
+			continue
+		}
+		e.nFiles++
+	}
+}
+
+// staticFses returns the static file directories to export by URL path
+func (e *exporter) staticFses() map[string]fs.FS {
+	fses := make(map[string]fs.FS)
+	if sub, err := fs.Sub(resources.Webserver, "static"); err == nil {
+		fses["/static"] = sub
+	}
+	if sub, err := fs.Sub(resources.Shared, "static"); err == nil {
+		fses["/shared"] = sub
+	}
+	if t := e.wi.template; t.staticFs != nil {
+		fses[t.staticRoot] = t.staticFs
+	}
+	return fses
+}
+
+// filePath returns the path of the file relative to the output directory
+// for a server-relative URL path. pages and categories become .html files
+func (e *exporter) filePath(urlPath string) string {
+	root := e.wi.Opt.Root
+	urlPath = "/" + strings.Trim(urlPath, "/")
+
+	// files which are served as-is
+	asIs := false
+	for _, pfx := range []string{root.Image, root.File, "/static", "/shared", e.wi.template.staticRoot} {
+		if pfx != "" && (urlPath == pfx || strings.HasPrefix(urlPath, pfx+"/")) {
+			asIs = true
+			break
+		}
+	}
+
+	// relative to the wiki root
+	if wikiRoot := strings.TrimSuffix(root.Wiki, "/"); wikiRoot != "" && (urlPath == wikiRoot || strings.HasPrefix(urlPath, wikiRoot+"/")) {
+		urlPath = "/" + strings.TrimPrefix(urlPath[len(wikiRoot):], "/")
+	}
+	if asIs {
+		return strings.TrimPrefix(urlPath, "/")
+	}
+
+	// the wiki root
+	if urlPath == "/" {
+		return "index.html"
+	}
+
+	return strings.TrimPrefix(urlPath, "/") + ".html"
+}
+
+var exportLinkRegex = regexp.MustCompile(`(\s(?:href|src|srcset|action)=")([^"]*)(")`)
+
+// rewriteLinks rewrites server-relative URLs in HTML as relative to file.
+// images are recorded so they can be exported too
+func (e *exporter) rewriteLinks(file string, content []byte) []byte {
+	dir := path.Dir(file)
+	return exportLinkRegex.ReplaceAllFunc(content, func(match []byte) []byte {
+		parts := exportLinkRegex.FindSubmatch(match)
+		value := html.UnescapeString(string(parts[2]))
+
+		// srcset has a list of URLs, each followed by a descriptor
+		var urls []string
+		if strings.Contains(string(parts[1]), "srcset") {
+			urls = strings.Split(value, ",")
+		} else {
+			urls = []string{value}
+		}
+		for i, candidate := range urls {
+			fields := strings.Fields(candidate)
+			if len(fields) == 0 {
+				continue
+			}
+			fields[0] = e.relativeURL(dir, fields[0])
+			urls[i] = strings.Join(fields, " ")
+		}
+
+		return []byte(string(parts[1]) + html.EscapeString(strings.Join(urls, ", ")) + string(parts[3]))
+	})
+}
+
+// relativeURL returns a URL relative to dir for a server-relative URL.
+// other URLs are returned unchanged
+func (e *exporter) relativeURL(dir, link string) string {
+	if !strings.HasPrefix(link, "/") || strings.HasPrefix(link, "//") {
+		return link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	// remember images
+	if imageRoot := e.wi.Opt.Root.Image + "/"; strings.HasPrefix(u.Path, imageRoot) {
+		e.images[strings.TrimPrefix(u.Path, imageRoot)] = true
+	}
+
+	rel, err := filepath.Rel(filepath.FromSlash(dir), filepath.FromSlash(e.filePath(u.Path)))
+	if err != nil {
+		return link
+	}
+
+	// the query is meaningless without the server, but keep the fragment
+	return (&url.URL{Path: filepath.ToSlash(rel), Fragment: u.Fragment}).String()
+}
+
+// copyFS copies all files in fsys to dir
+func copyFS(fsys fs.FS, dir string) error {
+	return fs.WalkDir(fsys, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		outPath := filepath.Join(dir, filepath.FromSlash(filePath))
+		if d.IsDir() {
+			return os.MkdirAll(outPath, 0755)
+		}
+		content, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(outPath, content, 0644)
+	})
+}
+
+// copyFile copies a file, creating its directory if needed
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
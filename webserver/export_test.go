@@ -0,0 +1,149 @@
+package webserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// newExportTestWiki creates a wiki with pages in a subdirectory, a category,
+// a draft, and a redirect, with the usual roots
+func newExportTestWiki(t *testing.T) *WikiInfo {
+	t.Helper()
+	wi := newTestWikiInfo(t, map[string]string{
+		"main_page.page":  "@page.title: Main;\nSee [[ Other ]] and [[ Nested | sub/nested ]].\n",
+		"other.page":      "@page.title: Other;\n@category.news;\nother\n",
+		"sub/nested.page": "@page.title: Nested;\n[[ Other | /other ]] [[ Section | /other#top ]]\n",
+		"draft.page":      "@page.draft;\nsecret\n",
+		"moved.page":      "@page.redirect: /other;\n",
+	})
+	wi.Opt.Root.Page = "/page"
+	wi.Opt.MainPage = "main_page"
+	return wi
+}
+
+// readExport reads an exported file, failing the test if it does not exist
+func readExport(t *testing.T, out, file string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestExport(t *testing.T) {
+	wi := newExportTestWiki(t)
+	out := t.TempDir()
+	if err := Export(wi.Wiki, out); err != nil {
+		t.Fatal(err)
+	}
+
+	// pages, the main page at the root, and both forms of category posts
+	for _, file := range []string{
+		"index.html", "page/main_page.html", "page/other.html", "page/sub/nested.html",
+		"page/moved.html", "topic/news.html", "topic/news/1.html", "static/quiki.css",
+	} {
+		if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(file))); err != nil {
+			t.Errorf("%s was not exported", file)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "page", "draft.html")); err == nil {
+		t.Error("the draft was exported")
+	}
+
+	// links are relative to each file
+	for file, links := range map[string][]string{
+		"index.html": {
+			`href="page/other.html"`, `href="page/sub/nested.html"`, `href="static/quiki.css"`,
+		},
+		"page/sub/nested.html": {
+			`href="../other.html"`, `href="../other.html#top"`, `href="../../index.html"`, `href="../../static/quiki.css"`,
+		},
+		"page/moved.html": {
+			`url=other.html"`, `href="other.html"`,
+		},
+	} {
+		content := readExport(t, out, file)
+		for _, link := range links {
+			if !strings.Contains(content, link) {
+				t.Errorf("%s does not contain %s", file, link)
+			}
+		}
+	}
+
+	// without root.ext, there are no absolute URLs to describe the page
+	content := readExport(t, out, "page/other.html")
+	if strings.Contains(content, "og:url") || strings.Contains(content, `"url"`) || strings.Contains(content, "://example") {
+		t.Errorf("page/other.html has URLs without root.ext:\n%s", content)
+	}
+}
+
+func TestExportExternalRoot(t *testing.T) {
+	wi := newExportTestWiki(t)
+	wi.Opt.Root.Ext = "https://wiki.example.com"
+	out := t.TempDir()
+	if err := Export(wi.Wiki, out); err != nil {
+		t.Fatal(err)
+	}
+
+	// absolute URLs are left as they are
+	content := readExport(t, out, "page/other.html")
+	for _, want := range []string{
+		`<meta property="og:url" content="https://wiki.example.com/page/other" />`,
+		`"url":"https://wiki.example.com/page/other"`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("page/other.html does not contain %s", want)
+		}
+	}
+}
+
+func TestExportRelativeURL(t *testing.T) {
+	e := &exporter{wi: newExportTestWiki(t), images: make(map[string]bool)}
+	for _, test := range []struct{ dir, link, want string }{
+		{".", "/page/a", "page/a.html"},
+		{"page/sub", "/page/a", "../a.html"},
+		{"page", "/page/a?rev=abc#section", "a.html#section"},
+		{"page", "/images/200x100-cat.png", "../images/200x100-cat.png"},
+		{"page", "/", "../index.html"},
+		{"page", "https://example.com/", "https://example.com/"},
+		{"page", "//example.com/a", "//example.com/a"},
+		{"page", "relative", "relative"},
+	} {
+		if got := e.relativeURL(test.dir, test.link); got != test.want {
+			t.Errorf("relativeURL(%q, %q) = %q, want %q", test.dir, test.link, got, test.want)
+		}
+	}
+
+	// images are remembered for export
+	if !e.images["200x100-cat.png"] {
+		t.Errorf("images = %v", e.images)
+	}
+
+	// each URL in srcset is rewritten
+	got := string(e.rewriteLinks("page/a.html", []byte(`<img src="/images/a.png" srcset="/images/a.png 1x, /images/a@2x.png 2x" />`)))
+	if want := `<img src="../images/a.png" srcset="../images/a.png 1x, ../images/a@2x.png 2x" />`; got != want {
+		t.Errorf("rewriteLinks = %s, want %s", got, want)
+	}
+}
+
+func TestCopyFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.css":         {Data: []byte("a")},
+		"ext/b.js":      {Data: []byte("b")},
+		"ext/deep/c.js": {Data: []byte("c")},
+	}
+	dir := filepath.Join(t.TempDir(), "static")
+	if err := copyFS(fsys, dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, file := range fsys {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || string(got) != string(file.Data) {
+			t.Errorf("%s = %q, %v", name, got, err)
+		}
+	}
+}
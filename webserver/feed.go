@@ -0,0 +1,283 @@
+package webserver
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cooper/quiki/wiki"
+)
+
+// feed formats and their file extensions
+var feedTypes = map[string]string{
+	".atom": "application/atom+xml",
+	".rss":  "application/rss+xml",
+}
+
+// feedLink is a link to a feed for the alternate links in templates
+type feedLink struct {
+	Title string // feed title
+	Type  string // MIME type
+	Link  string // path to feed
+}
+
+// feedFormat returns the feed extension of a path, if any
+func feedFormat(relPath string) string {
+	for ext := range feedTypes {
+		if strings.HasSuffix(relPath, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// category feed request, e.g. /topic/news.atom
+func handleCategoryFeed(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+	// check if authentication is required
+	if !requireAuth(wi, w, r) {
+		return // redirected to login
+	}
+
+	ext := feedFormat(relPath)
+	catName := strings.TrimSuffix(relPath, ext)
+	feed, err := wi.CategoryFeed(catName, 0)
+	if err != nil {
+		handleError(wi, "Category does not exist.", w, r)
+		return
+	}
+	self := wi.Opt.Root.Category + "/" + relPath
+	serveFeed(wi, feed, ext, self, wi.Opt.Root.Category+"/"+catName, w, r)
+}
+
+// recent changes feed request, e.g. /changes.atom
+func handleChangesFeed(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+	// check if authentication is required
+	if !requireAuth(wi, w, r) {
+		return // redirected to login
+	}
+
+	feed, err := wi.RecentChangesFeed(0)
+	if err != nil {
+		handleError(wi, wiki.DisplayError{Error: "Recent changes are unavailable.", DetailedError: err.Error(), Status: http.StatusInternalServerError}, w, r)
+		return
+	}
+	feed.Title = wi.Title + " - " + feed.Title
+	serveFeed(wi, feed, feedFormat(relPath), wi.Opt.Root.Wiki+"/"+relPath, wi.Opt.Root.Wiki+"/", w, r)
+}
+
+// feedLinks returns links to the feeds relevant to every page
+func feedLinks(wi *WikiInfo) []feedLink {
+	return []feedLink{{
+		Title: wi.Title + " - Recent changes",
+		Type:  feedTypes[".atom"],
+		Link:  wi.Opt.Root.Wiki + "/changes.atom",
+	}}
+}
+
+// serveFeed writes a feed as Atom or RSS, depending on ext. self is the path
+// of the feed itself, and alternate is the path of the HTML equivalent
+func serveFeed(wi *WikiInfo, feed *wiki.Feed, ext, self, alternate string, w http.ResponseWriter, r *http.Request) {
+	base := externalURL(wi, r, "")
+	pageURL := func(entry wiki.FeedEntry) string {
+		if entry.Action == "delete" {
+			return ""
+		}
+		return base + wi.Opt.Root.Page + "/" + entry.Page
+	}
+
+	var doc any
+	if ext == ".rss" {
+		channel := rssChannel{
+			Title:       feed.Title,
+			Link:        base + alternate,
+			Description: feed.Title,
+			AtomLink:    atomLink{Rel: "self", Type: feedTypes[ext], Href: base + self},
+		}
+		if !feed.Updated.IsZero() {
+			channel.LastBuildDate = feed.Updated.Format(time.RFC1123Z)
+		}
+		for _, entry := range feed.Entries {
+			item := rssItem{
+				Title:       entry.Title,
+				Link:        pageURL(entry),
+				Guid:        rssGuid{Value: feedEntryId(base, wi, entry)},
+				Creator:     entry.Author,
+				Description: entry.Summary,
+				PubDate:     entryPublished(entry).Format(time.RFC1123Z),
+			}
+			if entry.Content != "" {
+				item.Description = string(entry.Content)
+			}
+			channel.Items = append(channel.Items, item)
+		}
+		doc = rssFeed{Version: "2.0", XmlnsAtom: atomNS, XmlnsDC: dcNS, Channel: channel}
+	} else {
+		atom := atomFeed{
+			Xmlns:   atomNS,
+			Id:      base + self,
+			Title:   feed.Title,
+			Updated: atomTime(feed.Updated),
+			Links: []atomLink{
+				{Rel: "self", Type: feedTypes[ext], Href: base + self},
+				{Rel: "alternate", Type: "text/html", Href: base + alternate},
+			},
+		}
+		for _, entry := range feed.Entries {
+			ae := atomEntry{
+				Id:      feedEntryId(base, wi, entry),
+				Title:   entry.Title,
+				Updated: atomTime(entry.Updated),
+				Summary: entry.Summary,
+			}
+			if !entry.Published.IsZero() {
+				ae.Published = atomTime(entry.Published)
+			}
+			if entry.Author != "" {
+				ae.Author = &atomPerson{Name: entry.Author}
+			}
+			if link := pageURL(entry); link != "" {
+				ae.Links = []atomLink{{Rel: "alternate", Type: "text/html", Href: link}}
+			}
+			if entry.Content != "" {
+				ae.Content = &atomContent{Type: "html", Base: pageURL(entry), Value: string(entry.Content)}
+			}
+			atom.Entries = append(atom.Entries, ae)
+		}
+
+		// atom requires an author; use the wiki title if entries lack one
+		for _, ae := range atom.Entries {
+			if ae.Author == nil {
+				atom.Author = &atomPerson{Name: wi.Title}
+				break
+			}
+		}
+		doc = atom
+	}
+
+	serveXML(doc, feedTypes[ext], w)
+}
+
+// externalURL returns the absolute URL for a server-relative path, based on
+// root.ext or the request if that is not a full URL
+func externalURL(wi *WikiInfo, r *http.Request, path string) string {
+	return externalRoot(wi, r) + path
+}
+
+// externalRoot returns the scheme and host for absolute URLs, based on
+// root.ext or the request if that is not a full URL. without a request,
+// such as when exporting, it is empty unless root.ext is a full URL
+func externalRoot(wi *WikiInfo, r *http.Request) string {
+	if u, err := url.Parse(wi.Opt.Root.Ext); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Scheme + "://" + u.Host
+	}
+	if r == nil {
+		return ""
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// feedEntryId returns a permanent identifier for a feed entry: the page URL,
+// and for recent changes, the revision
+func feedEntryId(base string, wi *WikiInfo, entry wiki.FeedEntry) string {
+	id := base + wi.Opt.Root.Page + "/" + entry.Page
+	if entry.Revision != "" {
+		id += "?rev=" + entry.Revision
+	}
+	return id
+}
+
+// entryPublished returns the published time of an entry, or the updated
+// time if unknown
+func entryPublished(entry wiki.FeedEntry) time.Time {
+	if entry.Published.IsZero() {
+		return entry.Updated
+	}
+	return entry.Published
+}
+
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+const (
+	atomNS = "http://www.w3.org/2005/Atom"
+	dcNS   = "http://purl.org/dc/elements/1.1/"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  *atomPerson `xml:"author,omitempty"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Id        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published,omitempty"`
+	Author    *atomPerson  `xml:"author,omitempty"`
+	Links     []atomLink   `xml:"link"`
+	Summary   string       `xml:"summary,omitempty"`
+	Content   *atomContent `xml:"content,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Base  string `xml:"xml:base,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	XmlnsAtom string     `xml:"xmlns:atom,attr"`
+	XmlnsDC   string     `xml:"xmlns:dc,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	Guid        rssGuid `xml:"guid"`
+	Creator     string  `xml:"dc:creator,omitempty"`
+	Description string  `xml:"description,omitempty"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
@@ -0,0 +1,146 @@
+package webserver
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cooper/quiki/wiki"
+)
+
+// testFeed has a page entry with content and a deleted page entry
+func testFeed() *wiki.Feed {
+	updated := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	return &wiki.Feed{
+		Title:   "News & <Updates>",
+		Updated: updated,
+		Entries: []wiki.FeedEntry{
+			{
+				Title:     "First & <best>",
+				Page:      "first",
+				Author:    "alice",
+				Published: updated.Add(-time.Hour),
+				Updated:   updated,
+				Summary:   "summary",
+				Content:   "<p>Hello &amp; welcome</p>",
+			},
+			{
+				Title:    "Deleted old",
+				Page:     "old",
+				Revision: "abc123",
+				Action:   "delete",
+				Updated:  updated.Add(-2 * time.Hour),
+			},
+		},
+	}
+}
+
+func TestServeAtomFeed(t *testing.T) {
+	wi := newTestWikiInfo(t, nil)
+	wi.Title = "Test"
+	rec := httptest.NewRecorder()
+	serveFeed(wi, testFeed(), ".atom", "/news.atom", "/news", rec, httptest.NewRequest("GET", "http://example.com/news.atom", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/atom+xml; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	page := "http://example.com" + wi.Opt.Root.Page
+	if feed.Title != "News & <Updates>" || feed.Id != "http://example.com/news.atom" || feed.Updated != "2024-05-06T07:08:09Z" {
+		t.Errorf("feed = %q, %q, %q", feed.Title, feed.Id, feed.Updated)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(feed.Entries))
+	}
+
+	// the wiki title is the author of entries which have none
+	if feed.Author == nil || feed.Author.Name != "Test" {
+		t.Errorf("feed author = %+v", feed.Author)
+	}
+
+	first := feed.Entries[0]
+	if first.Id != page+"/first" || first.Title != "First & <best>" || first.Published != "2024-05-06T06:08:09Z" {
+		t.Errorf("first entry = %+v", first)
+	}
+	if len(first.Links) != 1 || first.Links[0].Href != page+"/first" {
+		t.Errorf("first entry links = %+v", first.Links)
+	}
+	if first.Content == nil || first.Content.Type != "html" || first.Content.Value != "<p>Hello &amp; welcome</p>" {
+		t.Errorf("first entry content = %+v", first.Content)
+	}
+
+	// deleted pages have no link, and revisions make the id unique
+	deleted := feed.Entries[1]
+	if deleted.Id != page+"/old?rev=abc123" || len(deleted.Links) != 0 || deleted.Content != nil {
+		t.Errorf("deleted entry = %+v", deleted)
+	}
+}
+
+func TestServeRSSFeed(t *testing.T) {
+	wi := newTestWikiInfo(t, nil)
+	wi.Opt.Root.Ext = "https://wiki.example.com/ignored/path"
+	rec := httptest.NewRecorder()
+	serveFeed(wi, testFeed(), ".rss", "/news.rss", "/news", rec, httptest.NewRequest("GET", "http://localhost/news.rss", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/rss+xml; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/">`) {
+		t.Errorf("missing rss element with namespaces:\n%s", body)
+	}
+
+	var feed struct {
+		Channel struct {
+			Title         string `xml:"title"`
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title       string `xml:"title"`
+				Link        string `xml:"link"`
+				Guid        string `xml:"guid"`
+				Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				Description string `xml:"description"`
+				PubDate     string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	// root.ext is used rather than the request
+	ch := feed.Channel
+	page := "https://wiki.example.com" + wi.Opt.Root.Page
+	if ch.Title != "News & <Updates>" || ch.LastBuildDate != "Mon, 06 May 2024 07:08:09 +0000" {
+		t.Errorf("channel = %q, %q", ch.Title, ch.LastBuildDate)
+	}
+	for _, want := range []string{
+		"<link>https://wiki.example.com/news</link>",
+		`<atom:link rel="self" type="application/rss+xml" href="https://wiki.example.com/news.rss"></atom:link>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if len(ch.Items) != 2 {
+		t.Fatalf("%d items, want 2", len(ch.Items))
+	}
+
+	// the content is preferred to the summary
+	first := ch.Items[0]
+	if first.Link != page+"/first" || first.Guid != page+"/first" || first.Creator != "alice" ||
+		first.Description != "<p>Hello &amp; welcome</p>" || first.PubDate != "Mon, 06 May 2024 06:08:09 +0000" {
+		t.Errorf("first item = %+v", first)
+	}
+
+	// without a published time, the updated time is used
+	deleted := ch.Items[1]
+	if deleted.Link != "" || deleted.Guid != page+"/old?rev=abc123" || deleted.PubDate != "Mon, 06 May 2024 05:08:09 +0000" {
+		t.Errorf("deleted item = %+v", deleted)
+	}
+}
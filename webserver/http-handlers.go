@@ -4,13 +4,17 @@ package webserver
 
 import (
 	"bytes"
+	"errors"
+	"html"
 	"html/template"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cooper/quiki/wiki"
+	"github.com/cooper/quiki/wikifier"
 )
 
 // master handler
@@ -39,6 +43,31 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// other routes are served only if no page has the same name
+		switch {
+		case shadowsPage(delayedWiki, relPath):
+			handlePage(delayedWiki, relPath, w, r)
+			return
+		case relPath == "search":
+			handleSearch(delayedWiki, w, r)
+			return
+		case strings.HasPrefix(relPath, "backlinks/"):
+			handleBacklinks(delayedWiki, strings.TrimPrefix(relPath, "backlinks/"), w, r)
+			return
+		case relPath == "changes":
+			handleChanges(delayedWiki, w, r)
+			return
+		case relPath == "changes.atom", relPath == "changes.rss":
+			handleChangesFeed(delayedWiki, relPath, w, r)
+			return
+		case isSitemapPath(relPath):
+			handleSitemap(delayedWiki, relPath, w, r)
+			return
+		case relPath == "robots.txt":
+			handleRobots(delayedWiki, w, r)
+			return
+		}
+
 		// show the main page for the delayed wiki
 		if r.URL.Path == wikiRoot || r.URL.Path == wikiRoot+"/" {
 			handleWiki(delayedWiki, "", w, r)
@@ -61,6 +90,16 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 	http.NotFound(w, r)
 }
 
+// shadowsPage returns whether a path would be served as an existing page
+// if not for one of the wiki's own routes, like /search or /changes. this is
+// only possible when pages are served from the wiki root
+func shadowsPage(wi *WikiInfo, relPath string) bool {
+	if wi.Opt.Root.Page != "" || relPath == "" {
+		return false
+	}
+	return wi.FindPage(relPath).Exists()
+}
+
 // wiki root
 func handleWiki(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
 	// check if authentication is required
@@ -95,6 +134,39 @@ func handlePage(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Req
 		return // redirected to login
 	}
 
+	// viewing an earlier revision. the history may include drafts and
+	// deleted content, so this requires a logged in user
+	if rev := r.URL.Query().Get("rev"); rev != "" && !useLowLevelError {
+		if !SessMgr.GetBool(r.Context(), "loggedIn") {
+			handleError(wi, wiki.DisplayError{
+				Error:  "You must be logged in to view page history.",
+				Status: http.StatusForbidden,
+			}, w, r)
+			return
+		}
+		handleResponse(wi, wi.DisplayPageRevision(relPath, rev), w, r)
+		return
+	}
+
+	// previewing a page on another branch; this also requires login
+	if branch := r.URL.Query().Get("branch"); branch != "" && !useLowLevelError {
+		if !SessMgr.GetBool(r.Context(), "loggedIn") {
+			handleError(wi, wiki.DisplayError{
+				Error:  "You must be logged in to preview branches.",
+				Status: http.StatusForbidden,
+			}, w, r)
+			return
+		}
+		branchWiki, err := wi.Branch(branch)
+		if err != nil {
+			handleError(wi, err, w, r)
+			return
+		}
+		bwi := wi.Copy(branchWiki)
+		handleResponse(bwi, bwi.DisplayPageDraft(relPath, true), w, r)
+		return
+	}
+
 	if wi.pregenerateManager == nil {
 		http.Error(w, "please try again in a moment", http.StatusInternalServerError)
 		return
@@ -119,6 +191,12 @@ func handleImage(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Re
 // topic request
 func handleCategoryPosts(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
 
+	// feed for the category
+	if feedFormat(relPath) != "" {
+		handleCategoryFeed(wi, relPath, w, r)
+		return
+	}
+
 	// extract page number from relPath
 	pageN := 0
 	catName := relPath
@@ -133,12 +211,223 @@ func handleCategoryPosts(wi *WikiInfo, relPath string, w http.ResponseWriter, r
 	handleResponse(wi, wi.DisplayCategoryPosts(catName, pageN), w, r)
 }
 
+// search request
+func handleSearch(wi *WikiInfo, w http.ResponseWriter, r *http.Request) {
+	// check if authentication is required
+	if !requireAuth(wi, w, r) {
+		return // redirected to login
+	}
+
+	if !wi.Opt.Search.Enable {
+		handleError(wi, "Search is not enabled.", w, r)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	results, err := wi.Search(query)
+	if err != nil {
+		handleError(wi, wiki.DisplayError{Error: "Search failed.", DetailedError: err.Error(), Status: http.StatusInternalServerError}, w, r)
+		return
+	}
+
+	page := wikiPageWith(wi)
+	page.Name = "search"
+	page.Title = "Search"
+	page.Query = query
+	for _, res := range results {
+		title := res.Title
+		if title == "" {
+			title = res.FileNE
+		}
+		page.SearchResults = append(page.SearchResults, searchResult{
+			Title:   title,
+			Link:    wi.Opt.Root.Page + "/" + res.FileNE,
+			Snippet: template.HTML(res.SnippetHTML),
+		})
+	}
+
+	// if the template does not provide a search page, show results as a page
+	if wi.template.template != nil && wi.template.template.Lookup("search.tpl") == nil {
+		var buf bytes.Buffer
+		for _, res := range page.SearchResults {
+			buf.WriteString(`<p><a href="` + html.EscapeString(res.Link) + `">` + html.EscapeString(res.Title) + `</a><br />` + string(res.Snippet) + `</p>`)
+		}
+		if len(page.SearchResults) == 0 {
+			buf.WriteString(`<p>No pages matched your search.</p>`)
+		}
+		page.HTMLContent = template.HTML(buf.String())
+		renderTemplate(wi, w, "page", page)
+		return
+	}
+
+	renderTemplate(wi, w, "search", page)
+}
+
+// backlinks request
+func handleBacklinks(wi *WikiInfo, pageName string, w http.ResponseWriter, r *http.Request) {
+	// check if authentication is required
+	if !requireAuth(wi, w, r) {
+		return // redirected to login
+	}
+
+	// the page must exist and be published
+	info := wi.PageInfo(wi.FindPage(pageName).Name())
+	if info.Path == "" || info.Draft {
+		handleError(wi, "Page not found.", w, r)
+		return
+	}
+
+	title := info.Title
+	if title == "" {
+		title = info.FileNE
+	}
+
+	// list each page which links here, skipping drafts
+	var buf bytes.Buffer
+	link := func(info wikifier.PageInfo) string {
+		title := info.Title
+		if title == "" {
+			title = info.FileNE
+		}
+		return `<a href="` + html.EscapeString(wi.Opt.Root.Page+"/"+info.FileNE) + `">` + html.EscapeString(title) + `</a>`
+	}
+	buf.WriteString(`<h1>Pages that link to ` + link(info) + `</h1>`)
+	buf.WriteString(`<ul class="backlinks">`)
+	n := 0
+	for _, bl := range wi.Backlinks(info.File) {
+		if bl.Draft {
+			continue
+		}
+		buf.WriteString(`<li>` + link(bl.PageInfo) + `</li>`)
+		n++
+	}
+	buf.WriteString(`</ul>`)
+	if n == 0 {
+		buf.WriteString(`<p>No pages link here.</p>`)
+	}
+
+	page := wikiPageWith(wi)
+	page.Name = "backlinks/" + info.FileNE
+	page.Title = "Pages that link to " + title
+	page.HTMLContent = template.HTML(buf.String())
+	renderTemplate(wi, w, "page", page)
+}
+
+// number of changes shown on the recent changes page
+const recentChangesLimit = 100
+
+var changeGroupTitles = map[wiki.ChangeType]string{
+	wiki.ChangeTypePage:   "Pages",
+	wiki.ChangeTypeImage:  "Images",
+	wiki.ChangeTypeModel:  "Models",
+	wiki.ChangeTypeConfig: "Configuration",
+}
+
+var changeActions = map[string]string{
+	"create": "created",
+	"modify": "modified",
+	"delete": "deleted",
+}
+
+// recent changes request
+func handleChanges(wi *WikiInfo, w http.ResponseWriter, r *http.Request) {
+	// check if authentication is required
+	if !requireAuth(wi, w, r) {
+		return // redirected to login
+	}
+
+	groups, err := wi.RecentChanges(recentChangesLimit, time.Time{})
+	if err != nil {
+		handleError(wi, wiki.DisplayError{Error: "Recent changes are unavailable.", DetailedError: err.Error(), Status: http.StatusInternalServerError}, w, r)
+		return
+	}
+
+	// title and draft status of each page, which may change many times
+	pages := make(map[string]struct {
+		title string
+		draft bool
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString(`<h1>Recent changes</h1>`)
+	n := 0
+	for _, group := range groups {
+		var items bytes.Buffer
+		for _, change := range group.Changes {
+			title, link := change.File, ""
+			switch group.Type {
+
+			// pages link to the page unless deleted. skip drafts
+			case wiki.ChangeTypePage:
+				name := wikifier.PageNameNE(change.File)
+				page, ok := pages[name]
+				if !ok {
+					info := wi.CompletePageInfo(change.File)
+					page.title, page.draft = info.Title, info.Draft
+					if page.title == "" {
+						page.title = name
+					}
+					pages[name] = page
+				}
+				if page.draft {
+					continue
+				}
+				title = page.title
+				if change.Action != "delete" {
+					link = wi.Opt.Root.Page + "/" + name
+				}
+
+			// images link to the full size image unless deleted
+			case wiki.ChangeTypeImage:
+				if change.Action != "delete" {
+					link = wi.Opt.Root.Image + "/" + change.File
+				}
+			}
+
+			items.WriteString(`<li>`)
+			if link != "" {
+				items.WriteString(`<a href="` + html.EscapeString(link) + `">` + html.EscapeString(title) + `</a>`)
+			} else {
+				items.WriteString(html.EscapeString(title))
+			}
+			items.WriteString(` ` + changeActions[change.Action])
+			if change.Author != "" {
+				items.WriteString(` by ` + html.EscapeString(change.Author))
+			}
+			items.WriteString(` <span class="change-date">` + change.Date.Format("2006-01-02 15:04") + `</span>`)
+			if msg := strings.TrimSpace(change.Message); msg != "" {
+				items.WriteString(`<br /><span class="change-message">` + html.EscapeString(msg) + `</span>`)
+			}
+			items.WriteString(`</li>`)
+			n++
+		}
+		if items.Len() == 0 {
+			continue
+		}
+		buf.WriteString(`<h2>` + changeGroupTitles[group.Type] + `</h2>`)
+		buf.WriteString(`<ul class="changes">`)
+		buf.Write(items.Bytes())
+		buf.WriteString(`</ul>`)
+	}
+	if n == 0 {
+		buf.WriteString(`<p>There are no recent changes.</p>`)
+	}
+
+	page := wikiPageWith(wi)
+	page.Name = "changes"
+	page.Title = "Recent changes"
+	page.HTMLContent = template.HTML(buf.String())
+	renderTemplate(wi, w, "page", page)
+}
+
 func handleResponse(wi *WikiInfo, res any, w http.ResponseWriter, r *http.Request) {
 	switch res := res.(type) {
 
 	// page content
 	case wiki.DisplayPage:
-		renderTemplate(wi, w, "page", wikiPageFromRes(wi, res))
+		page := wikiPageFromRes(wi, res)
+		page.ExtRoot = externalRoot(wi, r)
+		renderTemplate(wi, w, "page", page)
 
 	// image content
 	case wiki.DisplayImage:
@@ -146,21 +435,8 @@ func handleResponse(wi *WikiInfo, res any, w http.ResponseWriter, r *http.Reques
 
 	// posts
 	case wiki.DisplayCategoryPosts:
-
-		// create template page
-		page := wikiPageWith(wi)
-		page.PageCSS = template.CSS(res.CSS)
-		page.File = res.File
-		page.Name = res.Name
-		page.Title = res.Title
-		page.PageN = res.PageN + 1
-		page.NumPages = res.NumPages
-
-		// add each page result as a wikiPage
-		for _, dispPage := range res.Pages {
-			page.Pages = append(page.Pages, wikiPageFromRes(wi, dispPage))
-		}
-
+		page := wikiPageFromPostsRes(wi, res)
+		page.ExtRoot = externalRoot(wi, r)
 		renderTemplate(wi, w, "posts", page)
 
 	// error
@@ -239,18 +515,25 @@ func handleError(wi *WikiInfo, errMaybe any, w http.ResponseWriter, r *http.Requ
 }
 
 func renderTemplate(wi *WikiInfo, w http.ResponseWriter, templateName string, dot wikiPage) {
-	if wi.template.template == nil {
-		http.Error(w, "Template not found", http.StatusInternalServerError)
-		return
-	}
-	var buf bytes.Buffer
-	err := wi.template.template.ExecuteTemplate(&buf, templateName+".tpl", dot)
+	content, err := executeTemplate(wi, templateName, dot)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Length", strconv.FormatInt(int64(buf.Len()), 10))
-	w.Write(buf.Bytes())
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Write(content)
+}
+
+// executeTemplate executes one of the wiki's templates, returning the HTML
+func executeTemplate(wi *WikiInfo, templateName string, dot wikiPage) ([]byte, error) {
+	if wi.template.template == nil {
+		return nil, errors.New("template not found")
+	}
+	var buf bytes.Buffer
+	if err := wi.template.template.ExecuteTemplate(&buf, templateName+".tpl", dot); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func wikiPageFromRes(wi *WikiInfo, res wiki.DisplayPage) wikiPage {
@@ -263,6 +546,38 @@ func wikiPageFromRes(wi *WikiInfo, res wiki.DisplayPage) wikiPage {
 	page.Description = res.Description
 	page.Keywords = res.Keywords
 	page.Author = res.Author
+	page.Preview = res.Preview
+	page.Created = res.Created
+	page.Modified = res.Modified
+	page.Image = res.Image
+	return page
+}
+
+func wikiPageFromPostsRes(wi *WikiInfo, res wiki.DisplayCategoryPosts) wikiPage {
+
+	// create template page
+	page := wikiPageWith(wi)
+	page.PageCSS = template.CSS(res.CSS)
+	page.File = res.File
+	page.Name = res.Name
+	page.Title = res.Title
+	page.PageN = res.PageN + 1
+	page.NumPages = res.NumPages
+	feedTitle := res.Title
+	if feedTitle == "" {
+		feedTitle = res.Name
+	}
+	page.Feeds = append(page.Feeds, feedLink{
+		Title: feedTitle,
+		Type:  feedTypes[".atom"],
+		Link:  wi.Opt.Root.Category + "/" + res.Name + ".atom",
+	})
+
+	// add each page result as a wikiPage
+	for _, dispPage := range res.Pages {
+		page.Pages = append(page.Pages, wikiPageFromRes(wi, dispPage))
+	}
+
 	return page
 }
 
@@ -274,6 +589,16 @@ func wikiPageWith(wi *WikiInfo) wikiPage {
 		Root:       wi.Opt.Root,
 		StaticRoot: wi.template.staticRoot,
 		Navigation: wi.Opt.Navigation,
+		SearchRoot: searchRoot(wi),
+		Feeds:      feedLinks(wi),
 		retina:     wi.Opt.Image.Retina,
 	}
 }
+
+// searchRoot returns the HTTP path to search the wiki, if enabled
+func searchRoot(wi *WikiInfo) string {
+	if !wi.Opt.Search.Enable {
+		return ""
+	}
+	return wi.Opt.Root.Wiki + "/search"
+}
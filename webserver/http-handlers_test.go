@@ -0,0 +1,27 @@
+package webserver
+
+import "testing"
+
+func TestShadowsPage(t *testing.T) {
+	wi := newTestWikiInfo(t, map[string]string{
+		"search.page": "@page.title: Search tips;\ntext\n",
+	})
+
+	// pages are served from the wiki root, so the page is not hidden
+	wi.Opt.Root.Page = ""
+	for relPath, want := range map[string]bool{
+		"search":  true,
+		"changes": false,
+		"":        false,
+	} {
+		if got := shadowsPage(wi, relPath); got != want {
+			t.Errorf("shadowsPage(%q) = %v, want %v", relPath, got, want)
+		}
+	}
+
+	// pages have their own root, so /search is always search
+	wi.Opt.Root.Page = "/page"
+	if shadowsPage(wi, "search") {
+		t.Error("shadowsPage(search) with a page root = true")
+	}
+}
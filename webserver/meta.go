@@ -0,0 +1,159 @@
+package webserver
+
+import (
+	"encoding/json"
+	"html"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Meta returns tags describing the page for link previews and search
+// engines: Open Graph, Twitter Card, and schema.org JSON-LD. A page is
+// described as an Article, and category posts as a BreadcrumbList.
+//
+// These require absolute URLs, so URLs are omitted when the scheme and
+// host are not known, as when exporting without a full URL for root.ext.
+//
+// Templates include it in <head> with {{.Meta}}.
+func (p wikiPage) Meta() template.HTML {
+	// only for pages and category posts
+	if p.File == "" {
+		return ""
+	}
+	posts := p.NumPages != 0
+
+	title := html.UnescapeString(p.Title)
+	if title == "" {
+		title = p.Name
+	}
+	desc := p.Description
+	if desc == "" {
+		desc = p.Preview
+	}
+	pageURL := p.absoluteURL(p.Root.Page + "/" + p.Name)
+	if posts {
+		pageURL = p.absoluteURL(p.Root.Category + "/" + p.Name)
+	}
+
+	var b strings.Builder
+	tag := func(attr, name, content string) {
+		if content == "" {
+			return
+		}
+		b.WriteString(`    <meta ` + attr + `="` + html.EscapeString(name) + `" content="` + html.EscapeString(content) + `" />` + "\n")
+	}
+
+	// Open Graph
+	ogType := "article"
+	if posts {
+		ogType = "website"
+	}
+	tag("property", "og:site_name", p.WikiTitle)
+	tag("property", "og:type", ogType)
+	tag("property", "og:title", title)
+	tag("property", "og:description", desc)
+	tag("property", "og:url", pageURL)
+	imageURL := ""
+	if p.Image != nil {
+		imageURL = p.absoluteURL(p.Image.Path)
+		tag("property", "og:image", imageURL)
+		if p.Image.Width != 0 && p.Image.Height != 0 {
+			tag("property", "og:image:width", strconv.Itoa(p.Image.Width))
+			tag("property", "og:image:height", strconv.Itoa(p.Image.Height))
+		}
+	}
+	if !posts {
+		tag("property", "article:published_time", metaTime(p.Created))
+		tag("property", "article:modified_time", metaTime(p.Modified))
+		tag("property", "article:author", p.Author)
+		for _, keyword := range p.Keywords {
+			tag("property", "article:tag", keyword)
+		}
+	}
+
+	// Twitter Card
+	card := "summary"
+	if imageURL != "" {
+		card = "summary_large_image"
+	}
+	tag("name", "twitter:card", card)
+	tag("name", "twitter:title", title)
+	tag("name", "twitter:description", desc)
+	tag("name", "twitter:image", imageURL)
+
+	// JSON-LD
+	var ld map[string]any
+	if posts {
+		items := []map[string]any{
+			{"@type": "ListItem", "position": 1, "name": p.WikiTitle},
+			{"@type": "ListItem", "position": 2, "name": title},
+		}
+		if pageURL != "" {
+			items[0]["item"] = p.absoluteURL(p.Root.Wiki + "/")
+			items[1]["item"] = pageURL
+		}
+		ld = map[string]any{
+			"@context":        "https://schema.org",
+			"@type":           "BreadcrumbList",
+			"itemListElement": items,
+		}
+	} else {
+		ld = map[string]any{
+			"@context":  "https://schema.org",
+			"@type":     "Article",
+			"headline":  title,
+			"publisher": map[string]any{"@type": "Organization", "name": p.WikiTitle},
+		}
+		if pageURL != "" {
+			ld["url"] = pageURL
+			ld["mainEntityOfPage"] = pageURL
+		}
+		if desc != "" {
+			ld["description"] = desc
+		}
+		if p.Author != "" {
+			ld["author"] = map[string]any{"@type": "Person", "name": p.Author}
+		}
+		if created := metaTime(p.Created); created != "" {
+			ld["datePublished"] = created
+		}
+		if modified := metaTime(p.Modified); modified != "" {
+			ld["dateModified"] = modified
+		}
+		if imageURL != "" {
+			ld["image"] = imageURL
+		}
+		if len(p.Keywords) != 0 {
+			ld["keywords"] = p.KeywordString()
+		}
+	}
+
+	// json.Marshal escapes <, >, and & so this is safe within <script>
+	if j, err := json.Marshal(ld); err == nil {
+		b.WriteString(`    <script type="application/ld+json">` + string(j) + "</script>\n")
+	}
+
+	return template.HTML(b.String())
+}
+
+// absoluteURL returns an absolute URL for a server-relative path, or an
+// empty string if the scheme and host are not known
+func (p wikiPage) absoluteURL(path string) string {
+	if strings.Contains(path, "://") {
+		return path
+	}
+	if p.ExtRoot == "" {
+		return ""
+	}
+	return p.ExtRoot + path
+}
+
+// metaTime formats a time for meta tags, or returns empty if there is none
+func metaTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,132 @@
+package webserver
+
+import (
+	"encoding/json"
+	"html"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// a title which would break out of attributes and <script> if not escaped
+const metaTestTitle = `Say "hi" </script><script>alert(1)</script>`
+
+func metaTestPage() wikiPage {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return wikiPage{
+		File:        "hello.page",
+		Name:        "hello",
+		Title:       html.EscapeString(metaTestTitle),
+		Description: `A "quoted" <description>`,
+		Author:      "alice",
+		Keywords:    []string{"one", "two"},
+		Created:     &created,
+		Modified:    &created,
+		Image:       &wikifier.PageImage{File: "cat.png", Path: "/images/1200x800-cat.png", Width: 1200, Height: 800},
+		ExtRoot:     "https://example.com",
+		WikiTitle:   "Test & Wiki",
+		Root:        wikifier.PageOptRoot{Wiki: "", Page: "/page", Category: "/topic"},
+	}
+}
+
+// metaJSONLD returns the decoded JSON-LD in meta tags
+func metaJSONLD(t *testing.T, meta string) map[string]any {
+	t.Helper()
+	const open = `<script type="application/ld+json">`
+	start := strings.Index(meta, open)
+	end := strings.LastIndex(meta, "</script>")
+	if start == -1 || end < start {
+		t.Fatalf("no JSON-LD in:\n%s", meta)
+	}
+	var ld map[string]any
+	if err := json.Unmarshal([]byte(meta[start+len(open):end]), &ld); err != nil {
+		t.Fatal(err)
+	}
+	return ld
+}
+
+func TestMeta(t *testing.T) {
+	meta := string(metaTestPage().Meta())
+
+	for _, want := range []string{
+		`<meta property="og:site_name" content="Test &amp; Wiki" />`,
+		`<meta property="og:type" content="article" />`,
+		`<meta property="og:title" content="Say &#34;hi&#34; &lt;/script&gt;&lt;script&gt;alert(1)&lt;/script&gt;" />`,
+		`<meta property="og:description" content="A &#34;quoted&#34; &lt;description&gt;" />`,
+		`<meta property="og:url" content="https://example.com/page/hello" />`,
+		`<meta property="og:image" content="https://example.com/images/1200x800-cat.png" />`,
+		`<meta property="og:image:width" content="1200" />`,
+		`<meta property="article:published_time" content="2024-01-02T03:04:05Z" />`,
+		`<meta property="article:author" content="alice" />`,
+		`<meta property="article:tag" content="two" />`,
+		`<meta name="twitter:card" content="summary_large_image" />`,
+		`<meta name="twitter:title" content="Say &#34;hi&#34; &lt;/script&gt;&lt;script&gt;alert(1)&lt;/script&gt;" />`,
+	} {
+		if !strings.Contains(meta, want) {
+			t.Errorf("missing %s in:\n%s", want, meta)
+		}
+	}
+
+	// the title can't end the JSON-LD <script> early
+	if n := strings.Count(meta, "</script>"); n != 1 {
+		t.Errorf("%d </script> in:\n%s", n, meta)
+	}
+	if strings.Contains(meta, "<script>") {
+		t.Errorf("title not escaped in JSON-LD:\n%s", meta)
+	}
+	ld := metaJSONLD(t, meta)
+	for key, want := range map[string]any{
+		"@type":         "Article",
+		"headline":      metaTestTitle,
+		"url":           "https://example.com/page/hello",
+		"image":         "https://example.com/images/1200x800-cat.png",
+		"datePublished": "2024-01-02T03:04:05Z",
+		"keywords":      "one, two",
+	} {
+		if ld[key] != want {
+			t.Errorf("JSON-LD %s = %v, want %v", key, ld[key], want)
+		}
+	}
+	if author, _ := ld["author"].(map[string]any); author["name"] != "alice" {
+		t.Errorf("JSON-LD author = %v", ld["author"])
+	}
+}
+
+func TestMetaCategoryPosts(t *testing.T) {
+	p := metaTestPage()
+	p.NumPages, p.Image = 1, nil
+	meta := string(p.Meta())
+
+	for _, want := range []string{
+		`<meta property="og:type" content="website" />`,
+		`<meta property="og:url" content="https://example.com/topic/hello" />`,
+		`<meta name="twitter:card" content="summary" />`,
+	} {
+		if !strings.Contains(meta, want) {
+			t.Errorf("missing %s in:\n%s", want, meta)
+		}
+	}
+	if strings.Contains(meta, "article:") {
+		t.Errorf("article tags for category posts:\n%s", meta)
+	}
+
+	ld := metaJSONLD(t, meta)
+	items, _ := ld["itemListElement"].([]any)
+	if ld["@type"] != "BreadcrumbList" || len(items) != 2 {
+		t.Fatalf("JSON-LD = %v", ld)
+	}
+	last, _ := items[1].(map[string]any)
+	if last["name"] != metaTestTitle || last["item"] != "https://example.com/topic/hello" {
+		t.Errorf("last breadcrumb = %v", last)
+	}
+}
+
+func TestMetaNotPage(t *testing.T) {
+	p := metaTestPage()
+	p.File = ""
+	if meta := p.Meta(); meta != "" {
+		t.Errorf("Meta() = %s, want nothing for non-pages", meta)
+	}
+}
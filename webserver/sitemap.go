@@ -0,0 +1,181 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cooper/quiki/wiki"
+	"github.com/cooper/quiki/wikifier"
+)
+
+// maximum number of URLs in one sitemap, per the sitemap protocol. larger
+// wikis are split into several sitemaps listed in a sitemap index
+const sitemapMaxURLs = 50000
+
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+var sitemapPageRegex = regexp.MustCompile(`^sitemap-(\d+)\.xml$`)
+
+// isSitemapPath returns whether a path relative to the wiki root is a sitemap
+func isSitemapPath(relPath string) bool {
+	return relPath == "sitemap.xml" || sitemapPageRegex.MatchString(relPath)
+}
+
+// sitemap request, e.g. /sitemap.xml or /sitemap-2.xml
+func handleSitemap(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+
+	// private wikis are not indexed
+	if !crawlable(wi) {
+		handleError(wi, "Page not found.", w, r)
+		return
+	}
+
+	base := externalURL(wi, r, "")
+	urls := sitemapURLs(wi)
+	nSitemaps := (len(urls) + sitemapMaxURLs - 1) / sitemapMaxURLs
+
+	// sitemap index for large wikis
+	if relPath == "sitemap.xml" && nSitemaps > 1 {
+		index := sitemapIndex{Xmlns: sitemapNS}
+		for n := 1; n <= nSitemaps; n++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapRef{
+				Loc:     base + wi.Opt.Root.Wiki + "/sitemap-" + strconv.Itoa(n) + ".xml",
+				LastMod: urls[(n-1)*sitemapMaxURLs].LastMod, // newest first
+			})
+		}
+		serveXML(index, "application/xml", w)
+		return
+	}
+
+	// one page of a large sitemap
+	if match := sitemapPageRegex.FindStringSubmatch(relPath); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		if n < 1 || n > nSitemaps {
+			handleError(wi, "Page not found.", w, r)
+			return
+		}
+		urls = urls[(n-1)*sitemapMaxURLs : min(n*sitemapMaxURLs, len(urls))]
+	}
+
+	set := sitemapURLSet{Xmlns: sitemapNS}
+	for _, u := range urls {
+		u.Loc = base + u.Loc
+		set.URLs = append(set.URLs, u)
+	}
+	serveXML(set, "application/xml", w)
+}
+
+// sitemapURLs returns the server-relative URLs of each published page,
+// most recently modified first
+func sitemapURLs(wi *WikiInfo) []sitemapURL {
+	var urls []sitemapURL
+	for _, info := range wi.PagesSorted(true, wiki.SortModified) {
+
+		// skip errors, as of when the page was last generated
+		if info.Error != nil {
+			continue
+		}
+
+		// skip drafts and redirects, even if the page was never generated
+		if complete := wi.CompletePageInfo(info.File); complete.Draft || complete.Redirect != "" {
+			continue
+		}
+
+		// the main page is served at the wiki root
+		loc := wi.Opt.Root.Page + "/" + info.FileNE
+		if !wi.Opt.MainRedirect && info.FileNE == wikifier.PageNameNE(wi.Opt.MainPage) {
+			loc = wi.Opt.Root.Wiki + "/"
+		}
+		if robotsDisallowed(wi, loc) {
+			continue
+		}
+
+		u := sitemapURL{Loc: loc}
+		if info.Modified != nil {
+			u.LastMod = info.Modified.UTC().Format(time.RFC3339)
+		}
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// robots.txt request
+func handleRobots(wi *WikiInfo, w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	buf.WriteString("User-agent: *\n")
+
+	// private wikis are not indexed
+	if !crawlable(wi) {
+		buf.WriteString("Disallow: /\n")
+	} else {
+		for _, path := range wi.Opt.Robots.Disallow {
+			buf.WriteString("Disallow: " + wi.Opt.Root.Wiki + path + "\n")
+		}
+		if len(wi.Opt.Robots.Disallow) == 0 {
+			buf.WriteString("Disallow:\n")
+		}
+		buf.WriteString("\nSitemap: " + externalURL(wi, r, wi.Opt.Root.Wiki+"/sitemap.xml") + "\n")
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// crawlable returns whether search engines may index a wiki
+func crawlable(wi *WikiInfo) bool {
+	return wi.Opt.Robots.Index && !wi.Opt.Auth.Require
+}
+
+// robotsDisallowed returns whether a server-relative URL path is excluded by
+// robots.disallow
+func robotsDisallowed(wi *WikiInfo, urlPath string) bool {
+	for _, path := range wi.Opt.Robots.Disallow {
+		if strings.HasPrefix(urlPath, wi.Opt.Root.Wiki+path) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveXML writes an XML document with the given MIME type
+func serveXML(doc any, contentType string, w http.ResponseWriter) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapRef `xml:"sitemap"`
+}
+
+type sitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
@@ -0,0 +1,59 @@
+package webserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cooper/quiki/wiki"
+)
+
+// newTestWikiInfo creates a wiki in a temporary directory with the given
+// pages, keyed by file name relative to the pages directory.
+func newTestWikiInfo(t *testing.T, pages map[string]string) *WikiInfo {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "wiki.conf"), []byte("@name: test;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, source := range pages {
+		path := filepath.Join(dir, "pages", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := wiki.NewWiki(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &WikiInfo{Name: "test", Wiki: w}
+}
+
+func TestSitemapSkipsUngeneratedDrafts(t *testing.T) {
+	wi := newTestWikiInfo(t, map[string]string{
+		"published.page": "@page.title: Published;\ntext\n",
+		"moved.page":     "@page.redirect: /published;\n",
+	})
+
+	// the first write creates the repository
+	if _, err := wi.GetLatestCommitHash(); err != nil {
+		t.Fatal(err)
+	}
+
+	// new pages are drafts until they are published
+	if _, err := wi.CreatePage("", "New Page", nil, wiki.CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	urls := sitemapURLs(wi)
+	var locs []string
+	for _, u := range urls {
+		locs = append(locs, u.Loc)
+	}
+	if len(locs) != 1 || locs[0] != wi.Opt.Root.Page+"/published" {
+		t.Errorf("sitemap lists %v, want only the published page", locs)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/cooper/quiki/wikifier"
 )
@@ -33,6 +34,7 @@ type wikiTemplate struct {
 	// path       string             // template directory path
 	template   *template.Template // master HTML template
 	staticPath string             // static file directory path, if any
+	staticFs   fs.FS              // static file directory, if any
 	staticRoot string             // static file directory HTTP root, if any
 	manifest   struct {
 
@@ -142,6 +144,13 @@ func loadTemplate(walkPath, name string, fses []fs.FS) (wikiTemplate, error) {
 				t.staticPath = filePath
 				t.staticRoot = "/tmpl/" + name
 				if subFs, err := fs.Sub(templateFs, filePath); err == nil {
+					t.staticFs = subFs
+
+					// not serving, e.g. exporting a static site
+					if Router == nil {
+						return nil
+					}
+
 					fileServer := http.FileServer(http.FS(subFs))
 					pfx := t.staticRoot + "/"
 					Router.Handle(pfx, "template static files", http.StripPrefix(pfx, fileServer))
@@ -191,26 +200,42 @@ func loadTemplate(walkPath, name string, fses []fs.FS) (wikiTemplate, error) {
 }
 
 type wikiPage struct {
-	File        string                       // page name, with extension
-	Name        string                       // page name, without extension
-	WholeTitle  string                       // optional, shown in <title> as-is
-	Title       string                       // page title
-	Description string                       // page description
-	Keywords    []string                     // page keywords
-	Author      string                       // page author
-	WikiTitle   string                       // wiki titled
-	WikiLogo    string                       // path to wiki logo image (deprecated, use Logo)
-	WikiRoot    string                       // wiki HTTP root (deprecated, use Root.Wiki)
-	Root        wikifier.PageOptRoot         // all roots
-	StaticRoot  string                       // path to static resources
-	Pages       []wikiPage                   // more pages for category posts
-	Message     string                       // message for error page
-	Navigation  []wikifier.PageOptNavigation // slice of nav items
-	PageN       int                          // for category posts, the page number (first page = 1)
-	NumPages    int                          // for category posts, the number of pages
-	PageCSS     template.CSS                 // css
-	HTMLContent template.HTML                // html
-	retina      []int                        // retina scales for logo
+	File          string                       // page name, with extension
+	Name          string                       // page name, without extension
+	WholeTitle    string                       // optional, shown in <title> as-is
+	Title         string                       // page title
+	Description   string                       // page description
+	Keywords      []string                     // page keywords
+	Author        string                       // page author
+	Preview       string                       // page description, or else the start of its text
+	Created       *time.Time                   // page creation time
+	Modified      *time.Time                   // page modify time
+	Image         *wikifier.PageImage          // page image, from @page.image
+	ExtRoot       string                       // scheme and host for absolute URLs, if known
+	WikiTitle     string                       // wiki titled
+	WikiLogo      string                       // path to wiki logo image (deprecated, use Logo)
+	WikiRoot      string                       // wiki HTTP root (deprecated, use Root.Wiki)
+	Root          wikifier.PageOptRoot         // all roots
+	StaticRoot    string                       // path to static resources
+	Pages         []wikiPage                   // more pages for category posts
+	Message       string                       // message for error page
+	Navigation    []wikifier.PageOptNavigation // slice of nav items
+	PageN         int                          // for category posts, the page number (first page = 1)
+	NumPages      int                          // for category posts, the number of pages
+	PageCSS       template.CSS                 // css
+	HTMLContent   template.HTML                // html
+	SearchRoot    string                       // path to search, if enabled
+	Query         string                       // for search, the query
+	SearchResults []searchResult               // for search, the results
+	Feeds         []feedLink                   // feeds to advertise with alternate links
+	retina        []int                        // retina scales for logo
+}
+
+// searchResult is a search result ready for display
+type searchResult struct {
+	Title   string        // page title
+	Link    string        // path to page
+	Snippet template.HTML // excerpt with matches highlighted
 }
 
 func (p wikiPage) VisibleTitle() string {
@@ -373,18 +373,9 @@ func Configure(_initial_options Options) {
 		templateFses = append(templateFses, templateFs)
 	}
 
-	// add embedded templates
-	if sub, err := fs.Sub(resources.Webserver, "templates"); err == nil {
-		templateFses = append(templateFses, sub)
-	} else {
-		log.Fatal(errors.Wrap(err, "loading embedded templates"))
-	}
-
-	// add shared templates
-	if sub, err := fs.Sub(resources.Shared, "template"); err == nil {
-		templateFses = append(templateFses, sub)
-	} else {
-		log.Fatal(errors.Wrap(err, "loading shared templates"))
+	// add embedded and shared templates
+	if err = addEmbeddedTemplates(); err != nil {
+		log.Fatal(err)
 	}
 
 	// set up wikis
@@ -485,6 +476,27 @@ func Shutdown() {
 	}
 }
 
+// addEmbeddedTemplates adds the templates built into quiki to the template
+// search path, after any configured template directories
+func addEmbeddedTemplates() error {
+
+	// add embedded templates
+	sub, err := fs.Sub(resources.Webserver, "templates")
+	if err != nil {
+		return errors.Wrap(err, "loading embedded templates")
+	}
+	templateFses = append(templateFses, sub)
+
+	// add shared templates
+	sub, err = fs.Sub(resources.Shared, "template")
+	if err != nil {
+		return errors.Wrap(err, "loading shared templates")
+	}
+	templateFses = append(templateFses, sub)
+
+	return nil
+}
+
 func setupStatic() error {
 	subFS, err := fs.Sub(resources.Webserver, "static")
 	if err != nil {
@@ -0,0 +1,68 @@
+package wiki
+
+import (
+	"reflect"
+	"testing"
+)
+
+// backlinkFiles returns the file names of pages linking to pageName
+func backlinkFiles(w *Wiki, pageName string) []string {
+	var files []string
+	for _, bl := range w.Backlinks(pageName) {
+		files = append(files, bl.File)
+	}
+	return files
+}
+
+func TestBacklinks(t *testing.T) {
+	w := newTestWiki(t, map[string]string{
+		"target.page":    "@page.title: Target;\ntext\n",
+		"zebra.page":     "@page.title: Zebra;\nsee [[ Target ]]\n",
+		"apple.page":     "@page.title: apple;\nfirst [[ target ]]\n\nagain [[ here | Target ]]\n",
+		"unrelated.page": "@page.title: Unrelated;\nno links\n",
+	})
+
+	// links are only known once the linking pages are generated
+	if bls := w.Backlinks("target"); len(bls) != 0 {
+		t.Errorf("before generating: %+v", bls)
+	}
+	for _, name := range []string{"zebra.page", "apple.page", "unrelated.page"} {
+		displayPage(t, w, name)
+	}
+
+	// sorted by title, ignoring case
+	bls := w.Backlinks("target")
+	if files := backlinkFiles(w, "target"); !reflect.DeepEqual(files, []string{"apple.page", "zebra.page"}) {
+		t.Fatalf("Backlinks(target) = %v", files)
+	}
+	if bls[0].Title != "apple" {
+		t.Errorf("title = %q", bls[0].Title)
+	}
+	if !reflect.DeepEqual(bls[0].Lines, []int{2, 4}) {
+		t.Errorf("lines = %v, want [2 4]", bls[0].Lines)
+	}
+
+	// the page may be given by file name or in another form
+	for _, name := range []string{"target.page", "Target"} {
+		if files := backlinkFiles(w, name); len(files) != 2 {
+			t.Errorf("Backlinks(%s) = %v", name, files)
+		}
+	}
+
+	// nothing links here
+	if bls := w.Backlinks("zebra"); bls != nil {
+		t.Errorf("Backlinks(zebra) = %+v", bls)
+	}
+}
+
+func TestBacklinksMissingPage(t *testing.T) {
+	w := newTestWiki(t, map[string]string{
+		"source.page": "wanted: [[ Not Yet Written ]]\n",
+	})
+	displayPage(t, w, "source.page")
+
+	// links to pages which don't exist are tracked by the name as linked
+	if files := backlinkFiles(w, "Not Yet Written"); !reflect.DeepEqual(files, []string{"source.page"}) {
+		t.Errorf("Backlinks(Not Yet Written) = %v", files)
+	}
+}
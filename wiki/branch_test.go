@@ -0,0 +1,153 @@
+package wiki
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidBranchName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"feature":     true,
+		"my-branch_2": true,
+		"":            false,
+		"-b":          false,
+		"--force":     false,
+		"a/b":         false,
+		"a..b":        false,
+		"a.lock":      false,
+		"HEAD":        false,
+		"a b":         false,
+	} {
+		if got := ValidBranchName(name); got != want {
+			t.Errorf("ValidBranchName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// newBranchTestWiki creates a wiki with a repository and a branch, using the
+// git CLI or not
+func newBranchTestWiki(t *testing.T, gitCLI bool) (*Wiki, *Wiki) {
+	t.Helper()
+	if !gitCLI {
+		t.Setenv("PATH", "")
+	}
+	w := newTestWiki(t, map[string]string{
+		"a.page": "one\ntwo\nthree\n",
+		"b.page": "b\n",
+	})
+	if _, err := w.repo(); err != nil {
+		t.Fatal(err)
+	}
+	bw, err := w.NewBranch("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, bw
+}
+
+func TestNewBranch(t *testing.T) {
+	for _, gitCLI := range []bool{true, false} {
+		w, bw := newBranchTestWiki(t, gitCLI)
+		if bw.Dir() == w.Dir() {
+			t.Fatalf("gitCLI=%v: branch uses the master directory", gitCLI)
+		}
+
+		// the branch starts with the master files
+		if got, _ := os.ReadFile(bw.PathForPage("a.page")); string(got) != "one\ntwo\nthree\n" {
+			t.Errorf("gitCLI=%v: branch a.page = %q", gitCLI, got)
+		}
+
+		// switching to it again gives the same wiki
+		if again, err := w.Branch("feature"); err != nil || again != bw {
+			t.Errorf("gitCLI=%v: Branch = %v, %v", gitCLI, again, err)
+		}
+
+		// changes on the branch do not affect master
+		if err := bw.WritePage("a.page", []byte("changed\n"), false, CommitOpts{}); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(w.PathForPage("a.page")); string(got) != "one\ntwo\nthree\n" {
+			t.Errorf("gitCLI=%v: master a.page = %q", gitCLI, got)
+		}
+		names, err := w.BranchNames()
+		if err != nil || strings.Join(names, ",") != "feature,master" {
+			t.Errorf("gitCLI=%v: BranchNames = %v, %v", gitCLI, names, err)
+		}
+	}
+
+	w := newTestWiki(t, nil)
+	if _, err := w.NewBranch("-b"); err == nil {
+		t.Error("NewBranch(-b): no error")
+	}
+}
+
+func TestMergeBranch(t *testing.T) {
+	w, bw := newBranchTestWiki(t, true)
+
+	// different lines of a.page on each, and a new page on the branch
+	if err := bw.WritePage("a.page", []byte("ONE\ntwo\nthree\n"), false, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bw.WritePage("c.page", []byte("c\n"), true, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePage("a.page", []byte("one\ntwo\nTHREE\n"), false, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.MergeBranch("feature", CommitOpts{Name: "alice", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"a.page": "ONE\ntwo\nTHREE\n", "b.page": "b\n", "c.page": "c\n"} {
+		if got, _ := os.ReadFile(w.PathForPage(name)); string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// the merge commit has both parents
+	repo, _ := w.repo()
+	head, _ := repo.Head()
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.NumParents() != 2 || commit.Author.Name != "alice" {
+		t.Errorf("merge commit has %d parents and author %s", commit.NumParents(), commit.Author.Name)
+	}
+
+	// merging again has nothing to do
+	if err := w.MergeBranch("feature", CommitOpts{}); err == nil {
+		t.Error("second MergeBranch: no error")
+	}
+}
+
+func TestMergeBranchConflict(t *testing.T) {
+	w, bw := newBranchTestWiki(t, true)
+	if err := bw.WritePage("a.page", []byte("one\nbranch\nthree\n"), false, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bw.WritePage("b.page", []byte("b on branch\n"), false, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePage("a.page", []byte("one\nmaster\nthree\n"), false, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	repo, _ := w.repo()
+	before, _ := repo.Head()
+
+	err := w.MergeBranch("feature", CommitOpts{})
+	if err == nil || !strings.Contains(err.Error(), "merge conflict in pages/a.page") {
+		t.Fatalf("MergeBranch = %v, want a conflict in pages/a.page", err)
+	}
+
+	// nothing is changed, even files which would merge cleanly
+	for name, want := range map[string]string{"a.page": "one\nmaster\nthree\n", "b.page": "b\n"} {
+		if got, _ := os.ReadFile(w.PathForPage(name)); string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if after, _ := repo.Head(); after.Hash() != before.Hash() {
+		t.Error("a commit was made")
+	}
+}
@@ -28,8 +28,14 @@ const (
 
 	// CategoryTypePage is a metacategory that tracks which pages reference another page.
 	CategoryTypePage = "page"
+
+	// CategoryTypeQuery is a metacategory that tracks which pages list other pages with pages{}.
+	CategoryTypeQuery = "query"
 )
 
+// name of the CategoryTypeQuery metacategory
+const queryCategoryName = "pages"
+
 // CategoryManager provides operations for category management
 type CategoryManager interface {
 	AddPage(page *wikifier.Page, dimensions [][]int, lines []int)
@@ -90,6 +96,10 @@ type Category struct {
 	// for CategoryTypePage, this is the info for the tracked page
 	PageInfo *wikifier.PageInfo `json:"page_info,omitempty"`
 
+	// for CategoryTypePage, this is what pages{} queries match the tracked
+	// page on, besides its PageInfo
+	PageQueryInfo *PageQueryInfo `json:"page_query_info,omitempty"`
+
 	// for CategoryTypeModel, this is the info for the tracked model
 	ModelInfo *wikifier.ModelInfo `json:"model_info,omitempty"`
 
@@ -111,6 +121,13 @@ type Category struct {
 	wiki *Wiki `json:"-"`
 }
 
+// PageQueryInfo is the info about a page which pages{} queries match on,
+// other than its PageInfo.
+type PageQueryInfo struct {
+	Categories []string          `json:"categories,omitempty"` // normalized category names
+	Vars       map[string]string `json:"vars,omitempty"`       // @page variables, as returned by Page.PageVars
+}
+
 // A CategoryEntry describes a page that belongs to a category.
 type CategoryEntry struct {
 
@@ -520,6 +537,10 @@ func (cat *Category) updateUnlocked() {
 			case CategoryTypeModel:
 				_, stillMember = page.Models[wikifier.CategoryNameNE(cat.Name)]
 
+			// for queries, check if the page still has pages{}
+			case CategoryTypeQuery:
+				stillMember = len(page.Queries) != 0
+
 			// for normal categories, check @category
 			default:
 				for _, catName := range page.Categories() {
@@ -599,6 +620,10 @@ func (cat *Category) shouldPurge() bool {
 		_, err := os.Lstat(cat.wiki.PathForModel(nameNE))
 		preserve = err != nil
 
+	// for queries, there is nothing to keep once no pages use pages{}
+	case CategoryTypeQuery:
+		preserve = false
+
 	// for normal categories, check if it's being manually preserved
 	default:
 		preserve = cat.Preserve
@@ -698,6 +723,7 @@ func (w *Wiki) updatePageCategories(page *wikifier.Page) {
 	info := page.Info()
 	pageCat := w.GetSpecialCategory(page.NameNE(), CategoryTypePage)
 	pageCat.PageInfo = &info
+	pageCat.PageQueryInfo = newPageQueryInfo(page)
 	pageCat.Preserve = true // keep until page no longer exists
 	pageCat.writeOrBatch()
 	pageCat.addPageExtras(nil, nil, nil)
@@ -730,6 +756,12 @@ func (w *Wiki) updatePageCategories(page *wikifier.Page) {
 		modelCat.ModelInfo = &modelInfo
 		modelCat.addPageExtras(page, nil, nil)
 	}
+
+	// query tracking category
+	if len(page.Queries) != 0 {
+		queryCat := w.GetSpecialCategory(queryCategoryName, CategoryTypeQuery)
+		queryCat.addPageExtras(page, nil, page.Queries)
+	}
 }
 
 // DisplayCategoryPosts returns the display result for a category.
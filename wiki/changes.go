@@ -0,0 +1,125 @@
+package wiki
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ChangeType describes the type of file affected by a Change.
+type ChangeType string
+
+const (
+	// ChangeTypePage is a change to a page.
+	ChangeTypePage ChangeType = "page"
+
+	// ChangeTypeImage is a change to an image.
+	ChangeTypeImage ChangeType = "image"
+
+	// ChangeTypeModel is a change to a model.
+	ChangeTypeModel ChangeType = "model"
+
+	// ChangeTypeConfig is a change to the wiki configuration.
+	ChangeTypeConfig ChangeType = "config"
+)
+
+// changeTypes is the order in which change groups are listed
+var changeTypes = []ChangeType{ChangeTypePage, ChangeTypeImage, ChangeTypeModel, ChangeTypeConfig}
+
+// A Change is a file changed in a revision.
+type Change struct {
+	RevisionInfo
+
+	// type of file changed
+	Type ChangeType `json:"type"`
+
+	// file name relative to the directory for its type, e.g. some/page.page.
+	// for config, this is relative to the wiki directory
+	File string `json:"file"`
+
+	// "create", "modify", or "delete"
+	Action string `json:"action"`
+}
+
+// A ChangeGroup is a list of changes to files of one type.
+type ChangeGroup struct {
+
+	// type of file changed
+	Type ChangeType `json:"type"`
+
+	// changes, newest first
+	Changes []Change `json:"changes"`
+}
+
+// RecentChanges returns changes to pages, images, models, and configuration
+// files found in the revision history, grouped by file type.
+//
+// Revisions are considered from newest to oldest until limit changes are
+// found or revisions older than since are reached. Zero values mean no
+// limit. Groups with no changes are omitted.
+func (w *Wiki) RecentChanges(limit int, since time.Time) ([]ChangeGroup, error) {
+	return w.RecentChangesBy("", limit, since)
+}
+
+// RecentChangesBy is like RecentChanges, except that if author is not empty,
+// only changes by that author are returned and count toward the limit.
+func (w *Wiki) RecentChangesBy(author string, limit int, since time.Time) ([]ChangeGroup, error) {
+
+	// directories for each type relative to the wiki directory
+	dirs := make(map[ChangeType]string)
+	for typ, dir := range map[ChangeType]string{
+		ChangeTypePage:  w.Opt.Dir.Page,
+		ChangeTypeImage: w.Opt.Dir.Image,
+		ChangeTypeModel: w.Opt.Dir.Model,
+	} {
+		absDir, _ := filepath.Abs(dir)
+		if rel := w.RelPath(absDir); rel != "" {
+			dirs[typ] = filepath.ToSlash(rel) + "/"
+		}
+	}
+
+	// determine the type and name of a changed file
+	classify := func(relPath string) (ChangeType, string) {
+		for typ, pfx := range dirs {
+			if strings.HasPrefix(relPath, pfx) {
+				return typ, strings.TrimPrefix(relPath, pfx)
+			}
+		}
+		if !strings.Contains(relPath, "/") && path.Ext(relPath) == ".conf" {
+			return ChangeTypeConfig, relPath
+		}
+		return "", ""
+	}
+
+	fileChanges, err := w._fileChanges(limit, since, func(fc fileChange) bool {
+		if author != "" && fc.Author != author {
+			return false
+		}
+		typ, _ := classify(fc.path)
+		return typ != ""
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// group them
+	byType := make(map[ChangeType][]Change)
+	for _, fc := range fileChanges {
+		typ, file := classify(fc.path)
+		byType[typ] = append(byType[typ], Change{
+			RevisionInfo: fc.RevisionInfo,
+			Type:         typ,
+			File:         file,
+			Action:       fc.action,
+		})
+	}
+	var groups []ChangeGroup
+	for _, typ := range changeTypes {
+		if changes := byType[typ]; len(changes) != 0 {
+			groups = append(groups, ChangeGroup{Type: typ, Changes: changes})
+		}
+	}
+
+	return groups, nil
+}
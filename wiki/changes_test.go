@@ -0,0 +1,101 @@
+package wiki
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRecentChangesBy(t *testing.T) {
+	w := newTestWiki(t, nil)
+	if _, err := w.repo(); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, author string) {
+		t.Helper()
+		err := w.WritePage(name, []byte("by "+author+"\n"), true, CommitOpts{Name: author, Email: author + "@example.com", Comment: "edit " + name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("old.page", "bob")
+	for i := 0; i < 5; i++ {
+		write("new"+strconv.Itoa(i)+".page", "alice")
+	}
+
+	// bob's change is older than the limit of all changes
+	groups, err := w.RecentChangesBy("bob", 2, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0].Changes) != 1 || groups[0].Changes[0].File != "old.page" {
+		t.Errorf("RecentChangesBy(bob) = %+v", groups)
+	}
+
+	groups, err = w.RecentChanges(2, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0].Changes) != 2 || groups[0].Changes[0].Author != "alice" {
+		t.Errorf("RecentChanges = %+v", groups)
+	}
+
+	// the initial commit is by quiki
+	authors, err := w.ChangeAuthors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alice", "bob", "quiki"}; !reflect.DeepEqual(authors, want) {
+		t.Errorf("ChangeAuthors = %v, want %v", authors, want)
+	}
+}
+
+func TestRecentChangesMerge(t *testing.T) {
+	w := newTestWiki(t, nil)
+	if _, err := w.repo(); err != nil {
+		t.Fatal(err)
+	}
+	alice := CommitOpts{Name: "alice", Email: "alice@example.com", Comment: "edit"}
+	if err := w.WritePage("main.page", []byte("main\n"), true, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	// change a page on a branch, then merge it
+	branch, err := w.NewBranch("topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob := CommitOpts{Name: "bob", Email: "bob@example.com", Comment: "draft"}
+	if err := branch.WritePage("topic.page", []byte("@page.title: Topic;\ntopic\n"), true, bob); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.MergeBranch("topic", alice); err != nil {
+		t.Fatal(err)
+	}
+
+	// the merged page is listed once, as of the merge
+	groups, err := w.RecentChanges(0, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, change := range groups[0].Changes {
+		files = append(files, change.File)
+	}
+	if want := []string{"topic.page", "main.page"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("RecentChanges files = %v, want %v", files, want)
+	}
+	if change := groups[0].Changes[0]; change.Author != "alice" || change.Action != "create" {
+		t.Errorf("merged change = %+v", change)
+	}
+
+	// and appears in the feed, titled from the page info
+	feed, err := w.RecentChangesFeed(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Entries) == 0 || feed.Entries[0].Title != "Created Topic" {
+		t.Errorf("RecentChangesFeed entries = %+v", feed.Entries)
+	}
+}
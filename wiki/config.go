@@ -16,6 +16,8 @@ var defaultWikiOpt = wikifier.PageOpt{
 		Code: wikifier.PageOptCode{
 			Style: "monokailight",
 		},
+		Find:  includePage,
+		Query: queryPages,
 	},
 	Dir: wikifier.PageOptDir{
 		Wiki:  "",
@@ -50,6 +52,9 @@ var defaultWikiOpt = wikifier.PageOpt{
 	Search: wikifier.PageOptSearch{
 		Enable: true,
 	},
+	Robots: wikifier.PageOptRobots{
+		Index: true,
+	},
 	Link: wikifier.PageOptLink{
 		ParseInternal: linkPageExists,
 		ParseCategory: linkCategoryExists,
@@ -125,6 +130,37 @@ func defaultImageSizer(name string, width, height int, page *wikifier.Page) stri
 	return page.Opt.Root.Image + "/" + si.TrueName()
 }
 
+// includePage finds a page for include{}.
+func includePage(page *wikifier.Page, name string) *wikifier.Page {
+	w, good := page.Wiki.(*Wiki)
+	if !good {
+		return nil
+	}
+	return w.FindPage(name)
+}
+
+// queryPages finds the pages listed by pages{}, except for the page itself.
+func queryPages(page *wikifier.Page, q wikifier.PageQuery) []wikifier.PageInfo {
+	w, good := page.Wiki.(*Wiki)
+	if !good {
+		return nil
+	}
+	limit := q.Limit
+	if limit > 0 {
+		q.Limit++ // in case the page is among them
+	}
+	var pages []wikifier.PageInfo
+	for _, info := range w.PagesMatching(q) {
+		if info.FileNE != page.NameNE() {
+			pages = append(pages, info)
+		}
+	}
+	if limit > 0 && len(pages) > limit {
+		pages = pages[:limit]
+	}
+	return pages
+}
+
 func linkPageExists(page *wikifier.Page, o *wikifier.PageOptLinkOpts) {
 	w, good := page.Wiki.(*Wiki)
 	if !good {
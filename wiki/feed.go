@@ -0,0 +1,219 @@
+package wiki
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cooper/quiki/wikifier"
+	"github.com/pkg/errors"
+)
+
+// default number of entries in a feed
+const defaultFeedLimit = 20
+
+// A Feed is a list of recent entries suitable for syndication as Atom or RSS.
+type Feed struct {
+
+	// feed title
+	Title string `json:"title"`
+
+	// time of the most recent entry
+	Updated time.Time `json:"updated"`
+
+	// entries, newest first
+	Entries []FeedEntry `json:"entries"`
+}
+
+// A FeedEntry is a single item in a Feed.
+type FeedEntry struct {
+
+	// entry title
+	Title string `json:"title"`
+
+	// name of the page the entry is about, without the extension
+	Page string `json:"page"`
+
+	// for recent changes, the commit hash of the revision
+	Revision string `json:"revision,omitempty"`
+
+	// for recent changes, "create", "modify", or "delete"
+	Action string `json:"action,omitempty"`
+
+	// entry author, if known
+	Author string `json:"author,omitempty"`
+
+	// time the entry was first published, if known
+	Published time.Time `json:"published,omitempty"`
+
+	// time the entry was last updated
+	Updated time.Time `json:"updated"`
+
+	// plain text summary
+	Summary string `json:"summary,omitempty"`
+
+	// full HTML content, if available
+	Content wikifier.HTML `json:"-"`
+}
+
+// CategoryFeed returns a feed of the pages in a category, newest first.
+//
+// Pages are ordered by @page.created, or by modification time for pages
+// without one. Entries include the full page content. If limit is 0, the
+// category's per-page option is used, falling back to a default.
+func (w *Wiki) CategoryFeed(catName string, limit int) (*Feed, error) {
+	cat := w.GetCategory(catName)
+	cat.update()
+	if !cat.Exists() {
+		return nil, errors.New("category does not exist")
+	}
+
+	if limit == 0 {
+		limit = cat.PerPage
+	}
+	if limit == 0 {
+		limit = w.Opt.Category.PerPage
+	}
+	if limit <= 0 {
+		limit = defaultFeedLimit
+	}
+
+	// order the pages by their cached info, skipping drafts and redirects,
+	// so that only those in the feed need to be displayed
+	var infos []wikifier.PageInfo
+	for pageName := range cat.Pages {
+		info := w.CompletePageInfo(pageName)
+		if info.Path == "" || info.Draft || info.Redirect != "" {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].Created.Equal(*infos[j].Created) {
+			return infos[i].Created.After(*infos[j].Created)
+		}
+		return infos[i].File < infos[j].File
+	})
+
+	feed := &Feed{Title: cat.Title}
+	if feed.Title == "" {
+		feed.Title = cat.Name
+	}
+	for _, info := range infos {
+		if len(feed.Entries) == limit {
+			break
+		}
+
+		// skip any which can't be displayed after all
+		page, ok := w.DisplayPage(info.File).(DisplayPage)
+		if !ok {
+			continue
+		}
+
+		entry := FeedEntry{
+			Title:   page.Title,
+			Page:    page.Name,
+			Author:  page.Author,
+			Summary: page.Preview,
+			Content: page.Content,
+		}
+		if entry.Title == "" {
+			entry.Title = page.Name
+		}
+		if page.Modified != nil {
+			entry.Updated = *page.Modified
+		}
+		if page.Created != nil {
+			entry.Published = *page.Created
+		}
+		if entry.Updated.IsZero() {
+			entry.Updated = entry.Published
+		}
+		if entry.Updated.After(feed.Updated) {
+			feed.Updated = entry.Updated
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed, nil
+}
+
+// RecentChangesFeed returns a feed of recent page revisions, newest first.
+//
+// Each entry is a change to one page in one revision. Changes to pages which
+// are currently drafts are excluded. If limit is 0, a default is used.
+func (w *Wiki) RecentChangesFeed(limit int) (*Feed, error) {
+	if limit <= 0 {
+		limit = defaultFeedLimit
+	}
+
+	// page files relative to the wiki directory
+	pageDir, _ := filepath.Abs(w.Opt.Dir.Page)
+	pagePrefix := w.RelPath(pageDir)
+	if pagePrefix == "" {
+		return nil, errors.New("page directory is outside of wiki directory")
+	}
+	pagePrefix = filepath.ToSlash(pagePrefix) + "/"
+
+	// changes to page files, skipping pages which are currently drafts.
+	// this is checked while walking the history, so the feed is filled
+	// even if many of the latest changes are to drafts
+	infos := make(map[string]wikifier.PageInfo)
+	changes, err := w._fileChanges(limit, time.Time{}, func(fc fileChange) bool {
+		if !strings.HasPrefix(fc.path, pagePrefix) || !hasPageExtension(fc.path) {
+			return false
+		}
+		name := strings.TrimPrefix(fc.path, pagePrefix)
+		info, ok := infos[name]
+		if !ok {
+			info = w.CompletePageInfo(name)
+			infos[name] = info
+		}
+		return !info.Draft
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &Feed{Title: "Recent changes"}
+	for _, change := range changes {
+		name := strings.TrimPrefix(change.path, pagePrefix)
+		info := infos[name]
+		entry := FeedEntry{
+			Title:    info.Title,
+			Page:     wikifier.PageNameNE(name),
+			Revision: change.Id,
+			Action:   change.action,
+			Author:   change.Author,
+			Updated:  change.Date,
+			Summary:  strings.TrimSpace(change.Message),
+		}
+		if entry.Title == "" {
+			entry.Title = wikifier.PageNameNE(name)
+		}
+		switch change.action {
+		case "create":
+			entry.Title = "Created " + entry.Title
+		case "delete":
+			entry.Title = "Deleted " + entry.Title
+		}
+		if change.Date.After(feed.Updated) {
+			feed.Updated = change.Date
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed, nil
+}
+
+// hasPageExtension returns whether a file name has a page file extension
+func hasPageExtension(name string) bool {
+	ext := filepath.Ext(name)
+	for _, pageExt := range pageExtensions {
+		if ext == "."+pageExt {
+			return true
+		}
+	}
+	return false
+}
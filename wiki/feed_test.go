@@ -0,0 +1,103 @@
+package wiki
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// feedPages returns the pages of the entries in a feed, in order
+func feedPages(feed *Feed) []string {
+	var pages []string
+	for _, entry := range feed.Entries {
+		pages = append(pages, entry.Page)
+	}
+	return pages
+}
+
+func TestCategoryFeed(t *testing.T) {
+	w := newTestWiki(t, queryTestPages)
+	for name := range queryTestPages {
+		w.DisplayPage(name)
+	}
+
+	// the oldest page's cache is outdated, but the page is not in the feed,
+	// so it should not be generated again
+	oldPage := w.FindPage("guides/one.page")
+	past := oldPage.CacheModified().Add(-time.Hour)
+	if err := os.Chtimes(oldPage.CachePath(), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	feed, err := w.CategoryFeed("docs", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"guides/two", "three"}; !reflect.DeepEqual(feedPages(feed), want) {
+		t.Errorf("CategoryFeed = %v, want %v", feedPages(feed), want)
+	}
+	entry := feed.Entries[0]
+	if entry.Title != "Two" || entry.Author != "Bob" || entry.Published.Unix() != 3000 || entry.Content == "" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if !oldPage.CacheModified().Equal(past) {
+		t.Error("a page not in the feed was generated")
+	}
+
+	// drafts and redirects are never included
+	feed, err = w.CategoryFeed("docs", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"guides/two", "three", "guides/one"}; !reflect.DeepEqual(feedPages(feed), want) {
+		t.Errorf("CategoryFeed = %v, want %v", feedPages(feed), want)
+	}
+
+	if _, err := w.CategoryFeed("nonexistent", 0); err == nil {
+		t.Error("CategoryFeed(nonexistent): no error")
+	}
+}
+
+func TestRecentChangesFeed(t *testing.T) {
+	w := newTestWiki(t, nil)
+	if _, err := w.repo(); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := w.WritePage(name, []byte(content), true, CommitOpts{Name: "alice", Comment: "edit " + name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("one.page", "@page.title: One;\n")
+	write("two.page", "@page.title: Two;\n")
+	write("one.page", "@page.title: One;\nmore\n")
+
+	// many more recent changes to drafts
+	for i := 0; i < 6; i++ {
+		write("draft.page", "@page.draft;\n"+strconv.Itoa(i)+"\n")
+	}
+
+	feed, err := w.RecentChangesFeed(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"one", "two", "one"}; !reflect.DeepEqual(feedPages(feed), want) {
+		t.Fatalf("RecentChangesFeed = %v, want %v", feedPages(feed), want)
+	}
+	var titles []string
+	for _, entry := range feed.Entries {
+		titles = append(titles, entry.Title)
+	}
+	if want := []string{"One", "Created Two", "Created One"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("titles = %v, want %v", titles, want)
+	}
+	if entry := feed.Entries[0]; entry.Author != "alice" || entry.Summary != "edit one.page" || entry.Action != "modify" || entry.Revision == "" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if !feed.Updated.Equal(feed.Entries[0].Updated) {
+		t.Errorf("feed updated %v, want %v", feed.Updated, feed.Entries[0].Updated)
+	}
+}
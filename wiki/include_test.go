@@ -0,0 +1,85 @@
+package wiki
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIncludeFootnotes(t *testing.T) {
+	w := newTestWiki(t, map[string]string{
+		"host.page": "sec [Host] {\n    Host[^ host note].\n}\ninclude [other#part] {}\n",
+		"other.page": "sec [Intro] {\n    Intro[^ intro note].\n}\n" +
+			"sec [Part] {\n    Part[^ part note].\n}\n",
+	})
+	content := string(displayPage(t, w, "host").Content)
+
+	// numbered across both pages, and listed once by the host
+	for _, id := range []string{`id="qa-refsrc-1"`, `id="qa-refsrc-2"`, `id="qa-ref-1"`, `id="qa-ref-2"`} {
+		if n := strings.Count(content, id); n != 1 {
+			t.Errorf("%s appears %d times in:\n%s", id, n, content)
+		}
+	}
+	if strings.Contains(content, "intro note") || !strings.Contains(content, "part note") {
+		t.Errorf("wrong footnotes listed in:\n%s", content)
+	}
+}
+
+func TestIncludeDraft(t *testing.T) {
+	w := newTestWiki(t, map[string]string{
+		"host.page":  "include [draft] {}\nafter\n",
+		"draft.page": "@page.draft;\nsecret\n",
+	})
+	r := displayPage(t, w, "host")
+	if strings.Contains(string(r.Content), "secret") {
+		t.Errorf("draft was included:\n%s", r.Content)
+	}
+	if len(r.Warnings) == 0 || !strings.Contains(r.Warnings[0].Message, "draft") {
+		t.Errorf("no warning about the draft: %v", r.Warnings)
+	}
+}
+
+func TestGetIncludingPages(t *testing.T) {
+	w := newTestWiki(t, map[string]string{
+		"host.page":   "include [other] {}\n",
+		"linker.page": "[[ other ]]\n",
+		"other.page":  "text\n",
+	})
+	displayPage(t, w, "host")
+	displayPage(t, w, "linker")
+
+	// pages which only link to it are not affected by changes
+	if got, want := w.GetIncludingPages("other"), []string{"host.page"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetIncludingPages = %v, want %v", got, want)
+	}
+}
+
+func TestIncludeOutsideWiki(t *testing.T) {
+	w := newTestWiki(t, map[string]string{"other.page": "text\n"})
+	secret := filepath.Join(w.Opt.Dir.Wiki, "secret.page")
+	if err := os.WriteFile(secret, []byte("secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, w.PathForPage("link.page")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"../secret.page", "../secret", "other/../../secret", secret, "link"} {
+		path := w.PathForPage("host.page")
+		if err := os.WriteFile(path, []byte("include ["+name+"] {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.RegeneratePage("host.page"); err != nil {
+			t.Fatal(err)
+		}
+		r := displayPage(t, w, "host")
+		if strings.Contains(string(r.Content), "secret") {
+			t.Errorf("%s: file outside the wiki was included:\n%s", name, r.Content)
+		}
+		if len(r.Warnings) == 0 || !strings.Contains(r.Warnings[0].Message, "not within the wiki") {
+			t.Errorf("%s: no warning about the page: %v", name, r.Warnings)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package wiki
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+// A MergeConflictError is returned when changes to a file cannot be merged
+// with other changes made to it since the revision they were based on.
+type MergeConflictError struct {
+
+	// file path relative to the wiki directory
+	File string `json:"file"`
+
+	// revision the rejected changes were based on
+	Base string `json:"base"`
+
+	// latest revision. after resolving conflicts, use this as the new base
+	Latest string `json:"latest"`
+
+	// conflicting regions
+	Conflicts []MergeConflict `json:"conflicts"`
+
+	// merged content, with conflicting regions surrounded by markers
+	Merged string `json:"merged"`
+}
+
+// A MergeConflict is a region of a file changed differently on each side.
+type MergeConflict struct {
+
+	// line number in Merged where the conflict markers begin
+	Line int `json:"line"`
+
+	// the region as it was in the base revision
+	Base string `json:"base"`
+
+	// the region with the rejected changes
+	Ours string `json:"ours"`
+
+	// the region with the changes made since the base revision
+	Theirs string `json:"theirs"`
+}
+
+func (e *MergeConflictError) Error() string {
+	n := len(e.Conflicts)
+	s := "s"
+	if n == 1 {
+		s = ""
+	}
+	return fmt.Sprintf("%s was modified since revision %s; %d conflicting change%s", e.File, shortHash(e.Base), n, s)
+}
+
+// mergeWithBase merges content based on revision base with changes made to
+// the file at path since then. relPath is relative to the wiki directory
+func (w *Wiki) mergeWithBase(relPath, path string, ours []byte, base string) ([]byte, error) {
+
+	// current content, which might have been deleted
+	theirs, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// content at base, which might not have existed yet
+	baseContent, err := w.FileAtRevision(relPath, base)
+	if err != nil && !errors.Is(err, object.ErrFileNotFound) {
+		return nil, err
+	}
+
+	// nothing changed since base, or the same changes were made
+	if bytes.Equal(theirs, baseContent) || bytes.Equal(theirs, ours) {
+		return ours, nil
+	}
+
+	merged, conflicts := merge3(baseContent, ours, theirs)
+	if len(conflicts) != 0 {
+		latest, _ := w.GetLatestCommitHash()
+		return nil, &MergeConflictError{
+			File:      relPath,
+			Base:      base,
+			Latest:    latest,
+			Conflicts: conflicts,
+			Merged:    string(merged),
+		}
+	}
+
+	return merged, nil
+}
+
+// merge3 performs a three-way line merge. changes from base to ours and from
+// base to theirs are combined. regions changed differently on both sides are
+// returned as conflicts and included in the output with conflict markers
+func merge3(base, ours, theirs []byte) ([]byte, []MergeConflict) {
+	b, o, t := splitLines(base), splitLines(ours), splitLines(theirs)
+	mo, mt := lcsMatches(b, o), lcsMatches(b, t)
+
+	var out bytes.Buffer
+	var conflicts []MergeConflict
+	line := 1
+	write := func(lines []string) {
+		for _, l := range lines {
+			out.WriteString(l)
+			line++
+		}
+	}
+
+	// walk through regions between lines unchanged on both sides
+	bi, oi, ti := 0, 0, 0
+	for {
+
+		// find the next line unchanged on both sides
+		bj := bi
+		for bj < len(b) && (mo[bj] == -1 || mt[bj] == -1) {
+			bj++
+		}
+		oj, tj := len(o), len(t)
+		if bj < len(b) {
+			oj, tj = mo[bj], mt[bj]
+		}
+
+		// merge the region before it
+		bc, oc, tc := b[bi:bj], o[oi:oj], t[ti:tj]
+		switch {
+		case equalLines(oc, bc):
+			write(tc)
+		case equalLines(tc, bc), equalLines(oc, tc):
+			write(oc)
+		default:
+			conflicts = append(conflicts, MergeConflict{
+				Line:   line,
+				Base:   strings.Join(bc, ""),
+				Ours:   strings.Join(oc, ""),
+				Theirs: strings.Join(tc, ""),
+			})
+			write([]string{"<<<<<<< yours\n"})
+			write(terminated(oc))
+			write([]string{"=======\n"})
+			write(terminated(tc))
+			write([]string{">>>>>>> latest\n"})
+		}
+
+		// reached the end
+		if bj == len(b) {
+			break
+		}
+
+		// write the unchanged line
+		write(b[bj : bj+1])
+		bi, oi, ti = bj+1, oj+1, tj+1
+	}
+
+	return out.Bytes(), conflicts
+}
+
+// splitLines splits text into lines, each including its newline
+func splitLines(text []byte) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(text), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// terminated ensures the last line ends with a newline
+func terminated(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	lines = append([]string(nil), lines...)
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lcsMatches finds the longest common subsequence of lines using the linear
+// space variant of the Myers diff algorithm. it returns, for each line in a,
+// the index of the matching line in b or -1 if the line is not common to both
+func lcsMatches(a, b []string) []int {
+
+	// compare numbers rather than strings
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		nums := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			nums[i] = id
+		}
+		return nums
+	}
+	aNums, bNums := intern(a), intern(b)
+
+	// lines found on only one side can't match, so leave them out
+	inA, inB := make(map[int]bool), make(map[int]bool)
+	for _, id := range aNums {
+		inA[id] = true
+	}
+	for _, id := range bNums {
+		inB[id] = true
+	}
+	l := &lcs{}
+	var aIdx, bIdx []int
+	for i, id := range aNums {
+		if inB[id] {
+			l.a = append(l.a, id)
+			aIdx = append(aIdx, i)
+		}
+	}
+	for i, id := range bNums {
+		if inA[id] {
+			l.b = append(l.b, id)
+			bIdx = append(bIdx, i)
+		}
+	}
+
+	l.match = make([]int, len(l.a))
+	for i := range l.match {
+		l.match[i] = -1
+	}
+	l.compare(0, len(l.a), 0, len(l.b))
+
+	// convert back to the original line numbers
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	for i, j := range l.match {
+		if j != -1 {
+			match[aIdx[i]] = bIdx[j]
+		}
+	}
+	return match
+}
+
+type lcs struct {
+	a, b  []int
+	match []int
+}
+
+// compare finds the matches between a[aLo:aHi] and b[bLo:bHi]
+func (l *lcs) compare(aLo, aHi, bLo, bHi int) {
+
+	// common prefix and suffix
+	for aLo < aHi && bLo < bHi && l.a[aLo] == l.b[bLo] {
+		l.match[aLo] = bLo
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && l.a[aHi-1] == l.b[bHi-1] {
+		aHi--
+		bHi--
+		l.match[aHi] = bHi
+	}
+	if aLo == aHi || bLo == bHi {
+		return
+	}
+
+	// split where the paths from each end meet, then compare each half
+	x, y, ok := l.bisect(aLo, aHi, bLo, bHi)
+	if !ok {
+		return // nothing in common
+	}
+	l.compare(aLo, x, bLo, y)
+	l.compare(x, aHi, y, bHi)
+}
+
+// how far bisect searches from each end. time grows with the square of this,
+// so very different versions are considered to have nothing in common. this
+// only makes conflicts larger
+const lcsMaxD = 1000
+
+// bisect searches forward from the start and backward from the end at once,
+// and returns the point where the paths overlap. each half of the shortest
+// edit script is then on one side of it. only the furthest point reached on
+// each diagonal is kept, so space is linear
+func (l *lcs) bisect(aLo, aHi, bLo, bHi int) (x, y int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := min((n+m+1)/2, lcsMaxD)
+	off := maxD + 1
+	vf, vb := make([]int, 2*maxD+3), make([]int, 2*maxD+3)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[off+1], vb[off+1] = 0, 0
+
+	// if the difference in length is odd, the forward path finds the
+	// overlap; otherwise, the backward one does
+	delta := n - m
+	front := delta%2 != 0
+
+	// diagonals which went past the edges are skipped
+	var kfStart, kfEnd, kbStart, kbEnd int
+
+	for d := 0; d < maxD; d++ {
+
+		// forward
+		for k := -d + kfStart; k <= d-kfEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && l.a[aLo+x] == l.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			switch {
+			case x > n:
+				kfEnd += 2
+			case y > m:
+				kfStart += 2
+			case front:
+				kb := delta - k
+				if kb >= -d && kb <= d && vb[off+kb] != -1 && x >= n-vb[off+kb] {
+					return aLo + x, bLo + y, true
+				}
+			}
+		}
+
+		// backward, counting from the end
+		for k := -d + kbStart; k <= d-kbEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && l.a[aHi-x-1] == l.b[bHi-y-1] {
+				x++
+				y++
+			}
+			vb[off+k] = x
+			switch {
+			case x > n:
+				kbEnd += 2
+			case y > m:
+				kbStart += 2
+			case !front:
+				kf := delta - k
+				if kf >= -d && kf <= d && vf[off+kf] != -1 && vf[off+kf] >= n-x {
+					xf := vf[off+kf]
+					return aLo + xf, bLo + xf - kf, true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,115 @@
+package wiki
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// lcsLength finds the length of the longest common subsequence the slow way
+func lcsLength(a, b []string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func TestLCSMatches(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, r.Intn(40))
+		for i := range lines {
+			lines[i] = strconv.Itoa(r.Intn(6))
+		}
+		return lines
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(), randomLines()
+		match := lcsMatches(a, b)
+
+		// matches are in order and are of equal lines
+		n, last := 0, -1
+		for x, y := range match {
+			if y == -1 {
+				continue
+			}
+			if y <= last || a[x] != b[y] {
+				t.Fatalf("%v and %v: bad match %d -> %d in %v", a, b, x, y, match)
+			}
+			n, last = n+1, y
+		}
+
+		// and there are as many as possible
+		if want := lcsLength(a, b); n != want {
+			t.Fatalf("%v and %v: %d matches, want %d", a, b, n, want)
+		}
+	}
+}
+
+func TestLCSMatchesLarge(t *testing.T) {
+
+	// mostly different versions of a long page. this used to need memory
+	// proportional to the square of the number of lines
+	r := rand.New(rand.NewSource(1))
+	a, b := make([]string, 20000), make([]string, 20000)
+	for i := range a {
+		a[i] = strconv.Itoa(r.Intn(1000))
+		b[i] = strconv.Itoa(r.Intn(1000))
+	}
+	if match := lcsMatches(a, b); len(match) != len(a) {
+		t.Fatalf("%d matches for %d lines", len(match), len(a))
+	}
+
+	// but a few changes to a long page are still found
+	b = append([]string(nil), a...)
+	for i := 0; i < 100; i++ {
+		b[r.Intn(len(b))] = "changed"
+	}
+	n := 0
+	for _, y := range lcsMatches(a, b) {
+		if y != -1 {
+			n++
+		}
+	}
+	if n < len(a)-100 {
+		t.Errorf("%d matches, want at least %d", n, len(a)-100)
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		name, ours, theirs, want string
+		conflicts                int
+	}{
+		{"separate changes", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n", 0},
+		{"same change", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n", 0},
+		{"insert and delete", "a\nb\nx\nc\nd\ne\n", "a\nb\nc\ne\n", "a\nb\nx\nc\ne\n", 0},
+		{"conflict", "a\nb\nours\nd\ne\n", "a\nb\ntheirs\nd\ne\n",
+			"a\nb\n<<<<<<< yours\nours\n=======\ntheirs\n>>>>>>> latest\nd\ne\n", 1},
+	}
+	for _, test := range tests {
+		merged, conflicts := merge3([]byte(base), []byte(test.ours), []byte(test.theirs))
+		if string(merged) != test.want {
+			t.Errorf("%s: merged\n%s\nwant\n%s", test.name, merged, test.want)
+		}
+		if len(conflicts) != test.conflicts {
+			t.Errorf("%s: %d conflicts, want %d", test.name, len(conflicts), test.conflicts)
+		}
+		for _, c := range conflicts {
+			if !strings.HasPrefix(strings.Split(string(merged), "\n")[c.Line-1], "<<<<<<<") {
+				t.Errorf("%s: conflict line %d is not a marker", test.name, c.Line)
+			}
+		}
+	}
+}
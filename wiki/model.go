@@ -122,6 +122,14 @@ func (w *Wiki) ModelInfo(name string) (info wikifier.ModelInfo) {
 	// if model category exists use that info
 	if modelCat.Exists() && modelCat.ModelInfo != nil {
 		info = *modelCat.ModelInfo
+	} else {
+		// the model has not been used yet, so read its variables
+		model := wikifier.NewModel(path, name)
+		model.Opt = &w.Opt
+		model.VarsOnly = true
+		if model.Parse() == nil {
+			info = model.ModelInfo()
+		}
 	}
 
 	// this stuff is available to all
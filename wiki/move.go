@@ -0,0 +1,387 @@
+package wiki
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cooper/quiki/wikifier"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/pkg/errors"
+)
+
+// matches [[ page links ]]
+var linkRewriteRegex = regexp.MustCompile(`\[\[(.*?)\]\]`)
+
+// matches other types of link targets which are never page links
+var nonPageLinkRegex = regexp.MustCompile(`^([\w]+://|\$|mailto:|~|[^@\s]+@[^@\s]+$|\w+:)`)
+
+// MovePage renames a page.
+//
+// Links to the page found in referencing pages are rewritten to the new name.
+// All changes are committed in a single revision.
+//
+// Only links in quiki source pages are rewritten; links in markdown pages
+// are left untouched.
+func (w *Wiki) MovePage(oldName, newName string, commit CommitOpts) error {
+	return w.movePage(oldName, newName, false, commit)
+}
+
+// MovePageRedirect is like MovePage, except that a page redirecting to the
+// new name is left in place of the old one.
+func (w *Wiki) MovePageRedirect(oldName, newName string, commit CommitOpts) error {
+	return w.movePage(oldName, newName, true, commit)
+}
+
+func (w *Wiki) movePage(oldName, newName string, redirect bool, commit CommitOpts) error {
+
+	// find the existing page
+	page := w.FindPage(oldName)
+	if !page.Exists() {
+		return errors.New("page does not exist: " + oldName)
+	}
+	oldNE := page.NameNE()
+
+	// keep the same extension unless the new name specifies one
+	ext := filepath.Ext(page.Name())
+	newName = wikifier.PageNameExt(newName, ext)
+	newNE := wikifier.PageNameNE(newName)
+	newPath, _ := filepath.Abs(filepath.Join(w.Opt.Dir.Page, filepath.FromSlash(newName)))
+	if w.FindPage(newNE).Exists() {
+		return errors.New("page already exists: " + newName)
+	}
+
+	// lock the page and every page which will be rewritten, so that
+	// concurrent edits to them are not lost
+	var refNames []string
+	for _, refName := range w.GetReferencingPages(oldNE) {
+		if refName != page.Name() && filepath.Ext(refName) == ".page" {
+			refNames = append(refNames, refName)
+		}
+	}
+	defer w.lockPages(append(refNames, page.Name(), newName))()
+
+	// rewrite links in each page which references this one
+	changes := make(map[string][]byte)
+	for _, refName := range refNames {
+		refPath := w.PathForPage(refName)
+		content, err := os.ReadFile(refPath)
+		if err != nil {
+			continue
+		}
+		if newContent, n := rewritePageLinks(content, wikifier.PageNameNE(refName), oldNE, newNE); n != 0 {
+			changes[refPath] = newContent
+		}
+	}
+
+	// leave a redirect behind
+	if redirect {
+		stub := "@page.redirect: " + escLinkTarget("/"+newNE) + ";\n"
+		changes[w.PathForPage(wikifier.PageNameExt(oldNE, ".page"))] = []byte(stub)
+	}
+
+	comment := "Move " + page.Name() + " to " + newName
+	if err := w.moveAndCommit(page.Path(), newPath, changes, comment, commit); err != nil {
+		return err
+	}
+
+	// the old cache and text files are no longer valid
+	os.Remove(page.CachePath())
+	os.Remove(w.searchTextPath(page.Name()))
+
+	return nil
+}
+
+// MoveImage renames an image.
+//
+// References to the image in pages which use it are rewritten to the new
+// name. All changes are committed in a single revision.
+func (w *Wiki) MoveImage(oldName, newName string, commit CommitOpts) error {
+	oldPath, newPath := w.PathForImage(oldName), w.PathForImage(newName)
+	if _, err := os.Lstat(oldPath); err != nil {
+		return errors.New("image does not exist: " + oldName)
+	}
+	if _, err := os.Lstat(newPath); err == nil {
+		return errors.New("image already exists: " + newName)
+	}
+
+	// lock the image and the pages which use it
+	imageLock := w.GetImageLock(oldName)
+	imageLock.Lock()
+	defer imageLock.Unlock()
+	cat := w.GetSpecialCategory(oldName, CategoryTypeImage)
+	refNames := pageSourceNames(cat)
+	defer w.lockPages(refNames)()
+
+	// rewrite references to the image
+	changes := make(map[string][]byte)
+	for _, refName := range refNames {
+		refPath := w.PathForPage(refName)
+		content, err := os.ReadFile(refPath)
+		if err != nil {
+			continue
+		}
+		if newContent, n := rewriteImageRefs(content, oldName, newName); n != 0 {
+			changes[refPath] = newContent
+		}
+	}
+
+	return w.moveAndCommit(oldPath, newPath, changes, "Move "+oldName+" to "+newName, commit)
+}
+
+// MoveModel renames a model.
+//
+// Usages of the model in pages are rewritten to the new name. All changes
+// are committed in a single revision.
+func (w *Wiki) MoveModel(oldName, newName string, commit CommitOpts) error {
+	oldFile, newFile := wikifier.ModelName(oldName), wikifier.ModelName(newName)
+	oldPath, newPath := w.PathForModel(oldFile), w.PathForModel(newFile)
+	if _, err := os.Lstat(oldPath); err != nil {
+		return errors.New("model does not exist: " + oldName)
+	}
+	if _, err := os.Lstat(newPath); err == nil {
+		return errors.New("model already exists: " + newName)
+	}
+
+	// lock the pages which use it
+	cat := w.GetSpecialCategory(oldFile, CategoryTypeModel)
+	refNames := pageSourceNames(cat)
+	defer w.lockPages(refNames)()
+
+	// rewrite usages of the model
+	changes := make(map[string][]byte)
+	for _, refName := range refNames {
+		refPath := w.PathForPage(refName)
+		content, err := os.ReadFile(refPath)
+		if err != nil {
+			continue
+		}
+		if newContent, n := rewriteModelRefs(content, wikifier.PageNameNE(oldFile), wikifier.PageNameNE(newFile)); n != 0 {
+			changes[refPath] = newContent
+		}
+	}
+
+	return w.moveAndCommit(oldPath, newPath, changes, "Move "+oldFile+" to "+newFile, commit)
+}
+
+// pageSourceNames returns the quiki source pages in a category
+func pageSourceNames(cat *Category) []string {
+	var names []string
+	for name := range cat.Pages {
+		if filepath.Ext(name) == ".page" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// lockPages locks several pages in a consistent order, so that two moves
+// can't each wait for the other. it returns a function to unlock them
+func (w *Wiki) lockPages(names []string) func() {
+	names = slices.Compact(slices.Sorted(slices.Values(names)))
+	for _, name := range names {
+		w.GetPageLock(name).Lock()
+	}
+	return func() {
+		for _, name := range names {
+			w.GetPageLock(name).Unlock()
+		}
+	}
+}
+
+// moveAndCommit renames a file, writes any other changed files, and commits
+// everything at once. paths are absolute. if anything fails, the files and
+// index are put back as they were
+func (w *Wiki) moveAndCommit(oldPath, newPath string, changes map[string][]byte, comment string, commit CommitOpts) (err error) {
+	oldRel, newRel := w.RelPath(oldPath), w.RelPath(newPath)
+	if oldRel == "" || newRel == "" {
+		return errors.New("path outside of wiki directory")
+	}
+
+	// remember the files to be changed, so they can be restored
+	originals := make(map[string][]byte)
+	for path := range changes {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			originals[path] = content
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// get repo
+	repo, err := w.repo()
+	if err != nil {
+		return err
+	}
+
+	// get worktree
+	wt, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrap(err, "git:repo:Worktree")
+	}
+
+	// ensure parent directories exist
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+
+	// undo everything if something fails from here on
+	moved := false
+	var written []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, path := range written {
+			if content, ok := originals[path]; ok {
+				os.WriteFile(path, content, 0644)
+			} else {
+				os.Remove(path)
+			}
+		}
+		if moved {
+			os.Rename(newPath, oldPath)
+		}
+		if head, headErr := repo.Head(); headErr == nil {
+			wt.Reset(&git.ResetOptions{Commit: head.Hash(), Mode: git.MixedReset})
+		}
+	}()
+
+	// move the file. if it is not yet tracked, move and add it
+	_, err = wt.Move(oldRel, newRel)
+	if errors.Is(err, index.ErrEntryNotFound) {
+		if err = os.Rename(oldPath, newPath); err == nil {
+			moved = true
+			_, err = wt.Add(newRel)
+		}
+	} else if err == nil {
+		moved = true
+	}
+	if err != nil {
+		return errors.Wrap(err, "git:worktree:Move")
+	}
+
+	// write other changes, such as rewritten links
+	for path, content := range changes {
+		written = append(written, path)
+		if err = w.writeFileNoCommit(wt, path, content); err != nil {
+			return err
+		}
+	}
+
+	return w.andCommit(wt, comment, commit)
+}
+
+// writeFileNoCommit writes a file and adds it to the worktree without
+// committing, refusing to write to symlinks like writeFile.
+func (w *Wiki) writeFileNoCommit(wt *git.Worktree, path string, content []byte) error {
+	relPath := w.RelPath(path)
+	if relPath == "" {
+		return errors.New("path outside of wiki directory")
+	}
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		return errors.New("refusing to write to symlinked file")
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return err
+	}
+	_, err := wt.Add(relPath)
+	return err
+}
+
+// rewritePageLinks rewrites [[ links ]] to oldNE found in the source of the
+// page refNE so that they point to newNE. returns the number rewritten
+func rewritePageLinks(content []byte, refNE, oldNE, newNE string) ([]byte, int) {
+
+	// links without a leading slash are relative to the page prefix
+	refPfx := ""
+	if i := strings.LastIndexByte(refNE, '/'); i != -1 {
+		refPfx = refNE[:i+1]
+	}
+
+	n := 0
+	content = linkRewriteRegex.ReplaceAllFunc(content, func(match []byte) []byte {
+		inner := string(match[2 : len(match)-2])
+
+		// separate display|target
+		display, target := "", inner
+		if i := strings.IndexByte(inner, '|'); i != -1 {
+			display, target = inner[:i+1], inner[i+1:]
+		}
+
+		// separate target#section
+		pageTarget, sec := target, ""
+		if i := strings.IndexByte(target, '#'); i != -1 {
+			pageTarget, sec = target[:i], target[i:]
+		}
+
+		// preserve surrounding whitespace
+		trimmed := strings.TrimSpace(pageTarget)
+		if trimmed == "" || nonPageLinkRegex.MatchString(trimmed) {
+			return match
+		}
+		lead := pageTarget[:strings.Index(pageTarget, trimmed)]
+		trail := pageTarget[len(lead)+len(trimmed):]
+
+		// resolve the target like the link parser does
+		abs := strings.HasPrefix(trimmed, "/")
+		resolved := refPfx + trimmed
+		if abs {
+			resolved = trimmed[1:]
+		}
+		resolved = wikifier.PageNameNE(resolved)
+		if resolved != oldNE && strings.ToLower(resolved) != oldNE {
+			return match
+		}
+
+		// prefer a relative target when possible
+		newTarget := "/" + newNE
+		if !abs && strings.HasPrefix(newNE, refPfx) {
+			newTarget = strings.TrimPrefix(newNE, refPfx)
+		}
+
+		n++
+		return []byte("[[" + display + lead + escLinkTarget(newTarget) + trail + sec + "]]")
+	})
+	return content, n
+}
+
+// rewriteImageRefs replaces an image filename where it is the file of
+// image{}, imagebox{}, and similar blocks, or @page.image
+func rewriteImageRefs(content []byte, oldName, newName string) ([]byte, int) {
+	n := 0
+	repl := []byte("${1}" + strings.ReplaceAll(escLinkTarget(newName), "$", "$$") + "${2}")
+	for _, rgx := range []*regexp.Regexp{
+		regexp.MustCompile(`(\bfile\s*:\s*)` + regexp.QuoteMeta(oldName) + `(\s*[;}])`),
+		regexp.MustCompile(`(@page\.image\s*:\s*)` + regexp.QuoteMeta(oldName) + `(\s*;)`),
+	} {
+		n += len(rgx.FindAllIndex(content, -1))
+		content = rgx.ReplaceAll(content, repl)
+	}
+	return content, n
+}
+
+// rewriteModelRefs replaces $model{} and model [model] {} usages
+func rewriteModelRefs(content []byte, oldNE, newNE string) ([]byte, int) {
+	n := 0
+	for _, rgx := range []*regexp.Regexp{
+		regexp.MustCompile(`(\$)` + regexp.QuoteMeta(oldNE) + `(\s*[{.])`),
+		regexp.MustCompile(`(\bmodel(?:\.[\w.]+)?\s*\[\s*)` + regexp.QuoteMeta(oldNE) + `(\s*\])`),
+	} {
+		n += len(rgx.FindAllIndex(content, -1))
+		content = rgx.ReplaceAll(content, []byte("${1}"+newNE+"${2}"))
+	}
+	return content, n
+}
+
+// escape a link target for use in quiki source
+func escLinkTarget(s string) string {
+	for _, c := range []string{"\\", "[", "]", "{", "}", "|", ";"} {
+		s = strings.ReplaceAll(s, c, "\\"+c)
+	}
+	return s
+}
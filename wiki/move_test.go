@@ -0,0 +1,120 @@
+package wiki
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMovePage(t *testing.T) {
+	for _, redirect := range []bool{false, true} {
+		w := newTestWiki(t, map[string]string{
+			"a.page": "@page.title: A;\n\nsee [[ b ]] and [[ other | b ]].\n",
+			"b.page": "@page.title: B;\n\nhello\n",
+		})
+		if _, err := w.repo(); err != nil {
+			t.Fatal(err)
+		}
+		displayPage(t, w, "a")
+
+		move := w.MovePage
+		if redirect {
+			move = w.MovePageRedirect
+		}
+		if err := move("b", "c", CommitOpts{Name: "alice", Email: "alice@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if !w.FindPage("c").Exists() {
+			t.Errorf("redirect=%v: c does not exist", redirect)
+		}
+		a, _ := os.ReadFile(w.PathForPage("a.page"))
+		if want := "see [[ c ]] and [[ other | c ]]."; !strings.Contains(string(a), want) {
+			t.Errorf("redirect=%v: a = %q, want %q", redirect, a, want)
+		}
+
+		b, err := os.ReadFile(w.PathForPage("b.page"))
+		switch {
+		case redirect && !strings.Contains(string(b), "@page.redirect: /c;"):
+			t.Errorf("redirect=%v: b = %q, %v", redirect, b, err)
+		case !redirect && !os.IsNotExist(err):
+			t.Errorf("redirect=%v: b still exists", redirect)
+		}
+	}
+}
+
+func TestRewriteImageRefs(t *testing.T) {
+	source := `@page.image: x.png;
+
+image {
+    file: x.png;
+}
+imagebox { file: x.png }
+
+see x.png for details.
+
+code {
+    file: x.png.old;
+}
+`
+	got, n := rewriteImageRefs([]byte(source), "x.png", "y.png")
+	want := `@page.image: y.png;
+
+image {
+    file: y.png;
+}
+imagebox { file: y.png }
+
+see x.png for details.
+
+code {
+    file: x.png.old;
+}
+`
+	if n != 3 || string(got) != want {
+		t.Errorf("rewriteImageRefs = %d\n%s\nwant 3\n%s", n, got, want)
+	}
+}
+
+func TestMoveRollback(t *testing.T) {
+	w := newTestWiki(t, map[string]string{
+		"a.page":      "a\n",
+		"b.page":      "b\n",
+		"target.page": "target\n",
+	})
+	if _, err := w.repo(); err != nil {
+		t.Fatal(err)
+	}
+
+	// writing through a symlink fails, after the move and maybe other writes
+	link := w.PathForPage("link.page")
+	if err := os.Symlink(w.PathForPage("target.page"), link); err != nil {
+		t.Fatal(err)
+	}
+	oldPath, newPath := w.PathForPage("a.page"), filepath.Join(w.Opt.Dir.Page, "moved.page")
+	changes := map[string][]byte{
+		w.PathForPage("b.page"): []byte("changed\n"),
+		link:                    []byte("changed\n"),
+	}
+	if err := w.moveAndCommit(oldPath, newPath, changes, "move", CommitOpts{}); err == nil {
+		t.Fatal("moveAndCommit: no error")
+	}
+
+	for name, want := range map[string]string{"a.page": "a\n", "b.page": "b\n", "target.page": "target\n"} {
+		if got, _ := os.ReadFile(w.PathForPage(name)); string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat(newPath); !os.IsNotExist(err) {
+		t.Error("moved.page still exists")
+	}
+	repo, _ := w.repo()
+	wt, _ := repo.Worktree()
+	status, _ := wt.Status()
+	for file, s := range status {
+		if s.Staging != ' ' && s.Staging != '?' {
+			t.Errorf("%s is still staged (%c)", file, s.Staging)
+		}
+	}
+}
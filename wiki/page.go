@@ -1,12 +1,14 @@
 package wiki
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -90,13 +92,21 @@ type DisplayPage struct {
 	// page keywords as extracted from the special @page.keywords variable
 	Keywords []string `json:"keywords,omitempty"`
 
+	// image representing the page as extracted from the special @page.image
+	// variable, sized for link previews
+	Image *wikifier.PageImage `json:"image,omitempty"`
+
 	// first formatting-stripped 25 words of page, up to 150 chars
 	Preview string `json:"preview,omitempty"`
+
+	// if the page was rendered from an earlier revision, its commit hash
+	Revision string `json:"revision,omitempty"`
 }
 
 type pageJSONManifest struct {
 	CSS        string   `json:"css,omitempty"`
 	Categories []string `json:"categories,omitempty"`
+	Includes   []string `json:"includes,omitempty"`
 	wikifier.PageInfo
 }
 
@@ -228,8 +238,12 @@ func (w *Wiki) DisplayPageDraft(name string, draftOK bool) any {
 	}
 
 	// if this is a draft and we're not serving drafts, pretend
-	// that the page does not exist
+	// that the page does not exist. its info is updated so that
+	// a page which was published before is no longer listed
 	if !draftOK && page.Draft() {
+		w.WithCategoryBatching(func() {
+			w.updatePageCategories(page)
+		})
 		return DisplayError{Error: "Page has not yet been published.", Draft: true}
 	}
 
@@ -262,12 +276,14 @@ func (w *Wiki) DisplayPageDraft(name string, draftOK bool) any {
 	r.Author = page.Author()
 	r.Description = page.Description()
 	r.Keywords = page.Keywords()
+	r.Image = page.Image()
 	r.Draft = page.Draft()
 	r.Modified = &mod
 	r.ModifiedHTTP = httpdate.Time2Str(mod)
 	r.Content = page.HTML()
 	r.CSS = page.CSS()
 	r.Warnings = page.Warnings
+	r.Preview = page.Preview()
 
 	// update categories
 	w.WithCategoryBatching(func() {
@@ -292,6 +308,75 @@ func (w *Wiki) DisplayPageDraft(name string, draftOK bool) any {
 	return r
 }
 
+// DisplayPageRevision returns the display result for a page as it existed
+// at the given revision.
+//
+// The source is read from the git history, so the working tree, cache, and
+// categories are not touched. Drafts are served.
+func (w *Wiki) DisplayPageRevision(name, commitHash string) any {
+	var r DisplayPage
+
+	// find the page source at this revision
+	page := w.FindPage(name)
+	path := w.PathForPage(page.Name())
+	content, commit, err := w.fileAtRevision(w.RelPath(path), commitHash)
+	if err != nil {
+		return DisplayError{
+			Error:         "Page does not exist at this revision.",
+			DetailedError: err.Error(),
+		}
+	}
+
+	// an empty Source would fall back to reading the file
+	page.Source = string(content)
+	if page.Source == "" {
+		page.Source = "\n"
+	}
+
+	// filename and path info
+	r.File = page.Name()
+	r.Name = page.NameNE()
+	r.Path = path
+	r.Revision = commit.Hash.String()
+
+	// parse the historical source
+	if err := page.Parse(); err != nil {
+		var pErr *wikifier.ParserError
+		errors.As(err, &pErr)
+		return DisplayError{Error: err.Error(), Pos: pErr.Pos}
+	}
+
+	// it was a redirect at the time
+	if redir := page.Redirect(); redir != "" {
+		return DisplayRedirect{Redirect: redir}
+	}
+
+	// generate HTML and metadata
+	create := page.Created()
+	if !create.IsZero() {
+		r.Created = &create
+		r.CreatedHTTP = httpdate.Time2Str(create)
+	}
+	mod := commit.Committer.When
+	r.Generated = true
+	r.Title = page.Title()
+	r.FmtTitle = page.FmtTitle()
+	r.Author = page.Author()
+	r.Description = page.Description()
+	r.Keywords = page.Keywords()
+	r.Image = page.Image()
+	r.Draft = page.Draft()
+	r.Modified = &mod
+	r.ModifiedHTTP = httpdate.Time2Str(mod)
+	r.Content = page.HTML()
+	r.CSS = page.CSS()
+	r.Warnings = page.Warnings
+	r.Categories = page.Categories()
+	r.Preview = page.Preview()
+
+	return r
+}
+
 // Pages returns info about all the pages in the wiki.
 func (w *Wiki) Pages() []wikifier.PageInfo {
 	pageNames := w.allPageFiles()
@@ -338,6 +423,131 @@ func (w *Wiki) PagesSorted(descend bool, sorters ...SortFunc) []wikifier.PageInf
 	return _pagesSorted(w.Pages(), descend, sorters...)
 }
 
+// PagesMatching returns info about the pages matching a query, sorted and
+// limited as specified by the query.
+//
+// Pages are matched on their info as of when they were last generated.
+// Pages which have not been generated yet are parsed for their variables.
+func (w *Wiki) PagesMatching(q wikifier.PageQuery) []wikifier.PageInfo {
+	prefix := strings.ToLower(filepath.ToSlash(q.Prefix))
+	var pages []wikifier.PageInfo
+	for _, name := range w.allPageFiles() {
+		if prefix != "" && !strings.HasPrefix(strings.ToLower(filepath.ToSlash(name)), prefix) {
+			continue
+		}
+		info, qi := w.pageQueryInfo(name)
+		switch {
+		case info.Path == "", info.Redirect != "":
+			continue
+		case q.Draft == "yes" && !info.Draft, q.Draft == "no" && info.Draft:
+			continue
+		case q.Author != "" && !strings.EqualFold(info.Author, q.Author):
+			continue
+		case q.Category != "" && !slices.Contains(qi.Categories, q.Category):
+			continue
+		case !pageVarsMatch(qi.Vars, q.Vars):
+			continue
+		}
+		pages = append(pages, info)
+	}
+
+	// sort
+	sorters := []SortFunc{SortTitle}
+	switch q.Sort {
+	case "author":
+		sorters = []SortFunc{SortAuthor, SortTitle}
+	case "created":
+		sorters = []SortFunc{SortCreated, SortTitle}
+	case "modified":
+		sorters = []SortFunc{SortModified, SortTitle}
+	}
+	pages = _pagesSorted(pages, q.Descend, sorters...)
+
+	if q.Limit > 0 && len(pages) > q.Limit {
+		pages = pages[:q.Limit]
+	}
+	return pages
+}
+
+// CompletePageInfo is like PageInfo, except that if the page has not been
+// generated yet, its variables are read so that the info is complete.
+func (w *Wiki) CompletePageInfo(name string) wikifier.PageInfo {
+	info, _ := w.pageQueryInfo(name)
+	return info
+}
+
+// pageQueryInfo returns the info pages{} queries match a page on. it is
+// cached when the page is generated; until then, the page is parsed for it
+func (w *Wiki) pageQueryInfo(name string) (wikifier.PageInfo, PageQueryInfo) {
+	info := w.PageInfo(name)
+	if info.Path == "" {
+		return info, PageQueryInfo{}
+	}
+
+	// the page has been generated
+	pageCat := w.GetSpecialCategory(wikifier.PageNameNE(name), CategoryTypePage)
+	if pageCat.PageInfo != nil && pageCat.PageQueryInfo != nil {
+		return info, *pageCat.PageQueryInfo
+	}
+
+	// parse variables only
+	page := w.FindPage(name)
+	page.VarsOnly = true
+	if err := page.Parse(); err != nil {
+		return info, PageQueryInfo{}
+	}
+	parsed := page.Info()
+	parsed.Path, parsed.File, parsed.FileNE = info.Path, info.File, info.FileNE
+	parsed.Base, parsed.BaseNE = info.Base, info.BaseNE
+	parsed.Modified = info.Modified
+	if parsed.Created == nil {
+		parsed.Created = info.Created
+	}
+	return parsed, *newPageQueryInfo(page)
+}
+
+// newPageQueryInfo returns the info pages{} queries match a parsed page on
+func newPageQueryInfo(page *wikifier.Page) *PageQueryInfo {
+	qi := &PageQueryInfo{Vars: page.PageVars()}
+	for _, name := range page.Categories() {
+		qi.Categories = append(qi.Categories, wikifier.CategoryNameNE(name))
+	}
+	return qi
+}
+
+// pageVarsMatch returns true if the @page variables have the wanted values.
+// booleans match yes or true, and no or false
+func pageVarsMatch(have, want map[string]string) bool {
+	for key, val := range want {
+		switch strings.ToLower(val) {
+		case "true":
+			val = "yes"
+		case "false":
+			val = "no"
+		}
+		if !strings.EqualFold(have[key], val) {
+			return false
+		}
+	}
+	return true
+}
+
+// PageQueryState returns a summary of what pages{} queries match and sort a
+// page on, as of when it was last generated. if it differs after the page is
+// regenerated, pages with pages{} may need to list different pages.
+func (w *Wiki) PageQueryState(name string) string {
+	pageCat := w.GetSpecialCategory(wikifier.PageNameNE(name), CategoryTypePage)
+	if pageCat.PageInfo == nil {
+		return ""
+	}
+	info := pageCat.PageInfo
+	state, _ := json.Marshal([]any{
+		info.FmtTitle, info.Author, info.Description, info.Created,
+		info.Draft, info.Redirect, pageCat.PageQueryInfo,
+	})
+	return string(state)
+}
+
 func _pagesSorted(pages []wikifier.PageInfo, descend bool, sorters ...SortFunc) []wikifier.PageInfo {
 	// convert to []Sortable
 	sorted := make([]Sortable, len(pages))
@@ -481,6 +691,12 @@ func (w *Wiki) writePageCache(page *wikifier.Page, r *DisplayPage) any {
 		PageInfo:   page.Info(),
 	}
 
+	// pages included with include{}
+	for name := range page.Includes {
+		info.Includes = append(info.Includes, name)
+	}
+	sort.Strings(info.Includes)
+
 	// encode as json
 	j, err := json.Marshal(info)
 	if err != nil {
@@ -510,6 +726,22 @@ func (w *Wiki) writePageCache(page *wikifier.Page, r *DisplayPage) any {
 	return nil // success
 }
 
+// readCacheManifest reads the manifest from the first line of a page's
+// cache file.
+func readCacheManifest(page *wikifier.Page) (info pageJSONManifest, err error) {
+	cacheFile, err := os.Open(page.CachePath())
+	if err != nil {
+		return
+	}
+	defer cacheFile.Close()
+	jsonData, err := bufio.NewReader(cacheFile).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(jsonData, &info)
+	return
+}
+
 func (w *Wiki) writePageText(page *wikifier.Page, r *DisplayPage) any {
 
 	// search optimization isn't enabled
@@ -577,6 +809,15 @@ func (w *Wiki) displayCachedPage(page *wikifier.Page, r *DisplayPage, draftOK bo
 		}
 	}
 
+	// an included page is more recent than the cache file.
+	// discard the outdated cached copy
+	for _, name := range info.Includes {
+		if w.FindPage(name).Modified().After(cacheModify) {
+			os.Remove(page.CachePath())
+			return nil // OK
+		}
+	}
+
 	// if this is a draft and we're not serving drafts, pretend
 	// that the page does not exist
 	if !draftOK && info.Draft {
@@ -610,6 +851,11 @@ func (w *Wiki) displayCachedPage(page *wikifier.Page, r *DisplayPage, draftOK bo
 	r.FmtTitle = info.FmtTitle
 	r.Description = info.Description
 	r.Keywords = info.Keywords
+	r.Image = info.Image
+	r.Preview = info.Preview
+	if r.Preview == "" {
+		r.Preview = info.Description
+	}
 	r.Warnings = info.Warnings
 	r.FromCache = true
 	r.CSS = info.CSS
@@ -0,0 +1,132 @@
+package wiki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+var queryTestPages = map[string]string{
+	"guides/one.page": "@page.title: One;\n@page.author: Alice;\n@page.type: guide;\n@page.created: 1000;\n@category.docs;\none\n",
+	"guides/two.page": "@page.title: Two;\n@page.author: Bob;\n@page.type: guide;\n@page.featured;\n@page.created: 3000;\n@category.docs;\ntwo\n",
+	"three.page":      "@page.title: Three;\n@page.author: alice;\n@page.created: 2000;\n@category.docs;\nthree\n",
+	"draft.page":      "@page.title: Draft;\n@page.author: Alice;\n@page.draft;\n@category.docs;\ndraft\n",
+	"moved.page":      "@page.redirect: /three;\n",
+}
+
+// pageNames returns the names of pages in the order given
+func pageNames(pages []wikifier.PageInfo) []string {
+	names := make([]string, len(pages))
+	for i, info := range pages {
+		names[i] = info.FileNE
+	}
+	return names
+}
+
+func TestPagesMatching(t *testing.T) {
+	tests := []struct {
+		name  string
+		query wikifier.PageQuery
+		want  []string
+	}{
+		{"all", wikifier.PageQuery{Draft: "no"}, []string{"guides/one", "three", "guides/two"}},
+		{"category", wikifier.PageQuery{Draft: "no", Category: "docs"}, []string{"guides/one", "three", "guides/two"}},
+		{"no category", wikifier.PageQuery{Draft: "no", Category: "other"}, []string{}},
+		{"prefix", wikifier.PageQuery{Draft: "no", Prefix: "guides/"}, []string{"guides/one", "guides/two"}},
+		{"author", wikifier.PageQuery{Draft: "no", Author: "alice"}, []string{"guides/one", "three"}},
+		{"var", wikifier.PageQuery{Draft: "no", Vars: map[string]string{"type": "Guide"}}, []string{"guides/one", "guides/two"}},
+		{"bool var", wikifier.PageQuery{Draft: "no", Vars: map[string]string{"featured": "true"}}, []string{"guides/two"}},
+		{"two vars", wikifier.PageQuery{Draft: "no", Vars: map[string]string{"type": "guide", "author": "bob"}}, []string{"guides/two"}},
+		{"only drafts", wikifier.PageQuery{Draft: "yes"}, []string{"draft"}},
+		{"any drafts", wikifier.PageQuery{Draft: "any", Author: "Alice"}, []string{"draft", "guides/one", "three"}},
+		{"sort created", wikifier.PageQuery{Draft: "no", Sort: "created"}, []string{"guides/one", "three", "guides/two"}},
+		{"sort created desc", wikifier.PageQuery{Draft: "no", Sort: "created", Descend: true}, []string{"guides/two", "three", "guides/one"}},
+		{"sort author", wikifier.PageQuery{Draft: "no", Sort: "author"}, []string{"guides/one", "three", "guides/two"}},
+		{"limit", wikifier.PageQuery{Draft: "no", Sort: "created", Descend: true, Limit: 2}, []string{"guides/two", "three"}},
+	}
+
+	w := newTestWiki(t, queryTestPages)
+	run := func(when string) {
+		for _, test := range tests {
+			got := pageNames(w.PagesMatching(test.query))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%s: %s: got %v, want %v", when, test.name, got, test.want)
+			}
+		}
+	}
+
+	// before the pages are generated, their variables are read
+	run("not generated")
+
+	// afterward, the cached info is used
+	for name := range queryTestPages {
+		w.DisplayPageDraft(name, true)
+	}
+	if w.GetSpecialCategory("three", CategoryTypePage).PageQueryInfo == nil {
+		t.Fatal("query info was not cached")
+	}
+	run("generated")
+}
+
+func TestPagesBlock(t *testing.T) {
+	pages := map[string]string{
+		"index.page": "@page.title: Index;\n@category.docs;\npages { category: docs; }\n",
+	}
+	for name, source := range queryTestPages {
+		pages[name] = source
+	}
+	w := newTestWiki(t, pages)
+
+	// titles are shown before the listed pages are generated, and the page
+	// does not list itself
+	content := string(displayPage(t, w, "index").Content)
+	for _, title := range []string{"One", "Two", "Three"} {
+		if !strings.Contains(content, title) {
+			t.Errorf("index does not list %s:\n%s", title, content)
+		}
+	}
+	if strings.Contains(content, `href="/index"`) || strings.Contains(content, "Draft") {
+		t.Errorf("index lists itself or a draft:\n%s", content)
+	}
+}
+
+func TestPageQueryState(t *testing.T) {
+	w := newTestWiki(t, queryTestPages)
+	if _, err := w.repo(); err != nil {
+		t.Fatal(err)
+	}
+	if state := w.PageQueryState("three"); state != "" {
+		t.Errorf("PageQueryState before generation = %q", state)
+	}
+	displayPage(t, w, "three")
+	before := w.PageQueryState("three")
+	if before == "" {
+		t.Fatal("PageQueryState after generation is empty")
+	}
+
+	// changing content does not change what queries match
+	write := func(source string) {
+		t.Helper()
+		if err := w.WritePage("three.page", []byte(source), false, CommitOpts{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the cache might have the same modification time
+		if err := w.RegeneratePage("three.page"); err != nil {
+			t.Fatal(err)
+		}
+		displayPage(t, w, "three")
+	}
+	write(strings.Replace(queryTestPages["three.page"], "three\n", "changed\n", 1))
+	if state := w.PageQueryState("three"); state != before {
+		t.Errorf("PageQueryState changed with content: %s -> %s", before, state)
+	}
+
+	// changing a variable does
+	write("@page.type: guide;\n" + queryTestPages["three.page"])
+	if state := w.PageQueryState("three"); state == before {
+		t.Error("PageQueryState did not change with a variable")
+	}
+}
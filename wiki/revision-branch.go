@@ -1,6 +1,19 @@
 package wiki
 
-import "github.com/go-git/go-git/v5/plumbing"
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cooper/quiki/wikifier"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
 
 // BranchNames returns the revision branches available.
 func (w *Wiki) BranchNames() ([]string, error) {
@@ -20,145 +33,379 @@ func (w *Wiki) BranchNames() ([]string, error) {
 	return names, nil
 }
 
-// working with multiple branches is disabled until go-git supports it officially
-// see: https://github.com/cooper/quiki/issues/156
-
-// // ensure a branch exists in git
-// func (w *Wiki) hasBranch(name string) (bool, error) {
-// 	names, err := w.BranchNames()
-// 	if err != nil {
-// 		return false, err
-// 	}
-// 	for _, branchName := range names {
-// 		if branchName == name {
-// 			return true, nil
-// 		}
-// 	}
-// 	return false, nil
-// }
-
-// // checks out a branch in another directory. returns the directory
-// func (w *Wiki) checkoutBranch(name string) (string, error) {
-
-// 	// never checkout master in a linked repo
-// 	if name == "master" {
-// 		return "", errors.New("cannot check out master in a linked repo")
-// 	}
-
-// 	// make sure name is a simple wordlike string with no path elements
-// 	if !ValidBranchName(name) {
-// 		return "", errors.New("invalid branch name")
-// 	}
-
-// 	// make cache/branch/ if needed
-// 	wikifier.MakeDir(filepath.Join(w.Opt.Dir.Cache, "branch"), "")
-
-// 	// e.g. cache/branch/mybranchname
-// 	targetDir := filepath.Join(w.Opt.Dir.Cache, "branch", name)
-
-// 	// directory already exists, so I'm good with saying the branch is there
-// 	if fi, err := os.Stat(targetDir); err == nil && fi.IsDir() {
-// 		return targetDir, nil
-// 	}
-
-// 	repo, err := w.repo()
-// 	if err != nil {
-// 		return "", err
-// 	}
-
-// 	// create the linked repository
-// 	if _, err = repo.PlainAddWorktree(name, targetDir, &git.AddWorktreeOptions{}); err != nil {
-// 		return "", err
-// 	}
-
-// 	return targetDir, nil
-// }
-
-// // Branch returns a Wiki instance for this wiki at another branch.
-// // If the branch does not exist, an error is returned.
-// func (w *Wiki) Branch(name string) (*Wiki, error) {
-
-// 	// never checkout master in a linked repo
-// 	if name == "master" {
-// 		return w, nil
-// 	}
-
-// 	// find branch
-// 	if exist, err := w.hasBranch(name); !exist {
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		return nil, git.ErrBranchNotFound
-// 	}
-
-// 	// check out the branch in cache/branch/<name>;
-// 	// if it already has been checked out, this does nothing
-// 	dir, err := w.checkoutBranch(name)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	// create a new Wiki at this location
-// 	return NewWiki(dir)
-// }
-
-// // NewBranch is like Branch, except it creates the branch at the
-// // current master revision if it does not yet exist.
-// func (w *Wiki) NewBranch(name string) (*Wiki, error) {
-// 	repo, err := w.repo()
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	// find branch
-// 	if exist, err := w.hasBranch(name); !exist {
-// 		if err != nil {
-// 			return nil, err
-// 		}
-
-// 		// try to create it
-// 		err := repo.CreateBranch(&config.Branch{
-// 			Name:  name,
-// 			Merge: plumbing.NewBranchReferenceName(name),
-// 		})
-// 		if err != nil {
-// 			return nil, err
-// 		}
-
-// 		// determine where master is at
-// 		fs := repo.Storer.(interface{ Filesystem() billy.Filesystem }).Filesystem()
-// 		f1, err := fs.Open(fs.Join("refs", "heads", "master"))
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		defer f1.Close()
-// 		masterRef, err := io.ReadAll(f1)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-
-// 		// set refs/heads/<name> to same as master
-// 		f2, err := fs.Create(fs.Join("refs", "heads", name))
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		defer f2.Close()
-// 		_, err = fmt.Fprintf(f2, "%s\n", string(masterRef))
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 	}
-
-// 	// now that it exists, fetch it
-// 	return w.Branch(name)
-// }
-
-// var branchNameRgx = regexp.MustCompile(`^[\w-]+$`)
-
-// // ValidBranchName returns whether a branch name is valid.
-// //
-// // quiki branch names may contain word-like characters `\w` and
-// // forward slash (`/`) but may not start or end with a slash.
-// func ValidBranchName(name string) bool {
-// 	return branchNameRgx.MatchString(name)
-// }
+// branches other than master are checked out as linked worktrees in
+// cache/branch/<name>, just as `git worktree add` would do. each is
+// served by its own Wiki instance.
+
+// ensure a branch exists in git
+func (w *Wiki) hasBranch(name string) (bool, error) {
+	names, err := w.BranchNames()
+	if err != nil {
+		return false, err
+	}
+	for _, branchName := range names {
+		if branchName == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checks out a branch in another directory. returns the directory
+func (w *Wiki) checkoutBranch(name string) (string, error) {
+
+	// never checkout master in a linked repo
+	if name == "master" {
+		return "", errors.New("cannot check out master in a linked repo")
+	}
+
+	// make sure name is a simple wordlike string with no path elements
+	if !ValidBranchName(name) {
+		return "", errors.New("invalid branch name")
+	}
+
+	// make cache/branch/ if needed
+	wikifier.MakeDir(filepath.Join(w.Opt.Dir.Cache, "branch"), "")
+
+	// e.g. cache/branch/mybranchname
+	targetDir, err := filepath.Abs(filepath.Join(w.Opt.Dir.Cache, "branch", name))
+	if err != nil {
+		return "", err
+	}
+
+	// directory already exists, so I'm good with saying the branch is there
+	if fi, err := os.Stat(filepath.Join(targetDir, ".git")); err == nil && !fi.IsDir() {
+		return targetDir, nil
+	}
+
+	// prefer the git CLI if available, since go-git cannot add worktrees
+	if gitPath, err := exec.LookPath("git"); err == nil {
+
+		// forget worktrees whose directories were deleted
+		exec.Command(gitPath, "-C", w.Dir(), "worktree", "prune").Run()
+
+		out, err := exec.Command(gitPath, "-C", w.Dir(), "worktree", "add", "--", targetDir, name).CombinedOutput()
+		if err != nil {
+			return "", errors.Wrap(errors.New(strings.TrimSpace(string(out))), "git worktree add")
+		}
+		return targetDir, nil
+	}
+
+	// otherwise create the linked worktree ourselves
+	if err := w.addWorktree(name, targetDir); err != nil {
+		return "", err
+	}
+
+	return targetDir, nil
+}
+
+// addWorktree creates a linked worktree without the git CLI. the layout is
+// the same as that of `git worktree add`, so the two are interchangeable
+func (w *Wiki) addWorktree(name, targetDir string) error {
+	adminDir := w.Dir(".git", "worktrees", name)
+	if err := os.MkdirAll(adminDir, 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+
+	// link the two together
+	for path, content := range map[string]string{
+		filepath.Join(adminDir, "HEAD"):      "ref: " + plumbing.NewBranchReferenceName(name).String(),
+		filepath.Join(adminDir, "commondir"): filepath.Join("..", ".."),
+		filepath.Join(adminDir, "gitdir"):    filepath.Join(targetDir, ".git"),
+		filepath.Join(targetDir, ".git"):     "gitdir: " + adminDir,
+	} {
+		if err := os.WriteFile(path, []byte(content+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+
+	// open the new worktree
+	repo, err := git.PlainOpenWithOptions(targetDir, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return errors.Wrap(err, "git:PlainOpen")
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrap(err, "git:repo:Worktree")
+	}
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(name), true)
+	if err != nil {
+		return errors.Wrap(err, "git:repo:Reference")
+	}
+
+	// populate the index and files
+	if err := wt.Reset(&git.ResetOptions{Commit: ref.Hash(), Mode: git.HardReset}); err != nil {
+		return errors.Wrap(err, "git:worktree:Reset")
+	}
+
+	return nil
+}
+
+// Branch returns a Wiki instance for this wiki at another branch.
+// If the branch does not exist, an error is returned.
+func (w *Wiki) Branch(name string) (*Wiki, error) {
+
+	// never checkout master in a linked repo
+	if name == "master" {
+		return w, nil
+	}
+
+	// find branch
+	if exist, err := w.hasBranch(name); !exist {
+		if err != nil {
+			return nil, err
+		}
+		return nil, git.ErrBranchNotFound
+	}
+
+	w.branchesMu.Lock()
+	defer w.branchesMu.Unlock()
+
+	// already loaded
+	if bw, ok := w.branches[name]; ok {
+		return bw, nil
+	}
+
+	// check out the branch in cache/branch/<name>;
+	// if it already has been checked out, this does nothing
+	dir, err := w.checkoutBranch(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// create a new Wiki at this location
+	bw, err := NewWiki(dir)
+	if err != nil {
+		return nil, err
+	}
+	if w.branches == nil {
+		w.branches = make(map[string]*Wiki)
+	}
+	w.branches[name] = bw
+
+	return bw, nil
+}
+
+// NewBranch is like Branch, except it creates the branch at the
+// current master revision if it does not yet exist.
+func (w *Wiki) NewBranch(name string) (*Wiki, error) {
+	if !ValidBranchName(name) {
+		return nil, errors.New("invalid branch name")
+	}
+
+	repo, err := w.repo()
+	if err != nil {
+		return nil, err
+	}
+
+	// find branch
+	if exist, err := w.hasBranch(name); !exist {
+		if err != nil {
+			return nil, err
+		}
+
+		// determine where master is at
+		master, err := repo.Reference(plumbing.Master, true)
+		if err != nil {
+			return nil, errors.Wrap(err, "git:repo:Reference")
+		}
+
+		// set refs/heads/<name> to same as master
+		ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), master.Hash())
+		if err := repo.Storer.SetReference(ref); err != nil {
+			return nil, errors.Wrap(err, "git:repo:SetReference")
+		}
+	}
+
+	// now that it exists, fetch it
+	return w.Branch(name)
+}
+
+// MergeBranch merges a branch into master.
+//
+// Files changed on the branch since it diverged from master are brought into
+// master. Files changed on both are merged line by line. If any changes
+// conflict, the merge fails and nothing is changed. On success, a merge
+// commit is created.
+func (w *Wiki) MergeBranch(name string, commit CommitOpts) error {
+	if name == "master" {
+		return errors.New("cannot merge master into itself")
+	}
+
+	repo, err := w.repo()
+	if err != nil {
+		return err
+	}
+
+	// this must be the master worktree
+	head, err := repo.Head()
+	if err != nil {
+		return errors.Wrap(err, "git:repo:Head")
+	}
+	if head.Name() != plumbing.Master {
+		return errors.New("branches can only be merged into master")
+	}
+
+	// find both commits
+	branch, err := repo.Reference(plumbing.NewBranchReferenceName(name), true)
+	if err != nil {
+		return errors.Wrap(err, "git:repo:Reference")
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return errors.Wrap(err, "git:repo:CommitObject")
+	}
+	branchCommit, err := repo.CommitObject(branch.Hash())
+	if err != nil {
+		return errors.Wrap(err, "git:repo:CommitObject")
+	}
+
+	// nothing to do
+	if merged, err := branchCommit.IsAncestor(headCommit); err != nil {
+		return errors.Wrap(err, "git:commit:IsAncestor")
+	} else if merged || branch.Hash() == head.Hash() {
+		return errors.New("branch " + name + " has no changes to merge")
+	}
+
+	// find where they diverged
+	bases, err := headCommit.MergeBase(branchCommit)
+	if err != nil {
+		return errors.Wrap(err, "git:commit:MergeBase")
+	}
+	if len(bases) == 0 {
+		return errors.New("branch " + name + " has no history in common with master")
+	}
+
+	// compare the trees
+	baseTree, err := bases[0].Tree()
+	if err != nil {
+		return errors.Wrap(err, "git:commit:Tree")
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return errors.Wrap(err, "git:commit:Tree")
+	}
+	branchTree, err := branchCommit.Tree()
+	if err != nil {
+		return errors.Wrap(err, "git:commit:Tree")
+	}
+	changes, err := object.DiffTree(baseTree, branchTree)
+	if err != nil {
+		return errors.Wrap(err, "git:DiffTree")
+	}
+
+	// decide what to do with each file changed on the branch
+	var conflicts []string
+	apply := make(map[string][]byte) // nil means delete
+	for _, change := range changes {
+		path := change.To.Name
+		if path == "" {
+			path = change.From.Name
+		}
+		baseHash, branchHash, headHash := treeEntryHash(baseTree, path), treeEntryHash(branchTree, path), treeEntryHash(headTree, path)
+		switch {
+
+		// master already has this version
+		case headHash == branchHash:
+
+		// master has not touched it, so take the branch version
+		case headHash == baseHash:
+			content, err := treeFileContents(branchTree, branchHash, path)
+			if err != nil {
+				return err
+			}
+			apply[path] = content
+
+		// deleted on one side and changed on the other
+		case headHash.IsZero() || branchHash.IsZero():
+			conflicts = append(conflicts, path)
+
+		// changed on both; try merging the lines
+		default:
+			var contents [3][]byte
+			for i, tree := range []*object.Tree{baseTree, headTree, branchTree} {
+				if contents[i], err = treeFileContents(tree, treeEntryHash(tree, path), path); err != nil {
+					return err
+				}
+			}
+			merged, mergeConflicts := merge3(contents[0], contents[2], contents[1])
+			if len(mergeConflicts) != 0 {
+				conflicts = append(conflicts, path)
+				continue
+			}
+			apply[path] = append([]byte{}, merged...) // never nil
+		}
+	}
+	if len(conflicts) != 0 {
+		sort.Strings(conflicts)
+		return errors.New("merge conflict in " + strings.Join(conflicts, ", "))
+	}
+
+	// get worktree
+	wt, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrap(err, "git:repo:Worktree")
+	}
+
+	// write the changes
+	for path, content := range apply {
+		if content == nil {
+			if _, err := wt.Remove(path); err != nil {
+				return errors.Wrap(err, "git:worktree:Remove")
+			}
+			continue
+		}
+		absPath := w.Dir(filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			return err
+		}
+		if err := w.writeFileNoCommit(wt, absPath, content); err != nil {
+			return err
+		}
+	}
+
+	return w.commitWithParents(wt, "Merge branch "+name, commit, head.Hash(), branch.Hash())
+}
+
+// treeFileContents returns the content of a file in a tree, or nil if the
+// hash is zero, meaning the file does not exist
+func treeFileContents(tree *object.Tree, hash plumbing.Hash, path string) ([]byte, error) {
+	if hash.IsZero() {
+		return nil, nil
+	}
+	file, err := tree.File(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "git:tree:File")
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, errors.Wrap(err, "git:file:Contents")
+	}
+	return []byte(content), nil
+}
+
+// treeEntryHash returns the blob hash for a path in a tree, or the zero hash
+func treeEntryHash(tree *object.Tree, path string) plumbing.Hash {
+	entry, err := tree.FindEntry(path)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return entry.Hash
+}
+
+var branchNameRgx = regexp.MustCompile(`^\w[\w-]*$`)
+
+// ValidBranchName returns whether a branch name is valid.
+//
+// quiki branch names may contain word-like characters `\w` and
+// hyphens (`-`), but may not start with a hyphen. They must also be
+// valid git branch names.
+func ValidBranchName(name string) bool {
+	if !branchNameRgx.MatchString(name) || name == "HEAD" {
+		return false
+	}
+	return plumbing.NewBranchReferenceName(name).Validate() == nil
+}
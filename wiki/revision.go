@@ -1,8 +1,10 @@
 package wiki
 
 import (
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/pkg/errors"
 )
 
@@ -31,6 +34,12 @@ type CommitOpts struct {
 	// Time is the timestamp to associate with the revision.
 	// If unspecified, current time is used.
 	Time time.Time
+
+	// Base is the revision that new file content was based on. If set,
+	// changes made to the file since then are merged with the new content.
+	// If they conflict, nothing is written and a *MergeConflictError is
+	// returned. This is intended for text files such as pages and models.
+	Base string
 }
 
 // default options for commits made by quiki itself
@@ -51,8 +60,8 @@ func (w *Wiki) repo() (repo *git.Repository, err error) {
 		return
 	}
 
-	// open it
-	repo, err = git.PlainOpen(w.Dir())
+	// open it. commondir support is needed for branch worktrees
+	repo, err = git.PlainOpenWithOptions(w.Dir(), &git.PlainOpenOptions{EnableDotGitCommonDir: true})
 
 	// it doesn't exist- let's initialize it
 	if err == git.ErrRepositoryNotExists {
@@ -132,6 +141,12 @@ func (w *Wiki) createRepo() (repo *git.Repository, err error) {
 
 // the "and commit" portion of the *andCommit functions
 func (w *Wiki) andCommit(wt *git.Worktree, comment string, commit CommitOpts) error {
+	return w.commitWithParents(wt, comment, commit)
+}
+
+// commitWithParents is like andCommit, except that the parents of the new
+// commit may be specified. if none are, the parent is HEAD
+func (w *Wiki) commitWithParents(wt *git.Worktree, comment string, commit CommitOpts, parents ...plumbing.Hash) error {
 
 	// comment overrides default
 	if commit.Comment != "" {
@@ -150,6 +165,7 @@ func (w *Wiki) andCommit(wt *git.Worktree, comment string, commit CommitOpts) er
 			Email: commit.Email,
 			When:  commit.Time,
 		},
+		Parents: parents,
 	})
 	if err != nil {
 		return errors.Wrap(err, "git:worktree:Commit")
@@ -250,6 +266,14 @@ func (w *Wiki) writeFile(path string, content []byte, createOK bool, commit Comm
 		return errors.New("refusing to write to symlinked file")
 	}
 
+	// merge with changes made since the base revision
+	if commit.Base != "" {
+		content, err = w.mergeWithBase(relPath, path, content, commit.Base)
+		if err != nil {
+			return err
+		}
+	}
+
 	// write file all at once
 	if err := os.WriteFile(path, content, 0644); err != nil {
 		return err
@@ -283,7 +307,8 @@ func (w *Wiki) writeFileWithMonitorCoordination(path string, content []byte, cre
 
 // WriteModel writes a model file.
 func (w *Wiki) WriteModel(name string, content []byte, createOK bool, commit CommitOpts) error {
-	return w.writeFile(w.PathForModel(name), content, createOK, commit)
+	// models are locked like pages; the extension keeps the names apart
+	return w.writeFileWithMonitorCoordination(w.PathForModel(name), content, createOK, commit, wikifier.ModelName(name))
 }
 
 // WriteImage writes an image file.
@@ -419,6 +444,105 @@ func (w *Wiki) RevisionsMatchingFile(relPath string) ([]RevisionInfo, error) {
 	return w._revisionsMatchingFile(relPath)
 }
 
+// FileAtRevision returns the content of a file as of the given revision.
+// The path must be relative to the wiki directory.
+func (w *Wiki) FileAtRevision(relPath, commitHash string) ([]byte, error) {
+	content, _, err := w.fileAtRevision(relPath, commitHash)
+	return content, err
+}
+
+// PageAtRevision returns the source of a page as of the given revision.
+func (w *Wiki) PageAtRevision(name, commitHash string) ([]byte, error) {
+	return w.FileAtRevision(w.RelPath(w.PathForPage(name)), commitHash)
+}
+
+// ModelAtRevision returns the source of a model as of the given revision.
+func (w *Wiki) ModelAtRevision(name, commitHash string) ([]byte, error) {
+	return w.FileAtRevision(w.RelPath(w.PathForModel(name)), commitHash)
+}
+
+// RevertPage restores a page to its content as of the given revision.
+//
+// The old content is written as a new revision, so no history is lost.
+// If the page did not exist as of the revision, an error is returned.
+func (w *Wiki) RevertPage(name, commitHash string, commit CommitOpts) error {
+	file := filepath.Base(w.PathForPage(name))
+	content, err := w.PageAtRevision(name, commitHash)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return errors.New(file + " did not exist as of " + shortHash(commitHash))
+	}
+	if err != nil {
+		return err
+	}
+	if commit.Comment == "" {
+		commit.Comment = "Revert " + file + " to " + shortHash(commitHash)
+	}
+	return w.WritePage(name, content, true, commit)
+}
+
+// RevertModel restores a model to its content as of the given revision.
+//
+// The old content is written as a new revision, so no history is lost.
+// If the model did not exist as of the revision, an error is returned.
+func (w *Wiki) RevertModel(name, commitHash string, commit CommitOpts) error {
+	file := filepath.Base(w.PathForModel(name))
+	content, err := w.ModelAtRevision(name, commitHash)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return errors.New(file + " did not exist as of " + shortHash(commitHash))
+	}
+	if err != nil {
+		return err
+	}
+	if commit.Comment == "" {
+		commit.Comment = "Revert " + file + " to " + shortHash(commitHash)
+	}
+	return w.WriteModel(name, content, true, commit)
+}
+
+// fileAtRevision returns file content and the commit it was found in
+func (w *Wiki) fileAtRevision(relPath, commitHash string) ([]byte, *object.Commit, error) {
+	if relPath == "" {
+		return nil, nil, errors.New("path outside of wiki directory")
+	}
+	if commitHash == "" {
+		return nil, nil, errors.New("no revision specified")
+	}
+
+	repo, err := w.repo()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// find the commit; this accepts abbreviated hashes too
+	hash, err := repo.ResolveRevision(plumbing.Revision(commitHash))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "git:repo:ResolveRevision")
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "git:repo:CommitObject")
+	}
+
+	// find the file in the commit tree
+	file, err := commit.File(filepath.ToSlash(relPath))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "git:commit:File")
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "git:file:Contents")
+	}
+
+	return []byte(content), commit, nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
 // Diff returns the diff between two revisions.
 // NOTE: For now, this includes all changes, not just those to a specific file.
 func (w *Wiki) Diff(from, to string) (*object.Patch, error) {
@@ -487,3 +611,125 @@ func (w *Wiki) _revisionsMatchingFile(path string) ([]RevisionInfo, error) {
 
 	return revisions, nil
 }
+
+// a fileChange is a file changed in a revision
+type fileChange struct {
+	RevisionInfo
+	path   string // relative to the wiki directory, with forward slashes
+	action string // "create", "modify", or "delete"
+}
+
+// _fileChanges walks the history of the current branch from newest to
+// oldest and returns files changed in each revision, stopping at limit
+// changes or at revisions older than since. zero values mean no limit.
+// filter selects which changes are returned. merges are compared to the
+// branch they were merged into, so changes from a merged branch are listed
+// once, as of the merge
+func (w *Wiki) _fileChanges(limit int, since time.Time, filter func(fc fileChange) bool) ([]fileChange, error) {
+	var changes []fileChange
+	err := w.firstParentLog(func(c *object.Commit) error {
+		if !since.IsZero() && c.Committer.When.Before(since) {
+			return storer.ErrStop
+		}
+
+		// compare to the first parent, or to nothing for the first commit
+		tree, err := c.Tree()
+		if err != nil {
+			return errors.Wrap(err, "git:commit:Tree")
+		}
+		var parentTree *object.Tree
+		if c.NumParents() != 0 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				return errors.Wrap(err, "git:commit:Parent")
+			}
+			if parentTree, err = parent.Tree(); err != nil {
+				return errors.Wrap(err, "git:commit:Tree")
+			}
+		}
+		diff, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return errors.Wrap(err, "git:DiffTree")
+		}
+
+		info := RevisionInfo{
+			Id:      c.Hash.String(),
+			Author:  c.Author.Name,
+			Date:    c.Author.When,
+			Message: c.Message,
+		}
+		for _, change := range diff {
+			path, action := change.To.Name, "modify"
+			switch {
+			case change.From.Name == "":
+				action = "create"
+			case change.To.Name == "":
+				path, action = change.From.Name, "delete"
+			}
+			fc := fileChange{RevisionInfo: info, path: path, action: action}
+			if filter != nil && !filter(fc) {
+				continue
+			}
+			changes = append(changes, fc)
+			if limit > 0 && len(changes) == limit {
+				return storer.ErrStop
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// firstParentLog calls fn for each revision on the current branch, newest
+// first, following only the first parent of merges. fn may return
+// storer.ErrStop to stop early
+func (w *Wiki) firstParentLog(fn func(c *object.Commit) error) error {
+	repo, err := w.repo()
+	if err != nil {
+		return err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return errors.Wrap(err, "git:repo:Head")
+	}
+	c, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return errors.Wrap(err, "git:repo:CommitObject")
+	}
+
+	for {
+		if err := fn(c); err == storer.ErrStop {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if c.NumParents() == 0 {
+			return nil
+		}
+		if c, err = c.Parent(0); err != nil {
+			return errors.Wrap(err, "git:commit:Parent")
+		}
+	}
+}
+
+// ChangeAuthors returns the names of everyone who has authored a revision
+// listed in recent changes, sorted.
+func (w *Wiki) ChangeAuthors() ([]string, error) {
+	authorSet := make(map[string]bool)
+	err := w.firstParentLog(func(c *object.Commit) error {
+		if c.Author.Name != "" {
+			authorSet[c.Author.Name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Sorted(maps.Keys(authorSet)), nil
+}
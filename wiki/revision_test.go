@@ -0,0 +1,121 @@
+package wiki
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// latestCommit returns the latest commit hash, failing the test if it can't
+func latestCommit(t *testing.T, w *Wiki) string {
+	t.Helper()
+	hash, err := w.GetLatestCommitHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestPageAtRevision(t *testing.T) {
+	w := newTestWiki(t, nil)
+	before := latestCommit(t, w)
+	if err := w.WritePage("a.page", []byte("one\n"), true, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	first := latestCommit(t, w)
+	if err := w.WritePage("a.page", []byte("two\n"), false, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// full and abbreviated hashes
+	for _, hash := range []string{first, first[:7]} {
+		if got, err := w.PageAtRevision("a.page", hash); err != nil || string(got) != "one\n" {
+			t.Errorf("PageAtRevision(%s) = %q, %v", hash, got, err)
+		}
+	}
+	if got, err := w.PageAtRevision("a.page", latestCommit(t, w)); err != nil || string(got) != "two\n" {
+		t.Errorf("PageAtRevision(latest) = %q, %v", got, err)
+	}
+
+	// before the page existed, at an unknown revision, and with none
+	for _, hash := range []string{before, "0000000", ""} {
+		if _, err := w.PageAtRevision("a.page", hash); err == nil {
+			t.Errorf("PageAtRevision(%q): no error", hash)
+		}
+	}
+}
+
+func TestRevertPage(t *testing.T) {
+	w := newTestWiki(t, nil)
+	latestCommit(t, w) // create the repository
+	if err := w.WritePage("a.page", []byte("one\n"), true, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	first := latestCommit(t, w)
+	if err := w.WritePage("a.page", []byte("two\n"), false, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := w.RevertPage("a.page", first, CommitOpts{Name: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(w.PathForPage("a.page")); string(got) != "one\n" {
+		t.Errorf("a.page = %q after revert", got)
+	}
+
+	// the revert is a new revision, so the history is kept
+	revs, err := w.RevisionsMatchingPage("a.page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 3 {
+		t.Fatalf("%d revisions after revert, want 3", len(revs))
+	}
+	if want := "Revert a.page to " + first[:7]; revs[0].Message != want || revs[0].Author != "alice" {
+		t.Errorf("revert revision = %q by %s, want %q by alice", revs[0].Message, revs[0].Author, want)
+	}
+}
+
+func TestRevertPageNotYetCreated(t *testing.T) {
+	w := newTestWiki(t, nil)
+	before := latestCommit(t, w)
+	if err := w.WritePage("a.page", []byte("one\n"), true, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	head := latestCommit(t, w)
+
+	// nothing to revert to, so nothing is changed
+	err := w.RevertPage("a.page", before, CommitOpts{})
+	if err == nil || !strings.Contains(err.Error(), "a.page did not exist as of "+before[:7]) {
+		t.Errorf("RevertPage = %v, want an error saying a.page did not exist", err)
+	}
+	if got, _ := os.ReadFile(w.PathForPage("a.page")); string(got) != "one\n" {
+		t.Errorf("a.page = %q", got)
+	}
+	if latestCommit(t, w) != head {
+		t.Error("a commit was made")
+	}
+}
+
+func TestRevertModel(t *testing.T) {
+	w := newTestWiki(t, nil)
+	before := latestCommit(t, w)
+	if err := w.WriteModel("m", []byte("one\n"), true, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	first := latestCommit(t, w)
+	if err := w.WriteModel("m", []byte("two\n"), false, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.RevertModel("m", first, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(w.PathForModel("m")); string(got) != "one\n" {
+		t.Errorf("m.model = %q after revert", got)
+	}
+	if err := w.RevertModel("m", before, CommitOpts{}); err == nil || !strings.Contains(err.Error(), "m.model did not exist") {
+		t.Errorf("RevertModel before it existed = %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package wiki
+
+import (
+	"html"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cooper/quiki/wikifier"
+	"github.com/pkg/errors"
+)
+
+// maximum number of results returned by Search
+const maxSearchResults = 100
+
+// number of characters of context on each side of a snippet match
+const searchSnippetContext = 80
+
+// SearchResult represents a page matching a search query.
+type SearchResult struct {
+	wikifier.PageInfo
+
+	// relevance score; higher is better
+	Score float64 `json:"score"`
+
+	// plain text excerpt surrounding the first match
+	Snippet string `json:"snippet,omitempty"`
+
+	// like Snippet, except HTML-escaped with matching terms wrapped in <mark>
+	SnippetHTML wikifier.HTML `json:"snippet_html,omitempty"`
+}
+
+// searchIndex is an inverted index of page text files.
+//
+// it is updated incrementally; only text files which have been modified
+// since they were last indexed are read again.
+type searchIndex struct {
+	mu       sync.Mutex
+	docs     map[string]*searchDoc     // page filename -> document
+	postings map[string]map[string]int // term -> page filename -> frequency
+	totalLen int                       // sum of all document lengths
+}
+
+// searchDoc is a single page in the search index.
+type searchDoc struct {
+	mod    time.Time       // text file modify time when indexed
+	length int             // number of terms
+	title  map[string]bool // terms in the page title
+	terms  []string        // distinct terms, for removal
+}
+
+// Search returns pages matching the query, ordered by relevance.
+//
+// Every term in the query must be present for a page to match. Search
+// reads the text files written when search is enabled, so pages which have
+// never been generated are not found.
+func (w *Wiki) Search(query string) ([]SearchResult, error) {
+	if !w.Opt.Search.Enable {
+		return nil, errors.New("search is not enabled")
+	}
+
+	terms := uniqueTerms(searchTerms(query))
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	idx := &w.searchIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	// bring the index up-to-date
+	if err := w.updateSearchIndex(); err != nil {
+		return nil, err
+	}
+
+	// find pages containing every term
+	var matches []string
+	for name := range idx.postings[terms[0]] {
+		all := true
+		for _, term := range terms[1:] {
+			if _, ok := idx.postings[term][name]; !ok {
+				all = false
+				break
+			}
+		}
+		if all {
+			matches = append(matches, name)
+		}
+	}
+
+	// score each using BM25, plus a bonus for title matches
+	const k1, b = 1.2, 0.75
+	nDocs := float64(len(idx.docs))
+	avgLen := float64(idx.totalLen) / math.Max(nDocs, 1)
+	scores := make(map[string]float64, len(matches))
+	for _, name := range matches {
+		doc := idx.docs[name]
+		score := 0.0
+		for _, term := range terms {
+			df := float64(len(idx.postings[term]))
+			idf := math.Log(1 + (nDocs-df+0.5)/(df+0.5))
+			tf := float64(idx.postings[term][name])
+			score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(doc.length)/avgLen))
+			if doc.title[term] {
+				score += idf * 2
+			}
+		}
+		scores[name] = score
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if scores[matches[i]] != scores[matches[j]] {
+			return scores[matches[i]] > scores[matches[j]]
+		}
+		return matches[i] < matches[j]
+	})
+
+	// build results, skipping drafts and redirects
+	var results []SearchResult
+	for _, name := range matches {
+		if len(results) == maxSearchResults {
+			break
+		}
+		info := w.PageInfo(name)
+		if info.Path == "" || info.Draft || info.Redirect != "" {
+			continue
+		}
+		text, _ := os.ReadFile(w.searchTextPath(name))
+		snippet, snippetHTML := searchSnippet(string(text), terms)
+		results = append(results, SearchResult{
+			PageInfo:    info,
+			Score:       scores[name],
+			Snippet:     snippet,
+			SnippetHTML: snippetHTML,
+		})
+	}
+
+	return results, nil
+}
+
+// updateSearchIndex (re)indexes modified text files and drops deleted pages.
+// the index lock must be held.
+func (w *Wiki) updateSearchIndex() error {
+	idx := &w.searchIndex
+	if idx.docs == nil {
+		idx.docs = make(map[string]*searchDoc)
+		idx.postings = make(map[string]map[string]int)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range w.allPageFiles() {
+		fi, err := os.Stat(w.searchTextPath(name))
+		if err != nil {
+			continue
+		}
+		seen[name] = true
+
+		// unchanged since last indexed
+		if doc := idx.docs[name]; doc != nil && doc.mod.Equal(fi.ModTime()) {
+			continue
+		}
+
+		text, err := os.ReadFile(w.searchTextPath(name))
+		if err != nil {
+			return errors.Wrap(err, "read search text")
+		}
+		idx.remove(name)
+		idx.add(name, fi.ModTime(), string(text), w.PageInfo(name).Title)
+	}
+
+	// remove pages which no longer exist or have no text
+	for name := range idx.docs {
+		if !seen[name] {
+			idx.remove(name)
+		}
+	}
+
+	return nil
+}
+
+// searchTextPath returns the path to the text file for a page.
+// this is equivalent to Page.SearchPath but does not create directories.
+func (w *Wiki) searchTextPath(name string) string {
+	return filepath.Join(w.Opt.Dir.Cache, "page", filepath.FromSlash(name)+".txt")
+}
+
+// add indexes a document
+func (idx *searchIndex) add(name string, mod time.Time, text, title string) {
+	terms := searchTerms(text)
+	doc := &searchDoc{mod: mod, length: len(terms), title: make(map[string]bool)}
+	for _, term := range searchTerms(title) {
+		doc.title[term] = true
+	}
+	for _, term := range terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[string]int)
+		}
+		if idx.postings[term][name] == 0 {
+			doc.terms = append(doc.terms, term)
+		}
+		idx.postings[term][name]++
+	}
+	idx.docs[name] = doc
+	idx.totalLen += doc.length
+}
+
+// remove drops a document from the index
+func (idx *searchIndex) remove(name string) {
+	doc := idx.docs[name]
+	if doc == nil {
+		return
+	}
+	for _, term := range doc.terms {
+		pages := idx.postings[term]
+		delete(pages, name)
+		if len(pages) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	idx.totalLen -= doc.length
+	delete(idx.docs, name)
+}
+
+// searchTerms splits text into lowercase terms
+func searchTerms(text string) []string {
+	words := strings.FieldsFunc(text, isNotSearchRune)
+	terms := words[:0]
+	for _, word := range words {
+		if utf8.RuneCountInString(word) < 2 {
+			continue
+		}
+		terms = append(terms, strings.ToLower(word))
+	}
+	return terms
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := terms[:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+func isNotSearchRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// searchSnippet extracts text surrounding the first matching term
+func searchSnippet(text string, terms []string) (string, wikifier.HTML) {
+	text = strings.Join(strings.Fields(text), " ")
+	lower := strings.ToLower(text)
+
+	// lowercasing changed byte offsets, so just use the lowercase text
+	if len(lower) != len(text) {
+		text = lower
+	}
+
+	// find the earliest whole-word match of any term
+	start, end := -1, -1
+	for _, term := range terms {
+		for off := 0; ; {
+			i := strings.Index(lower[off:], term)
+			if i == -1 {
+				break
+			}
+			i += off
+			if isWordAt(lower, i, i+len(term)) {
+				if start == -1 || i < start {
+					start, end = i, i+len(term)
+				}
+				break
+			}
+			off = i + len(term)
+		}
+	}
+
+	// no match (possible if the title matched only)
+	if start == -1 {
+		start, end = 0, 0
+	}
+
+	// expand to context, snapping to word boundaries
+	from, to := start-searchSnippetContext, end+searchSnippetContext
+	prefix, suffix := "...", "..."
+	if from <= 0 {
+		from, prefix = 0, ""
+	} else if sp := strings.IndexByte(text[from:start], ' '); sp != -1 {
+		from += sp + 1
+	}
+	if to >= len(text) {
+		to, suffix = len(text), ""
+	} else if sp := strings.LastIndexByte(text[end:to], ' '); sp != -1 {
+		to = end + sp
+	}
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	snippet := text[from:to]
+
+	// highlight all occurrences of each term
+	var h strings.Builder
+	lowerSnippet := lower[from:to]
+	for i := 0; i < len(snippet); {
+		matched := ""
+		for _, term := range terms {
+			if strings.HasPrefix(lowerSnippet[i:], term) && isWordAt(lowerSnippet, i, i+len(term)) {
+				matched = snippet[i : i+len(term)]
+				break
+			}
+		}
+		if matched != "" {
+			h.WriteString("<mark>" + html.EscapeString(matched) + "</mark>")
+			i += len(matched)
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(snippet[i:])
+		h.WriteString(html.EscapeString(snippet[i : i+size]))
+		i += size
+	}
+
+	return prefix + snippet + suffix, wikifier.HTML(prefix + h.String() + suffix)
+}
+
+// isWordAt returns true if text[start:end] is not surrounded by word characters
+func isWordAt(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if !isNotSearchRune(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if !isNotSearchRune(r) {
+			return false
+		}
+	}
+	return true
+}
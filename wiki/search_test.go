@@ -0,0 +1,143 @@
+package wiki
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newSearchTestWiki creates a wiki with search enabled and generates each
+// page, which writes the text that is indexed
+func newSearchTestWiki(t *testing.T, pages map[string]string) *Wiki {
+	t.Helper()
+	w := newTestWiki(t, pages)
+	w.Opt.Search.Enable = true
+	for name := range pages {
+		w.DisplayPage(name)
+	}
+	return w
+}
+
+// searchNames returns the page filenames found by a search, in order
+func searchNames(t *testing.T, w *Wiki, query string) []string {
+	t.Helper()
+	results, err := w.Search(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, result := range results {
+		names = append(names, result.File)
+	}
+	return names
+}
+
+func TestSearchTerms(t *testing.T) {
+	got := searchTerms("Hello, Wörld! a b2 [[link]] don't 42")
+	want := []string{"hello", "wörld", "b2", "link", "don", "42"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("searchTerms = %q, want %q", got, want)
+	}
+	if got := uniqueTerms([]string{"a", "b", "a"}); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("uniqueTerms = %q", got)
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	w := newSearchTestWiki(t, map[string]string{
+		"once.page":  "@page.title: Once;\nApples grow on trees in the orchard.\n",
+		"often.page": "@page.title: Often;\nApples, apples, apples, and more apples.\n",
+		"title.page": "@page.title: Apples;\nThe orchard has trees.\n",
+		"none.page":  "@page.title: None;\nPears grow on trees.\n",
+	})
+
+	// the title counts most, then how often the term appears
+	if got, want := searchNames(t, w, "apples"), []string{"title.page", "often.page", "once.page"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("search apples = %v, want %v", got, want)
+	}
+
+	// every term must match
+	if got, want := searchNames(t, w, "apples trees"), []string{"title.page", "once.page"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("search apples trees = %v, want %v", got, want)
+	}
+
+	// terms are case insensitive, and snippets mark them
+	results, err := w.Search("PEARS")
+	if err != nil || len(results) != 1 {
+		t.Fatalf("search PEARS = %v, %v", results, err)
+	}
+	if results[0].SnippetHTML != "None <mark>Pears</mark> grow on trees." {
+		t.Errorf("snippet = %q", results[0].SnippetHTML)
+	}
+
+	// no terms, no results
+	if got := searchNames(t, w, "a !"); len(got) != 0 {
+		t.Errorf("search without terms = %v", got)
+	}
+}
+
+func TestSearchSkipsDraftsAndRedirects(t *testing.T) {
+	w := newSearchTestWiki(t, map[string]string{
+		"public.page":   "Secret recipes are here.\n",
+		"draft.page":    "Secret recipes are here too.\n",
+		"redirect.page": "@page.redirect: /public;\n",
+	})
+	if got := searchNames(t, w, "secret"); !reflect.DeepEqual(got, []string{"public.page", "draft.page"}) {
+		t.Fatalf("search = %v", got)
+	}
+
+	// the page was indexed before it became a draft
+	time.Sleep(10 * time.Millisecond)
+	if err := os.WriteFile(w.PathForPage("draft.page"), []byte("@page.draft;\nSecret recipes are here too.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.DisplayPage("draft.page")
+	if got := searchNames(t, w, "secret"); !reflect.DeepEqual(got, []string{"public.page"}) {
+		t.Errorf("search = %v, want only public.page", got)
+	}
+}
+
+func TestSearchIndexUpdates(t *testing.T) {
+	w := newSearchTestWiki(t, map[string]string{
+		"a.page": "The old text.\n",
+		"b.page": "Unrelated.\n",
+	})
+	if _, err := w.repo(); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchNames(t, w, "old"); !reflect.DeepEqual(got, []string{"a.page"}) {
+		t.Fatalf("search old = %v", got)
+	}
+
+	// saving the page replaces what was indexed once it is generated again.
+	// make sure the modification times differ
+	time.Sleep(10 * time.Millisecond)
+	if err := w.WritePage("a.page", []byte("The new text.\n"), false, CommitOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	displayPage(t, w, "a.page")
+	if got := searchNames(t, w, "old"); len(got) != 0 {
+		t.Errorf("search old after save = %v", got)
+	}
+	if got := searchNames(t, w, "new"); !reflect.DeepEqual(got, []string{"a.page"}) {
+		t.Errorf("search new after save = %v", got)
+	}
+
+	// deleted pages are removed
+	if err := os.Remove(w.PathForPage("b.page")); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchNames(t, w, "unrelated"); len(got) != 0 {
+		t.Errorf("search after delete = %v", got)
+	}
+	if _, ok := w.searchIndex.docs["b.page"]; ok {
+		t.Error("deleted page is still indexed")
+	}
+
+	// search must be enabled
+	w.Opt.Search.Enable = false
+	if _, err := w.Search("new"); err == nil {
+		t.Error("Search with search disabled: no error")
+	}
+}
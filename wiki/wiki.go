@@ -4,6 +4,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -26,6 +29,9 @@ type Wiki struct {
 	checks         []Check
 	checkMu        sync.Mutex
 	currentBatcher *categoryBatcher // current batching context, if any
+	searchIndex    searchIndex
+	branches       map[string]*Wiki // linked worktrees for other branches
+	branchesMu     sync.Mutex
 	_repo          *git.Repository
 	_logger        *log.Logger
 	_logFile       *os.File
@@ -112,6 +118,99 @@ func (w *Wiki) GetReferencingPages(pageName string) []string {
 	return referencingPages
 }
 
+// GetIncludingPages returns a list of page names that include the given page
+// with include{}, as of when their caches were generated.
+func (w *Wiki) GetIncludingPages(pageName string) []string {
+	var includingPages []string
+	for _, referencingPageName := range w.GetReferencingPages(pageName) {
+		info, err := readCacheManifest(w.FindPage(referencingPageName))
+		if err != nil {
+			continue
+		}
+		for _, included := range info.Includes {
+			if included == pageName {
+				includingPages = append(includingPages, referencingPageName)
+				break
+			}
+		}
+	}
+	return includingPages
+}
+
+// GetQueryingPages returns a list of page names that list other pages with
+// pages{}, whose results may change as pages are created or removed.
+func (w *Wiki) GetQueryingPages() []string {
+	cat := w.GetSpecialCategory(queryCategoryName, CategoryTypeQuery)
+	if !cat.Exists() || len(cat.Pages) == 0 {
+		return nil
+	}
+	var queryingPages []string
+	for queryingPageName := range cat.Pages {
+		queryingPages = append(queryingPages, queryingPageName)
+	}
+	return queryingPages
+}
+
+// GetListingPages returns a list of page names that list the given page
+// with pages{}.
+func (w *Wiki) GetListingPages(pageName string) []string {
+	querying := w.GetQueryingPages()
+	var listingPages []string
+	for _, referencingPageName := range w.GetReferencingPages(pageName) {
+		if slices.Contains(querying, referencingPageName) {
+			listingPages = append(listingPages, referencingPageName)
+		}
+	}
+	return listingPages
+}
+
+// A Backlink describes a page which links to another page.
+type Backlink struct {
+
+	// info for the page containing the link.
+	// note this info is accurate only as of when the link was recorded
+	wikifier.PageInfo
+
+	// line numbers on which the link occurs
+	Lines []int `json:"lines,omitempty"`
+}
+
+// Backlinks returns the pages which link to the given page, sorted by title.
+//
+// This uses page metacategories, so links are only known for pages which
+// have been generated.
+func (w *Wiki) Backlinks(pageName string) []Backlink {
+
+	// links to existing pages use the real page name;
+	// links to nonexistent pages use the normalized target
+	name := wikifier.PageNameNE(pageName)
+	if page := w.FindPage(pageName); page.Exists() {
+		name = page.NameNE()
+	}
+
+	cat := w.GetSpecialCategory(name, CategoryTypePage)
+	if !cat.Exists() || len(cat.Pages) == 0 {
+		return nil
+	}
+
+	backlinks := make([]Backlink, 0, len(cat.Pages))
+	for _, entry := range cat.Pages {
+		backlinks = append(backlinks, Backlink{entry.PageInfo, entry.Lines})
+	}
+
+	sort.Slice(backlinks, func(i, j int) bool {
+		return backlinkTitle(backlinks[i]) < backlinkTitle(backlinks[j])
+	})
+	return backlinks
+}
+
+func backlinkTitle(b Backlink) string {
+	if b.Title != "" {
+		return strings.ToLower(b.Title)
+	}
+	return strings.ToLower(b.FileNE)
+}
+
 // Shutdown closes the wiki active filehandles.
 func (w *Wiki) Shutdown() {
 	if w._logFile != nil {
@@ -0,0 +1,45 @@
+package wiki
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestWiki creates a wiki in a temporary directory with the given pages,
+// keyed by file name relative to the pages directory.
+func newTestWiki(t *testing.T, pages map[string]string) *Wiki {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "wiki.conf"), []byte("@name: test;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, source := range pages {
+		path := filepath.Join(dir, "pages", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := NewWiki(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// displayPage displays a page, failing the test if it can't be.
+func displayPage(t *testing.T, w *Wiki, name string) DisplayPage {
+	t.Helper()
+	switch r := w.DisplayPage(name).(type) {
+	case DisplayPage:
+		return r
+	case DisplayError:
+		t.Fatalf("%s: %s: %s", name, r.Error, r.DetailedError)
+	default:
+		t.Fatalf("%s: unexpected result %T", name, r)
+	}
+	return DisplayPage{}
+}
@@ -0,0 +1,52 @@
+package wikifier
+
+// note{}, warning{}, tip{}
+
+// default titles for each kind of callout
+var calloutTitles = map[string]string{
+	"note":    "Note",
+	"warning": "Warning",
+	"tip":     "Tip",
+}
+
+// calloutBlock is a note{}, warning{}, or tip{}, which sets content apart
+// from the surrounding text. Its name is an optional title.
+type calloutBlock struct {
+	title string
+	*parserBlock
+}
+
+// newCalloutBlock creates a note{}, warning{}, or tip{} given an underlying
+// parser block.
+func newCalloutBlock(name string, b *parserBlock) block {
+	return &calloutBlock{parserBlock: b}
+}
+
+// parse determines the heading ID.
+func (c *calloutBlock) parse(page *Page) {
+	c.title = c.blockName()
+	if c.headingID == "" {
+		c.headingID = PageNameLink(c.title)
+	}
+	c.parserBlock.parse(page)
+}
+
+// html displays the title and content.
+func (c *calloutBlock) html(page *Page, el element) {
+	el.addClass("callout")
+	el.setAttr("role", "note")
+
+	title := el.createChild("div", "callout-title")
+	if c.title != "" {
+		// titled callouts can be linked to
+		c.headingID = page.uniqueHeadingID(c.headingID)
+		title.setAttr("id", "qa-"+c.headingID)
+		title.addHTML(format(c, c.title, c.openPos))
+		el.setAttr("aria-labelledby", "qa-"+c.headingID)
+	} else {
+		title.addText(calloutTitles[c.blockType()])
+		el.setAttr("aria-label", calloutTitles[c.blockType()])
+	}
+
+	handleGenericContent(c, page, el)
+}
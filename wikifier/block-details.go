@@ -0,0 +1,38 @@
+package wikifier
+
+// details{}
+
+// details{} is a section which the reader can expand and collapse. Its name
+// is the summary, which is always visible.
+type detailsBlock struct {
+	title string
+	*parserBlock
+}
+
+// newDetailsBlock creates a details{} given an underlying parser block.
+func newDetailsBlock(name string, b *parserBlock) block {
+	return &detailsBlock{parserBlock: b}
+}
+
+// parse determines the summary and heading ID.
+func (d *detailsBlock) parse(page *Page) {
+	d.title = d.blockName()
+	if d.headingID == "" {
+		d.headingID = PageNameLink(d.title)
+	}
+	d.parserBlock.parse(page)
+}
+
+// html creates a native <details> element.
+func (d *detailsBlock) html(page *Page, el element) {
+	el.setTag("details")
+
+	// without a summary, the browser displays its own
+	if d.title != "" {
+		d.headingID = page.uniqueHeadingID(d.headingID)
+		el.setAttr("id", "qa-"+d.headingID)
+		el.createChild("summary", "details-summary").addHTML(format(d, d.title, d.openPos))
+	}
+
+	handleGenericContent(d, page, el)
+}
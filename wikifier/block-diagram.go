@@ -0,0 +1,240 @@
+package wikifier
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// diagram{} displays a text-based diagram as inline SVG. The kind of diagram
+// is given as the block name, e.g. diagram [sequence] {{ ... }}.
+type diagramBlock struct {
+	*parserBlock
+}
+
+// diagram kinds and the functions which render them. each returns the SVG
+// and any warnings
+var diagramKinds = map[string]func(src string) (HTML, []string){
+	"graph":    renderGraphDiagram,
+	"digraph":  renderGraphDiagram,
+	"dot":      renderGraphDiagram,
+	"sequence": renderSequenceDiagram,
+}
+
+// approximate text metrics, used to size boxes around labels
+const (
+	diagramCharWidth  = 8
+	diagramLineHeight = 18
+	diagramMargin     = 20
+)
+
+// styles shared by all diagrams, added to the page CSS once
+const diagramCSS = `.q-diagram {
+    overflow-x: auto;
+    margin: 15px 0;
+}
+.q-diagram-svg text {
+    font-family: sans-serif;
+    font-size: 14px;
+    fill: #222;
+}
+.q-diagram-node {
+    fill: #f8f8f8;
+    stroke: #444;
+    stroke-width: 1.5;
+}
+.q-diagram-edge {
+    fill: none;
+    stroke: #444;
+    stroke-width: 1.5;
+}
+.q-diagram-arrow {
+    fill: #444;
+}
+.q-diagram-dashed {
+    stroke-dasharray: 6 4;
+}
+.q-diagram-lifeline {
+    stroke: #aaa;
+    stroke-dasharray: 4 4;
+}
+.q-diagram-note {
+    fill: #fff8c5;
+    stroke: #c8b458;
+}
+.q-diagram-svg text.q-diagram-label {
+    paint-order: stroke;
+    stroke: #fff;
+    stroke-width: 4px;
+    stroke-linejoin: round;
+}`
+
+// matches lines which can only be from a sequence diagram
+var sequenceLineRegex = regexp.MustCompile(`(?im)^\s*(participant|actor|note)\s|^[^\[\]=]+-{1,2}>{1,2}[^\[\]=]*:`)
+
+// newDiagramBlock creates a diagram{} given an underlying parser block.
+func newDiagramBlock(name string, b *parserBlock) block {
+	return &diagramBlock{parserBlock: b}
+}
+
+// parse adds the diagram styles to the page once.
+func (db *diagramBlock) parse(page *Page) {
+	if !page.diagStyles {
+		page.staticStyles = append(page.staticStyles, diagramCSS)
+		page.diagStyles = true
+	}
+	db.parserBlock.parse(page)
+}
+
+// html renders the diagram to SVG.
+func (db *diagramBlock) html(page *Page, el element) {
+	el.setMeta("noIndent", true)
+
+	// get diagram source
+	src := ""
+	for _, piece := range db.textContent() {
+		src += piece
+	}
+
+	// if the kind is not given, guess
+	kind := strings.ToLower(db.blockName())
+	render, ok := diagramKinds[kind]
+	if !ok {
+		if kind != "" {
+			db.warn(db.openPosition(), "No such diagram{} kind '"+db.blockName()+"'")
+		}
+		render = renderGraphDiagram
+		if sequenceLineRegex.MatchString(src) {
+			render = renderSequenceDiagram
+		}
+	}
+
+	svg, warnings := render(src)
+	for _, warning := range warnings {
+		db.warn(db.openPosition(), warning)
+	}
+	el.addHTML(svg)
+}
+
+// INTERNALS
+
+// diagramLabel splits a label into lines at \n
+func diagramLabel(label string) []string {
+	if label == "" {
+		return nil
+	}
+	label = strings.NewReplacer(`\l`, `\n`, `\r`, `\n`).Replace(label)
+	return strings.Split(label, `\n`)
+}
+
+// diagramTextWidth returns the approximate width of label lines
+func diagramTextWidth(lines []string) float64 {
+	longest := 0
+	for _, line := range lines {
+		if n := utf8.RuneCountInString(line); n > longest {
+			longest = n
+		}
+	}
+	return float64(longest * diagramCharWidth)
+}
+
+// diagramColor returns a CSS color from a color name or hex code, or an empty
+// string if it is not valid
+func diagramColor(color string) string {
+	if c, ok := colors[strings.ToLower(color)]; ok {
+		return c
+	}
+	if colorRegex.MatchString(color) {
+		return color
+	}
+	return ""
+}
+
+// diagramStyle returns a style attribute, or an empty string if there are no
+// properties. props are pairs of property names and values
+func diagramStyle(props ...string) string {
+	var parts []string
+	for i := 0; i+1 < len(props); i += 2 {
+		if props[i+1] != "" {
+			parts = append(parts, props[i]+": "+props[i+1])
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return ` style="` + strings.Join(parts, "; ") + `"`
+}
+
+// svgNum formats a coordinate
+func svgNum(f float64) string {
+	return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64)
+}
+
+// diagramBounds tracks the extent of a drawing
+type diagramBounds struct {
+	minX, minY, maxX, maxY float64
+	set                    bool
+}
+
+// extend grows the bounds to include a rectangle
+func (d *diagramBounds) extend(x1, y1, x2, y2 float64) {
+	if !d.set {
+		d.minX, d.minY, d.maxX, d.maxY, d.set = x1, y1, x2, y2, true
+		return
+	}
+	d.minX, d.minY = math.Min(d.minX, x1), math.Min(d.minY, y1)
+	d.maxX, d.maxY = math.Max(d.maxX, x2), math.Max(d.maxY, y2)
+}
+
+// svg wraps a drawing in an SVG document sized to the bounds plus a margin
+func (d *diagramBounds) svg(drawing string) HTML {
+	x, y := d.minX-diagramMargin, d.minY-diagramMargin
+	w, h := d.maxX-d.minX+2*diagramMargin, d.maxY-d.minY+2*diagramMargin
+	var b strings.Builder
+	b.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" class="q-diagram-svg" role="img"`)
+	b.WriteString(` width="` + svgNum(w) + `" height="` + svgNum(h) + `"`)
+	b.WriteString(` viewBox="` + svgNum(x) + " " + svgNum(y) + " " + svgNum(w) + " " + svgNum(h) + `">`)
+	b.WriteString(drawing)
+	b.WriteString("</svg>")
+	return HTML(b.String())
+}
+
+// svgText writes label lines vertically centered on y. anchor is the SVG
+// text-anchor: start, middle, or end
+func svgText(b *strings.Builder, x, y float64, anchor, class string, lines []string) {
+	top := y - float64(len(lines)-1)*diagramLineHeight/2
+	b.WriteString(`<text`)
+	if class != "" {
+		b.WriteString(` class="` + class + `"`)
+	}
+	b.WriteString(` text-anchor="` + anchor + `" dominant-baseline="central">`)
+	for i, line := range lines {
+		b.WriteString(`<tspan x="` + svgNum(x) + `" y="` + svgNum(top+float64(i)*diagramLineHeight) + `">`)
+		b.WriteString(html.EscapeString(line) + "</tspan>")
+	}
+	b.WriteString("</text>")
+}
+
+// svgArrowHead writes an arrowhead at (x, y) pointing away from (fromX, fromY).
+// if open, it is drawn as lines rather than a filled triangle
+func svgArrowHead(b *strings.Builder, fromX, fromY, x, y float64, open bool, color string) {
+	dx, dy := x-fromX, y-fromY
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	ux, uy := dx/length, dy/length
+	baseX, baseY := x-10*ux, y-10*uy
+	px, py := -uy*5, ux*5
+	points := svgNum(baseX+px) + "," + svgNum(baseY+py) + " " +
+		svgNum(x) + "," + svgNum(y) + " " +
+		svgNum(baseX-px) + "," + svgNum(baseY-py)
+	if open {
+		b.WriteString(`<polyline class="q-diagram-edge" points="` + points + `"` + diagramStyle("stroke", color) + `/>`)
+		return
+	}
+	b.WriteString(`<polygon class="q-diagram-arrow" points="` + points + `"` + diagramStyle("fill", color) + `/>`)
+}
@@ -5,7 +5,7 @@ import (
 )
 
 type forBlock struct {
-	iterableName string
+	iterableExpr string
 	itemName     string
 	invalid      bool
 	_scope       *variableScope
@@ -23,17 +23,17 @@ func (b *forBlock) variables() *variableScope {
 
 func (b *forBlock) parse(p *Page) {
 
-	// separate the iterable name from the item name
+	// separate the iterable expression from the item name
 	expression := strings.TrimSpace(b.name)
 	parts := strings.Split(expression, " as")
-	b.iterableName = strings.TrimPrefix(strings.TrimSpace(parts[0]), "@")
+	b.iterableExpr = strings.TrimSpace(parts[0])
 	b.itemName = "value"
 	if len(parts) > 1 {
 		b.itemName = strings.TrimPrefix(strings.TrimSpace(parts[1]), "@")
 	}
 
-	// warn if missing iterable name
-	if b.iterableName == "" {
+	// warn if missing iterable
+	if b.iterableExpr == "" {
 		b.warn(b.openPos, "Missing iterable name, should be: for [@var] {...}")
 		b.invalid = true
 		return
@@ -46,14 +46,19 @@ func (b *forBlock) parse(p *Page) {
 		return
 	}
 
-	found, err := b.variables().Get(b.iterableName)
+	// for [items] is the same as for [@items]
+	if variableRegex.MatchString("@" + b.iterableExpr) {
+		b.iterableExpr = "@" + b.iterableExpr
+	}
+
+	found, err := evalExpression(b.variables(), b.iterableExpr)
 	if err != nil {
 		b.warn(b.openPos, err.Error())
 		b.invalid = true
 		return
 	}
 	if found == nil {
-		b.warn(b.openPos, "@"+b.iterableName+" is not defined")
+		b.warn(b.openPos, b.iterableExpr+" is not defined")
 		b.invalid = true
 		return
 	}
@@ -71,7 +76,7 @@ func (b *forBlock) html(p *Page, el element) {
 		return
 	}
 
-	iterable, err := b.variables().Get(b.iterableName)
+	iterable, err := evalExpression(b.variables(), b.iterableExpr)
 	if err != nil {
 		b.warn(b.openPos, err.Error())
 		return
@@ -92,6 +97,6 @@ func (b *forBlock) html(p *Page, el element) {
 			handleGenericContent(b, p, el)
 		}
 	default:
-		b.warn(b.openPos, "@"+b.iterableName+" is not iterable")
+		b.warn(b.openPos, b.iterableExpr+" is not iterable")
 	}
 }
@@ -0,0 +1,208 @@
+package wikifier
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maximum number of nested include{} blocks
+const includeMaxDepth = 5
+
+// include{} embeds another page, or one section of it, into the page.
+// The page name is given as the block name, with an optional #section
+// heading ID, e.g. include [other page#some section] {}.
+type includeBlock struct {
+	pageName string    // name of the included page
+	section  string    // heading ID of the included section, if any
+	included *Page     // the included page, if it was parsed successfully
+	sec      *secBlock // the included section, if any
+	*parserBlock
+}
+
+// newIncludeBlock creates an include{} given an underlying parser block.
+func newIncludeBlock(name string, b *parserBlock) block {
+	return &includeBlock{parserBlock: b}
+}
+
+// parse finds and parses the included page.
+func (ib *includeBlock) parse(page *Page) {
+	ib.parserBlock.parse(page)
+
+	// separate page name and section
+	ib.pageName = strings.TrimSpace(ib.blockName())
+	if hashIdx := strings.IndexByte(ib.pageName, '#'); hashIdx != -1 {
+		ib.section = PageNameLink(strings.TrimSpace(ib.pageName[hashIdx+1:]))
+		ib.pageName = strings.TrimSpace(ib.pageName[:hashIdx])
+	}
+	if ib.pageName == "" {
+		ib.warn(ib.openPos, "No page specified for include{}")
+		return
+	}
+
+	// the page must be within the page directory
+	included := page.findPage(ib.pageName)
+	if included == nil {
+		ib.warn(ib.openPos, "Page '"+ib.pageName+"' is not within the wiki")
+		return
+	}
+
+	// check for cycles and depth
+	depth := 0
+	for p := page; p != nil; p = p.includedBy {
+		if p.Path() == included.Path() {
+			ib.warn(ib.openPos, "Page '"+ib.pageName+"' cannot be included recursively")
+			return
+		}
+		depth++
+	}
+	if depth > includeMaxDepth {
+		ib.warn(ib.openPos, "Too many nested include{} blocks (max "+strconv.Itoa(includeMaxDepth)+")")
+		return
+	}
+
+	// remember the page uses this, even if it does not exist yet,
+	// so that creating it regenerates this page
+	name := included.NameNE()
+	line := ib.openPos.Line
+	page.Includes[name] = append(page.Includes[name], line)
+	page.PageLinks[name] = append(page.PageLinks[name], line)
+
+	if !included.Exists() {
+		ib.warn(ib.openPos, "Page '"+ib.pageName+"' does not exist")
+		return
+	}
+
+	// heading IDs and footnotes must be unique across both pages
+	included.includedBy = page
+	included.headingIDs = page.headingIDs
+
+	// included pages do not have their own title heading
+	opt := *page.Opt
+	opt.Page.EnableTitle = false
+	included.Opt = &opt
+	included.Wiki = page.Wiki
+
+	if err := included.Parse(); err != nil {
+		ib.warn(ib.openPos, "Page '"+ib.pageName+"' error: "+err.Error())
+		return
+	}
+
+	// drafts are not served, so they can't be included either
+	if included.Draft() {
+		ib.warn(ib.openPos, "Page '"+ib.pageName+"' is a draft")
+		return
+	}
+
+	// find the section
+	if ib.section != "" {
+		ib.sec = findSection(included.main, ib.section)
+		if ib.sec == nil {
+			ib.warn(ib.openPos, "Page '"+ib.pageName+"' has no section '"+ib.section+"'")
+			return
+		}
+	}
+
+	ib.included = included
+}
+
+// html embeds the included page or section.
+func (ib *includeBlock) html(page *Page, el element) {
+	included := ib.included
+	ib.included = nil
+	if included == nil {
+		el.hide()
+		return
+	}
+	el.addClass("include-" + PageNameLink(included.NameNE()))
+
+	// generate the DOM
+	if ib.sec != nil {
+		secEl := ib.sec.el().copy()
+		ib.sec.html(included, secEl)
+		el.addChild(secEl)
+	} else {
+		mainBlock := included.mainBlock()
+		mainEl := mainBlock.el()
+		mainBlock.html(included, mainEl)
+		mainEl.setMeta("noTags", true)
+		el.addChild(mainEl)
+	}
+
+	// the included page's references are this page's too, so that
+	// changes to them regenerate this page
+	line := ib.openPos.Line
+	for name, dimensions := range included.Images {
+		page.Images[name] = append(page.Images[name], dimensions...)
+	}
+	for name, info := range included.Models {
+		page.Models[name] = info
+	}
+	for name := range included.PageLinks {
+		page.PageLinks[name] = append(page.PageLinks[name], line)
+	}
+	for name := range included.Includes {
+		page.Includes[name] = append(page.Includes[name], line)
+	}
+
+	// and it needs the same styles
+	for _, style := range included.staticStyles {
+		found := false
+		for _, existing := range page.staticStyles {
+			if existing == style {
+				found = true
+				break
+			}
+		}
+		if !found {
+			page.staticStyles = append(page.staticStyles, style)
+		}
+	}
+	page.codeStyles = page.codeStyles || included.codeStyles
+	page.diagStyles = page.diagStyles || included.diagStyles
+}
+
+// INTERNALS
+
+// findPage finds another page by name. It returns nil if the name is
+// absolute or contains .. or if the page file is outside the page directory.
+func (p *Page) findPage(name string) *Page {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") {
+		return nil
+	}
+	for _, element := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if strings.TrimSpace(element) == ".." {
+			return nil
+		}
+	}
+
+	var found *Page
+	if p.Opt.Page.Find != nil {
+		found = p.Opt.Page.Find(p, name)
+	}
+	if found == nil {
+		found = NewPage(pageAbs(filepath.Join(p.Opt.Dir.Page, PageName(name))))
+	}
+
+	// check where it really is before it is opened
+	if found.Exists() {
+		rel, err := filepath.Rel(pageAbs(p.Opt.Dir.Page), found.Path())
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return found
+}
+
+// findSection finds a sec{} by heading ID.
+func findSection(b block, headingID string) *secBlock {
+	for _, child := range b.blockContent() {
+		if sec, ok := child.(*secBlock); ok && strings.EqualFold(sec.headingID, headingID) {
+			return sec
+		}
+		if sec := findSection(child, headingID); sec != nil {
+			return sec
+		}
+	}
+	return nil
+}
@@ -70,6 +70,12 @@ func (mb *mainBlock) html(page *Page, el element) {
 		item.html(page, item.el())
 		el.addChild(item.el())
 	}
+
+	// footnotes not listed in any references{}. those in included pages
+	// are listed at the end of the page which includes them
+	if page.includedBy == nil && page.notesListed < len(page.footnotes) {
+		page.addFootnotes(el.createChild("ul", "references"))
+	}
 }
 
 func (mb *mainBlock) createSection(page *Page, pcs []posContent) block {
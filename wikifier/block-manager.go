@@ -1,5 +1,7 @@
 package wikifier
 
+import "sort"
+
 var blockAliases = map[string]string{
 	"section":   "sec",
 	"paragraph": "p",
@@ -10,27 +12,44 @@ var blockAliases = map[string]string{
 }
 
 var blockInitializers = map[string]func(name string, b *parserBlock) block{
-	"main":      newMainBlock,
-	"clear":     newClearBlock,
-	"sec":       newSecBlock,
-	"p":         newPBlock,
-	"map":       newMapBlock,
-	"infobox":   newInfobox,
-	"infosec":   newInfosec,
-	"invisible": newInvisibleBlock,
-	"list":      newListBlock,
-	"numlist":   newNumlistBlock,
-	"code":      newCodeBlock,
-	"fmt":       newFmtBlock,
-	"html":      newHTMLBlock,
-	"history":   newHistoryBlock,
-	"style":     newStyleBlock,
-	"imagebox":  newImagebox,
-	"image":     newImageBlock,
-	"model":     newModelBlock,
-	"toc":       newTocBlock,
-	"gallery":   newGalleryBlock,
-	"for":       newForBlock,
+	"main":       newMainBlock,
+	"clear":      newClearBlock,
+	"details":    newDetailsBlock,
+	"sec":        newSecBlock,
+	"p":          newPBlock,
+	"map":        newMapBlock,
+	"include":    newIncludeBlock,
+	"infobox":    newInfobox,
+	"infosec":    newInfosec,
+	"invisible":  newInvisibleBlock,
+	"list":       newListBlock,
+	"numlist":    newNumlistBlock,
+	"pages":      newPagesBlock,
+	"quote":      newQuoteBlock,
+	"code":       newCodeBlock,
+	"diagram":    newDiagramBlock,
+	"math":       newMathBlock,
+	"fmt":        newFmtBlock,
+	"html":       newHTMLBlock,
+	"history":    newHistoryBlock,
+	"style":      newStyleBlock,
+	"imagebox":   newImagebox,
+	"image":      newImageBlock,
+	"model":      newModelBlock,
+	"toc":        newTocBlock,
+	"tabs":       newTabsBlock,
+	"tab":        newTabBlock,
+	"note":       newCalloutBlock,
+	"warning":    newCalloutBlock,
+	"tip":        newCalloutBlock,
+	"gallery":    newGalleryBlock,
+	"for":        newForBlock,
+	"table":      newTableBlock,
+	"row":        newRowBlock,
+	"head":       newHeadBlock,
+	"cell":       newCellBlock,
+	"references": newReferencesBlock,
+	"cite":       newCiteBlock,
 }
 
 func newBlock(blockType, blockName, headingID string, blockClasses []string, parentBlock block, parentCatch catch, pos Position, page *Page) block {
@@ -70,6 +89,31 @@ func newBlock(blockType, blockName, headingID string, blockClasses []string, par
 	return newUnknownBlock(underlying)
 }
 
+// BlockTypes returns the names of the block types which can be used in page
+// source, including aliases, in alphabetical order.
+func BlockTypes() []string {
+	types := make([]string, 0, len(blockInitializers)+len(blockAliases))
+	for typ := range blockInitializers {
+		if typ != "main" {
+			types = append(types, typ)
+		}
+	}
+	for alias := range blockAliases {
+		types = append(types, alias)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// BlockAlias returns the block type which an alias stands for, or the type
+// itself if it is not an alias.
+func BlockAlias(blockType string) string {
+	if alias, exist := blockAliases[blockType]; exist {
+		return alias
+	}
+	return blockType
+}
+
 func generateBlock(b block, page *Page) HTML {
 	b.html(page, b.el()) // FIXME: actual page
 	return b.el().generate()
@@ -28,6 +28,7 @@ type mapListEntry struct {
 	value    any             // string, html, block, or mixed []any
 	typ      valueType       // value type
 	pos      Position        // position where the item started
+	keyPos   Position        // position where the key started
 	metas    map[string]bool // metadata
 }
 
@@ -53,6 +54,7 @@ type mapParser struct {
 	escape        bool
 	inValue       bool
 	startPos      Position
+	keyPos        Position
 	pos           Position
 	overwroteKey  any
 	overwroteWith any
@@ -113,6 +115,7 @@ func (m *Map) parse(page *Page) {
 				p.overwroteKey = p.key
 				p.overwroteWith = item
 				p.key = item
+				p.keyPos = p.pos
 			}
 			m.warnMaybe(p)
 
@@ -120,13 +123,21 @@ func (m *Map) parse(page *Page) {
 			item.parse(page)
 
 		case string:
-			item = strings.Trim(item, "\t ") // remove non-newline whitespace
-			// item = strings.Replace(item, "\n", " ", -1) // convert newlines
+			// remove non-newline whitespace, keeping track of the position
+			trimmed := strings.TrimLeft(item, "\t ")
+			p.pos.Column += len(item) - len(trimmed)
+			item = strings.TrimRight(trimmed, "\t ")
 			if item == "" {
 				continue
 			}
 			for _, c := range item {
 				m.handleChar(page, i, p, c)
+				if c == '\n' {
+					p.pos.Line++
+					p.pos.Column = 1
+				} else {
+					p.pos.Column++
+				}
 			}
 		}
 	}
@@ -147,8 +158,6 @@ func (m *Map) parse(page *Page) {
 }
 
 func (m *Map) handleChar(_ *Page, i int, p *mapParser, c rune) {
-	p.pos.Column = i
-
 	if c == ':' && !p.inValue && !p.escape {
 		// first colon indicates we're entering a value
 		m.warnMaybe(p)
@@ -236,6 +245,11 @@ func (m *Map) handleChar(_ *Page, i int, p *mapParser, c rune) {
 		// FIXME: should this be setOwn?
 		m.Set(strKey, valueToStore)
 
+		// anonymous values have no key of their own
+		if keyTitle == "" || p.keyPos.none() {
+			p.keyPos = p.startPos
+		}
+
 		// store the value in the map list
 		m.mapList = append(m.mapList, &mapListEntry{
 			keyTitle: keyTitle,                   // displayed key
@@ -243,6 +257,7 @@ func (m *Map) handleChar(_ *Page, i int, p *mapParser, c rune) {
 			typ:      getValueType(valueToStore), // type of value
 			key:      strKey,                     // actual underlying key
 			pos:      p.startPos,                 // position where the item started
+			keyPos:   p.keyPos,                   // position where the key started
 		})
 
 		// check for warnings once more
@@ -251,6 +266,7 @@ func (m *Map) handleChar(_ *Page, i int, p *mapParser, c rune) {
 		// reset status
 		p.inValue = false
 		p.key = nil
+		p.keyPos = Position{}
 		p.values = nil
 
 	} else {
@@ -298,6 +314,7 @@ func (m *Map) handleChar(_ *Page, i int, p *mapParser, c rune) {
 				return
 			}
 			p.startPos = p.pos
+			p.keyPos = p.pos
 			p.key = add
 			return
 		}
@@ -331,7 +348,7 @@ func (m *Map) getEntry(key string) *mapListEntry {
 // If the key doesn't exist, it returns the position where the map started.
 func (m *Map) getKeyPos(key string) Position {
 	if entry := m.getEntry(key); entry != nil {
-		return entry.pos
+		return entry.keyPos
 	}
 	return m.openPos
 }
@@ -0,0 +1,30 @@
+package wikifier
+
+// math{} displays TeX math as a MathML block. Like code{}, its content is
+// best brace-escaped with math {{ ... }} so that TeX groups are not parsed
+// as quiki blocks.
+type mathBlock struct {
+	*parserBlock
+}
+
+// newMathBlock creates a math{} given an underlying parser block.
+func newMathBlock(name string, b *parserBlock) block {
+	return &mathBlock{parserBlock: b}
+}
+
+// html converts the math to MathML.
+func (mb *mathBlock) html(page *Page, el element) {
+	el.setMeta("noIndent", true)
+
+	// get TeX source
+	tex := ""
+	for _, piece := range mb.textContent() {
+		tex += piece
+	}
+
+	math, err := texToMathML(tex, true)
+	if err != nil {
+		mb.warn(mb.openPosition(), "math: "+err.Error())
+	}
+	el.addHTML(math)
+}
@@ -1,6 +1,9 @@
 package wikifier
 
-import "path/filepath"
+import (
+	"path/filepath"
+	"strings"
+)
 
 type modelBlock struct {
 	modelName   string
@@ -23,9 +26,7 @@ func (mb *modelBlock) parse(page *Page) {
 	path := pageAbs(filepath.Join(page.Opt.Dir.Model, file))
 
 	// create page
-	model := NewPage(path)
-	model.name = name
-	model.model = true
+	model := NewModel(path, name)
 
 	// copy wiki opt from this page
 	model.Opt = page.Opt
@@ -51,8 +52,50 @@ func (mb *modelBlock) parse(page *Page) {
 	mb.modelName = name
 	mb.model = model
 
+	// check the options against @model.params
+	mb.checkParams(model)
+
 	// remember the page uses this
-	page.Models[file] = model.modelInfo()
+	page.Models[file] = model.ModelInfo()
+}
+
+// checkParams warns about options which the model does not accept, options
+// of the wrong type, and missing required options.
+func (mb *modelBlock) checkParams(model *Page) {
+	params := model.modelParams()
+	if params == nil {
+		return
+	}
+	model.applyModelParams()
+
+	name := "Model $" + mb.modelName + "{}"
+	known := make(map[string]bool, len(params))
+	for _, param := range params {
+		known[param.Name] = true
+
+		if !modelParamTypes[param.Type] {
+			mb.warn(mb.openPos, name+" option '"+param.Name+"' has unknown type '"+param.Type+"'")
+			continue
+		}
+
+		val, _ := mb.Get(param.Name)
+		if exprEmpty(val) {
+			if param.Required {
+				mb.warn(mb.openPos, name+" requires option '"+param.Name+"'")
+			}
+			continue
+		}
+
+		if _, ok := param.check(val); !ok {
+			mb.warn(mb.getKeyPos(param.Name), name+" option '"+param.Name+"' should be of type "+param.Type)
+		}
+	}
+
+	for _, key := range mb.OrderedKeys() {
+		if !known[key] && !strings.HasPrefix(key, "anon_") {
+			mb.warn(mb.getKeyPos(key), name+" has no option '"+key+"'")
+		}
+	}
 }
 
 func (mb *modelBlock) html(page *Page, mbEl element) {
@@ -0,0 +1,238 @@
+package wikifier
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PageQuery describes the pages to list in a pages{} block.
+type PageQuery struct {
+	Category string            // only pages in this category
+	Prefix   string            // only pages in this directory or starting with this
+	Author   string            // only pages by this author
+	Vars     map[string]string // only pages with these @page variables
+	Draft    string            // "no" to exclude drafts (default), "yes" for only drafts, or "any"
+	Sort     string            // title (default), author, created, or modified
+	Descend  bool              // sort in descending order
+	Limit    int               // maximum number of pages, or 0 for all
+}
+
+// columns which can be displayed in a pages{} table
+var pagesColumns = map[string]string{
+	"title":    "Title",
+	"author":   "Author",
+	"created":  "Created",
+	"modified": "Modified",
+	"desc":     "Description",
+}
+
+// pages{}
+
+// pages{} lists pages of the wiki matching a query, as a list or table.
+// Its keys are query options, and keys starting with page. match
+// @page variables, e.g. page.type: guide;
+type pagesBlock struct {
+	query   PageQuery
+	table   bool
+	columns []string
+	results []PageInfo
+	*Map
+}
+
+// newPagesBlock creates a pages{} given an underlying parser block.
+func newPagesBlock(name string, b *parserBlock) block {
+	return &pagesBlock{Map: newMapBlock("", b).(*Map)}
+}
+
+// parse parses the query options and finds the matching pages.
+func (pb *pagesBlock) parse(page *Page) {
+	pb.Map.parse(page)
+	pb.query.Draft = "no"
+	pb.columns = []string{"title", "author", "created"}
+
+	for _, entry := range pb.mapList {
+		key := entry.key
+		pos := entry.pos
+
+		// @page variable
+		if name := strings.TrimPrefix(entry.keyTitle, "page."); name != entry.keyTitle {
+			val, err := pb.GetStr(key)
+			if err != nil {
+				pb.warn(pos, errors.Wrap(err, key).Error())
+				continue
+			}
+			if pb.query.Vars == nil {
+				pb.query.Vars = make(map[string]string)
+			}
+			pb.query.Vars[name] = strings.TrimSpace(val)
+			continue
+		}
+
+		// anonymous values are not used
+		if strings.HasPrefix(key, "anon_") {
+			pb.warn(pos, "pages{} does not accept standalone values")
+			continue
+		}
+
+		val, err := pb.GetStr(key)
+		if err != nil {
+			pb.warn(pos, errors.Wrap(err, key).Error())
+			continue
+		}
+		val = strings.TrimSpace(val)
+
+		switch key {
+		case "category":
+			pb.query.Category = CategoryNameNE(val)
+
+		case "prefix", "dir":
+			pb.query.Prefix = strings.TrimPrefix(val, "/")
+
+		case "author":
+			pb.query.Author = val
+
+		case "draft":
+			val = strings.ToLower(val)
+			switch val {
+			case "yes", "no", "any":
+				pb.query.Draft = val
+			default:
+				pb.warn(pos, key+": expected yes, no, or any")
+			}
+
+		case "sort":
+			val = strings.ToLower(val)
+			switch val {
+			case "title", "author", "created", "modified":
+				pb.query.Sort = val
+			default:
+				pb.warn(pos, key+": expected title, author, created, or modified")
+			}
+
+		case "order":
+			switch strings.ToLower(val) {
+			case "asc":
+				pb.query.Descend = false
+			case "desc":
+				pb.query.Descend = true
+			default:
+				pb.warn(pos, key+": expected asc or desc")
+			}
+
+		case "limit":
+			limit, err := strconv.Atoi(val)
+			if err != nil || limit < 0 {
+				pb.warn(pos, key+": expected a positive number")
+				break
+			}
+			pb.query.Limit = limit
+
+		case "style":
+			switch strings.ToLower(val) {
+			case "list":
+				pb.table = false
+			case "table":
+				pb.table = true
+			default:
+				pb.warn(pos, key+": expected list or table")
+			}
+
+		case "columns":
+			pb.columns = nil
+			for _, col := range strings.Split(val, ",") {
+				col = strings.ToLower(strings.TrimSpace(col))
+				if pagesColumns[col] == "" {
+					pb.warn(pos, key+": unknown column '"+col+"'")
+					continue
+				}
+				pb.columns = append(pb.columns, col)
+			}
+
+		default:
+			pb.warn(pos, "Invalid key '"+key+"'")
+		}
+	}
+
+	// the wiki answers the query
+	if page.Opt.Page.Query == nil {
+		pb.warn(pb.openPos, "pages{} can only be used within a wiki")
+		return
+	}
+	pb.results = page.Opt.Page.Query(page, pb.query)
+
+	// the pages are linked, so changes to them regenerate this page
+	line := pb.openPos.Line
+	for _, info := range pb.results {
+		page.PageLinks[info.FileNE] = append(page.PageLinks[info.FileNE], line)
+	}
+
+	// and the page is regenerated when others are created or removed
+	page.Queries = append(page.Queries, line)
+}
+
+// html displays the matching pages.
+func (pb *pagesBlock) html(page *Page, el element) {
+	pb.Map.html(page, nil)
+	if len(pb.results) == 0 {
+		el.hide()
+		return
+	}
+
+	// list of links
+	if !pb.table {
+		el.setTag("ul")
+		for _, info := range pb.results {
+			li := el.createChild("li", "pages-item")
+			pagesAddColumn(page, li, info, "title")
+		}
+		return
+	}
+
+	// table with a column for each option
+	el.setTag("table")
+	el.addClass("table")
+	tr := el.createChild("thead", "").createChild("tr", "table-row")
+	for _, col := range pb.columns {
+		th := tr.createChild("th", "table-head")
+		th.setAttr("scope", "col")
+		th.addText(pagesColumns[col])
+	}
+	tbody := el.createChild("tbody", "")
+	for _, info := range pb.results {
+		tr := tbody.createChild("tr", "table-row")
+		for _, col := range pb.columns {
+			pagesAddColumn(page, tr.createChild("td", "table-cell"), info, col)
+		}
+	}
+}
+
+// pagesAddColumn adds one piece of page info to an element.
+func pagesAddColumn(page *Page, el element, info PageInfo, col string) {
+	switch col {
+	case "title":
+		a := el.createChild("a", "pages-link")
+		a.setAttr("href", page.Opt.Root.Page+"/"+info.FileNE)
+		switch {
+		case info.FmtTitle != "":
+			a.addHTML(info.FmtTitle)
+		case info.Title != "":
+			a.addText(info.Title)
+		default:
+			a.addText(info.FileNE)
+		}
+	case "author":
+		el.addText(info.Author)
+	case "created":
+		if info.Created != nil {
+			el.addText(exprStrftime(*info.Created, exprDateFormat))
+		}
+	case "modified":
+		if info.Modified != nil {
+			el.addText(exprStrftime(*info.Modified, exprDateFormat))
+		}
+	case "desc":
+		el.addText(info.Description)
+	}
+}
@@ -1 +1,20 @@
 package wikifier
+
+// quote{}
+
+// quoteBlock is a block quotation. Like sec{}, it may contain text, which is
+// broken into paragraphs, and other blocks.
+type quoteBlock struct {
+	*parserBlock
+}
+
+// newQuoteBlock creates a quote{} given an underlying parser block.
+func newQuoteBlock(name string, b *parserBlock) block {
+	return &quoteBlock{parserBlock: b}
+}
+
+// html creates a <blockquote> with the content.
+func (q *quoteBlock) html(page *Page, el element) {
+	el.setTag("blockquote")
+	handleGenericContent(q, page, el)
+}
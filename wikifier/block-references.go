@@ -0,0 +1,157 @@
+package wikifier
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+// references{}
+
+// references{} lists the footnotes which appear on the page before it and
+// after any previous references{}. Its own values are listed after them,
+// so it can also be used for a bibliography of cite{} blocks.
+type referencesBlock struct {
+	*List
+}
+
+// newReferencesBlock creates a references{} given an underlying parser block.
+func newReferencesBlock(name string, b *parserBlock) block {
+	return &referencesBlock{newListBlock("", b).(*List)}
+}
+
+// html converts the references to HTML elements.
+func (r *referencesBlock) html(page *Page, el element) {
+	el.setTag("ul")
+
+	// footnotes up to this point
+	page.addFootnotes(el)
+
+	// other references
+	for i, entry := range r.list {
+		value := prepareForHTML(entry.value, r, entry.pos)
+		r.list[i].value = value
+		r.list[i].typ = getValueType(value)
+		el.createChild("li", "ref-item").add(value)
+	}
+}
+
+// cite{}
+
+// cite{} is a structured reference to a source.
+type citeBlock struct {
+	citation HTML
+	*Map
+}
+
+// newCiteBlock creates a cite{} given an underlying parser block.
+func newCiteBlock(name string, b *parserBlock) block {
+	return &citeBlock{Map: newMapBlock("", b).(*Map)}
+}
+
+// parse parses the source information and formats the citation.
+func (c *citeBlock) parse(page *Page) {
+	c.Map.parse(page)
+
+	values := make(map[string]string)
+	for _, key := range c.OrderedKeys() {
+		switch key {
+		case "author", "title", "url", "date", "publisher", "accessed":
+			str, err := c.GetStr(key)
+			if err != nil {
+				c.warn(c.getKeyPos(key), key+": "+err.Error())
+				continue
+			}
+			values[key] = strings.TrimSpace(str)
+		default:
+			c.warn(c.getKeyPos(key), "Invalid key '"+key+"'")
+		}
+	}
+
+	// no title - this is mandatory unless there is a URL
+	if values["title"] == "" && values["url"] == "" {
+		c.warn(c.openPos, "No title or url specified for cite{}")
+		return
+	}
+
+	// Author (Date). "Title". Publisher. Retrieved Accessed.
+	var parts []string
+	if author, date := values["author"], values["date"]; author != "" && date != "" {
+		parts = append(parts, author+" ("+date+")")
+	} else if author != "" {
+		parts = append(parts, author)
+	} else if date != "" {
+		parts = append(parts, "("+date+")")
+	}
+
+	title := values["title"]
+	if title != "" {
+		title = `<span class="q-cite-title">&ldquo;` + title + `&rdquo;</span>`
+	}
+	if url := html.UnescapeString(values["url"]); url != "" {
+		lower := strings.ToLower(url)
+		url = html.EscapeString(url)
+		if title == "" {
+			title = url
+		}
+
+		// only link to web pages; other schemes like javascript: are unsafe
+		if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+			title = `<a class="q-link-other" href="` + url + `">` + title + `</a>`
+		} else {
+			c.warn(c.getKeyPos("url"), "url: must begin with http:// or https://")
+		}
+	}
+	parts = append(parts, title)
+
+	if publisher := values["publisher"]; publisher != "" {
+		parts = append(parts, `<span class="q-cite-publisher">`+publisher+`</span>`)
+	}
+	if accessed := values["accessed"]; accessed != "" {
+		parts = append(parts, "Retrieved "+accessed)
+	}
+
+	c.citation = HTML(strings.Join(parts, ". ") + ".")
+}
+
+// html converts the citation to an HTML element.
+func (c *citeBlock) html(page *Page, el element) {
+	el.setTag("span")
+	el.addHTML(c.citation)
+}
+
+// INTERNALS
+
+// noteHost returns the page which numbers and lists footnotes. Included
+// pages use the page which includes them, so numbers are not repeated.
+func (p *Page) noteHost() *Page {
+	for p.includedBy != nil {
+		p = p.includedBy
+	}
+	return p
+}
+
+// addFootnote stores a footnote and returns the HTML which refers to it.
+func (p *Page) addFootnote(note HTML) HTML {
+	p = p.noteHost()
+	p.footnotes = append(p.footnotes, note)
+	n := strconv.Itoa(len(p.footnotes))
+	return HTML(`<sup class="q-ref" id="qa-refsrc-` + n + `"><a class="q-ref-anchor" href="#qa-ref-` + n + `">[` + n + `]</a></sup>`)
+}
+
+// addFootnotes appends the footnotes not yet listed to a references list.
+func (p *Page) addFootnotes(list element) {
+	p = p.noteHost()
+	for p.notesListed < len(p.footnotes) {
+		note := p.footnotes[p.notesListed]
+		p.notesListed++
+		n := strconv.Itoa(p.notesListed)
+
+		li := list.createChild("li", "ref-item")
+		li.setAttr("id", "qa-ref-"+n)
+		key := li.createChild("span", "ref-key").createChild("a", "ref-anchor")
+		key.setAttr("href", "#qa-refsrc-"+n)
+		key.addText("[" + n + "]")
+		li.addHTML(note)
+	}
+}
@@ -47,8 +47,9 @@ func (sec *secBlock) parse(page *Page) {
 	}
 
 	// top-level headers start at h2 when @page.enable.title is true, since the
-	// page title is the sole h1. otherwise, h1 is top-level.
-	if enable && level == 1 {
+	// page title is the sole h1. otherwise, h1 is top-level. included pages
+	// are always beneath the title of the page which includes them
+	if (enable || page.includedBy != nil) && level == 1 {
 		level++
 	}
 
@@ -103,11 +104,7 @@ func (sec *secBlock) html(page *Page, el element) {
 		// TODO: meta section heading ID
 
 		// add -n as needed if this is already used
-		n := page.headingIDs[sec.headingID]
-		page.headingIDs[sec.headingID]++
-		if n != 0 {
-			sec.headingID += "-" + strconv.Itoa(n)
-		}
+		sec.headingID = page.uniqueHeadingID(sec.headingID)
 
 		// create the heading
 		h := el.createChild("h"+strconv.Itoa(level), typ)
@@ -117,3 +114,14 @@ func (sec *secBlock) html(page *Page, el element) {
 
 	handleGenericContent(sec, page, el)
 }
+
+// uniqueHeadingID returns the heading ID with -n added as needed if it is
+// already used on the page.
+func (p *Page) uniqueHeadingID(id string) string {
+	n := p.headingIDs[id]
+	p.headingIDs[id]++
+	if n != 0 {
+		id += "-" + strconv.Itoa(n)
+	}
+	return id
+}
@@ -0,0 +1,338 @@
+package wikifier
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// accepted values for the align options of table{} and cell{}
+var tableAlignments = map[string]bool{"left": true, "center": true, "right": true}
+
+// the most columns a cell{} may cover, as in HTML
+const maxTableColspan = 1000
+
+// table{}
+
+// table{} displays a table of rows and columns.
+// Its anonymous values are rows, and its named keys are options.
+type tableBlock struct {
+	sortable bool
+	align    []string
+	rows     []*tableRow
+	*Map
+}
+
+// newTableBlock creates a table{} given an underlying parser block.
+func newTableBlock(name string, b *parserBlock) block {
+	return &tableBlock{Map: newMapBlock("", b).(*Map)}
+}
+
+// parse parses the table options and rows.
+func (t *tableBlock) parse(page *Page) {
+	t.Map.parse(page)
+
+	for _, key := range t.OrderedKeys() {
+		switch key {
+
+		// whether the reader can sort by column
+		case "sortable":
+			t.sortable = mapGetFlag(t.Map, key)
+
+		// alignment for each column
+		case "align":
+			align, err := t.GetStrList(key)
+			if err != nil {
+				t.warn(t.getKeyPos(key), errors.Wrap(err, key).Error())
+				break
+			}
+			for i, a := range align {
+				align[i] = strings.ToLower(a)
+				if !tableAlignments[align[i]] && align[i] != "-" {
+					t.warn(t.getKeyPos(key), key+": expected left, center, right, or -")
+					align[i] = "-"
+				}
+			}
+			t.align = align
+
+		default:
+
+			// unknown key
+			if !strings.HasPrefix(key, "anon_") {
+				t.warn(t.getKeyPos(key), "Invalid key '"+key+"'")
+				break
+			}
+
+			// anonymous row is OK
+			blk, err := t.GetBlock(key)
+			if err != nil {
+				t.warn(t.getKeyPos(key), errors.Wrap(err, key).Error())
+				break
+			}
+			row, ok := blk.(*tableRow)
+			if !ok {
+				t.warn(t.getKeyPos(key), key+": expected Block<row{}>")
+				break
+			}
+			t.rows = append(t.rows, row)
+		}
+	}
+}
+
+// html converts the table to HTML elements.
+func (t *tableBlock) html(page *Page, el element) {
+	el.setTag("table")
+	if t.sortable {
+		el.addClass("table-sortable")
+	}
+
+	// display the title if there is one
+	if t.name != "" {
+		el.createChild("caption", "table-caption").addHTML(format(t, t.name, t.openPos))
+	}
+
+	// header rows at the top go in thead; the rest in tbody
+	nHead := 0
+	for nHead < len(t.rows) && t.rows[nHead].head {
+		nHead++
+	}
+	var thead, tbody element
+	var spans []int // rows remaining for each column spanned from above
+	for i, row := range t.rows {
+		group := tbody
+		if row.head && tbody == nil {
+			if thead == nil {
+				thead = el.createChild("thead", "")
+			}
+			group = thead
+		} else if tbody == nil {
+			tbody = el.createChild("tbody", "")
+			group = tbody
+		}
+
+		// cells can't span past the end of their section
+		rowsLeft := len(t.rows) - i
+		if i < nHead {
+			rowsLeft = nHead - i
+		}
+		spans = row.addTo(page, group, t.align, spans, rowsLeft)
+	}
+}
+
+// row{} and head{}
+
+// row{} is a list of cells in a table{}.
+// head{} is the same, except its cells are header cells.
+type tableRow struct {
+	head bool
+	*List
+}
+
+// newRowBlock creates a row{} given an underlying parser block.
+func newRowBlock(name string, b *parserBlock) block {
+	return &tableRow{false, newListBlock("", b).(*List)}
+}
+
+// newHeadBlock creates a head{} given an underlying parser block.
+func newHeadBlock(name string, b *parserBlock) block {
+	return &tableRow{true, newListBlock("", b).(*List)}
+}
+
+// html is only used for rows outside of table{}.
+func (row *tableRow) html(page *Page, el element) {
+	// FIXME: do not produce this warning if the row is in a variable
+	row.warn(row.openPosition(), row.blockType()+"{} outside of table{} does nothing")
+	el.hide()
+}
+
+// addTo adds the row to a table section. spans is the number of rows still
+// occupied in each column by cells from rows above, and the updated spans
+// are returned. rowsLeft is the number of rows remaining in the section,
+// including this one.
+func (row *tableRow) addTo(page *Page, group element, align []string, spans []int, rowsLeft int) []int {
+	tr := group.createChild("tr", "table-row")
+
+	col := 0
+	for _, entry := range row.list {
+
+		// skip columns occupied from above
+		for col < len(spans) && spans[col] > 0 {
+			col++
+		}
+
+		// a cell{} with options, or just a value
+		cell, isCell := entry.value.(*tableCell)
+		pos := entry.pos
+		if isCell {
+			pos = cell.openPos
+		} else {
+			cell = &tableCell{colspan: 1, rowspan: 1, values: []any{entry.value}}
+		}
+
+		// don't span past the last row
+		rowspan := cell.rowspan
+		if rowspan > rowsLeft {
+			cell.warn(pos, "rowspan: extends past the last row; using "+strconv.Itoa(rowsLeft))
+			rowspan = rowsLeft
+		}
+
+		tag, typ := "td", "table-cell"
+		if row.head || cell.header {
+			tag, typ = "th", "table-head"
+		}
+		td := tr.createChild(tag, typ)
+		if row.head {
+			td.setAttr("scope", "col")
+		} else if cell.header {
+			td.setAttr("scope", "row")
+		}
+		if cell.colspan > 1 {
+			td.setAttr("colspan", strconv.Itoa(cell.colspan))
+		}
+		if rowspan > 1 {
+			td.setAttr("rowspan", strconv.Itoa(rowspan))
+		}
+
+		// alignment from the cell, else from the column
+		a := cell.align
+		if a == "" && col < len(align) {
+			a = align[col]
+		}
+		if tableAlignments[a] {
+			td.setStyle("text-align", a)
+		}
+
+		for _, value := range cell.values {
+			// empty cells have no value
+			if value != nil {
+				td.add(prepareForHTML(value, row, pos))
+			}
+		}
+
+		// remember which columns this occupies in rows below
+		for i := col; i < col+cell.colspan; i++ {
+			for len(spans) <= i {
+				spans = append(spans, 0)
+			}
+			if rowspan > spans[i] {
+				spans[i] = rowspan
+			}
+		}
+		col += cell.colspan
+	}
+
+	// one less row for each spanned column
+	for i := range spans {
+		if spans[i] > 0 {
+			spans[i]--
+		}
+	}
+
+	return spans
+}
+
+// cell{}
+
+// cell{} is a table cell with options.
+// Its anonymous values are its content, and its named keys are options.
+type tableCell struct {
+	colspan, rowspan int
+	align            string
+	header           bool
+	values           []any
+	*Map
+}
+
+// newCellBlock creates a cell{} given an underlying parser block.
+func newCellBlock(name string, b *parserBlock) block {
+	return &tableCell{colspan: 1, rowspan: 1, Map: newMapBlock("", b).(*Map)}
+}
+
+// parse parses the cell options and content.
+func (cell *tableCell) parse(page *Page) {
+	cell.Map.parse(page)
+
+	for _, entry := range cell.mapList {
+		switch key := entry.key; key {
+
+		// number of columns or rows covered
+		case "colspan", "rowspan":
+			str, err := cell.GetStr(key)
+			n, convErr := strconv.Atoi(strings.TrimSpace(str))
+			if err != nil || convErr != nil || n < 1 {
+				cell.warn(entry.pos, key+": expected positive integer")
+				break
+			}
+			if key == "colspan" {
+				if n > maxTableColspan {
+					cell.warn(entry.pos, key+": at most "+strconv.Itoa(maxTableColspan)+" columns; using "+strconv.Itoa(maxTableColspan))
+					n = maxTableColspan
+				}
+				cell.colspan = n
+			} else {
+				cell.rowspan = n
+			}
+
+		// text alignment
+		case "align":
+			align, err := cell.GetStr(key)
+			align = strings.ToLower(strings.TrimSpace(align))
+			if err != nil || !tableAlignments[align] {
+				cell.warn(entry.pos, key+": expected left, center, or right")
+				break
+			}
+			cell.align = align
+
+		// whether this is a header cell
+		case "header":
+			cell.header = mapGetFlag(cell.Map, key)
+
+		default:
+
+			// unknown key
+			if entry.keyTitle != "" {
+				cell.warn(entry.pos, "Invalid key '"+key+"'")
+				break
+			}
+
+			// anonymous value is content
+			cell.values = append(cell.values, entry.value)
+		}
+	}
+}
+
+// html is only used for cells outside of row{}.
+func (cell *tableCell) html(page *Page, el element) {
+	cell.warn(cell.openPosition(), "cell{} outside of row{} does nothing")
+	el.hide()
+}
+
+// INTERNALS
+
+// mapGetFlag fetches a yes or no option from a map, producing a warning at
+// the appropriate spot if needed
+func mapGetFlag(m *Map, key string) bool {
+	val, err := m.Get(key)
+	if err != nil {
+		m.warn(m.getKeyPos(key), key+": "+err.Error())
+		return false
+	}
+	var str string
+	switch v := val.(type) {
+	case bool:
+		return v
+	case string:
+		str = v
+	case HTML:
+		str = string(v)
+	}
+	switch strings.ToLower(strings.TrimSpace(str)) {
+	case "yes", "true", "on", "1":
+		return true
+	case "no", "false", "off", "0":
+		return false
+	}
+	m.warn(m.getKeyPos(key), key+": expected yes or no")
+	return false
+}
@@ -0,0 +1,92 @@
+package wikifier
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseTablePage parses a page and returns its HTML and warning messages
+func parseTablePage(t *testing.T, source string) (string, []string) {
+	t.Helper()
+	page := NewPageSource(source)
+	if err := page.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	html := string(page.HTML())
+	var warnings []string
+	for _, w := range page.Warnings {
+		warnings = append(warnings, w.Message)
+	}
+	return html, warnings
+}
+
+func TestTableSpans(t *testing.T) {
+	tests := []struct {
+		name, source string
+		contains     []string // in the HTML
+		missing      []string // not in the HTML
+		warning      string   // expected warning, if any
+	}{
+		{
+			name:     "rowspan within table",
+			source:   "table { { cell { rowspan: 2; :a; }; b; }; { c; }; }",
+			contains: []string{`rowspan="2"`},
+		},
+		{
+			name:     "rowspan past last row",
+			source:   "table { { x; }; { cell { rowspan: 5; :a; }; b; }; { c; }; }",
+			contains: []string{`rowspan="2"`},
+			missing:  []string{`rowspan="5"`},
+			warning:  "cell{}: rowspan: extends past the last row; using 2",
+		},
+		{
+			name:    "rowspan in last row",
+			source:  "table { { cell { rowspan: 3; :a; }; }; }",
+			missing: []string{`rowspan`},
+			warning: "cell{}: rowspan: extends past the last row; using 1",
+		},
+		{
+			name:    "rowspan past header",
+			source:  "table { head { cell { rowspan: 3; :h; }; }; { a; }; }",
+			missing: []string{`rowspan`},
+			warning: "cell{}: rowspan: extends past the last row; using 1",
+		},
+		{
+			name:     "huge colspan",
+			source:   "table { { cell { colspan: 999999999; :a; }; }; }",
+			contains: []string{`colspan="1000"`},
+			warning:  "cell{}: colspan: at most 1000 columns; using 1000",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			html, warnings := parseTablePage(t, test.source)
+			for _, s := range test.contains {
+				if !strings.Contains(html, s) {
+					t.Errorf("missing %q in %s", s, html)
+				}
+			}
+			for _, s := range test.missing {
+				if strings.Contains(html, s) {
+					t.Errorf("unexpected %q in %s", s, html)
+				}
+			}
+			if test.warning == "" {
+				if len(warnings) != 0 {
+					t.Errorf("unexpected warnings %q", warnings)
+				}
+			} else if len(warnings) != 1 || warnings[0] != test.warning {
+				t.Errorf("warnings = %q, want %q", warnings, test.warning)
+			}
+		})
+	}
+}
+
+// a rowspan in the header must not occupy columns in the body
+func TestTableHeaderSpanEnds(t *testing.T) {
+	html, _ := parseTablePage(t, "table { head { cell { rowspan: 3; :h; }; i; }; { a; b; }; }")
+	tbody := strings.Join(strings.Fields(html[strings.Index(html, "<tbody"):]), " ")
+	if !strings.Contains(tbody, "> a <") || !strings.Contains(tbody, "> b <") || strings.Count(tbody, "<td") != 2 {
+		t.Errorf("body = %s", tbody)
+	}
+}
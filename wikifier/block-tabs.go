@@ -0,0 +1,112 @@
+package wikifier
+
+import "strings"
+
+// tabs{}
+
+// tabs{} displays tab{} blocks as tabs, showing one at a time.
+type tabsBlock struct {
+	tabs []*tabBlock
+	*parserBlock
+}
+
+// newTabsBlock creates a tabs{} given an underlying parser block.
+func newTabsBlock(name string, b *parserBlock) block {
+	return &tabsBlock{parserBlock: b}
+}
+
+// parse finds the tabs.
+func (tabs *tabsBlock) parse(page *Page) {
+	tabs.parserBlock.parse(page)
+
+	for _, text := range tabs.textContent() {
+		if strings.TrimSpace(text) != "" {
+			tabs.warn(tabs.openPos, "tabs{} can only contain tab{} blocks")
+			break
+		}
+	}
+
+	for _, child := range tabs.blockContent() {
+		tab, ok := child.(*tabBlock)
+		if !ok {
+			tabs.warn(child.openPosition(), child.blockType()+"{} in tabs{} does nothing")
+			continue
+		}
+		tabs.tabs = append(tabs.tabs, tab)
+	}
+}
+
+// html displays the tab list followed by a panel for each tab. only the
+// first is visible until quiki.js switches between them.
+func (tabs *tabsBlock) html(page *Page, el element) {
+	if len(tabs.tabs) == 0 {
+		el.hide()
+		return
+	}
+
+	list := el.createChild("div", "tabs-list")
+	list.setAttr("role", "tablist")
+	for i, tab := range tabs.tabs {
+
+		// add -n as needed if this is already used
+		tab.headingID = page.uniqueHeadingID(tab.headingID)
+		id := "qa-" + tab.headingID
+
+		// the button which selects the tab
+		button := list.createChild("button", "tabs-tab")
+		button.setAttr("type", "button")
+		button.setAttr("role", "tab")
+		button.setAttr("id", id+"-tab")
+		button.setAttr("aria-controls", id)
+		if i == 0 {
+			button.setAttr("aria-selected", "true")
+		} else {
+			button.setAttr("aria-selected", "false")
+			button.setAttr("tabindex", "-1")
+		}
+		button.addHTML(format(tab, tab.title, tab.openPos))
+
+		// the tab content
+		panel := tab.el().copy()
+		panel.setAttr("role", "tabpanel")
+		panel.setAttr("id", id)
+		panel.setAttr("aria-labelledby", id+"-tab")
+		panel.setAttr("tabindex", "0")
+		panel.setBoolAttr("hidden", i != 0)
+		handleGenericContent(tab, page, panel)
+		el.addChild(panel)
+	}
+}
+
+// tab{}
+
+// tab{} is one tab in a tabs{} block. Its name is the title of the tab.
+type tabBlock struct {
+	title string
+	*parserBlock
+}
+
+// newTabBlock creates a tab{} given an underlying parser block.
+func newTabBlock(name string, b *parserBlock) block {
+	return &tabBlock{parserBlock: b}
+}
+
+// parse determines the title and heading ID.
+func (tab *tabBlock) parse(page *Page) {
+	tab.title = tab.blockName()
+	if tab.title == "" {
+		tab.warn(tab.openPos, "tab{} requires a title")
+		tab.title = "Tab"
+	}
+	if tab.headingID == "" {
+		tab.headingID = PageNameLink(tab.title)
+	}
+	tab.parserBlock.parse(page)
+}
+
+// html is only used for tabs outside of tabs{}.
+func (tab *tabBlock) html(page *Page, el element) {
+	// FIXME: do not produce this warning if the tab is in a variable
+	tab.warn(tab.openPos, "tab{} outside of tabs{} does nothing")
+	el.hide()
+}
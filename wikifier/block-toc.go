@@ -14,10 +14,8 @@ func (toc *tocBlock) html(page *Page, el element) {
 	el.addHTML(HTML("<li><strong>Contents</strong></li>"))
 
 	// add each top-level section
-	for _, child := range page.main.blockContent() {
-		if sec, ok := child.(*secBlock); ok {
-			toc.tocAdd(sec, el, page)
-		}
+	for _, child := range tocChildren(page.main) {
+		toc.tocAdd(child, el, page)
 	}
 
 	// don't show the toc if there are <2 on the page
@@ -26,28 +24,61 @@ func (toc *tocBlock) html(page *Page, el element) {
 	}
 }
 
-func (toc *tocBlock) tocAdd(sec *secBlock, addTo element, page *Page) {
+func (toc *tocBlock) tocAdd(b block, addTo element, page *Page) {
 	toc.secCount++
 
 	// create an item for this section if it has a title and isn't intro
 	var subList element
-	if !sec.isIntro && sec.title != "" {
+	if title, headingID, listed := tocEntry(b); listed {
 		li := addTo.createChild("li", "")
 		a := li.createChild("a", "link-internal")
-		a.setAttr("href", "#"+sec.headingID)
-		a.addHTML(format(toc, sec.title, sec.openPos))
+		a.setAttr("href", "#"+headingID)
+		a.addHTML(format(toc, title, b.openPosition()))
 		addTo = li
 	} else {
 		subList = addTo
 	}
 
 	// create a sub-list for each section underneath
-	for _, child := range sec.blockContent() {
-		if secChild, ok := child.(*secBlock); ok {
-			if subList == nil {
-				subList = addTo.createChild("ul", "")
+	for _, child := range tocChildren(b) {
+		if subList == nil {
+			subList = addTo.createChild("ul", "")
+		}
+		toc.tocAdd(child, subList, page)
+	}
+}
+
+// tocEntry returns the title and heading ID of a block listed in the toc,
+// and whether it is listed.
+func tocEntry(b block) (string, string, bool) {
+	switch b := b.(type) {
+	case *secBlock:
+		return b.title, b.headingID, !b.isIntro && b.title != ""
+	case *tabBlock:
+		return b.title, b.headingID, true
+	case *detailsBlock:
+		return b.title, b.headingID, b.title != ""
+	}
+	return "", "", false
+}
+
+// tocChildren returns the sections and other blocks listed in the toc which
+// are beneath a block. blocks like tabs{} and note{} are looked through, so
+// sections within them are listed as if they were not.
+func tocChildren(b block) []block {
+	var children []block
+	for _, child := range b.blockContent() {
+		switch child.(type) {
+		case *secBlock, *detailsBlock:
+			children = append(children, child)
+		case *tabBlock:
+			// tab{} outside of tabs{} is not displayed
+			if _, ok := b.(*tabsBlock); ok {
+				children = append(children, child)
 			}
-			toc.tocAdd(secChild, subList, page)
+		case *tabsBlock, *calloutBlock:
+			children = append(children, tocChildren(child)...)
 		}
 	}
+	return children
 }
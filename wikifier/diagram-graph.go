@@ -0,0 +1,627 @@
+package wikifier
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// diagram-graph.go - graphs in a subset of the Graphviz DOT language
+
+type graphNode struct {
+	id, shape, color, fill string
+	label                  []string
+	rank, order            int
+	x, y, w, h             float64
+}
+
+type graphEdge struct {
+	from, to   *graphNode
+	label      []string
+	head, tail bool // arrowheads at either end
+	dashed     bool
+	color      string
+	back       bool // ignored when ranking, to break cycles
+}
+
+type graphDiagram struct {
+	nodes        []*graphNode
+	byID         map[string]*graphNode
+	edges        []*graphEdge
+	leftToRight  bool
+	nodeDefaults map[string]string
+	edgeDefaults map[string]string
+	warnings     []string
+}
+
+type dotToken struct {
+	text string
+	id   bool // identifier or quoted string, as opposed to a symbol
+	line int
+}
+
+// renderGraphDiagram renders DOT source to SVG.
+func renderGraphDiagram(src string) (HTML, []string) {
+	g := &graphDiagram{
+		byID:         make(map[string]*graphNode),
+		nodeDefaults: make(map[string]string),
+		edgeDefaults: make(map[string]string),
+	}
+	g.parse(tokenizeDOT(src))
+	return g.render(), g.warnings
+}
+
+// tokenizeDOT splits DOT source into identifiers, quoted strings, and symbols
+func tokenizeDOT(src string) []dotToken {
+	var tokens []dotToken
+	runes := []rune(src)
+	line := 1
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+
+		case c == '\n':
+			line++
+			i++
+
+		case unicode.IsSpace(c):
+			i++
+
+		// comments
+		case c == '#' || (c == '/' && i+1 < len(runes) && runes[i+1] == '/'):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				if runes[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			i += 2
+
+		// quoted string
+		case c == '"':
+			var b strings.Builder
+			start := line
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '"' {
+					i++
+				} else if runes[i] == '\n' {
+					line++
+				}
+				b.WriteRune(runes[i])
+			}
+			i++
+			tokens = append(tokens, dotToken{b.String(), true, start})
+
+		// edge operators
+		case c == '-' && i+1 < len(runes) && (runes[i+1] == '>' || runes[i+1] == '-'):
+			tokens = append(tokens, dotToken{string(runes[i : i+2]), false, line})
+			i += 2
+
+		// identifier or number
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, dotToken{string(runes[start:i]), true, line})
+
+		// symbol
+		default:
+			tokens = append(tokens, dotToken{string(c), false, line})
+			i++
+		}
+	}
+	return tokens
+}
+
+// parse interprets DOT statements
+func (g *graphDiagram) parse(tokens []dotToken) {
+	i := 0
+	is := func(j int, text string) bool {
+		return j < len(tokens) && tokens[j].text == text
+	}
+	isID := func(j int) bool {
+		return j < len(tokens) && tokens[j].id
+	}
+
+	for i < len(tokens) {
+		tok := tokens[i]
+		switch {
+
+		// separators and grouping are ignored
+		case is(i, ";"), is(i, "{"), is(i, "}"), is(i, ","):
+			i++
+
+		// graph, node, and edge defaults
+		case (is(i, "graph") || is(i, "node") || is(i, "edge")) && is(i+1, "["):
+			var attrs map[string]string
+			i, attrs = g.parseAttrs(tokens, i+1)
+			for key, value := range attrs {
+				switch tok.text {
+				case "graph":
+					g.setGraphAttr(key, value)
+				case "node":
+					g.nodeDefaults[key] = value
+				case "edge":
+					g.edgeDefaults[key] = value
+				}
+			}
+
+		// graph header, e.g. digraph name {
+		case is(i, "strict"):
+			i++
+		case is(i, "graph") || is(i, "digraph") || is(i, "subgraph"):
+			i++
+			if isID(i) {
+				i++
+			}
+
+		// graph attribute, e.g. rankdir=LR
+		case isID(i) && is(i+1, "="):
+			if isID(i + 2) {
+				g.setGraphAttr(tok.text, tokens[i+2].text)
+			}
+			i += 3
+
+		// node or edge statement
+		case isID(i):
+			ids := []string{tok.text}
+			var ops []string
+			i = skipDOTPort(tokens, i+1)
+			for (is(i, "->") || is(i, "--")) && isID(i+1) {
+				ops = append(ops, tokens[i].text)
+				ids = append(ids, tokens[i+1].text)
+				i = skipDOTPort(tokens, i+2)
+			}
+			var attrs map[string]string
+			if is(i, "[") {
+				i, attrs = g.parseAttrs(tokens, i)
+			}
+
+			// node
+			if len(ids) == 1 {
+				n := g.node(ids[0])
+				g.setNodeAttrs(n, attrs)
+				break
+			}
+
+			// edges
+			for j, op := range ops {
+				e := &graphEdge{from: g.node(ids[j]), to: g.node(ids[j+1]), head: op == "->"}
+				g.setEdgeAttrs(e, g.edgeDefaults)
+				g.setEdgeAttrs(e, attrs)
+				g.edges = append(g.edges, e)
+			}
+
+		default:
+			g.warn(tok.line, "unexpected '"+tok.text+"'")
+			i++
+		}
+	}
+}
+
+// parseAttrs parses one or more [key=value, ...] lists starting at i
+func (g *graphDiagram) parseAttrs(tokens []dotToken, i int) (int, map[string]string) {
+	attrs := make(map[string]string)
+	for i < len(tokens) && !tokens[i].id && tokens[i].text == "[" {
+		for i++; i < len(tokens) && (tokens[i].id || tokens[i].text != "]"); {
+			tok := tokens[i]
+			if !tok.id {
+				// separator
+				if tok.text != "," && tok.text != ";" {
+					g.warn(tok.line, "unexpected '"+tok.text+"' in attributes")
+				}
+				i++
+				continue
+			}
+			if i+2 < len(tokens) && !tokens[i+1].id && tokens[i+1].text == "=" && tokens[i+2].id {
+				attrs[tok.text] = tokens[i+2].text
+				i += 3
+				continue
+			}
+			attrs[tok.text] = "true"
+			i++
+		}
+		if i >= len(tokens) {
+			g.warn(tokens[len(tokens)-1].line, "missing ']'")
+		}
+		i++
+	}
+	return i, attrs
+}
+
+// skipDOTPort skips a :port after a node ID, which is not supported
+func skipDOTPort(tokens []dotToken, i int) int {
+	for i+1 < len(tokens) && !tokens[i].id && tokens[i].text == ":" && tokens[i+1].id {
+		i += 2
+	}
+	return i
+}
+
+// node returns the node with the given ID, creating it if necessary
+func (g *graphDiagram) node(id string) *graphNode {
+	if n, ok := g.byID[id]; ok {
+		return n
+	}
+	n := &graphNode{id: id, label: diagramLabel(id), shape: "ellipse"}
+	g.setNodeAttrs(n, g.nodeDefaults)
+	g.byID[id] = n
+	g.nodes = append(g.nodes, n)
+	return n
+}
+
+func (g *graphDiagram) setGraphAttr(key, value string) {
+	if key == "rankdir" {
+		dir := strings.ToUpper(value)
+		g.leftToRight = dir == "LR" || dir == "RL"
+	}
+}
+
+func (g *graphDiagram) setNodeAttrs(n *graphNode, attrs map[string]string) {
+	for key, value := range attrs {
+		switch key {
+		case "label":
+			n.label = diagramLabel(value)
+		case "shape":
+			n.shape = strings.ToLower(value)
+		case "color":
+			n.color = diagramColor(value)
+		case "fillcolor":
+			n.fill = diagramColor(value)
+		}
+	}
+}
+
+func (g *graphDiagram) setEdgeAttrs(e *graphEdge, attrs map[string]string) {
+	for key, value := range attrs {
+		switch key {
+		case "label":
+			e.label = diagramLabel(value)
+		case "color":
+			e.color = diagramColor(value)
+		case "style":
+			e.dashed = value == "dashed" || value == "dotted"
+		case "dir":
+			e.head = value == "forward" || value == "both"
+			e.tail = value == "back" || value == "both"
+		}
+	}
+}
+
+func (g *graphDiagram) warn(line int, warning string) {
+	g.warnings = append(g.warnings, "diagram line "+strconv.Itoa(line)+": "+warning)
+}
+
+// LAYOUT
+
+// layout arranges nodes in ranks so that edges generally point in one
+// direction
+func (g *graphDiagram) layout() {
+	const nodeGap, rankGap = 30, 50
+
+	g.sizeNodes()
+	ranks := g.rankNodes()
+	g.orderNodes(ranks)
+
+	// cross is the axis along a rank; main is the axis across ranks
+	cross := func(n *graphNode) float64 {
+		if g.leftToRight {
+			return n.h
+		}
+		return n.w
+	}
+	main := func(n *graphNode) float64 {
+		if g.leftToRight {
+			return n.w
+		}
+		return n.h
+	}
+
+	// find the size of each rank
+	rankCross := make([]float64, len(ranks))
+	rankMain := make([]float64, len(ranks))
+	maxCross := 0.0
+	for r, rank := range ranks {
+		for i, n := range rank {
+			rankCross[r] += cross(n)
+			if i > 0 {
+				rankCross[r] += nodeGap
+			}
+			rankMain[r] = math.Max(rankMain[r], main(n))
+		}
+		maxCross = math.Max(maxCross, rankCross[r])
+	}
+
+	// position each node, centering each rank
+	mainPos := 0.0
+	for r, rank := range ranks {
+		crossPos := (maxCross - rankCross[r]) / 2
+		for _, n := range rank {
+			c, m := crossPos+cross(n)/2, mainPos+rankMain[r]/2
+			if g.leftToRight {
+				n.x, n.y = m, c
+			} else {
+				n.x, n.y = c, m
+			}
+			crossPos += cross(n) + nodeGap
+		}
+		mainPos += rankMain[r] + rankGap
+	}
+
+}
+
+// sizeNodes determines the size of each node from its label and shape
+func (g *graphDiagram) sizeNodes() {
+	for _, n := range g.nodes {
+		textW := diagramTextWidth(n.label)
+		textH := float64(len(n.label) * diagramLineHeight)
+		n.w, n.h = math.Max(textW+24, 40), textH+14
+		switch n.shape {
+		case "ellipse", "oval":
+			n.w, n.h = n.w*1.25, n.h*1.3
+		case "circle":
+			d := math.Max(n.w, n.h) * 1.1
+			n.w, n.h = d, d
+		case "diamond":
+			n.w, n.h = n.w*1.6, n.h*1.8
+		case "plaintext", "plain", "none":
+			n.w, n.h = textW+8, textH+8
+		}
+	}
+}
+
+// rankNodes assigns each node a rank by the longest path leading to it,
+// ignoring edges which would form cycles
+func (g *graphDiagram) rankNodes() [][]*graphNode {
+	out := make(map[*graphNode][]*graphEdge)
+	for _, e := range g.edges {
+		out[e.from] = append(out[e.from], e)
+	}
+
+	// find edges leading back to a node being visited
+	const visiting, visited = 1, 2
+	state := make(map[*graphNode]int)
+	var visit func(n *graphNode)
+	visit = func(n *graphNode) {
+		state[n] = visiting
+		for _, e := range out[n] {
+			switch state[e.to] {
+			case visiting:
+				e.back = true
+			case 0:
+				visit(e.to)
+			}
+		}
+		state[n] = visited
+	}
+	for _, n := range g.nodes {
+		if state[n] == 0 {
+			visit(n)
+		}
+	}
+
+	// rank in topological order
+	inDegree := make(map[*graphNode]int)
+	for _, e := range g.edges {
+		if !e.back && e.from != e.to {
+			inDegree[e.to]++
+		}
+	}
+	var queue []*graphNode
+	for _, n := range g.nodes {
+		if inDegree[n] == 0 {
+			queue = append(queue, n)
+		}
+	}
+	maxRank := 0
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		maxRank = max(maxRank, n.rank)
+		for _, e := range out[n] {
+			if e.back || e.from == e.to {
+				continue
+			}
+			e.to.rank = max(e.to.rank, n.rank+1)
+			if inDegree[e.to]--; inDegree[e.to] == 0 {
+				queue = append(queue, e.to)
+			}
+		}
+	}
+
+	if len(g.nodes) == 0 {
+		return nil
+	}
+	ranks := make([][]*graphNode, maxRank+1)
+	for _, n := range g.nodes {
+		n.order = len(ranks[n.rank])
+		ranks[n.rank] = append(ranks[n.rank], n)
+	}
+	return ranks
+}
+
+// orderNodes reduces edge crossings by moving each node toward the average
+// position of its neighbors in other ranks
+func (g *graphDiagram) orderNodes(ranks [][]*graphNode) {
+	neighbors := make(map[*graphNode][]*graphNode)
+	for _, e := range g.edges {
+		if e.from.rank != e.to.rank {
+			neighbors[e.from] = append(neighbors[e.from], e.to)
+			neighbors[e.to] = append(neighbors[e.to], e.from)
+		}
+	}
+
+	sortRank := func(rank []*graphNode, above bool) {
+		bary := make(map[*graphNode]float64)
+		for _, n := range rank {
+			sum, count := 0.0, 0
+			for _, nb := range neighbors[n] {
+				if (nb.rank < n.rank) == above {
+					sum += float64(nb.order)
+					count++
+				}
+			}
+			bary[n] = float64(n.order)
+			if count > 0 {
+				bary[n] = sum / float64(count)
+			}
+		}
+		sort.SliceStable(rank, func(i, j int) bool { return bary[rank[i]] < bary[rank[j]] })
+		for i, n := range rank {
+			n.order = i
+		}
+	}
+
+	for sweep := 0; sweep < 4; sweep++ {
+		for r := 1; r < len(ranks); r++ {
+			sortRank(ranks[r], true)
+		}
+		for r := len(ranks) - 2; r >= 0; r-- {
+			sortRank(ranks[r], false)
+		}
+	}
+}
+
+// boundary returns the point on the edge of a node's shape in the direction
+// (dx, dy) from its center
+func (n *graphNode) boundary(dx, dy float64) (float64, float64) {
+	a, b := n.w/2, n.h/2
+	if dx == 0 && dy == 0 {
+		return n.x, n.y
+	}
+	var t float64
+	switch n.shape {
+	case "ellipse", "oval", "circle":
+		t = 1 / math.Sqrt((dx/a)*(dx/a)+(dy/b)*(dy/b))
+	case "diamond":
+		t = 1 / (math.Abs(dx)/a + math.Abs(dy)/b)
+	default:
+		t = math.Inf(1)
+		if dx != 0 {
+			t = a / math.Abs(dx)
+		}
+		if dy != 0 {
+			t = math.Min(t, b/math.Abs(dy))
+		}
+	}
+	return n.x + dx*t, n.y + dy*t
+}
+
+// RENDERING
+
+// render lays out and draws the graph
+func (g *graphDiagram) render() HTML {
+	g.layout()
+
+	// edges which go in both directions between two nodes are curved
+	// so that they do not overlap
+	pairs := make(map[[2]*graphNode]bool)
+	for _, e := range g.edges {
+		pairs[[2]*graphNode{e.from, e.to}] = true
+	}
+
+	var b strings.Builder
+	var bounds diagramBounds
+
+	// edges go under nodes
+	for _, e := range g.edges {
+		g.renderEdge(&b, &bounds, e, pairs[[2]*graphNode{e.to, e.from}])
+	}
+	for _, n := range g.nodes {
+		g.renderNode(&b, n)
+		bounds.extend(n.x-n.w/2, n.y-n.h/2, n.x+n.w/2, n.y+n.h/2)
+	}
+
+	return bounds.svg(b.String())
+}
+
+func (g *graphDiagram) renderEdge(b *strings.Builder, bounds *diagramBounds, e *graphEdge, reversed bool) {
+	class := "q-diagram-edge"
+	if e.dashed {
+		class += " q-diagram-dashed"
+	}
+	style := diagramStyle("stroke", e.color)
+	labelW := diagramTextWidth(e.label)
+	labelH := float64(len(e.label) * diagramLineHeight)
+
+	// loop from a node to itself
+	if e.from == e.to {
+		n := e.from
+		x, y := n.x+n.w/2, n.y
+		if n.shape == "ellipse" || n.shape == "oval" || n.shape == "circle" || n.shape == "diamond" {
+			x -= n.w / 8
+		}
+		b.WriteString(`<path class="` + class + `" d="M` + svgNum(x) + "," + svgNum(y-8) +
+			" C" + svgNum(x+40) + "," + svgNum(y-30) + " " + svgNum(x+40) + "," + svgNum(y+30) +
+			" " + svgNum(x) + "," + svgNum(y+8) + `"` + style + "/>")
+		if e.head {
+			svgArrowHead(b, x+20, y+20, x, y+8, false, e.color)
+		}
+		if len(e.label) != 0 {
+			svgText(b, x+36, y, "start", "q-diagram-label", e.label)
+		}
+		bounds.extend(x, y-labelH/2, x+36+labelW, y+labelH/2)
+		return
+	}
+
+	// edges which skip over nodes or which overlap another are curved.
+	// the control point is twice as far from the line as the curve
+	bend := 0.0
+	rankSpan, orderSpan := e.to.rank-e.from.rank, e.to.order-e.from.order
+	if reversed || rankSpan > 1 || rankSpan < -1 || (rankSpan == 0 && (orderSpan > 1 || orderSpan < -1)) {
+		bend = 30
+	}
+	dx, dy := e.to.x-e.from.x, e.to.y-e.from.y
+	length := math.Hypot(dx, dy)
+	cx, cy := (e.from.x+e.to.x)/2-dy/length*bend*2, (e.from.y+e.to.y)/2+dx/length*bend*2
+
+	x1, y1 := e.from.boundary(cx-e.from.x, cy-e.from.y)
+	x2, y2 := e.to.boundary(cx-e.to.x, cy-e.to.y)
+	if bend == 0 {
+		cx, cy = (x1+x2)/2, (y1+y2)/2
+	}
+	b.WriteString(`<path class="` + class + `" d="M` + svgNum(x1) + "," + svgNum(y1) +
+		" Q" + svgNum(cx) + "," + svgNum(cy) + " " + svgNum(x2) + "," + svgNum(y2) + `"` + style + "/>")
+	if e.head {
+		svgArrowHead(b, cx, cy, x2, y2, false, e.color)
+	}
+	if e.tail {
+		svgArrowHead(b, cx, cy, x1, y1, false, e.color)
+	}
+
+	// label at the middle of the curve
+	mx, my := x1/4+cx/2+x2/4, y1/4+cy/2+y2/4
+	if len(e.label) != 0 {
+		svgText(b, mx, my, "middle", "q-diagram-label", e.label)
+	}
+	bounds.extend(mx-labelW/2, my-labelH/2, mx+labelW/2, my+labelH/2)
+}
+func (g *graphDiagram) renderNode(b *strings.Builder, n *graphNode) {
+	style := diagramStyle("fill", n.fill, "stroke", n.color)
+	x, y, w, h := n.x, n.y, n.w, n.h
+	switch n.shape {
+	case "ellipse", "oval", "circle":
+		b.WriteString(`<ellipse class="q-diagram-node" cx="` + svgNum(x) + `" cy="` + svgNum(y) +
+			`" rx="` + svgNum(w/2) + `" ry="` + svgNum(h/2) + `"` + style + "/>")
+	case "diamond":
+		b.WriteString(`<polygon class="q-diagram-node" points="` +
+			svgNum(x) + "," + svgNum(y-h/2) + " " + svgNum(x+w/2) + "," + svgNum(y) + " " +
+			svgNum(x) + "," + svgNum(y+h/2) + " " + svgNum(x-w/2) + "," + svgNum(y) + `"` + style + "/>")
+	case "plaintext", "plain", "none":
+	default:
+		radius := "0"
+		if n.shape == "rounded" || n.shape == "mrecord" {
+			radius = "8"
+		}
+		b.WriteString(`<rect class="q-diagram-node" x="` + svgNum(x-w/2) + `" y="` + svgNum(y-h/2) +
+			`" width="` + svgNum(w) + `" height="` + svgNum(h) + `" rx="` + radius + `"` + style + "/>")
+	}
+	svgText(b, x, y, "middle", "", n.label)
+}
@@ -0,0 +1,269 @@
+package wikifier
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// diagram-sequence.go - sequence diagrams
+//
+//	participant Long Name as A
+//	A -> B: message
+//	B --> A: dashed reply
+//	A ->> B: open arrow
+//	note over A, B: text
+
+var (
+	seqParticipantRegex = regexp.MustCompile(`(?i)^(?:participant|actor)\s+(.+?)(?:\s+as\s+(\S+))?$`)
+	seqNoteRegex        = regexp.MustCompile(`(?i)^note\s+(left of|right of|over)\s+([^:]+?)\s*:\s*(.*)$`)
+	seqMessageRegex     = regexp.MustCompile(`^(.+?)\s*(-->>|->>|-->|->)\s*(.+?)\s*(?::\s*(.*))?$`)
+)
+
+type seqParticipant struct {
+	label []string
+	index int
+	x, w  float64
+}
+
+type seqEvent struct {
+	from, to     *seqParticipant // message; or the participants of a note
+	label        []string
+	dashed, open bool
+	note         string // left of, right of, or over; empty for messages
+	y            float64
+}
+
+type sequenceDiagram struct {
+	participants []*seqParticipant
+	byID         map[string]*seqParticipant
+	events       []*seqEvent
+	warnings     []string
+}
+
+// renderSequenceDiagram renders a sequence diagram to SVG.
+func renderSequenceDiagram(src string) (HTML, []string) {
+	s := &sequenceDiagram{byID: make(map[string]*seqParticipant)}
+	s.parse(src)
+	return s.render(), s.warnings
+}
+
+// parse interprets each line
+func (s *sequenceDiagram) parse(src string) {
+	for i, line := range strings.Split(src, "\n") {
+		// lines may end with ; as in other diagram languages
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+
+		// blank line or comment
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		// participant [as alias]
+		if match := seqParticipantRegex.FindStringSubmatch(line); match != nil {
+			label, id := strings.Trim(match[1], `"`), match[2]
+			if id == "" {
+				id = label
+			}
+			s.participant(id).label = diagramLabel(label)
+			continue
+		}
+
+		// note
+		if match := seqNoteRegex.FindStringSubmatch(line); match != nil {
+			names := strings.Split(match[2], ",")
+			e := &seqEvent{note: strings.ToLower(match[1]), label: diagramLabel(match[3])}
+			e.from = s.participant(strings.TrimSpace(names[0]))
+			e.to = s.participant(strings.TrimSpace(names[len(names)-1]))
+			if e.note != "over" && len(names) > 1 {
+				s.warn(i+1, "note "+e.note+" must refer to one participant")
+			}
+			if e.to.index < e.from.index {
+				e.from, e.to = e.to, e.from
+			}
+			s.events = append(s.events, e)
+			continue
+		}
+
+		// message
+		if match := seqMessageRegex.FindStringSubmatch(line); match != nil {
+			s.events = append(s.events, &seqEvent{
+				from:   s.participant(match[1]),
+				to:     s.participant(match[3]),
+				label:  diagramLabel(match[4]),
+				dashed: strings.HasPrefix(match[2], "--"),
+				open:   strings.HasSuffix(match[2], ">>"),
+			})
+			continue
+		}
+
+		s.warn(i+1, "cannot understand '"+line+"'")
+	}
+}
+
+// participant returns the participant with the given ID, creating it if
+// necessary
+func (s *sequenceDiagram) participant(id string) *seqParticipant {
+	if p, ok := s.byID[id]; ok {
+		return p
+	}
+	p := &seqParticipant{label: diagramLabel(id), index: len(s.participants)}
+	s.byID[id] = p
+	s.participants = append(s.participants, p)
+	return p
+}
+
+func (s *sequenceDiagram) warn(line int, warning string) {
+	s.warnings = append(s.warnings, "diagram line "+strconv.Itoa(line)+": "+warning)
+}
+
+// LAYOUT
+
+// layout positions participants so that labels fit between them, and
+// positions events from top to bottom. it returns the height of the
+// participant boxes and the top of the bottom boxes
+func (s *sequenceDiagram) layout() (float64, float64) {
+	n := len(s.participants)
+	boxH := 0.0
+	for _, p := range s.participants {
+		p.w = math.Max(diagramTextWidth(p.label)+24, 60)
+		boxH = math.Max(boxH, float64(len(p.label)*diagramLineHeight)+14)
+	}
+
+	// minimum distance between each participant and the next
+	dist := make([]float64, max(n-1, 0))
+	for i := range dist {
+		dist[i] = math.Max(s.participants[i].w/2+s.participants[i+1].w/2+40, 120)
+	}
+	need := func(i, j int, d float64) {
+		if i < 0 || i >= j || j >= n {
+			return
+		}
+		have := 0.0
+		for k := i; k < j; k++ {
+			have += dist[k]
+		}
+		if have < d {
+			for k := i; k < j; k++ {
+				dist[k] += (d - have) / float64(j-i)
+			}
+		}
+	}
+	for _, e := range s.events {
+		labelW := diagramTextWidth(e.label)
+		switch {
+		case e.note == "left of":
+			need(e.from.index-1, e.from.index, labelW+40+e.from.w/2)
+		case e.note == "right of":
+			need(e.from.index, e.from.index+1, labelW+40+e.from.w/2)
+		case e.note == "over":
+			need(e.from.index, e.to.index, labelW-20)
+		case e.from == e.to:
+			need(e.from.index, e.from.index+1, labelW+60)
+		case e.from.index < e.to.index:
+			need(e.from.index, e.to.index, labelW+30)
+		default:
+			need(e.to.index, e.from.index, labelW+30)
+		}
+	}
+	x := 0.0
+	for i, p := range s.participants {
+		p.x = x
+		if i < len(dist) {
+			x += dist[i]
+		}
+	}
+
+	// events from top to bottom
+	y := boxH + 20
+	for _, e := range s.events {
+		labelH := float64(len(e.label) * diagramLineHeight)
+		e.y = y
+		switch {
+		case e.note != "":
+			y += labelH + 12 + 14
+		case e.from == e.to:
+			y += math.Max(labelH, 24) + 20
+		default:
+			y += labelH + 24
+		}
+	}
+	return boxH, y + 6
+}
+
+// RENDERING
+
+// render lays out and draws the diagram
+func (s *sequenceDiagram) render() HTML {
+	boxH, bottom := s.layout()
+	var b strings.Builder
+	var bounds diagramBounds
+
+	// participants and lifelines
+	top := 0.0
+	for _, p := range s.participants {
+		bounds.extend(p.x-p.w/2, top, p.x+p.w/2, bottom+boxH)
+		b.WriteString(`<line class="q-diagram-lifeline" x1="` + svgNum(p.x) + `" y1="` + svgNum(top+boxH) +
+			`" x2="` + svgNum(p.x) + `" y2="` + svgNum(bottom) + `"/>`)
+		for _, y := range []float64{top, bottom} {
+			b.WriteString(`<rect class="q-diagram-node" x="` + svgNum(p.x-p.w/2) + `" y="` + svgNum(y) +
+				`" width="` + svgNum(p.w) + `" height="` + svgNum(boxH) + `" rx="4"/>`)
+			svgText(&b, p.x, y+boxH/2, "middle", "", p.label)
+		}
+	}
+
+	// messages and notes
+	for _, e := range s.events {
+		labelW := diagramTextWidth(e.label)
+		labelH := float64(len(e.label) * diagramLineHeight)
+		class := "q-diagram-edge"
+		if e.dashed {
+			class += " q-diagram-dashed"
+		}
+
+		switch {
+
+		// note
+		case e.note != "":
+			left, right := e.from.x-labelW/2-8, e.from.x+labelW/2+8
+			switch e.note {
+			case "left of":
+				left, right = e.from.x-e.from.w/2-labelW-16, e.from.x-e.from.w/2
+			case "right of":
+				left, right = e.from.x+e.from.w/2, e.from.x+e.from.w/2+labelW+16
+			case "over":
+				center := (e.from.x + e.to.x) / 2
+				width := math.Max(labelW+16, e.to.x-e.from.x+40)
+				left, right = center-width/2, center+width/2
+			}
+			bounds.extend(left, e.y, right, e.y+labelH+12)
+			b.WriteString(`<rect class="q-diagram-note" x="` + svgNum(left) + `" y="` + svgNum(e.y) +
+				`" width="` + svgNum(right-left) + `" height="` + svgNum(labelH+12) + `"/>`)
+			svgText(&b, (left+right)/2, e.y+6+labelH/2, "middle", "", e.label)
+
+		// message to self
+		case e.from == e.to:
+			x, y := e.from.x, e.y
+			height := math.Max(labelH, 24)
+			b.WriteString(`<polyline class="` + class + `" points="` + svgNum(x) + "," + svgNum(y) + " " +
+				svgNum(x+30) + "," + svgNum(y) + " " + svgNum(x+30) + "," + svgNum(y+height) + " " +
+				svgNum(x) + "," + svgNum(y+height) + `"/>`)
+			svgArrowHead(&b, x+30, y+height, x, y+height, e.open, "")
+			svgText(&b, x+36, y+height/2, "start", "", e.label)
+			bounds.extend(x, y, x+36+labelW, y+height)
+
+		// message
+		default:
+			y := e.y + labelH + 6
+			b.WriteString(`<line class="` + class + `" x1="` + svgNum(e.from.x) + `" y1="` + svgNum(y) +
+				`" x2="` + svgNum(e.to.x) + `" y2="` + svgNum(y) + `"/>`)
+			svgArrowHead(&b, e.from.x, y, e.to.x, y, e.open, "")
+			if len(e.label) != 0 {
+				svgText(&b, (e.from.x+e.to.x)/2, e.y+labelH/2, "middle", "", e.label)
+			}
+		}
+	}
+
+	return bounds.svg(b.String())
+}
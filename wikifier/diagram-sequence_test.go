@@ -0,0 +1,30 @@
+package wikifier
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSequenceDiagramParse(t *testing.T) {
+	s := &sequenceDiagram{byID: make(map[string]*seqParticipant)}
+	s.parse("participant Long Name as A;\nA -> B: hello;\nB --> A;\nnote over A, B: done ;\n")
+	if len(s.warnings) != 0 {
+		t.Fatalf("warnings = %q", s.warnings)
+	}
+
+	// the ; terminator is not part of any label
+	var labels [][]string
+	for _, p := range s.participants {
+		labels = append(labels, p.label)
+	}
+	for _, e := range s.events {
+		labels = append(labels, e.label)
+	}
+	want := [][]string{{"Long Name"}, {"B"}, {"hello"}, nil, {"done"}}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("labels = %q, want %q", labels, want)
+	}
+	if len(s.events) != 3 || !s.events[1].dashed || s.events[2].note != "over" {
+		t.Errorf("events = %+v", s.events)
+	}
+}
@@ -0,0 +1,802 @@
+package wikifier
+
+import (
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	httpdate "github.com/Songmu/go-httpdate"
+	strip "github.com/grokify/html-strip-tags-go"
+	"github.com/pkg/errors"
+)
+
+// expression.go - expressions in [@...], if{} and for{}
+//
+//	[@price * 2]
+//	[@upper(@name)]
+//	[@page.author ?? "Anonymous"]
+//	[@count == 1 ? "item" : "items"]
+//	if [@m.count > 1] { ... }
+//
+// Expressions only read variables, so they are safe to evaluate anywhere.
+
+// maximum nesting of parentheses and operators
+const exprMaxDepth = 50
+
+// default format for date()
+const exprDateFormat = "%B %-d, %Y"
+
+// an exprNode is a parsed expression which can be evaluated
+type exprNode interface {
+	eval(scope *variableScope) (any, error)
+}
+
+type (
+	exprLiteral  struct{ value any }
+	exprVariable struct{ name string }
+	exprUnary    struct {
+		op string
+		x  exprNode
+	}
+	exprBinary struct {
+		op   string
+		x, y exprNode
+	}
+	exprTernary struct{ cond, x, y exprNode }
+	exprCall    struct {
+		name string
+		fn   exprFunc
+		args []exprNode
+	}
+)
+
+// an exprFunc is a function available in expressions
+type exprFunc struct {
+	minArgs, maxArgs int
+	call             func(args []any) (any, error)
+}
+
+var exprFuncs = map[string]exprFunc{
+	"upper":    {1, 1, exprUpper},
+	"lower":    {1, 1, exprLower},
+	"truncate": {2, 3, exprTruncate},
+	"date":     {1, 2, exprDate},
+	"len":      {1, 1, exprLen},
+}
+
+// evalExpression parses and evaluates an expression in the given scope.
+func evalExpression(scope *variableScope, expr string) (any, error) {
+	node, err := parseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return node.eval(scope)
+}
+
+// isSimpleVariable returns true if the expression is a variable with no
+// operators, such as @some.variable
+func isSimpleVariable(expr string) bool {
+	return len(expr) > 1 && expr[0] == '@' && variableRegex.MatchString(expr)
+}
+
+// PARSING
+
+type exprToken struct {
+	typ  byte // 'n' number, 's' string, 'v' variable, 'i' identifier, 'o' operator, 0 end
+	text string
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	depth  int
+}
+
+// operators, longest first
+var exprOperators = []string{
+	"??", "||", "&&", "==", "!=", "<=", ">=",
+	"?", ":", "<", ">", "+", "-", "*", "/", "%", "!", "(", ")", ",",
+}
+
+// parseExpression parses an expression.
+func parseExpression(expr string) (exprNode, error) {
+	tokens, err := tokenizeExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.typ != 0 {
+		return nil, errors.New("unexpected '" + tok.text + "'")
+	}
+	return node, nil
+}
+
+func tokenizeExpression(expr string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(expr)
+	isName := func(r rune) bool {
+		return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+
+		// whitespace
+		case unicode.IsSpace(r):
+			i++
+
+		// number
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{'n', string(runes[start:i])})
+
+		// string
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated string")
+			}
+			i++
+			tokens = append(tokens, exprToken{'s', b.String()})
+
+		// variable
+		case r == '@':
+			start := i + 1
+			for i++; i < len(runes) && isName(runes[i]); i++ {
+			}
+			if i == start {
+				return nil, errors.New("expected variable name after '@'")
+			}
+			tokens = append(tokens, exprToken{'v', string(runes[start:i])})
+
+		// identifier
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for ; i < len(runes) && isName(runes[i]); i++ {
+			}
+			tokens = append(tokens, exprToken{'i', string(runes[start:i])})
+
+		// operator
+		default:
+			found := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, exprToken{'o', op})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, errors.New("unexpected '" + string(r) + "'")
+			}
+		}
+	}
+	return tokens, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return exprToken{}
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.peek()
+	if tok.typ != 0 {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the given operators
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.typ != 'o' {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(op string) error {
+	if _, ok := p.accept(op); ok {
+		return nil
+	}
+	if tok := p.peek(); tok.typ != 0 {
+		return errors.New("expected '" + op + "' but found '" + tok.text + "'")
+	}
+	return errors.New("expected '" + op + "' at end of expression")
+}
+
+// cond ? x : y
+func (p *exprParser) parseTernary() (exprNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > exprMaxDepth {
+		return nil, errors.New("expression is too deeply nested")
+	}
+
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+	x, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	y, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return exprTernary{cond, x, y}, nil
+}
+
+// binary operators, from lowest to highest precedence
+var exprPrecedence = [][]string{
+	{"??"},
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	if level == len(exprPrecedence) {
+		return p.parseUnary()
+	}
+	x, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(exprPrecedence[level]...)
+		if !ok {
+			return x, nil
+		}
+		y, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = exprBinary{op, x, y}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	op, ok := p.accept("!", "-")
+	if !ok {
+		return p.parsePrimary()
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > exprMaxDepth {
+		return nil, errors.New("expression is too deeply nested")
+	}
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return exprUnary{op, x}, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch tok.typ {
+
+	case 'n':
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, errors.New("invalid number '" + tok.text + "'")
+		}
+		return exprLiteral{f}, nil
+
+	case 's':
+		return exprLiteral{tok.text}, nil
+
+	// @name is a variable unless it is followed by parentheses, so that
+	// [@upper(@name)] works
+	case 'v', 'i':
+		if p.peek().typ == 'o' && p.peek().text == "(" {
+			return p.parseCall(tok.text)
+		}
+		if tok.typ == 'v' {
+			return exprVariable{tok.text}, nil
+		}
+		switch tok.text {
+		case "true":
+			return exprLiteral{true}, nil
+		case "false":
+			return exprLiteral{false}, nil
+		case "null":
+			return exprLiteral{nil}, nil
+		}
+		return nil, errors.New("unknown name '" + tok.text + "'; variables start with @")
+
+	case 'o':
+		if tok.text == "(" {
+			x, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		}
+		return nil, errors.New("unexpected '" + tok.text + "'")
+	}
+
+	return nil, errors.New("unexpected end of expression")
+}
+
+func (p *exprParser) parseCall(name string) (exprNode, error) {
+	fn, ok := exprFuncs[name]
+	if !ok {
+		return nil, errors.New("unknown function " + name + "()")
+	}
+	p.next() // (
+
+	var args []exprNode
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+		want := strconv.Itoa(fn.minArgs)
+		if fn.maxArgs != fn.minArgs {
+			want += " to " + strconv.Itoa(fn.maxArgs)
+		}
+		return nil, errors.New(name + "() takes " + want + " arguments")
+	}
+	return exprCall{name, fn, args}, nil
+}
+
+// EVALUATION
+
+func (e exprLiteral) eval(*variableScope) (any, error) {
+	return e.value, nil
+}
+
+func (e exprVariable) eval(scope *variableScope) (any, error) {
+	val, err := scope.Get(e.name)
+	if err != nil {
+		return nil, errors.Wrap(err, "@"+e.name)
+	}
+	if i, ok := val.(int); ok {
+		return float64(i), nil
+	}
+	return val, nil
+}
+
+func (e exprUnary) eval(scope *variableScope) (any, error) {
+	x, err := e.x.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "!" {
+		return !exprTruthy(x), nil
+	}
+	n, err := exprNumber(x)
+	if err != nil {
+		return nil, err
+	}
+	return -n, nil
+}
+
+func (e exprTernary) eval(scope *variableScope) (any, error) {
+	cond, err := e.cond.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+	if exprTruthy(cond) {
+		return e.x.eval(scope)
+	}
+	return e.y.eval(scope)
+}
+
+func (e exprCall) eval(scope *variableScope) (any, error) {
+	args := make([]any, len(e.args))
+	for i, arg := range e.args {
+		val, err := arg.eval(scope)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = val
+	}
+	val, err := e.fn.call(args)
+	if err != nil {
+		return nil, errors.Wrap(err, e.name+"()")
+	}
+	return val, nil
+}
+
+func (e exprBinary) eval(scope *variableScope) (any, error) {
+	x, err := e.x.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// short-circuit operators
+	switch e.op {
+	case "??":
+		if !exprEmpty(x) {
+			return x, nil
+		}
+		return e.y.eval(scope)
+	case "&&":
+		if !exprTruthy(x) {
+			return false, nil
+		}
+		y, err := e.y.eval(scope)
+		return exprTruthy(y), err
+	case "||":
+		if exprTruthy(x) {
+			return true, nil
+		}
+		y, err := e.y.eval(scope)
+		return exprTruthy(y), err
+	}
+
+	y, err := e.y.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return exprEqual(x, y), nil
+	case "!=":
+		return !exprEqual(x, y), nil
+	case "<", "<=", ">", ">=":
+		cmp := exprCompare(x, y)
+		switch e.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		}
+		return cmp >= 0, nil
+	}
+
+	// + adds numbers or joins text
+	if e.op == "+" {
+		xn, xOk := exprNumberOk(x)
+		yn, yOk := exprNumberOk(y)
+		if xOk && yOk {
+			return xn + yn, nil
+		}
+		return exprText(x) + exprText(y), nil
+	}
+
+	// other arithmetic
+	xn, err := exprNumber(x)
+	if err != nil {
+		return nil, err
+	}
+	yn, err := exprNumber(y)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "-":
+		return xn - yn, nil
+	case "*":
+		return xn * yn, nil
+	}
+	if yn == 0 {
+		return nil, errors.New("division by zero")
+	}
+	if e.op == "%" {
+		return math.Mod(xn, yn), nil
+	}
+	return xn / yn, nil
+}
+
+// VALUES
+
+// exprText returns the plain text of a value
+func exprText(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case HTML:
+		return html.UnescapeString(strip.StripTags(string(v)))
+	case float64:
+		return exprFormatNumber(v)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	return humanReadableValue(val)
+}
+
+// exprFormatNumber formats a number without needless digits
+func exprFormatNumber(f float64) string {
+	s := strconv.FormatFloat(f, 'g', 12, 64)
+	if strings.ContainsAny(s, "e") {
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return s
+}
+
+// exprNumberOk returns the numeric value of a number or numeric string
+func exprNumberOk(val any) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string, HTML:
+		f, err := strconv.ParseFloat(strings.TrimSpace(exprText(v)), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// exprNumber is like exprNumberOk except it returns an error
+func exprNumber(val any) (float64, error) {
+	if f, ok := exprNumberOk(val); ok {
+		return f, nil
+	}
+	return 0, errors.New("not a number (" + humanReadableValue(val) + ")")
+}
+
+// exprTruthy returns whether a value is true. like variables in if{}, only
+// false, nothing, empty text, and zero are false
+func exprTruthy(val any) bool {
+	switch v := val.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string, HTML:
+		text := strings.TrimSpace(exprText(v))
+		return text != "" && text != "0"
+	}
+	return true
+}
+
+// exprEmpty returns whether a value is nothing or empty text, for ??
+func exprEmpty(val any) bool {
+	switch v := val.(type) {
+	case nil:
+		return true
+	case string, HTML:
+		return strings.TrimSpace(exprText(v)) == ""
+	}
+	return false
+}
+
+// exprEqual compares numbers numerically and anything else as text
+func exprEqual(x, y any) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	xn, xOk := exprNumberOk(x)
+	yn, yOk := exprNumberOk(y)
+	if xOk && yOk {
+		return xn == yn
+	}
+	return exprText(x) == exprText(y)
+}
+
+// exprCompare compares numbers numerically and anything else as text
+func exprCompare(x, y any) int {
+	xn, xOk := exprNumberOk(x)
+	yn, yOk := exprNumberOk(y)
+	if xOk && yOk {
+		switch {
+		case xn < yn:
+			return -1
+		case xn > yn:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(exprText(x), exprText(y))
+}
+
+// FUNCTIONS
+
+func exprUpper(args []any) (any, error) {
+	return strings.ToUpper(exprText(args[0])), nil
+}
+
+func exprLower(args []any) (any, error) {
+	return strings.ToLower(exprText(args[0])), nil
+}
+
+// truncate(text, length[, suffix])
+func exprTruncate(args []any) (any, error) {
+	text := strings.TrimSpace(exprText(args[0]))
+	n, err := exprNumber(args[1])
+	if err != nil {
+		return nil, err
+	}
+	suffix := "…"
+	if len(args) > 2 {
+		suffix = exprText(args[2])
+	}
+
+	// check the length before converting, so a huge number can't overflow
+	runes := []rune(text)
+	if n < 0 || !(n < float64(len(runes))) {
+		return text, nil
+	}
+	return strings.TrimRightFunc(string(runes[:int(n)]), unicode.IsSpace) + suffix, nil
+}
+
+// len(list, map, or text)
+func exprLen(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case nil:
+		return 0.0, nil
+	case *List:
+		return float64(len(v.list)), nil
+	case *Map:
+		return float64(len(v.mapList)), nil
+	case []any:
+		return float64(len(v)), nil
+	case string, HTML:
+		return float64(utf8.RuneCountInString(exprText(v))), nil
+	}
+	return nil, errors.New("cannot find length of " + humanReadableValue(args[0]))
+}
+
+// date formats accepted by date()
+var exprDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// date(time[, format]) where time is a UNIX timestamp, an ISO 8601 date, or
+// an HTTP date, and format is like strftime
+func exprDate(args []any) (any, error) {
+	format := exprDateFormat
+	if len(args) > 1 {
+		format = exprText(args[1])
+	}
+
+	// nothing there
+	if exprEmpty(args[0]) {
+		return "", nil
+	}
+
+	// UNIX timestamp
+	if unix, ok := exprNumberOk(args[0]); ok {
+		return exprStrftime(time.Unix(int64(unix), 0).UTC(), format), nil
+	}
+
+	// date string
+	text := strings.TrimSpace(exprText(args[0]))
+	for _, layout := range exprDateLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return exprStrftime(t, format), nil
+		}
+	}
+	if t, err := httpdate.Str2Time(text, time.UTC); err == nil {
+		return exprStrftime(t, format), nil
+	}
+
+	return nil, errors.New("cannot understand date '" + text + "'")
+}
+
+// exprStrftime formats a time like strftime. a - after the % removes
+// padding, e.g. %-d
+func exprStrftime(t time.Time, format string) string {
+	var b strings.Builder
+	runes := []rune(format)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' || i+1 >= len(runes) {
+			b.WriteRune(runes[i])
+			continue
+		}
+		i++
+		pad := true
+		if runes[i] == '-' && i+1 < len(runes) {
+			pad = false
+			i++
+		}
+		num := func(n, width int) string {
+			s := strconv.Itoa(n)
+			if pad {
+				s = strings.Repeat("0", max(width-len(s), 0)) + s
+			}
+			return s
+		}
+		switch runes[i] {
+		case 'Y':
+			b.WriteString(strconv.Itoa(t.Year()))
+		case 'y':
+			b.WriteString(num(t.Year()%100, 2))
+		case 'm':
+			b.WriteString(num(int(t.Month()), 2))
+		case 'd':
+			b.WriteString(num(t.Day(), 2))
+		case 'e':
+			b.WriteString(strconv.Itoa(t.Day()))
+		case 'j':
+			b.WriteString(num(t.YearDay(), 3))
+		case 'H':
+			b.WriteString(num(t.Hour(), 2))
+		case 'I':
+			b.WriteString(num((t.Hour()+11)%12+1, 2))
+		case 'M':
+			b.WriteString(num(t.Minute(), 2))
+		case 'S':
+			b.WriteString(num(t.Second(), 2))
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'B':
+			b.WriteString(t.Month().String())
+		case 'b':
+			b.WriteString(t.Month().String()[:3])
+		case 'A':
+			b.WriteString(t.Weekday().String())
+		case 'a':
+			b.WriteString(t.Weekday().String()[:3])
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case '%':
+			b.WriteRune('%')
+		default:
+			b.WriteRune('%')
+			b.WriteRune(runes[i])
+		}
+	}
+	return b.String()
+}
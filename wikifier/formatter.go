@@ -5,6 +5,8 @@ import (
 	"html"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/enescakir/emoji"
 )
@@ -404,6 +406,20 @@ func convertMarkdown(text string) string {
 	var result strings.Builder
 
 	for i := 0; i < len(runes); {
+		// inline math is TeX, so leave it alone
+		if end := findInlineMathEnd(runes, i); end > 0 {
+			result.WriteString(string(runes[i:end]))
+			i = end
+			continue
+		}
+
+		// so are variable expressions, which may contain *
+		if end := findVariableEnd(runes, i); end > 0 {
+			result.WriteString(string(runes[i:end]))
+			i = end
+			continue
+		}
+
 		// handle escaped backslashes - \\ becomes literal \
 		if i+1 < len(runes) && runes[i] == '\\' && runes[i+1] == '\\' {
 			result.WriteRune('\\') // output literal backslash, consume both
@@ -453,6 +469,43 @@ func convertMarkdown(text string) string {
 	return output
 }
 
+// findInlineMathEnd returns the position after the [$ math $] which starts
+// at pos, or 0 if there is none
+func findInlineMathEnd(runes []rune, pos int) int {
+	if pos+2 >= len(runes) || runes[pos] != '[' || runes[pos+1] != '$' || !unicode.IsSpace(runes[pos+2]) {
+		return 0
+	}
+	if pos > 0 && runes[pos-1] == '\\' {
+		return 0
+	}
+	for i := pos + 2; i+2 < len(runes); i++ {
+		if unicode.IsSpace(runes[i]) && runes[i+1] == '$' && runes[i+2] == ']' {
+			return i + 3
+		}
+	}
+	return 0
+}
+
+// findVariableEnd returns the position after the [@variable] which starts at
+// pos, or 0 if there is none
+func findVariableEnd(runes []rune, pos int) int {
+	if pos+2 >= len(runes) || runes[pos] != '[' || (runes[pos+1] != '@' && runes[pos+1] != '%') {
+		return 0
+	}
+	if pos > 0 && runes[pos-1] == '\\' {
+		return 0
+	}
+	for i := pos + 2; i < len(runes); i++ {
+		switch runes[i] {
+		case ']':
+			return i + 1
+		case '[', '\n':
+			return 0
+		}
+	}
+	return 0
+}
+
 func tryConvertBold(runes []rune, pos int, result *strings.Builder) int {
 	// look for **text** but handle ***text*** specially
 	if runes[pos] != '*' || pos+1 >= len(runes) || runes[pos+1] != '*' {
@@ -539,11 +592,26 @@ func parseFormatType(b block, formatType string, o *FmtOpt) HTML {
 		return HTML(format)
 	}
 
+	// footnote
+	if strings.HasPrefix(formatType, "^ ") {
+		return handleFootnote(b, formatType[2:], o)
+	}
+
+	// inline math. spaces distinguish it from the deprecated $link$
+	if isInlineMath(formatType) {
+		return handleMath(b, formatType[1:len(formatType)-1], o)
+	}
+
 	// variable
 	if !o.noVariables && variableRegex.MatchString(formatType) {
 		return handleVariable(b, formatType, o)
 	}
 
+	// variable expression
+	if !o.noVariables && len(formatType) > 1 && (formatType[0] == '@' || formatType[0] == '%') {
+		return handleExpression(b, formatType, o)
+	}
+
 	// html entity
 	if formatType[0] == '&' {
 		return HTML("&" + formatType[1:] + ";")
@@ -606,6 +674,32 @@ func getVariableValue(b block, formatType string, o *FmtOpt) (any, HTML) {
 	return val, ""
 }
 
+func handleExpression(b block, formatType string, o *FmtOpt) HTML {
+	// the sigil is part of the expression when a name follows it, as in
+	// [@price * 2]; otherwise it only marks the expression, as in [@"a" + @b]
+	expr := formatType[1:]
+	if r, _ := utf8.DecodeRuneInString(expr); r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+		expr = "@" + expr
+	}
+	val, err := evalExpression(b.variables(), expr)
+	if err != nil {
+		if !o.NoWarnings {
+			b.warn(o.Pos, "expression "+formatType+": "+err.Error())
+		}
+		return HTML("(error: " + html.EscapeString(formatType) + ": " + html.EscapeString(err.Error()) + ")")
+	}
+	if val == nil {
+		if !o.NoWarnings {
+			b.warn(o.Pos, "expression "+formatType+" is undefined (in "+b.blockType()+"{} scope)")
+		}
+		return HTML("(null)")
+	}
+	if f, ok := val.(float64); ok {
+		val = exprFormatNumber(f)
+	}
+	return formatVariableValue(b, formatType, val, o)
+}
+
 func formatVariableValue(b block, formatType string, val any, o *FmtOpt) HTML {
 	strVal, isStr := val.(string)
 	htmlVal, isHTML := val.(HTML)
@@ -615,6 +709,11 @@ func formatVariableValue(b block, formatType string, val any, o *FmtOpt) HTML {
 		return formatUnescapedVariable(b, formatType, strVal, htmlVal, isStr, isHTML, o)
 	}
 
+	// cite{} can be used anywhere, such as in footnotes
+	if cite, ok := val.(*citeBlock); ok {
+		return cite.citation
+	}
+
 	// @var escapes strings but preserves HTML
 	if isStr {
 		return HTML(html.EscapeString(strVal))
@@ -639,6 +738,25 @@ func formatUnescapedVariable(b block, formatType string, strVal string, htmlVal
 	return formatOpts(b, strVal, o.Pos, FmtOpt{noVariables: true})
 }
 
+func handleFootnote(b block, text string, o *FmtOpt) HTML {
+	note := formatOpts(b, strings.TrimSpace(text), o.Pos, FmtOpt{NoEntities: o.NoEntities, NoWarnings: o.NoWarnings})
+	return b.page().addFootnote(note)
+}
+
+func isInlineMath(formatType string) bool {
+	runes := []rune(formatType)
+	return len(runes) >= 3 && runes[0] == '$' && runes[len(runes)-1] == '$' &&
+		unicode.IsSpace(runes[1]) && unicode.IsSpace(runes[len(runes)-2])
+}
+
+func handleMath(b block, tex string, o *FmtOpt) HTML {
+	math, err := texToMathML(tex, false)
+	if err != nil && !o.NoWarnings {
+		b.warn(o.Pos, "math: "+err.Error())
+	}
+	return math
+}
+
 func handleLink(b block, formatType string, o *FmtOpt) HTML {
 	// parse the link and generate HTML
 	ok, target, linkType, tooltip, display := parseLink(b, formatType[1:len(formatType)-1], o)
@@ -0,0 +1,658 @@
+package wikifier
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// math.go - converts TeX math to MathML for math{} and [$ $]
+
+// identifiers, displayed in italic if a single character
+var mathIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"omicron": "ο", "pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ",
+	"sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅",
+	"varnothing": "∅", "aleph": "ℵ", "ell": "ℓ", "hbar": "ℏ", "imath": "ı",
+	"jmath": "ȷ", "Re": "ℜ", "Im": "ℑ", "wp": "℘",
+}
+
+// operators and relations
+var mathOperators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "ominus": "⊖",
+	"otimes": "⊗", "odot": "⊙", "dagger": "†", "setminus": "∖",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅",
+	"propto": "∝", "ll": "≪", "gg": "≫", "prec": "≺", "succ": "≻",
+	"subset": "⊂", "supset": "⊃", "subseteq": "⊆", "supseteq": "⊇",
+	"in": "∈", "notin": "∉", "ni": "∋", "cup": "∪", "cap": "∩",
+	"wedge": "∧", "land": "∧", "vee": "∨", "lor": "∨", "neg": "¬", "lnot": "¬",
+	"forall": "∀", "exists": "∃", "nexists": "∄", "therefore": "∴",
+	"because": "∵", "perp": "⊥", "parallel": "∥", "mid": "∣", "vert": "|",
+	"Vert": "‖", "angle": "∠", "triangle": "△", "prime": "′",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "leftrightarrow": "↔",
+	"Leftrightarrow": "⇔", "implies": "⟹", "impliedby": "⟸", "iff": "⟺",
+	"mapsto": "↦", "uparrow": "↑", "downarrow": "↓", "longrightarrow": "⟶",
+	"longleftarrow": "⟵", "hookrightarrow": "↪",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "lbrace": "{", "rbrace": "}",
+	"lvert": "|", "rvert": "|", "lVert": "‖", "rVert": "‖", "colon": ":",
+	"{": "{", "}": "}", "|": "‖", "%": "%", "$": "$", "&": "&", "#": "#", "_": "_",
+}
+
+// large operators. those which take limits above and below in display mode
+// are true
+var mathLargeOperators = map[string]struct {
+	symbol string
+	limits bool
+}{
+	"sum": {"∑", true}, "prod": {"∏", true}, "coprod": {"∐", true},
+	"bigcup": {"⋃", true}, "bigcap": {"⋂", true}, "bigoplus": {"⨁", true},
+	"bigotimes": {"⨂", true}, "bigvee": {"⋁", true}, "bigwedge": {"⋀", true},
+	"int": {"∫", false}, "iint": {"∬", false}, "iiint": {"∭", false},
+	"oint": {"∮", false},
+}
+
+// named functions. those which take limits in display mode are true
+var mathFunctions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "cot": false, "sec": false,
+	"csc": false, "arcsin": false, "arccos": false, "arctan": false,
+	"sinh": false, "cosh": false, "tanh": false, "coth": false, "log": false,
+	"ln": false, "lg": false, "exp": false, "deg": false, "dim": false,
+	"ker": false, "hom": false, "arg": false,
+	"lim": true, "liminf": true, "limsup": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "gcd": true, "Pr": true,
+}
+
+// horizontal spacing commands
+var mathSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em",
+	" ": "0.25em", "quad": "1em", "qquad": "2em", "!": "-0.1667em",
+}
+
+// font commands and their mathvariant
+var mathVariants = map[string]string{
+	"mathbf": "bold", "mathit": "italic", "mathrm": "normal",
+	"mathbb": "double-struck", "mathcal": "script", "mathscr": "script",
+	"mathfrak": "fraktur", "mathsf": "sans-serif", "mathtt": "monospace",
+	"boldsymbol": "bold-italic",
+}
+
+// accents over and under their argument
+var mathAccents = map[string]struct {
+	symbol string
+	under  bool
+}{
+	"hat": {"^", false}, "widehat": {"^", false}, "bar": {"¯", false},
+	"overline": {"‾", false}, "vec": {"→", false}, "dot": {"˙", false},
+	"ddot": {"¨", false}, "tilde": {"˜", false}, "widetilde": {"˜", false},
+	"overrightarrow": {"→", false}, "overbrace": {"⏞", false},
+	"underline": {"_", true}, "underbrace": {"⏟", true},
+}
+
+// sizes for \big and friends
+var mathBigSizes = map[string]string{
+	"big": "1.2em", "bigl": "1.2em", "bigr": "1.2em", "bigm": "1.2em",
+	"Big": "1.623em", "Bigl": "1.623em", "Bigr": "1.623em", "Bigm": "1.623em",
+	"bigg": "2.047em", "biggl": "2.047em", "biggr": "2.047em", "biggm": "2.047em",
+	"Bigg": "2.470em", "Biggl": "2.470em", "Biggr": "2.470em", "Biggm": "2.470em",
+}
+
+// environments and their fences
+var mathEnvironments = map[string][2]string{
+	"matrix": {"", ""}, "pmatrix": {"(", ")"}, "bmatrix": {"[", "]"},
+	"Bmatrix": {"{", "}"}, "vmatrix": {"|", "|"}, "Vmatrix": {"‖", "‖"},
+	"cases": {"{", ""}, "aligned": {"", ""}, "align": {"", ""},
+	"align*": {"", ""}, "gathered": {"", ""}, "split": {"", ""},
+	"array": {"", ""},
+}
+
+// mathNode is a MathML element with information needed to attach scripts
+type mathNode struct {
+	ml     string // MathML
+	limits bool   // scripts go above and below in display mode
+}
+
+type mathParser struct {
+	runes   []rune
+	pos     int
+	display bool
+	variant string // mathvariant for identifiers, from font commands
+	err     error  // first error encountered
+}
+
+// texToMathML converts TeX math to a MathML <math> element. If display is
+// true, it is displayed as a block. If the TeX is invalid, the MathML
+// includes errors where they occurred, and the first error is returned.
+func texToMathML(tex string, display bool) (HTML, error) {
+	p := &mathParser{runes: []rune(tex), display: display}
+	row := p.parseRow(func() bool { return false })
+	if p.pos < len(p.runes) && p.err == nil {
+		p.fail("unexpected '" + string(p.runes[p.pos]) + "'")
+	}
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString("><semantics>")
+	b.WriteString(mathRow(row))
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString("</annotation></semantics></math>")
+	return HTML(b.String()), p.err
+}
+
+// record an error. only the first is kept
+func (p *mathParser) fail(msg string) {
+	if p.err == nil {
+		p.err = errors.New(msg)
+	}
+}
+
+// error node
+func (p *mathParser) errorNode(msg, text string) mathNode {
+	p.fail(msg)
+	return mathNode{ml: "<merror><mtext>" + html.EscapeString(text) + "</mtext></merror>"}
+}
+
+func (p *mathParser) peek() rune {
+	if p.pos >= len(p.runes) {
+		return 0
+	}
+	return p.runes[p.pos]
+}
+
+func (p *mathParser) skipSpace() {
+	for p.pos < len(p.runes) && unicode.IsSpace(p.runes[p.pos]) {
+		p.pos++
+	}
+}
+
+// peekCommand returns the name of the command at the current position, if any
+func (p *mathParser) peekCommand() string {
+	if p.peek() != '\\' || p.pos+1 >= len(p.runes) {
+		return ""
+	}
+	end := p.pos + 1
+	for end < len(p.runes) && unicode.IsLetter(p.runes[end]) {
+		end++
+	}
+	if end == p.pos+1 {
+		// a single non-letter, e.g. \, or \\
+		end++
+	}
+	return string(p.runes[p.pos+1 : end])
+}
+
+// readCommand consumes and returns the command at the current position
+func (p *mathParser) readCommand() string {
+	name := p.peekCommand()
+	p.pos += len([]rune(name)) + 1
+	return name
+}
+
+// readRaw consumes a {group} and returns its content without parsing it
+func (p *mathParser) readRaw() (string, bool) {
+	p.skipSpace()
+	if p.peek() != '{' {
+		return "", false
+	}
+	depth, start := 0, p.pos+1
+	for ; p.pos < len(p.runes); p.pos++ {
+		switch p.runes[p.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return string(p.runes[start : p.pos-1]), true
+			}
+		}
+	}
+	p.fail("missing '}'")
+	return string(p.runes[start:]), true
+}
+
+// parseRow parses nodes until the end of input or until stop returns true
+func (p *mathParser) parseRow(stop func() bool) []mathNode {
+	var row []mathNode
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.runes) || stop() {
+			return row
+		}
+		if p.peek() == '}' {
+			p.pos++
+			row = append(row, p.errorNode("unexpected '}'", "}"))
+			continue
+		}
+		node := p.parseAtom()
+		row = append(row, p.parseScripts(node))
+	}
+}
+
+// parseGroup parses a {group}, expecting the opening brace at the current
+// position
+func (p *mathParser) parseGroup() []mathNode {
+	p.pos++ // {
+	row := p.parseRow(func() bool { return p.peek() == '}' })
+	if p.peek() == '}' {
+		p.pos++
+	} else {
+		p.fail("missing '}'")
+	}
+	return row
+}
+
+// parseArg parses the argument to a command: a {group} or a single token
+func (p *mathParser) parseArg() mathNode {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == 0:
+		return p.errorNode("missing argument", "?")
+	case c == '{':
+		return mathNode{ml: mathRow(p.parseGroup())}
+	case c == '\\':
+		return p.parseAtom()
+	default:
+		p.pos++
+		return p.charNode(c)
+	}
+}
+
+// parseScripts attaches any superscript and subscript to a node
+func (p *mathParser) parseScripts(base mathNode) mathNode {
+	var sub, sup *mathNode
+	for {
+		p.skipSpace()
+
+		// \limits and \nolimits change where scripts go
+		switch p.peekCommand() {
+		case "limits":
+			p.readCommand()
+			base.limits = true
+			continue
+		case "nolimits":
+			p.readCommand()
+			base.limits = false
+			continue
+		}
+
+		switch c := p.peek(); c {
+		case '^', '_':
+			p.pos++
+			arg := p.parseArg()
+			target := &sup
+			if c == '_' {
+				target = &sub
+			}
+			if *target != nil {
+				p.fail("double " + map[rune]string{'^': "superscript", '_': "subscript"}[c])
+			}
+			*target = &arg
+			continue
+		case '\'':
+			// primes are superscripts
+			p.pos++
+			primes := "′"
+			for p.peek() == '\'' {
+				p.pos++
+				primes += "′"
+			}
+			node := mathNode{ml: "<mo>" + primes + "</mo>"}
+			sup = &node
+			continue
+		}
+		break
+	}
+
+	under, over := "msub", "msup"
+	both := "msubsup"
+	if base.limits && p.display {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sub != nil && sup != nil:
+		return mathNode{ml: "<" + both + ">" + base.ml + sub.ml + sup.ml + "</" + both + ">"}
+	case sub != nil:
+		return mathNode{ml: "<" + under + ">" + base.ml + sub.ml + "</" + under + ">"}
+	case sup != nil:
+		return mathNode{ml: "<" + over + ">" + base.ml + sup.ml + "</" + over + ">"}
+	}
+	return base
+}
+
+// parseAtom parses a single node, not including scripts
+func (p *mathParser) parseAtom() mathNode {
+	c := p.peek()
+	switch {
+
+	// group
+	case c == '{':
+		return mathNode{ml: mathRow(p.parseGroup())}
+
+	// scripts with no base
+	case c == '^' || c == '_':
+		return mathNode{ml: "<mrow></mrow>"}
+
+	// command
+	case c == '\\':
+		return p.parseCommand()
+
+	// number
+	case unicode.IsDigit(c) || (c == '.' && p.pos+1 < len(p.runes) && unicode.IsDigit(p.runes[p.pos+1])):
+		start := p.pos
+		for p.pos < len(p.runes) && (unicode.IsDigit(p.runes[p.pos]) || p.runes[p.pos] == '.') {
+			p.pos++
+		}
+		return mathNode{ml: p.token("mn", string(p.runes[start:p.pos]))}
+	}
+
+	p.pos++
+	return p.charNode(c)
+}
+
+// charNode returns the node for a single character
+func (p *mathParser) charNode(c rune) mathNode {
+	switch {
+	case unicode.IsLetter(c):
+		return mathNode{ml: p.token("mi", string(c))}
+	case unicode.IsDigit(c):
+		return mathNode{ml: p.token("mn", string(c))}
+	case c == '~':
+		return mathNode{ml: `<mspace width="0.25em"></mspace>`}
+	case c == '&':
+		return p.errorNode("'&' outside of environment", "&")
+	}
+	if c == '-' {
+		c = '−' // minus sign
+	}
+	return mathNode{ml: "<mo>" + html.EscapeString(string(c)) + "</mo>"}
+}
+
+// token returns a token element in the current font
+func (p *mathParser) token(tag, text string) string {
+	attr := ""
+	if p.variant != "" {
+		attr = ` mathvariant="` + p.variant + `"`
+	}
+	return "<" + tag + attr + ">" + html.EscapeString(text) + "</" + tag + ">"
+}
+
+// parseCommand parses a command and its arguments
+func (p *mathParser) parseCommand() mathNode {
+	name := p.readCommand()
+
+	if name == "" {
+		return p.errorNode("stray '\\'", "\\")
+	}
+	if sym, ok := mathIdentifiers[name]; ok {
+		return mathNode{ml: p.token("mi", sym)}
+	}
+	if sym, ok := mathOperators[name]; ok {
+		return mathNode{ml: "<mo>" + html.EscapeString(sym) + "</mo>"}
+	}
+	if op, ok := mathLargeOperators[name]; ok {
+		return mathNode{ml: `<mo largeop="true">` + op.symbol + "</mo>", limits: op.limits}
+	}
+	if limits, ok := mathFunctions[name]; ok {
+		return mathNode{ml: "<mi>" + name + "</mi>", limits: limits}
+	}
+	if width, ok := mathSpaces[name]; ok {
+		return mathNode{ml: `<mspace width="` + width + `"></mspace>`}
+	}
+	if variant, ok := mathVariants[name]; ok {
+		outer := p.variant
+		p.variant = variant
+		arg := p.parseArg()
+		p.variant = outer
+		return arg
+	}
+	if accent, ok := mathAccents[name]; ok {
+		arg := p.parseArg()
+		if accent.under {
+			return mathNode{ml: `<munder accentunder="true">` + arg.ml + "<mo>" + accent.symbol + "</mo></munder>"}
+		}
+		return mathNode{ml: `<mover accent="true">` + arg.ml + "<mo>" + accent.symbol + "</mo></mover>"}
+	}
+	if size, ok := mathBigSizes[name]; ok {
+		delim := p.parseDelimiter()
+		return mathNode{ml: `<mo minsize="` + size + `" maxsize="` + size + `">` + delim + "</mo>"}
+	}
+
+	switch name {
+
+	// fractions
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, den := p.parseArg(), p.parseArg()
+		return mathNode{ml: "<mfrac>" + num.ml + den.ml + "</mfrac>"}
+	case "binom", "dbinom", "tbinom":
+		n, k := p.parseArg(), p.parseArg()
+		return mathNode{ml: `<mrow><mo>(</mo><mfrac linethickness="0">` + n.ml + k.ml + "</mfrac><mo>)</mo></mrow>"}
+
+	// roots
+	case "sqrt":
+		p.skipSpace()
+		if p.peek() == '[' {
+			p.pos++
+			index := p.parseRow(func() bool { return p.peek() == ']' })
+			if p.peek() == ']' {
+				p.pos++
+			} else {
+				p.fail("missing ']'")
+			}
+			arg := p.parseArg()
+			return mathNode{ml: "<mroot>" + arg.ml + mathRow(index) + "</mroot>"}
+		}
+		arg := p.parseArg()
+		return mathNode{ml: "<msqrt>" + arg.ml + "</msqrt>"}
+
+	// text
+	case "text", "textrm", "mbox", "textit", "textbf", "textnormal":
+		text, ok := p.readRaw()
+		if !ok {
+			return p.errorNode("missing argument to \\"+name, "\\"+name)
+		}
+		attr := ""
+		switch name {
+		case "textit":
+			attr = ` mathvariant="italic"`
+		case "textbf":
+			attr = ` mathvariant="bold"`
+		}
+		return mathNode{ml: "<mtext" + attr + ">" + html.EscapeString(text) + "</mtext>"}
+	case "operatorname":
+		text, ok := p.readRaw()
+		if !ok {
+			return p.errorNode("missing argument to \\operatorname", "\\operatorname")
+		}
+		return mathNode{ml: "<mi>" + html.EscapeString(strings.TrimSpace(text)) + "</mi>"}
+
+	// fences
+	case "left":
+		open := p.parseDelimiter()
+		inner := p.parseRow(func() bool { return p.peekCommand() == "right" })
+		close := ""
+		if p.peekCommand() == "right" {
+			p.readCommand()
+			close = p.parseDelimiter()
+		} else {
+			p.fail("missing \\right")
+		}
+		var b strings.Builder
+		b.WriteString("<mrow>")
+		if open != "" {
+			b.WriteString(`<mo fence="true" stretchy="true">` + open + "</mo>")
+		}
+		for _, node := range inner {
+			b.WriteString(node.ml)
+		}
+		if close != "" {
+			b.WriteString(`<mo fence="true" stretchy="true">` + close + "</mo>")
+		}
+		b.WriteString("</mrow>")
+		return mathNode{ml: b.String()}
+	case "middle":
+		return mathNode{ml: `<mo stretchy="true">` + p.parseDelimiter() + "</mo>"}
+	case "right":
+		return p.errorNode("\\right without \\left", "\\right")
+
+	// negation
+	case "not":
+		p.skipSpace()
+		next := p.parseAtom()
+		if strings.HasPrefix(next.ml, "<mo>") {
+			return mathNode{ml: strings.Replace(next.ml, "</mo>", "̸</mo>", 1)}
+		}
+		return next
+
+	// environments
+	case "begin":
+		return p.parseEnvironment()
+	case "end":
+		env, _ := p.readRaw()
+		return p.errorNode("\\end{"+env+"} without \\begin", "\\end{"+env+"}")
+
+	// these have no effect on their own
+	case "displaystyle", "textstyle", "scriptstyle", "limits", "nolimits", "\\":
+		return mathNode{ml: "<mrow></mrow>"}
+	}
+
+	return p.errorNode("unknown command \\"+name, "\\"+name)
+}
+
+// parseDelimiter parses the delimiter after \left, \right, \big, etc.
+// "." means no delimiter
+func (p *mathParser) parseDelimiter() string {
+	p.skipSpace()
+	c := p.peek()
+	switch {
+	case c == 0:
+		p.fail("missing delimiter")
+		return ""
+	case c == '.':
+		p.pos++
+		return ""
+	case c == '\\':
+		name := p.readCommand()
+		if sym, ok := mathOperators[name]; ok {
+			return html.EscapeString(sym)
+		}
+		p.fail("invalid delimiter \\" + name)
+		return ""
+	}
+	p.pos++
+	return html.EscapeString(string(c))
+}
+
+// parseEnvironment parses \begin{env} ... \end{env} as a table
+func (p *mathParser) parseEnvironment() mathNode {
+	env, ok := p.readRaw()
+	if !ok {
+		return p.errorNode("missing environment name", "\\begin")
+	}
+	fences, ok := mathEnvironments[env]
+	if !ok {
+		return p.errorNode("unknown environment "+env, "\\begin{"+env+"}")
+	}
+
+	// array has a column specification, which is ignored
+	if env == "array" {
+		p.readRaw()
+	}
+
+	// rows are separated by \\ and cells by &
+	var rows [][]string
+	cells := []string{}
+	for {
+		cell := p.parseRow(func() bool {
+			cmd := p.peekCommand()
+			return p.peek() == '&' || cmd == "\\" || cmd == "end"
+		})
+		cells = append(cells, mathRow(cell))
+
+		if p.peek() == '&' {
+			p.pos++
+			continue
+		}
+		cmd := p.peekCommand()
+		if cmd == "\\" {
+			p.readCommand()
+			rows = append(rows, cells)
+			cells = []string{}
+			continue
+		}
+		if cmd == "end" {
+			p.readCommand()
+			if end, _ := p.readRaw(); end != env {
+				p.fail("\\begin{" + env + "} ended by \\end{" + end + "}")
+			}
+		} else {
+			p.fail("missing \\end{" + env + "}")
+		}
+		break
+	}
+
+	// a trailing \\ does not start a row
+	if len(cells) > 1 || cells[0] != "<mrow></mrow>" || len(rows) == 0 {
+		rows = append(rows, cells)
+	}
+
+	// cases are left-aligned; aligned environments alternate right and left
+	attrs := ""
+	switch env {
+	case "cases":
+		attrs = ` columnalign="left"`
+	case "aligned", "align", "align*", "split":
+		attrs = ` columnalign="right left right left" columnspacing="0em 2em 0em"`
+	}
+
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	if fences[0] != "" {
+		b.WriteString("<mo>" + html.EscapeString(fences[0]) + "</mo>")
+	}
+	b.WriteString("<mtable" + attrs + ">")
+	for _, row := range rows {
+		b.WriteString("<mtr>")
+		for _, cell := range row {
+			b.WriteString("<mtd>" + cell + "</mtd>")
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable>")
+	if fences[1] != "" {
+		b.WriteString("<mo>" + html.EscapeString(fences[1]) + "</mo>")
+	}
+	b.WriteString("</mrow>")
+	return mathNode{ml: b.String()}
+}
+
+// mathRow joins nodes in an mrow, unless there is just one
+func mathRow(row []mathNode) string {
+	if len(row) == 1 {
+		return row[0].ml
+	}
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	for _, node := range row {
+		b.WriteString(node.ml)
+	}
+	b.WriteString("</mrow>")
+	return b.String()
+}
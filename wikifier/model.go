@@ -1,6 +1,10 @@
 package wikifier
 
-import "time"
+import (
+	"sort"
+	"strings"
+	"time"
+)
 
 // ModelInfo represents metadata associated with a model.
 type ModelInfo struct {
@@ -12,17 +16,61 @@ type ModelInfo struct {
 	Path        string     `json:"path"`
 	Created     *time.Time `json:"created,omitempty"`  // creation time
 	Modified    *time.Time `json:"modified,omitempty"` // modify time
+
+	// options accepted by the model, from @model.params
+	Params []ModelParam `json:"params,omitempty"`
 }
 
-// modelInfo is like (wikifier.Page).Info() but used internally
-// to instead return a ModelInfo
-func (p *Page) modelInfo() ModelInfo {
+// ModelParam describes an option accepted by a model.
+//
+// Models declare their options in @model.params, with either a type or
+// a map of settings for each:
+//
+//	@model.params: map {
+//	    title:  string, required;
+//	    count:  map { type: number; default: 1; desc: How many; };
+//	};
+type ModelParam struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // string, number, bool, list, map, block, or any
+	Required    bool   `json:"required,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"desc,omitempty"`
+
+	defaultValue any // the default as it was declared, which may be HTML
+}
+
+// types accepted in @model.params
+var modelParamTypes = map[string]bool{
+	"string": true,
+	"number": true,
+	"bool":   true,
+	"list":   true,
+	"map":    true,
+	"block":  true,
+	"any":    true,
+}
+
+// NewModel creates a page object for a model given its path and name.
+//
+// Its information comes from @model rather than @page, and @m is empty
+// until it is set to the options provided to the model.
+func NewModel(filePath, name string) *Page {
+	p := NewPagePath(filePath, name)
+	p.model = true
+	p.Set("m", NewMap(nil))
+	return p
+}
+
+// ModelInfo is like Info but used for models created with NewModel.
+func (p *Page) ModelInfo() ModelInfo {
 	info := ModelInfo{
 		File:        p.Name(),
 		FileNE:      p.NameNE(),
 		Title:       p.Title(),
 		Author:      p.Author(),
 		Description: p.Description(),
+		Params:      p.modelParams(),
 	}
 	mod, create := p.Modified(), p.Created()
 	if !mod.IsZero() {
@@ -34,3 +82,150 @@ func (p *Page) modelInfo() ModelInfo {
 	}
 	return info
 }
+
+// modelParams returns the options declared in @model.params, in the order
+// they were declared
+func (p *Page) modelParams() []ModelParam {
+	if !p.model {
+		return nil
+	}
+	obj, err := p.GetObj("model.params")
+	if err != nil || obj == nil {
+		return nil
+	}
+	paramsMap, ok := obj.(*Map)
+	if !ok {
+		return nil
+	}
+
+	// keys from the source, then any set with @model.params.name
+	names := paramsMap.OrderedKeys()
+	var others []string
+	for _, name := range paramsMap.Keys() {
+		if paramsMap.getEntry(name) == nil {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	names = append(names, others...)
+
+	params := make([]ModelParam, 0, len(names))
+	for _, name := range names {
+		param := ModelParam{Name: name, Type: "any"}
+		switch spec := paramsMap.get(name).(type) {
+
+		// name: type[, required];
+		case string, HTML:
+			for _, word := range strings.FieldsFunc(exprText(spec), func(r rune) bool {
+				return r == ',' || r == ' '
+			}) {
+				if strings.EqualFold(word, "required") {
+					param.Required = true
+				} else {
+					param.Type = strings.ToLower(word)
+				}
+			}
+
+		// name: map { type: ...; required: true; default: ...; desc: ...; };
+		case AttributedObject:
+			if typ, _ := spec.GetStr("type"); typ != "" {
+				param.Type = strings.ToLower(strings.TrimSpace(typ))
+			}
+			required, _ := spec.Get("required")
+			param.Required, _ = modelParamBool(required)
+			param.defaultValue, _ = spec.Get("default")
+			if param.defaultValue != nil {
+				param.Default = exprText(param.defaultValue)
+			}
+			desc, _ := spec.Get("desc")
+			param.Description = strings.TrimSpace(exprText(desc))
+		}
+
+		// "text" is easier to remember for some
+		if param.Type == "text" {
+			param.Type = "string"
+		}
+
+		params = append(params, param)
+	}
+	return params
+}
+
+// applyModelParams fills in defaults for options not provided in @m, and
+// converts booleans so that they work in conditionals
+func (p *Page) applyModelParams() {
+	m, err := p.GetObj("m")
+	if err != nil || m == nil {
+		return
+	}
+	for _, param := range p.modelParams() {
+		val, _ := m.Get(param.Name)
+		if val == nil {
+			val = param.defaultValue
+		}
+		if val == nil {
+			continue
+		}
+		if converted, ok := param.check(val); ok {
+			m.Set(param.Name, converted)
+		}
+	}
+}
+
+// modelVarSet is called when a variable is set while parsing, so that @m
+// defaults are available as soon as @model.params declares them
+func (p *Page) modelVarSet(key string) {
+	if p.model && (key == "model" || strings.HasPrefix(key, "model.params")) {
+		p.applyModelParams()
+	}
+}
+
+// check checks a value against the parameter type. it returns the value to
+// use, which is converted for booleans, and whether the value is valid
+func (param ModelParam) check(val any) (any, bool) {
+	switch param.Type {
+	case "string":
+		switch val.(type) {
+		case string, HTML:
+			return val, true
+		}
+		return val, false
+	case "number":
+		switch val.(type) {
+		case string, HTML:
+			_, ok := exprNumberOk(val)
+			return val, ok
+		}
+		return val, false
+	case "bool":
+		if b, ok := modelParamBool(val); ok {
+			return b, true
+		}
+		return val, false
+	case "list":
+		_, ok := val.(*List)
+		return val, ok
+	case "map":
+		_, ok := val.(*Map)
+		return val, ok
+	case "block":
+		_, ok := val.(block)
+		return val, ok
+	}
+	return val, true
+}
+
+// modelParamBool interprets a boolean which may be written as text. it
+// returns false if the value is not boolean
+func modelParamBool(val any) (bool, bool) {
+	if b, ok := val.(bool); ok {
+		return b, true
+	}
+	switch strings.ToLower(strings.TrimSpace(exprText(val))) {
+	case "true", "yes", "on", "1":
+		return true, true
+	case "false", "no", "off", "0", "":
+		return false, true
+	}
+	return false, false
+}
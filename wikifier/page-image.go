@@ -0,0 +1,58 @@
+package wikifier
+
+import (
+	"html"
+	"strings"
+
+	strip "github.com/grokify/html-strip-tags-go"
+)
+
+// width of @page.image as generated for link previews
+const pageImageWidth = 1200
+
+// PageImage describes the image representing a page, from @page.image.
+type PageImage struct {
+	File   string `json:"file"`             // filename relative to the image directory, or a URL
+	Path   string `json:"path"`             // HTTP path to the image, sized for link previews
+	Width  int    `json:"width,omitempty"`  // width of the image at Path, if known
+	Height int    `json:"height,omitempty"` // height of the image at Path, if known
+}
+
+// Image returns the image representing the page, or nil if there is none.
+//
+// When the wiki sizes images on the server, the image is generated in a
+// size suitable for link previews on social media and elsewhere.
+func (p *Page) Image() *PageImage {
+	if p._image != nil {
+		return p._image
+	}
+	file, _ := p.getPageStr("image")
+	file = strings.TrimSpace(html.UnescapeString(strip.StripTags(file)))
+	if file == "" {
+		return nil
+	}
+	img := &PageImage{File: file, Path: file}
+
+	// external image; nothing to size
+	if externalImageRegex.MatchString(file) {
+		p._image = img
+		return img
+	}
+
+	img.Path = p.Opt.Root.Image + "/" + file
+	if strings.ToLower(p.Opt.Image.SizeMethod) != "server" || p.Opt.Image.Sizer == nil || p.Opt.Image.Calc == nil {
+		p._image = img
+		return img
+	}
+
+	// size it like image{} does, and remember that the page uses it
+	width, height, fullSize := p.Opt.Image.Calc(file, pageImageWidth, 0, p)
+	img.Path = p.Opt.Image.Sizer(file, width, height, p)
+	if !fullSize {
+		img.Width, img.Height = width, height
+	}
+	p.Images[file] = append(p.Images[file], []int{width, height})
+
+	p._image = img
+	return img
+}
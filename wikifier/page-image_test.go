@@ -0,0 +1,82 @@
+package wikifier
+
+import (
+	"reflect"
+	"testing"
+)
+
+// parseImagePage parses a page with the given options changed
+func parseImagePage(t *testing.T, source string, modify func(opt *PageOpt)) *Page {
+	t.Helper()
+	page := NewPageSource(source)
+	opt := *page.Opt
+	if modify != nil {
+		modify(&opt)
+	}
+	page.Opt = &opt
+	if err := page.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func TestPageImage(t *testing.T) {
+	if img := parseImagePage(t, "text\n", nil).Image(); img != nil {
+		t.Errorf("no @page.image: Image() = %+v", img)
+	}
+
+	// external images are used as they are
+	img := parseImagePage(t, "@page.image: https://example.com/cat.png;\n", nil).Image()
+	if img == nil || img.File != "https://example.com/cat.png" || img.Path != img.File || img.Width != 0 {
+		t.Errorf("external: Image() = %+v", img)
+	}
+
+	// formatting is removed from the filename
+	img = parseImagePage(t, "@page.image: [b]cat & dog.png[/b];\n", func(opt *PageOpt) {
+		opt.Root.Image = "/images"
+	}).Image()
+	if img == nil || img.File != "cat & dog.png" || img.Path != "/images/cat & dog.png" {
+		t.Errorf("unsized: Image() = %+v", img)
+	}
+}
+
+func TestPageImageSized(t *testing.T) {
+	var calcWidth int
+	sized := func(fullSize bool) func(opt *PageOpt) {
+		return func(opt *PageOpt) {
+			opt.Image.SizeMethod = "server"
+			opt.Image.Calc = func(file string, width, height int, page *Page) (int, int, bool) {
+				calcWidth = width
+				return 1200, 800, fullSize
+			}
+			opt.Image.Sizer = func(file string, width, height int, page *Page) string {
+				return "/images/1200x800-" + file
+			}
+		}
+	}
+
+	// sized for link previews, and the page uses the image in that size
+	page := parseImagePage(t, "@page.image: cat.png;\n", sized(false))
+	img := page.Image()
+	want := &PageImage{File: "cat.png", Path: "/images/1200x800-cat.png", Width: 1200, Height: 800}
+	if !reflect.DeepEqual(img, want) {
+		t.Errorf("Image() = %+v, want %+v", img, want)
+	}
+	if calcWidth != pageImageWidth {
+		t.Errorf("sized to width %d, want %d", calcWidth, pageImageWidth)
+	}
+	if dims := page.Images["cat.png"]; !reflect.DeepEqual(dims, [][]int{{1200, 800}}) {
+		t.Errorf("Images[cat.png] = %v", dims)
+	}
+
+	// the result is remembered
+	if page.Image() != img || len(page.Images["cat.png"]) != 1 {
+		t.Error("Image() sized the image again")
+	}
+
+	// the image is smaller than that, so its dimensions are not known
+	img = parseImagePage(t, "@page.image: cat.png;\n", sized(true)).Image()
+	if img == nil || img.Width != 0 || img.Height != 0 {
+		t.Errorf("full size: Image() = %+v", img)
+	}
+}
@@ -0,0 +1,545 @@
+package wikifier
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	markdownTagRegex       = regexp.MustCompile(`<(/?)(\w+)([^>]*)>`)
+	markdownAttrRegex      = regexp.MustCompile(`([\w-]+)="([^"]*)"`)
+	markdownEntityRegex    = regexp.MustCompile(`&(#?\w+;)`)
+	markdownLineStartRegex = regexp.MustCompile(`^(\s*)([#>+=-]|\d+[.)])`)
+)
+
+// ToMarkdown generates and returns CommonMark with GitHub Flavored Markdown
+// extensions for the page. Blocks with no Markdown equivalent are included
+// as HTML.
+// The page must be parsed with Parse before attempting this method.
+func (p *Page) ToMarkdown() string {
+
+	// footnotes are numbered again as the text is formatted
+	p.footnotes, p.notesListed = nil, 0
+
+	md := markdownBlock(p.main, p)
+
+	// footnotes are listed at the end
+	for i, note := range p.footnotes {
+		md += "\n\n[^" + strconv.Itoa(i+1) + "]: " + markdownInline(note, false)
+	}
+
+	return strings.TrimSpace(md) + "\n"
+}
+
+// markdownBlock converts a block to Markdown.
+func markdownBlock(b block, page *Page) string {
+	switch b := b.(type) {
+
+	case *mainBlock, *pBlock:
+		return markdownContent(b, page)
+
+	case *secBlock:
+		if b.title == "" {
+			return markdownContent(b, page)
+		}
+		title := b.fmtTitle
+		if title == "" {
+			title = format(b, b.title, b.openPos)
+		}
+		heading := strings.Repeat("#", b.headerLevel) + " " + markdownHeading(title)
+		return markdownJoin(heading, markdownContent(b, page))
+
+	case *List:
+		return markdownList(b, page)
+
+	case *referencesBlock:
+		// footnotes are listed at the end, so only the other references
+		return markdownList(b.List, page)
+
+	case *codeBlock:
+		text := strings.TrimRight(strings.Trim(strings.Join(b.textContent(), ""), "\n"), " \t\n")
+		lang := b.blockName()
+		if lang == "" {
+			lang = page.Opt.Page.Code.Lang
+		}
+		fence := "```"
+		for strings.Contains(text, fence) {
+			fence += "`"
+		}
+		return fence + lang + "\n" + text + "\n" + fence
+
+	case *imagebox:
+		md := markdownImage(b.imageBlock)
+		desc, _ := b.Get("description")
+		if desc == nil {
+			desc, _ = b.Get("desc")
+		}
+		if desc != nil {
+			md = markdownJoin(md, markdownValue(desc, b, b.openPos, false))
+		}
+		return md
+
+	case *imageBlock:
+		return markdownImage(b)
+
+	case *tableBlock:
+		return markdownTable(b, page)
+
+	case *htmlBlock:
+		return strings.TrimSpace(strings.Join(b.textContent(), ""))
+
+	case *detailsBlock:
+		summary := ""
+		if b.title != "" {
+			summary = "<summary>" + string(format(b, b.title, b.openPos)) + "</summary>\n"
+		}
+		return "<details>\n" + summary + "\n" + markdownContent(b, page) + "\n\n</details>"
+
+	case *calloutBlock:
+		// GitHub alerts have no title, so it is the first line
+		content := markdownContent(b, page)
+		if b.title != "" {
+			content = markdownJoin("**"+markdownHeading(format(b, b.title, b.openPos))+"**", content)
+		}
+		return "> [!" + strings.ToUpper(b.blockType()) + "]\n" + markdownPrefixLines(content, "> ", ">")
+
+	case *quoteBlock:
+		return markdownPrefixLines(markdownContent(b, page), "> ", ">")
+
+	case *tabsBlock:
+		var parts []string
+		for _, tab := range b.tabs {
+			title := "**" + markdownHeading(format(tab, tab.title, tab.openPos)) + "**"
+			parts = append(parts, markdownJoin(title, markdownContent(tab, page)))
+		}
+		return markdownJoin(parts...)
+
+	// Markdown renderers make their own
+	case *tocBlock:
+		return ""
+	}
+
+	// anything else is included as HTML
+	el := b.el().copy()
+	b.html(page, el)
+	if el.hidden() {
+		return ""
+	}
+	return strings.TrimSpace(string(el.generate()))
+}
+
+// markdownContent converts the mixed text and blocks inside a block to
+// Markdown, like handleGenericContent does for HTML.
+func markdownContent(b block, page *Page) string {
+	var parts []string
+	var lines []string
+
+	// joins lines of text into a paragraph
+	addParagraph := func() {
+		if len(lines) == 0 {
+			return
+		}
+		parts = append(parts, markdownInline(HTML(strings.Join(lines, "\n")), false))
+		lines = nil
+	}
+
+	for _, pc := range b.posContent() {
+		switch item := pc.content.(type) {
+		case block:
+			addParagraph()
+			parts = append(parts, markdownBlock(item, page))
+
+		case string:
+			// an empty line starts a new paragraph
+			item = strings.TrimSpace(item)
+			if item == "" {
+				addParagraph()
+				continue
+			}
+			lines = append(lines, string(format(b, item, pc.pos)))
+		}
+	}
+	addParagraph()
+
+	return markdownJoin(parts...)
+}
+
+// markdownList converts a list{} or numlist{} to Markdown.
+func markdownList(l *List, page *Page) string {
+	items := make([]string, len(l.list))
+	for i, entry := range l.list {
+		marker := "-"
+		if l.ordered {
+			marker = strconv.Itoa(i+1) + "."
+		}
+		indent := strings.Repeat(" ", len(marker)+1)
+		text := markdownValue(entry.value, l, entry.pos, false)
+		items[i] = marker + " " + markdownPrefixLines(text, indent, "")[len(indent):]
+	}
+	return strings.Join(items, "\n")
+}
+
+// markdownImage converts an image{} to Markdown.
+func markdownImage(image *imageBlock) string {
+	if image.parseFailed {
+		return ""
+	}
+	md := "![" + markdownEscape(image.alt, false) + "](" + markdownURL(image.path) + ")"
+
+	// images only link to other things in Markdown
+	if image.link != "" && image.link != "none" && image.link != image.path {
+		if ok, target, _, _, _ := parseLink(image, image.link, &FmtOpt{Pos: image.getKeyPos("link")}); ok {
+			md = "[" + md + "](" + markdownURL(target) + ")"
+		}
+	}
+	return md
+}
+
+// markdownTable converts a table{} to a GFM table. Tables which cannot be
+// represented that way are included as HTML.
+func markdownTable(t *tableBlock, page *Page) string {
+
+	// GFM tables must start with exactly one header row and cannot have
+	// cells which span rows or columns or contain blocks
+	simple := len(t.rows) != 0 && t.rows[0].head
+	for i, row := range t.rows {
+		if i != 0 && row.head {
+			simple = false
+		}
+		for _, entry := range row.list {
+			values := []any{entry.value}
+			if cell, ok := entry.value.(*tableCell); ok {
+				values = cell.values
+				if cell.colspan > 1 || cell.rowspan > 1 {
+					simple = false
+				}
+			}
+			for _, value := range values {
+				if _, ok := value.(block); ok {
+					simple = false
+				}
+			}
+		}
+	}
+	if !simple {
+		el := t.el().copy()
+		t.html(page, el)
+		return strings.TrimSpace(string(el.generate()))
+	}
+
+	// the caption goes before the table
+	var caption string
+	if t.name != "" {
+		caption = markdownInline(format(t, t.name, t.openPos), false)
+	}
+
+	var lines []string
+	cols := 0
+	for _, row := range t.rows {
+		var cells []string
+		for _, entry := range row.list {
+			values := []any{entry.value}
+			if cell, ok := entry.value.(*tableCell); ok {
+				values = cell.values
+			}
+			text := ""
+			for _, value := range values {
+				text += markdownValue(value, row, entry.pos, true)
+			}
+			cells = append(cells, text)
+		}
+		if len(cells) > cols {
+			cols = len(cells)
+		}
+		lines = append(lines, strings.Join(cells, " | "))
+	}
+
+	// the delimiter row determines the alignment
+	delims := make([]string, cols)
+	for i := range delims {
+		a := ""
+		if i < len(t.align) {
+			a = t.align[i]
+		}
+		switch a {
+		case "left":
+			delims[i] = ":---"
+		case "center":
+			delims[i] = ":---:"
+		case "right":
+			delims[i] = "---:"
+		default:
+			delims[i] = "---"
+		}
+	}
+
+	// pad rows with fewer cells
+	for i, row := range t.rows {
+		for n := len(row.list); n < cols; n++ {
+			lines[i] += " | "
+		}
+		lines[i] = "| " + lines[i] + " |"
+	}
+
+	table := lines[0] + "\n| " + strings.Join(delims, " | ") + " |"
+	if len(lines) > 1 {
+		table += "\n" + strings.Join(lines[1:], "\n")
+	}
+	return markdownJoin(caption, table)
+}
+
+// markdownValue converts a list or map value to Markdown.
+func markdownValue(value any, b block, pos Position, inTable bool) string {
+	switch v := value.(type) {
+	case string:
+		return markdownInline(format(b, v, pos), inTable)
+	case HTML:
+		return markdownInline(v, inTable)
+	case block:
+		return markdownBlock(v, b.page())
+	case element:
+		// already converted to HTML
+		return string(v.generate())
+	case []any:
+		// text is inline, but blocks are separate
+		var parts []string
+		inline := false
+		for _, val := range v {
+			md := markdownValue(val, b, pos, inTable)
+			_, isBlock := val.(block)
+			if inline && !isBlock {
+				parts[len(parts)-1] += md
+			} else {
+				parts = append(parts, md)
+			}
+			inline = !isBlock
+		}
+		return markdownJoin(parts...)
+	}
+	return ""
+}
+
+// markdownInline converts HTML generated by the formatter to Markdown.
+// Tags with no Markdown equivalent are passed through as HTML.
+func markdownInline(h HTML, inTable bool) string {
+	var md, code strings.Builder
+	var closers []string // what to write for each open tag
+	inCode := false      // true when within <code>
+	skip := 0            // depth of tags whose content is skipped
+
+	// writes text between tags
+	addText := func(text string) {
+		text = html.UnescapeString(text)
+		switch {
+		case skip != 0:
+		case inCode:
+			code.WriteString(text)
+		default:
+			md.WriteString(markdownEscape(text, inTable))
+		}
+	}
+
+	s, last := string(h), 0
+	for _, loc := range markdownTagRegex.FindAllStringSubmatchIndex(s, -1) {
+		addText(s[last:loc[0]])
+		last = loc[1]
+		tag := s[loc[0]:loc[1]]
+		name := strings.ToLower(s[loc[4]:loc[5]])
+
+		// closing tag
+		if loc[3] > loc[2] {
+			if len(closers) == 0 {
+				md.WriteString(tag)
+				continue
+			}
+			closer := closers[len(closers)-1]
+			closers = closers[:len(closers)-1]
+			switch {
+			case skip != 0:
+				if name == "sup" {
+					skip--
+				}
+			case inCode && name == "code":
+				inCode = false
+				md.WriteString(markdownCode(code.String()))
+				code.Reset()
+			case inCode:
+			default:
+				md.WriteString(closer)
+			}
+			continue
+		}
+
+		// content is skipped or code
+		if skip != 0 || inCode {
+			if skip != 0 && name == "sup" {
+				skip++
+			}
+			if !strings.HasSuffix(tag, "/>") && name != "br" {
+				closers = append(closers, "")
+			}
+			continue
+		}
+
+		attrs := make(map[string]string)
+		for _, attr := range markdownAttrRegex.FindAllStringSubmatch(s[loc[6]:loc[7]], -1) {
+			attrs[attr[1]] = html.UnescapeString(attr[2])
+		}
+
+		open, closer := tag, "</"+name+">"
+		switch name {
+
+		// tags without content
+		case "br":
+			if inTable {
+				md.WriteString("<br />")
+			} else {
+				md.WriteString("\\\n")
+			}
+			continue
+		case "img", "hr", "wbr":
+			md.WriteString(tag)
+			continue
+
+		case "code":
+			inCode = true
+			open, closer = "", ""
+
+		case "b", "strong":
+			open, closer = "**", "**"
+		case "i", "em":
+			open, closer = "*", "*"
+		case "s", "del":
+			open, closer = "~~", "~~"
+
+		// the formatter uses styled spans
+		case "span":
+			switch style := attrs["style"]; {
+			case strings.Contains(style, "font-weight: bold"):
+				open, closer = "**", "**"
+			case strings.Contains(style, "font-style: italic"):
+				open, closer = "*", "*"
+			case strings.Contains(style, "line-through"):
+				open, closer = "~~", "~~"
+			}
+
+		// footnotes are numbered in the same order
+		case "sup":
+			if id := attrs["id"]; strings.HasPrefix(id, "qa-refsrc-") {
+				open, closer = "[^"+strings.TrimPrefix(id, "qa-refsrc-")+"]", ""
+				skip++
+			}
+
+		case "a":
+			// the title is only the tooltip generated by quiki
+			if href, ok := attrs["href"]; ok {
+				open, closer = "[", "]("+markdownURL(href)+")"
+			}
+		}
+
+		md.WriteString(open)
+		closers = append(closers, closer)
+	}
+	addText(s[last:])
+
+	// close anything left open
+	if inCode {
+		md.WriteString(markdownCode(code.String()))
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		md.WriteString(closers[i])
+	}
+
+	return markdownEscapeLines(md.String())
+}
+
+// markdownCode returns inline code with enough backticks to contain the
+// text.
+func markdownCode(text string) string {
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + text + fence
+}
+
+// markdownEscape escapes characters which have meaning in Markdown.
+func markdownEscape(text string, inTable bool) string {
+	var md strings.Builder
+	for _, r := range text {
+		switch r {
+		case '\\', '`', '*', '_', '[', ']', '<', '~':
+			md.WriteRune('\\')
+		case '|':
+			if inTable {
+				md.WriteRune('\\')
+			}
+		case '\n':
+			if inTable {
+				md.WriteRune(' ')
+				continue
+			}
+		}
+		md.WriteRune(r)
+	}
+
+	// &amp; would otherwise be an entity
+	return markdownEntityRegex.ReplaceAllString(md.String(), `\&$1`)
+}
+
+// markdownEscapeLines escapes text at the start of lines which would
+// otherwise begin a heading, list, or quote.
+func markdownEscapeLines(md string) string {
+	lines := strings.Split(md, "\n")
+	for i, line := range lines {
+		// the backslash goes before the delimiter, since \1. would be a
+		// backslash followed by the number
+		lines[i] = markdownLineStartRegex.ReplaceAllStringFunc(line, func(start string) string {
+			return start[:len(start)-1] + `\` + start[len(start)-1:]
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownHeading converts formatted text to Markdown on a single line.
+func markdownHeading(h HTML) string {
+	return strings.Join(strings.Fields(markdownInline(h, false)), " ")
+}
+
+// markdownURL returns a link destination, in angle brackets if needed.
+func markdownURL(url string) string {
+	if strings.ContainsAny(url, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(url) + ">"
+	}
+	return url
+}
+
+// markdownJoin joins blocks of Markdown, skipping empty ones.
+func markdownJoin(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, "\n\n")
+}
+
+// markdownPrefixLines adds a prefix to each line of Markdown, or another
+// prefix for empty lines.
+func markdownPrefixLines(md, prefix, emptyPrefix string) string {
+	lines := strings.Split(md, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = emptyPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,53 @@
+package wikifier
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cooper/quiki/markdown"
+)
+
+// each testdata/markdown/NAME.page is converted and compared to NAME.md
+func TestToMarkdown(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "markdown", "*.page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test files")
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".page")
+		t.Run(name, func(t *testing.T) {
+			golden, err := os.ReadFile(strings.TrimSuffix(file, ".page") + ".md")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the page is outside of any wiki, like with quiki -to-markdown
+			md := pageMarkdown(t, NewPage(file))
+			if md != string(golden) {
+				t.Errorf("got:\n%s\nwant:\n%s", md, golden)
+			}
+
+			// importing the Markdown and converting it again gives the same
+			imported, err := markdown.Convert([]byte(md))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again := pageMarkdown(t, NewPageSource(string(imported))); again != md {
+				t.Errorf("round trip changed the Markdown:\n%s\nimported as:\n%s", again, imported)
+			}
+		})
+	}
+}
+
+func pageMarkdown(t *testing.T, page *Page) string {
+	t.Helper()
+	if err := page.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return page.ToMarkdown()
+}
@@ -33,6 +33,7 @@ type PageOpt struct {
 	Image        PageOptImage
 	Category     PageOptCategory
 	Search       PageOptSearch
+	Robots       PageOptRobots
 	Link         PageOptLink
 	External     map[string]PageOptExternal
 	Navigation   []PageOptNavigation
@@ -51,6 +52,14 @@ type PageOptPage struct {
 	EnableCache bool        // enable page caching
 	ForceGen    bool        // force generation of page even if unchanged
 	Code        PageOptCode // `code{}` block options
+
+	// Find finds another page by name for `include{}`. If it is nil or
+	// returns nil, the page is looked for in Dir.Page
+	Find func(page *Page, name string) *Page
+
+	// Query finds the pages matching a `pages{}` query. If it is nil,
+	// `pages{}` produces a warning and displays nothing
+	Query func(page *Page, q PageQuery) []PageInfo
 }
 
 // PageOptHost describes HTTP hosts for a wiki.
@@ -110,6 +119,12 @@ type PageOptSearch struct {
 	Enable bool
 }
 
+// PageOptRobots describes options for search engine crawlers.
+type PageOptRobots struct {
+	Index    bool     // allow crawlers to index the wiki
+	Disallow []string // paths crawlers should not visit, relative to the wiki root
+}
+
 // A PageOptLinkFunction sanitizes a link target.
 type PageOptLinkFunction func(page *Page, opts *PageOptLinkOpts)
 
@@ -202,6 +217,9 @@ var defaultPageOpt = PageOpt{
 	Search: PageOptSearch{
 		Enable: true,
 	},
+	Robots: PageOptRobots{
+		Index: true,
+	},
 	Link: PageOptLink{
 		ParseInternal: nil,
 		ParseExternal: defaultExternalLink,
@@ -284,6 +302,7 @@ func InjectPageOpt(page *Page, opt *PageOpt) error {
 		"page.enable.title":     &opt.Page.EnableTitle,     // enable page title headings
 		"page.enable.cache":     &opt.Page.EnableCache,     // enable page caching
 		"search.enable":         &opt.Search.Enable,        // enable search optimization
+		"robots.index":          &opt.Robots.Index,         // allow crawlers to index
 		"image.arbitrary_sizes": &opt.Image.ArbitrarySizes, // allow arbitrary image sizes
 	}
 	for name, ptr := range pageOptBool {
@@ -352,6 +371,21 @@ func InjectPageOpt(page *Page, opt *PageOpt) error {
 		opt.Category.PerPage = intVal
 	}
 
+	// robots.disallow - paths crawlers should not visit
+	str, err = page.GetStr("robots.disallow")
+	if err != nil {
+		return errors.Wrap(err, "robots.disallow")
+	}
+	if str != "" {
+		var disallow []string
+		for _, p := range strings.Split(str, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				disallow = append(disallow, startWithSlash(p))
+			}
+		}
+		opt.Robots.Disallow = disallow
+	}
+
 	// navigation - ordered navigation items
 	obj, err := page.GetObj("navigation")
 	if err != nil {
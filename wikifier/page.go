@@ -28,22 +28,29 @@ type Page struct {
 	styles       []styleEntry
 	staticStyles []string
 	codeStyles   bool
+	diagStyles   bool
 	parser       *parser              // wikifier parser instance
 	main         block                // main block
 	Images       map[string][][]int   // references to images
 	Models       map[string]ModelInfo // references to models
 	PageLinks    map[string][]int     // references to other pages
+	Includes     map[string][]int     // references to included pages
+	Queries      []int                // lines with pages{} queries
 	sectionN     int
 	name         string
 	headingIDs   map[string]int
+	footnotes    []HTML    // footnote content in order of appearance
+	notesListed  int       // number of footnotes shown in references
 	Wiki         any       // only available during Parse() and HTML()
 	Markdown     bool      // true if this is a markdown source
 	model        bool      // true if this is a model being generated
+	includedBy   *Page     // page which includes this one, if any
 	Warnings     []Warning // parser warnings
 	Error        *Warning  // parser error, as an encodable Warning
 	_html        HTML
 	_text        string
 	_preview     string
+	_image       *PageImage
 	_styleId     int
 	*variableScope
 }
@@ -66,6 +73,7 @@ type PageInfo struct {
 	Author      string     `json:"author,omitempty"`    // author's name
 	Description string     `json:"desc,omitempty"`      // description
 	Keywords    []string   `json:"keywords,omitempty"`  // keywords
+	Image       *PageImage `json:"image,omitempty"`     // image representing the page
 	Preview     string     `json:"preview,omitempty"`   // first 25 words or 150 chars. empty w/ description
 	Warnings    []Warning  `json:"warnings,omitempty"`  // parser warnings
 	Error       *Warning   `json:"error,omitempty"`     // parser error, as an encodable warning
@@ -87,6 +95,7 @@ func NewPage(filePath string) *Page {
 		Images:        make(map[string][][]int),
 		Models:        make(map[string]ModelInfo),
 		PageLinks:     make(map[string][]int),
+		Includes:      make(map[string][]int),
 		headingIDs:    make(map[string]int),
 		Markdown:      strings.HasSuffix(filePath, ".md"),
 	}
@@ -144,7 +153,10 @@ func (p *Page) _parse() error {
 	// create reader from file path or source code provided
 	var reader io.Reader
 	if p.Markdown && p.Source != "" {
-		d := markdown.Run([]byte(p.Source))
+		d, err := markdown.Convert([]byte(p.Source))
+		if err != nil {
+			return err
+		}
 		reader = bytes.NewReader(d)
 	} else if p.Source != "" {
 		reader = strings.NewReader(p.Source)
@@ -154,7 +166,10 @@ func (p *Page) _parse() error {
 		if err != nil {
 			return err
 		}
-		d := markdown.Run(md)
+		d, err := markdown.Convert(md)
+		if err != nil {
+			return err
+		}
 		reader = bytes.NewReader(d)
 	} else if p.FilePath != "" {
 		file, err := os.Open(p.FilePath)
@@ -190,6 +205,9 @@ func (p *Page) _parse() error {
 	// parse the blocks, unless we only want vars
 	if !p.VarsOnly {
 		p.main.parse(p)
+
+		// size @page.image now, so it is tracked with the other images
+		p.Image()
 	}
 
 	return nil
@@ -338,7 +356,12 @@ func (p *Page) OSNameNE() string {
 //
 // For example, for a page named a/b.page, this is a.
 // For a page named a.page, this is an empty string.
+// Pages outside of the page directory have no prefix, since their names are
+// relative to where the file is.
 func (p *Page) Prefix() string {
+	if p.External() {
+		return ""
+	}
 	dir := strings.TrimSuffix(filepath.ToSlash(filepath.Dir(p.Name())), "/")
 	if dir == "." {
 		return ""
@@ -602,6 +625,34 @@ func (p *Page) Categories() []string {
 	return catMap.Keys()
 }
 
+// PageVars returns the @page variables which have text or boolean values.
+// Booleans are yes or no.
+func (p *Page) PageVars() map[string]string {
+	obj, err := p.GetObj("page")
+	if err != nil {
+		return nil
+	}
+	pageMap, ok := obj.(*Map)
+	if !ok {
+		return nil
+	}
+	vars := make(map[string]string)
+	for key, val := range pageMap.Map() {
+		switch v := val.(type) {
+		case string:
+			vars[key] = strings.TrimSpace(v)
+		case HTML:
+			vars[key] = strings.TrimSpace(string(v))
+		case bool:
+			vars[key] = "no"
+			if v {
+				vars[key] = "yes"
+			}
+		}
+	}
+	return vars
+}
+
 // Info returns the PageInfo for the page.
 func (p *Page) Info() PageInfo {
 
@@ -627,6 +678,7 @@ func (p *Page) Info() PageInfo {
 		Author:      p.Author(),
 		Description: desc,
 		Keywords:    p.Keywords(),
+		Image:       p.Image(),
 		Preview:     prev,
 		Warnings:    p.Warnings,
 		Error:       p.Error,
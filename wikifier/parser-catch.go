@@ -150,6 +150,11 @@ func (c *genericCatch) finishLine() {
 	newPortion := strings.TrimPrefix(lastStr[lineStart:], c.removeIndent)
 	newStr := lastStr[:lineStart] + newPortion
 	c.positioned[len(c.positioned)-1].content = newStr
+
+	// if the string began with the indent, it now begins after it
+	if lineStart == 0 {
+		c.positioned[len(c.positioned)-1].pos.Column += len(lastStr) - len(newStr)
+	}
 }
 
 func (c *genericCatch) pushContent(item any, pos Position) {
@@ -13,6 +13,7 @@ import (
 var (
 	wordCharPattern = regexp.MustCompile(`[\w\-\$\.]`)
 	spacePattern    = regexp.MustCompile(`\s`)
+	condVarPattern  = regexp.MustCompile(`^!*@[\w\.\/]+$`)
 )
 
 type parser struct {
@@ -325,12 +326,15 @@ func (p *parser) parseRune(r rune, page *Page) error {
 		}
 
 		// if the current block is an infobox{}, sub-blocks are infosec{}
+		// if the current block is a table{}, sub-blocks are row{}
 		// otherwise:
 		// if there is a name but no type, it's a section with a heading
 		// if neither, it's a map
 		if len(blockType) == 0 {
 			if p.block.blockType() == "infobox" {
 				blockType = "infosec"
+			} else if p.block.blockType() == "table" {
+				blockType = "row"
 			} else if len(blockName) != 0 {
 				blockType = "sec"
 			} else {
@@ -566,6 +570,7 @@ func (p *parser) parseRune(r rune, page *Page) error {
 
 			// set the value
 			page.Set(p.varName, !p.varNegated)
+			page.modelVarSet(p.varName)
 
 			p.clearVariableState()
 			return p.nextRune(r)
@@ -611,6 +616,7 @@ func (p *parser) parseRune(r rune, page *Page) error {
 
 			// set the value
 			page.Set(p.varName, value)
+			page.modelVarSet(p.varName)
 
 			p.clearVariableState()
 			return p.nextRune(r)
@@ -712,6 +718,16 @@ func (p *parser) getConditional(blk block, page *Page, condition string) bool {
 		return false
 	}
 
+	// expression, such as @a == "b"
+	if !condVarPattern.MatchString(condition) {
+		val, err := evalExpression(blk.variables(), condition)
+		if err != nil {
+			blk.warn(blk.openPosition(), "Invalid "+blk.blockType()+"{} condition: "+err.Error())
+			return false
+		}
+		return exprTruthy(val)
+	}
+
 	// negated
 	if condition[0] == '!' {
 		return !p.getConditional(blk, page, condition[1:])
@@ -0,0 +1,1249 @@
+package wikifier
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FormatSource formats quiki source code.
+//
+// Blocks are indented by four spaces, consecutive map keys and variable
+// assignments are aligned, block headers are normalized, and unnecessary
+// escapes are removed. Comments are preserved.
+//
+// Only whitespace and escapes which do not affect the result are changed, so
+// the formatted source produces the same page. The contents of blocks such as
+// code{} and html{} are kept as-is, except that they may be re-indented when
+// that does not change the text.
+func FormatSource(source []byte) ([]byte, error) {
+	p := newSourceParser(source)
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	w := new(sourcePrinter)
+	w.nodes(p.main.content, 0)
+	w.endLine()
+	return []byte(w.out.String()), nil
+}
+
+type sourceKind int
+
+const (
+	sourceContainer sourceKind = iota // text and blocks, like sec{}
+	sourceMap                         // key-value pairs, like map{}
+	sourceList                        // values, like list{}
+	sourceVerbatim                    // whitespace may be significant, like code{}
+)
+
+// blocks whose content is kept as-is
+var verbatimBlocks = map[string]bool{
+	"code":     true,
+	"html":     true,
+	"math":     true,
+	"diagram":  true,
+	"fmt":      true,
+	"variable": true,
+}
+
+type sourceText struct {
+	text string
+}
+
+type sourceComment struct {
+	text   string
+	indent string // indentation of the line where the comment started
+}
+
+type sourceBlock struct {
+	header   string     // normalized type, name, and heading ID
+	lead     bool       // header was separated from preceding text
+	typ      string     // resolved block type
+	name     string     // block name
+	kind     sourceKind // how content is formatted
+	variable bool       // {@var}
+	brace    bool       // brace-escaped
+	content  []any      // text, comments, and blocks
+	raw      string     // source between the braces
+	alone    bool       // closing brace is alone on its line
+	start    int        // offset where raw starts
+	pos      Position   // position where the block opened
+	target   *[]any     // where the block is added when closed
+}
+
+type sourceVar struct {
+	prefix   string // @, %, -@, or -%
+	name     string
+	hasValue bool
+	value    []any
+	closed   bool
+	pad      int // spaces to align the value
+}
+
+type sourceParser struct {
+	src []rune
+	pos Position
+
+	last           rune
+	escape         bool
+	lineHasStarted bool
+
+	commentLevel int
+	comment      *sourceComment
+	commentStart int
+
+	braceLevel int
+
+	v          *sourceVar // variable being parsed
+	inVarName  bool
+	varNegated bool
+	dash       bool // - preceding @ or %
+
+	main   *sourceBlock
+	blocks []*sourceBlock // open blocks, innermost last
+
+	page      *Page
+	mainBlock block
+	kinds     map[string]sourceKind
+}
+
+func newSourceParser(source []byte) *sourceParser {
+	src := strings.Replace(string(source), "\r\n", "\n", -1)
+	main := &sourceBlock{typ: "main", kind: sourceContainer}
+	page := NewPageSource("")
+	return &sourceParser{
+		src:       []rune(src),
+		pos:       Position{Line: 1},
+		main:      main,
+		blocks:    []*sourceBlock{main},
+		page:      page,
+		mainBlock: newBlock("main", "", "", nil, nil, nil, Position{}, page),
+		kinds:     make(map[string]sourceKind),
+	}
+}
+
+// parse tokenizes the source following the same rules as the parser,
+// keeping the original text of everything.
+func (p *sourceParser) parse() error {
+	for i := 0; i < len(p.src); i++ {
+		r := p.src[i]
+		p.pos.Column++
+
+		// next two runes on this line
+		var next, next2 rune
+		if r != '\n' && i+1 < len(p.src) {
+			next = p.src[i+1]
+			if next != '\n' && i+2 < len(p.src) {
+				next2 = p.src[i+2]
+			}
+		} else if r != '\n' {
+			next = '\n'
+		}
+
+		skip, err := p.parseRune(i, r, next, next2)
+		if err != nil {
+			return err
+		}
+		p.finishRune(r)
+		for ; skip != 0; skip-- {
+			i++
+			if p.src[i] == '\n' {
+				p.finishRune('\n')
+			}
+		}
+	}
+
+	// unclosed comment runs to the end
+	if p.commentLevel != 0 {
+		p.comment.text = string(p.src[p.commentStart:])
+		p.appendNode(p.comment)
+	}
+
+	if p.dash {
+		p.appendText("-")
+	}
+
+	if p.v != nil {
+		if p.inVarName {
+			return parserError(p.pos, string(catchTypeVariableName)+" not closed")
+		}
+		return parserError(p.pos, string(catchTypeVariableValue)+" not closed")
+	}
+
+	if blk := p.blocks[len(p.blocks)-1]; blk != p.main {
+		return parserError(blk.pos, blk.typ+"{} not closed")
+	}
+	return nil
+}
+
+func (p *sourceParser) finishRune(r rune) {
+	if !p.lineHasStarted && !unicode.IsSpace(r) {
+		p.lineHasStarted = true
+	}
+	if r == '\n' {
+		p.pos.Line++
+		p.pos.Column = 0
+		p.lineHasStarted = false
+	}
+}
+
+// nextRune updates the escape state like the parser does
+func (p *sourceParser) nextRune(r rune) {
+	p.escape = r == '\\' && !p.escape && p.braceLevel == 0
+	p.last = r
+}
+
+func (p *sourceParser) parseRune(i int, r, next, next2 rune) (skip int, err error) {
+	defer func() {
+		if err == nil {
+			p.nextRune(r)
+		}
+	}()
+
+	// brace escape; the source is taken as-is when the block closes
+	if p.braceLevel != 0 {
+		if r == '{' {
+			p.braceLevel++
+		} else if r == '}' {
+			p.braceLevel--
+		}
+		return 0, nil
+	}
+
+	// comments
+	if r == '/' && next == '*' {
+		if p.escape {
+			return 0, p.text(r)
+		}
+		if p.inVarName {
+			return 0, parserError(p.pos, "Comment in variable name")
+		}
+		if p.commentLevel == 0 {
+			p.comment = &sourceComment{indent: p.lineIndent(i)}
+			p.commentStart = i
+		}
+		p.commentLevel++
+		return 0, nil
+	}
+	if r == '*' && next == '/' {
+		if p.commentLevel == 0 {
+			return 0, p.text(r)
+		}
+		p.commentLevel--
+		if p.commentLevel == 0 {
+			p.comment.text = string(p.src[p.commentStart : i+2])
+			p.appendNode(p.comment)
+		}
+		return 1, nil
+	}
+	if p.commentLevel != 0 {
+		return 0, nil
+	}
+
+	// blocks
+	if r == '{' {
+		if p.escape {
+			return 0, p.text(r)
+		}
+		return p.openBlock(i, next, next2)
+	}
+	if r == '}' {
+		if p.escape {
+			return 0, p.text(r)
+		}
+		return 0, p.closeBlock(i)
+	}
+
+	// variables
+	if len(p.blocks) == 1 && variableTokens[r] && p.last != '[' && !p.escape {
+		potentiallyVar := false
+		if p.v == nil {
+			if r == '@' || r == '%' {
+				potentiallyVar = (p.varNegated && p.last == '-') || !p.lineHasStarted
+			} else if r == '-' && !p.lineHasStarted {
+				p.varNegated = true
+			}
+		}
+
+		switch {
+		case potentiallyVar:
+			prefix := string(r)
+			if p.dash {
+				prefix = "-" + prefix
+				p.dash = false
+			}
+			p.v = &sourceVar{prefix: prefix}
+			p.inVarName = true
+			return 0, nil
+
+		case r == ':' && p.inVarName:
+			if p.v.name == "" {
+				return 0, parserError(p.pos, "variable has no name")
+			}
+			p.inVarName = false
+			p.v.hasValue = true
+			return 0, nil
+
+		case r == ';' && p.v != nil:
+			if p.v.name == "" {
+				return 0, parserError(p.pos, "variable has no name")
+			}
+			p.v.closed = true
+			p.inVarName = false
+			v := p.v
+			p.v = nil
+			p.varNegated = false
+			p.appendNode(v)
+			return 0, nil
+
+		case r == '-' && (next == '@' || next == '%'):
+			p.dash = true
+			return 0, nil
+		}
+	}
+
+	return 0, p.text(r)
+}
+
+// text adds a rune to the current variable name or text
+func (p *sourceParser) text(r rune) error {
+	if p.dash {
+		p.dash = false
+		if err := p.text('-'); err != nil {
+			return err
+		}
+	}
+
+	if !p.inVarName {
+		p.appendText(string(r))
+		return nil
+	}
+
+	// whitespace terminates the variable name, so it is just text
+	if unicode.IsSpace(r) {
+		v := p.v
+		p.v, p.inVarName = nil, false
+
+		// the parser keeps the indentation before it separately, which
+		// separates paragraphs like a blank line
+		nodes := p.target()
+		if len(*nodes) != 0 {
+			if t, ok := (*nodes)[len(*nodes)-1].(*sourceText); ok {
+				lineStart := strings.LastIndexByte(t.text, '\n') + 1
+				if indent := t.text[lineStart:]; indent != "" && strings.TrimSpace(indent) == "" {
+					t.text = t.text[:lineStart] + "\n"
+				}
+			}
+		}
+
+		p.appendText(v.prefix + v.name + string(r))
+		return nil
+	}
+
+	if !variableNameRgx.MatchString(string(r)) {
+		return parserError(p.pos, "Invalid rune '"+string(r)+"' in "+string(catchTypeVariableName)+".")
+	}
+	p.v.name += string(r)
+	return nil
+}
+
+// target returns the node list where content is currently added
+func (p *sourceParser) target() *[]any {
+	if p.v != nil && len(p.blocks) == 1 {
+		return &p.v.value
+	}
+	return &p.blocks[len(p.blocks)-1].content
+}
+
+func (p *sourceParser) appendText(s string) {
+	appendSourceText(p.target(), s)
+}
+
+func (p *sourceParser) appendNode(node any) {
+	t := p.target()
+	*t = append(*t, node)
+}
+
+func appendSourceText(nodes *[]any, s string) {
+	if len(*nodes) != 0 {
+		if t, ok := (*nodes)[len(*nodes)-1].(*sourceText); ok {
+			t.text += s
+			return
+		}
+	}
+	*nodes = append(*nodes, &sourceText{s})
+}
+
+// lineIndent returns the leading whitespace of the line containing i
+func (p *sourceParser) lineIndent(i int) string {
+	start := i
+	for start > 0 && p.src[start-1] != '\n' {
+		start--
+	}
+	end := start
+	for end < i && (p.src[end] == ' ' || p.src[end] == '\t') {
+		end++
+	}
+	return string(p.src[start:end])
+}
+
+func (p *sourceParser) openBlock(i int, next, next2 rune) (int, error) {
+	if p.inVarName {
+		return 0, parserError(p.pos, "Invalid rune '{' in "+string(catchTypeVariableName)+".")
+	}
+
+	parent := p.blocks[len(p.blocks)-1]
+	blk := &sourceBlock{pos: p.pos, target: p.target(), start: i + 1}
+	skip := 0
+
+	if next == '@' {
+		// {@var}
+		blk.typ, blk.variable = "variable", true
+		blk.start++
+		skip++
+	} else {
+		if err := p.scanHeader(blk); err != nil {
+			return 0, err
+		}
+		if blk.typ == "" {
+			if parent.typ == "infobox" {
+				blk.typ = "infosec"
+			} else if parent.typ == "table" {
+				blk.typ = "row"
+			} else if blk.name != "" {
+				blk.typ = "sec"
+			} else {
+				blk.typ = "map"
+			}
+		}
+		if blk.typ[0] == '$' {
+			blk.typ = "model"
+		}
+		if p.needsType(blk) {
+			blk.header = blk.typ
+		}
+	}
+	blk.kind = p.kindOf(blk.typ)
+
+	// brace-escaped block is kept as-is
+	if next == '{' {
+		blk.brace = true
+		blk.kind = sourceVerbatim
+		p.braceLevel++
+		skip++
+		if next2 == '\n' {
+			skip++
+		}
+	}
+
+	p.blocks = append(p.blocks, blk)
+	return skip, nil
+}
+
+// scanHeader finds the block type, name, and heading ID in the text before
+// the opening brace, just as the parser does, and removes it from the text.
+func (p *sourceParser) scanHeader(blk *sourceBlock) error {
+	texts, lastContent := sourceLastString(*p.target())
+	if len(lastContent) == 0 {
+		return parserError(p.pos, "block has no type")
+	}
+	h := scanSourceHeader(lastContent)
+
+	// remove the header from the text. if there was a comment in it, the
+	// comment ends up before the header. a blank previous line is kept
+	region := lastContent[len(lastContent)-h.scanned:]
+	blk.lead = region != "" && unicode.IsSpace(rune(region[0]))
+	if strings.TrimSpace(region) == "" && strings.HasSuffix(region, "\n") {
+		h.scanned = 0
+	}
+	for scanned, j := h.scanned, 0; scanned != 0; j++ {
+		t := texts[len(texts)-1-j]
+		if scanned <= len(t.text) {
+			t.text = t.text[:len(t.text)-scanned]
+			break
+		}
+		scanned -= len(t.text)
+		t.text = ""
+	}
+
+	// normalize the header
+	var header []string
+	if h.blockType != "" {
+		if h.tilde {
+			header = append(header, "~"+h.blockType)
+		} else {
+			header = append(header, h.blockType)
+		}
+	}
+	if h.hasName {
+		header = append(header, "["+h.blockName+"]")
+	}
+	if h.headingID != "" {
+		header = append(header, h.headingID+"#")
+	}
+	blk.header = strings.Join(header, " ")
+	blk.name = h.blockName
+
+	blk.typ = h.blockType
+	if dot := strings.IndexByte(blk.typ, '.'); dot != -1 {
+		blk.typ = blk.typ[:dot]
+	}
+	if alias, exist := blockAliases[blk.typ]; exist {
+		blk.typ = alias
+	}
+	return nil
+}
+
+// typeless blocks in the main block are written at the start of a line. if
+// the parser would take the previous line as the header, the type is written
+func (p *sourceParser) needsType(blk *sourceBlock) bool {
+	if blk.header != "" || len(p.blocks) != 1 || p.v != nil {
+		return false
+	}
+	_, lastContent := sourceLastString(p.main.content)
+	lastContent = strings.TrimRight(lastContent, " \t")
+	if !strings.HasSuffix(lastContent, "\n") {
+		return false
+	}
+	h := scanSourceHeader(lastContent)
+	return strings.TrimSpace(lastContent[len(lastContent)-h.scanned:]) != ""
+}
+
+// sourceLastString returns the text nodes at the end of content and the
+// text the parser would consider for a block header: the current line, or
+// the previous line if nothing has been added on this one. comments are
+// not text.
+func sourceLastString(nodes []any) ([]*sourceText, string) {
+	var texts []*sourceText
+	var lastContent string
+	for j := len(nodes) - 1; j >= 0; j-- {
+		if t, ok := nodes[j].(*sourceText); ok {
+			texts = append([]*sourceText{t}, texts...)
+			lastContent = t.text + lastContent
+		} else if _, ok := nodes[j].(*sourceComment); !ok {
+			break
+		}
+	}
+	if strings.HasSuffix(lastContent, "\n") {
+		lastContent = lastContent[strings.LastIndexByte(lastContent[:len(lastContent)-1], '\n')+1:]
+	} else {
+		lastContent = lastContent[strings.LastIndexByte(lastContent, '\n')+1:]
+	}
+	return texts, lastContent
+}
+
+type sourceHeader struct {
+	blockType, blockName, headingID string
+	hasName, tilde                  bool
+	scanned                         int // number of bytes in the header
+}
+
+// scanSourceHeader scans text backward for a block header like the parser
+func scanSourceHeader(lastContent string) (h sourceHeader) {
+	var inBlockName int
+	var inHeadingID bool
+	for i := len(lastContent) - 1; i != -1; i-- {
+		lastChar := lastContent[i]
+		h.scanned++
+
+		if lastChar == ']' && h.blockType == "" {
+			inBlockName++
+			if inBlockName == 1 {
+				inHeadingID = false
+				h.hasName = true
+				continue
+			}
+		} else if lastChar == '[' {
+			inBlockName--
+			if inBlockName != 1 {
+				continue
+			}
+		} else if lastChar == '#' && h.blockName == "" && h.blockType == "" {
+			inHeadingID = h.headingID == ""
+			continue
+		}
+
+		if inBlockName != 0 {
+			h.blockName = string(lastChar) + h.blockName
+		} else if inHeadingID {
+			if lastChar != ' ' && lastChar != '\t' {
+				h.headingID = string(lastChar) + h.headingID
+			}
+		} else if wordCharPattern.Match([]byte{lastChar}) {
+			h.blockType = string(lastChar) + h.blockType
+			continue
+		} else if lastChar == '~' && len(h.blockType) != 0 {
+			h.tilde = true
+			break
+		} else if spacePattern.Match([]byte{lastChar}) && len(h.blockType) == 0 {
+			continue
+		} else {
+			h.scanned--
+			break
+		}
+	}
+	return
+}
+
+func (p *sourceParser) closeBlock(i int) error {
+	if p.inVarName {
+		return parserError(p.pos, "Invalid rune '}' in "+string(catchTypeVariableName)+".")
+	}
+	if len(p.blocks) == 1 {
+		return parserError(p.pos, "attempted to close main block")
+	}
+
+	blk := p.blocks[len(p.blocks)-1]
+	p.blocks = p.blocks[:len(p.blocks)-1]
+	blk.raw = string(p.src[blk.start:i])
+
+	// the parser ignores whitespace around a closing brace alone on its line
+	lineStart := strings.LastIndexByte(blk.raw, '\n')
+	if lineStart != -1 && strings.TrimSpace(blk.raw[lineStart:]) == "" {
+		blk.alone = true
+		for _, r := range p.src[i+1:] {
+			if r == '\n' {
+				break
+			}
+			if !unicode.IsSpace(r) {
+				blk.alone = false
+				break
+			}
+		}
+	}
+
+	*blk.target = append(*blk.target, blk)
+	return nil
+}
+
+// kindOf determines how the content of a block type is formatted
+func (p *sourceParser) kindOf(typ string) sourceKind {
+	if kind, ok := p.kinds[typ]; ok {
+		return kind
+	}
+	kind := sourceContainer
+	switch typ {
+	case "if", "elsif", "else":
+	default:
+		if _, ok := blockInitializers[typ]; !ok || verbatimBlocks[typ] {
+			kind = sourceVerbatim
+			break
+		}
+		switch newBlock(typ, "", "", nil, p.mainBlock, p.mainBlock, Position{}, p.page).(type) {
+		case interface {
+			handleChar(*Page, int, *mapParser, rune)
+		}:
+			kind = sourceMap
+		case interface {
+			handleChar(*Page, int, *listParser, rune, bool)
+		}:
+			kind = sourceList
+		}
+	}
+	p.kinds[typ] = kind
+	return kind
+}
+
+// sourceEntry is a map or list entry, or a comment between entries
+type sourceEntry struct {
+	key, value []any
+	colon      bool
+	closed     bool
+	comment    *sourceComment // comment on its own line
+	trailing   *sourceComment // comment after the entry
+	blank      bool           // preceded by a blank line
+	pad        int            // spaces to align the value
+	commentPad int            // spaces to align the trailing comment
+}
+
+// sourceEntries splits map or list content into entries like the map and
+// list parsers do
+func sourceEntries(nodes []any, isMap bool) []*sourceEntry {
+	var entries []*sourceEntry
+	var cur *sourceEntry
+	var part *[]any
+	var newlines int
+	var escape, inValue bool
+
+	startEntry := func() {
+		cur = &sourceEntry{blank: newlines > 1}
+		part = &cur.key
+	}
+
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *sourceText:
+			var buf strings.Builder
+			flush := func() {
+				if buf.Len() != 0 {
+					appendSourceText(part, buf.String())
+					buf.Reset()
+				}
+			}
+			for _, r := range n.text {
+				if cur == nil {
+					if unicode.IsSpace(r) {
+						if r == '\n' {
+							newlines++
+						}
+						continue
+					}
+					startEntry()
+				}
+				switch {
+				case escape:
+					escape = false
+
+					// \: is the same as : in map values
+					if r == ':' && inValue && strings.HasSuffix(buf.String(), "\\") {
+						s := buf.String()
+						buf.Reset()
+						buf.WriteString(s[:len(s)-1])
+					}
+					buf.WriteRune(r)
+				case r == '\\':
+					escape = true
+					buf.WriteRune(r)
+				case r == ':' && isMap && !inValue:
+					flush()
+					cur.colon, inValue = true, true
+					part = &cur.value
+				case r == ';':
+					flush()
+					cur.closed = true
+					entries = append(entries, cur)
+					cur, inValue, newlines = nil, false, 0
+				default:
+					buf.WriteRune(r)
+				}
+			}
+			flush()
+
+		case *sourceComment:
+			if cur != nil {
+				*part = append(*part, n)
+				break
+			}
+			if last := len(entries) - 1; newlines == 0 && last != -1 && entries[last].comment == nil && entries[last].trailing == nil {
+				entries[last].trailing = n
+			} else {
+				entries = append(entries, &sourceEntry{comment: n, blank: newlines > 1})
+			}
+			newlines = 0
+
+		case *sourceBlock:
+			if cur == nil {
+				startEntry()
+			}
+			*part = append(*part, n)
+		}
+	}
+	if cur != nil {
+		entries = append(entries, cur)
+	}
+	return entries
+}
+
+// sourceTrim removes whitespace around content
+func sourceTrim(nodes []any) []any {
+	trimmed := make([]any, 0, len(nodes))
+	for _, node := range nodes {
+		trimmed = append(trimmed, node)
+	}
+	for len(trimmed) != 0 {
+		t, ok := trimmed[0].(*sourceText)
+		if !ok {
+			break
+		}
+		if s := strings.TrimLeftFunc(t.text, unicode.IsSpace); s != "" {
+			trimmed[0] = &sourceText{s}
+			break
+		}
+		trimmed = trimmed[1:]
+	}
+	for len(trimmed) != 0 {
+		t, ok := trimmed[len(trimmed)-1].(*sourceText)
+		if !ok {
+			break
+		}
+		if s := trimSourceRight(strings.TrimRightFunc(t.text, unicode.IsSpace), t.text); s != "" {
+			trimmed[len(trimmed)-1] = &sourceText{s}
+			break
+		}
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	return trimmed
+}
+
+// sourceTrimValue removes whitespace around a map or variable value. the
+// parser keeps a newline at the start of a value, so it stays
+func sourceTrimValue(nodes []any) []any {
+	trimmed := sourceTrim(nodes)
+	if len(nodes) != 0 {
+		if t, ok := nodes[0].(*sourceText); ok && strings.HasPrefix(strings.TrimLeft(t.text, " \t"), "\n") {
+			trimmed = append([]any{&sourceText{"\n"}}, trimmed...)
+		}
+	}
+	return trimmed
+}
+
+// sourceOneLine returns the width of content if it fits on one line
+func sourceOneLine(nodes []any) (int, bool) {
+	width := 0
+	for _, node := range nodes {
+		var s string
+		switch n := node.(type) {
+		case *sourceText:
+			s = n.text
+		case *sourceComment:
+			s = n.text
+		case *sourceBlock:
+			s = n.header + n.raw
+		case *sourceVar:
+			return 0, false
+		}
+		if strings.Contains(s, "\n") {
+			return 0, false
+		}
+		width += utf8.RuneCountInString(s)
+	}
+	return width, true
+}
+
+// trimSourceRight keeps whitespace which was escaped
+func trimSourceRight(trimmed, s string) string {
+	if len(trimmed) == len(s) {
+		return s
+	}
+	backslashes := len(trimmed) - len(strings.TrimRight(trimmed, "\\"))
+	if backslashes%2 == 1 {
+		return s[:len(trimmed)+1]
+	}
+	return trimmed
+}
+
+type sourcePrinter struct {
+	out     strings.Builder
+	line    strings.Builder
+	indent  string
+	depth   int
+	started bool // current line has content
+	raw     bool // current line is kept as-is
+	blank   bool // a blank line is pending
+	noBlank bool // blank lines are dropped until more content
+}
+
+// start begins a line at the given depth
+func (w *sourcePrinter) start(depth int) {
+	if w.started {
+		return
+	}
+	if w.blank && !w.noBlank && w.out.Len() != 0 {
+		w.out.WriteByte('\n')
+	}
+	w.blank, w.noBlank = false, false
+	w.indent = strings.Repeat("    ", depth)
+	w.depth = depth
+	w.started = true
+}
+
+// startRaw begins a line without indentation
+func (w *sourcePrinter) startRaw(raw bool) {
+	w.start(w.depth)
+	w.indent, w.raw = "", raw
+}
+
+// write adds text to the line, ignoring leading whitespace
+func (w *sourcePrinter) write(s string, depth int) {
+	if !w.started {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return
+		}
+		w.start(depth)
+	} else if strings.TrimSpace(s) != "" {
+		w.noBlank = false
+	}
+	w.line.WriteString(s)
+}
+
+// newline ends the line; if there is none, it is a blank line
+func (w *sourcePrinter) newline() {
+	if !w.started {
+		w.blank = true
+		return
+	}
+	line := w.line.String()
+	if !w.raw {
+		line = trimSourceRight(strings.TrimRight(line, " \t"), line)
+	}
+	if line != "" {
+		line = w.indent + line
+	}
+	w.out.WriteString(line + "\n")
+	w.line.Reset()
+	w.started, w.raw = false, false
+}
+
+// endLine ends the line if there is one
+func (w *sourcePrinter) endLine() {
+	if w.started {
+		w.newline()
+	}
+}
+
+// closeBrace closes a block which spans multiple lines
+func (w *sourcePrinter) closeBrace(depth int) {
+	w.blank = false
+	if !w.started {
+		w.write("}", depth)
+		return
+	}
+	line := w.line.String()
+	w.line.Reset()
+	w.line.WriteString(trimSourceRight(strings.TrimRight(line, " \t"), line) + " }")
+}
+
+func (w *sourcePrinter) nodes(nodes []any, depth int) {
+	alignSourceVars(nodes)
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *sourceText:
+			for i, seg := range strings.Split(n.text, "\n") {
+				if i != 0 {
+					w.newline()
+				}
+				w.write(seg, depth)
+			}
+		case *sourceComment:
+			w.comment(n, depth)
+		case *sourceBlock:
+			w.block(n, depth)
+		case *sourceVar:
+			w.variable(n, depth)
+		}
+	}
+}
+
+func (w *sourcePrinter) comment(c *sourceComment, depth int) {
+	lines := strings.Split(c.text, "\n")
+	w.start(depth)
+	w.line.WriteString(lines[0])
+	indent := w.indent
+
+	// continuation lines move with the first one
+	for _, line := range lines[1:] {
+		w.newline()
+		if strings.TrimSpace(line) == "" {
+			line = ""
+		} else if strings.HasPrefix(line, c.indent) {
+			line = indent + line[len(c.indent):]
+		}
+		w.startRaw(false)
+		w.line.WriteString(line)
+	}
+}
+
+func (w *sourcePrinter) variable(v *sourceVar, depth int) {
+	w.start(depth)
+	w.write(v.prefix+v.name, depth)
+	if v.hasValue {
+		w.write(":", depth)
+		if value := sourceTrimValue(v.value); len(value) != 0 {
+			if t, ok := value[0].(*sourceText); !ok || t.text != "\n" {
+				w.write(strings.Repeat(" ", v.pad+1), depth)
+			}
+			w.nodes(value, depth+1)
+		}
+	}
+	if v.closed {
+		w.write(";", depth)
+	}
+}
+
+func (w *sourcePrinter) block(b *sourceBlock, depth int) {
+	if b.variable {
+		w.write("{@"+b.raw+"}", depth)
+		return
+	}
+
+	// nested content is relative to the line the header is on
+	if w.started {
+		depth = w.depth
+		line := w.line.String()
+		if (b.lead || strings.HasSuffix(line, "*/")) && line != "" && !unicode.IsSpace(rune(line[len(line)-1])) {
+			w.write(" ", depth)
+		}
+	}
+	if b.header == "" {
+		w.write("{", depth)
+	} else {
+		w.write(b.header+" {", depth)
+	}
+
+	switch b.kind {
+	case sourceVerbatim:
+		w.verbatim(b, depth)
+	case sourceMap, sourceList:
+		w.entries(b, depth)
+	default:
+		w.container(b, depth)
+	}
+}
+
+func (w *sourcePrinter) container(b *sourceBlock, depth int) {
+	if !strings.Contains(b.raw, "\n") {
+		if content := sourceTrim(b.content); len(content) != 0 {
+			w.write(" ", depth)
+			w.nodes(content, depth+1)
+			w.write(" ", depth)
+		}
+		w.write("}", depth)
+		return
+	}
+	w.noBlank = true
+	w.nodes(b.content, depth+1)
+	w.closeBrace(depth)
+}
+
+func (w *sourcePrinter) entries(b *sourceBlock, depth int) {
+	entries := sourceEntries(b.content, b.kind == sourceMap)
+
+	// single line
+	if !strings.Contains(b.raw, "\n") {
+		for _, e := range entries {
+			w.write(" ", depth)
+			w.entry(e, depth+1)
+		}
+		if len(entries) != 0 {
+			w.write(" ", depth)
+		}
+		w.write("}", depth)
+		return
+	}
+
+	// one entry per line
+	alignSourceEntries(entries)
+	w.noBlank = true
+	for _, e := range entries {
+		w.endLine()
+		if e.blank {
+			w.blank = true
+		}
+		w.entry(e, depth+1)
+	}
+	w.endLine()
+	w.blank = false
+	w.write("}", depth)
+}
+
+func (w *sourcePrinter) entry(e *sourceEntry, depth int) {
+	if e.comment != nil {
+		w.comment(e.comment, depth)
+		return
+	}
+	w.start(depth)
+	key := sourceTrim(e.key)
+	w.nodes(key, depth)
+	if e.colon {
+		w.write(":", depth)
+
+		// anonymous values are written like :value;
+		if value := sourceTrimValue(e.value); len(value) != 0 {
+			if len(key) != 0 {
+				w.write(strings.Repeat(" ", e.pad+1), depth)
+			}
+			w.nodes(value, depth+1)
+		}
+	}
+	if e.closed {
+		w.write(";", depth)
+	}
+	if e.trailing != nil {
+		w.write(strings.Repeat(" ", e.commentPad+1), depth)
+		w.comment(e.trailing, depth)
+	}
+}
+
+func (w *sourcePrinter) verbatim(b *sourceBlock, depth int) {
+	if lines, ok := sourceReindent(b, strings.Repeat("    ", depth+1)); ok {
+		for _, line := range lines {
+			w.endLine()
+			w.startRaw(true)
+			w.line.WriteString(line)
+		}
+		w.endLine()
+		w.write("}", depth)
+		return
+	}
+
+	w.raw = true
+	segs := strings.Split(b.raw, "\n")
+	for i, seg := range segs {
+		if i != 0 {
+			w.newline()
+
+			// whitespace before a closing brace at the start of a line is
+			// ignored
+			if i == len(segs)-1 && (b.alone || strings.TrimSpace(seg) == "") {
+				w.write("}", depth)
+				return
+			}
+			w.startRaw(true)
+		}
+		w.line.WriteString(seg)
+	}
+	w.line.WriteString("}")
+}
+
+// sourceReindent returns the lines of a verbatim block with new indentation
+// if that does not change its text. The parser removes the indentation of
+// the first line from all lines, so changing it consistently has no effect.
+func sourceReindent(b *sourceBlock, indent string) ([]string, bool) {
+	if b.brace || !b.alone || !strings.HasPrefix(b.raw, "\n") {
+		return nil, false
+	}
+	for _, node := range b.content {
+		if _, ok := node.(*sourceText); !ok {
+			return nil, false
+		}
+	}
+
+	lines := strings.Split(b.raw[1:], "\n")
+	lines = lines[:len(lines)-1]
+	if len(lines) == 0 {
+		return nil, true
+	}
+
+	first := lines[0]
+	oldIndent := first[:len(first)-len(strings.TrimLeft(first, " \t"))]
+	if oldIndent == "" || strings.TrimSpace(first) == "" || len(first) < 2 {
+		return nil, false
+	}
+
+	reindented := make([]string, len(lines))
+	for i, line := range lines {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, oldIndent):
+			if rest := line[len(oldIndent):]; rest != "" {
+				reindented[i] = indent + rest
+			}
+		default:
+			return nil, false
+		}
+	}
+	return reindented, true
+}
+
+// alignSourceVars aligns the values of consecutive variable assignments
+func alignSourceVars(nodes []any) {
+	var pads []*int
+	var widths []int
+	flush := func() {
+		alignSource(pads, widths)
+		pads, widths = nil, nil
+	}
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *sourceText:
+			if strings.TrimSpace(n.text) != "" || strings.Count(n.text, "\n") > 1 {
+				flush()
+			}
+		case *sourceVar:
+			_, oneLine := sourceOneLine(sourceTrimValue(n.value))
+			if !n.hasValue || !n.closed || !oneLine {
+				flush()
+				continue
+			}
+			pads = append(pads, &n.pad)
+			widths = append(widths, utf8.RuneCountInString(n.prefix+n.name))
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+// alignSourceEntries aligns the values and trailing comments of
+// consecutive map entries
+func alignSourceEntries(entries []*sourceEntry) {
+	var pads, commentPads []*int
+	var widths, lineWidths []int
+	flush := func() {
+		alignSource(pads, widths)
+		pads, widths = nil, nil
+	}
+	flushComments := func() {
+		alignSource(commentPads, lineWidths)
+		commentPads, lineWidths = nil, nil
+	}
+
+	// values
+	for _, e := range entries {
+		if e.blank {
+			flush()
+		}
+		key := sourceTrim(e.key)
+		width, keyOneLine := sourceOneLine(key)
+		_, valueOneLine := sourceOneLine(sourceTrimValue(e.value))
+		if e.comment != nil || !e.colon || len(key) != 1 || !keyOneLine || !valueOneLine {
+			flush()
+			continue
+		}
+		if _, isText := key[0].(*sourceText); !isText {
+			flush()
+			continue
+		}
+		pads = append(pads, &e.pad)
+		widths = append(widths, width)
+	}
+	flush()
+
+	// trailing comments
+	for _, e := range entries {
+		if e.blank || e.trailing == nil {
+			flushComments()
+		}
+		if e.trailing == nil {
+			continue
+		}
+		key := sourceTrim(e.key)
+		keyWidth, keyOneLine := sourceOneLine(key)
+		value := sourceTrimValue(e.value)
+		valueWidth, valueOneLine := sourceOneLine(value)
+		if !keyOneLine || !valueOneLine {
+			flushComments()
+			continue
+		}
+
+		// same as entry() writes it
+		width := keyWidth
+		if e.colon {
+			width++
+			if len(value) != 0 && len(key) != 0 {
+				width += e.pad + 1
+			}
+			width += valueWidth
+		}
+		if e.closed {
+			width++
+		}
+		commentPads = append(commentPads, &e.commentPad)
+		lineWidths = append(lineWidths, width)
+	}
+	flushComments()
+}
+
+// alignSource pads each item in a run to the width of the widest one
+func alignSource(pads []*int, widths []int) {
+	longest := 0
+	for _, width := range widths {
+		if width > longest {
+			longest = width
+		}
+	}
+	for i, pad := range pads {
+		*pad = longest - widths[i]
+	}
+}
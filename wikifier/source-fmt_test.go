@@ -0,0 +1,63 @@
+package wikifier
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// each testdata/fmt/NAME.page is formatted and compared to NAME.golden
+func TestFormatSource(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "fmt", "*.page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test files")
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".page")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden, err := os.ReadFile(strings.TrimSuffix(file, ".page") + ".golden")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			output, err := FormatSource(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(output) != string(golden) {
+				t.Errorf("got:\n%s\nwant:\n%s", output, golden)
+			}
+
+			// formatting again changes nothing
+			again, err := FormatSource(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(output) {
+				t.Errorf("not idempotent; formatted again:\n%s", again)
+			}
+
+			// the formatted source produces the same page
+			if before, after := sourceHTML(t, input), sourceHTML(t, output); before != after {
+				t.Errorf("output differs:\n%s\nformatted:\n%s", before, after)
+			}
+		})
+	}
+}
+
+func sourceHTML(t *testing.T, source []byte) string {
+	t.Helper()
+	page := NewPageSource(string(source))
+	if err := page.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return string(page.HTML())
+}
@@ -48,8 +48,11 @@ func getValueType(i any) valueType {
 // Strings are formatted with quiki's text formatter.
 // Blocks are converted to elements.
 // Preformatted HTML is left as-is.
+// Empty values, like that of an empty list item, become empty HTML.
 func prepareForHTML(value any, b block, pos Position) any {
 	switch v := value.(type) {
+	case nil:
+		value = HTML("")
 	case string:
 		value = format(b, v, pos)
 	case block: